@@ -21,7 +21,11 @@ package general
 import (
 	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -38,6 +42,13 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// tasks are completed asynchronously after their pods are deleted, the removal of the app of a controller is
+	// retried until all its tasks are completed
+	appRemovalInterval = time.Second
+	appRemovalTimeout  = 2 * time.Minute
+)
+
 // Manager implements interfaces#Recoverable, interfaces#AppManager
 // generic app management service watches events from all the pods,
 // it recognize apps by reading pod's spec labels, if there are proper info such as
@@ -46,14 +57,22 @@ import (
 type Manager struct {
 	apiProvider            client.APIProvider
 	gangSchedulingDisabled bool
+	replicaSetApps         bool
+	ownerApps              bool
 	podEventHandler        *PodEventHandler
+	// apps of finished controllers waiting for their tasks to complete, true while a removal is being retried
+	pendingRemovals map[string]bool
+	sync.Mutex
 }
 
 func NewManager(apiProvider client.APIProvider, podEventHandler *PodEventHandler) *Manager {
 	return &Manager{
 		apiProvider:            apiProvider,
 		gangSchedulingDisabled: conf.GetSchedulerConf().DisableGangScheduling,
+		replicaSetApps:         conf.GetSchedulerConf().EnableReplicaSetApps,
+		ownerApps:              conf.GetSchedulerConf().EnableOwnerApps,
 		podEventHandler:        podEventHandler,
+		pendingRemovals:        make(map[string]bool),
 	}
 }
 
@@ -72,6 +91,28 @@ func (os *Manager) ServiceInit() error {
 			UpdateFn: os.updatePod,
 			DeleteFn: os.deletePod,
 		})
+	if os.replicaSetApps {
+		os.apiProvider.AddEventHandler(
+			&client.ResourceEventHandlers{
+				Type:     client.ReplicaSetInformerHandlers,
+				FilterFn: os.filterReplicaSets,
+				UpdateFn: os.updateReplicaSet,
+				DeleteFn: os.deleteReplicaSet,
+			})
+	}
+	if os.ownerApps {
+		os.apiProvider.AddEventHandler(
+			&client.ResourceEventHandlers{
+				Type:     client.DeploymentInformerHandlers,
+				DeleteFn: os.deleteDeployment,
+			})
+		os.apiProvider.AddEventHandler(
+			&client.ResourceEventHandlers{
+				Type:     client.JobInformerHandlers,
+				UpdateFn: os.updateJob,
+				DeleteFn: os.deleteJob,
+			})
+	}
 	return nil
 }
 
@@ -217,6 +258,177 @@ func (os *Manager) deletePod(obj interface{}) {
 		zap.String("podUID", string(pod.UID)))
 
 	os.podEventHandler.HandleEvent(DeletePod, Informers, pod)
+
+	// the pod might be the last task of a controller app which could not be removed yet
+	if taskMeta, ok := getTaskMetadata(pod); ok && os.isPendingRemoval(taskMeta.ApplicationID) {
+		os.retryAppRemoval(taskMeta.ApplicationID)
+	}
+}
+
+// convert2ReplicaSet returns the ReplicaSet of an informer event, including the final state of a deleted ReplicaSet
+func convert2ReplicaSet(obj interface{}) *appsv1.ReplicaSet {
+	if t, ok := obj.(k8sCache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	if rs, ok := obj.(*appsv1.ReplicaSet); ok {
+		return rs
+	}
+	return nil
+}
+
+// filter the ReplicaSets created by a Deployment, the admission controller can group their pods into an app per
+// rollout generation
+func (os *Manager) filterReplicaSets(obj interface{}) bool {
+	rs := convert2ReplicaSet(obj)
+	return rs != nil && rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey] != ""
+}
+
+// when a ReplicaSet is scaled down to zero, e.g. after a Deployment rolled out the next generation, all its pods are
+// gone and the app of the generation is completed
+func (os *Manager) updateReplicaSet(_, new interface{}) {
+	rs := convert2ReplicaSet(new)
+	if rs == nil || rs.Spec.Replicas == nil {
+		return
+	}
+	if *rs.Spec.Replicas != 0 {
+		// scaled up again before all tasks completed, the app is kept
+		os.cancelPendingRemoval(utils.GetControllerApplicationID(rs.Name, rs.UID))
+		return
+	}
+	if rs.Status.Replicas != 0 {
+		return
+	}
+	os.removeReplicaSetApp(rs)
+}
+
+func (os *Manager) deleteReplicaSet(obj interface{}) {
+	if rs := convert2ReplicaSet(obj); rs != nil {
+		os.removeReplicaSetApp(rs)
+	}
+}
+
+// remove the app of the ReplicaSet, if the pods of the ReplicaSet were grouped into one. A ReplicaSet that is scaled
+// up again, e.g. on a rollback, starts a new app with the same ID.
+func (os *Manager) removeReplicaSetApp(rs *appsv1.ReplicaSet) {
+	os.removeControllerApp("ReplicaSet", rs.Namespace, rs.Name, utils.GetControllerApplicationID(rs.Name, rs.UID))
+}
+
+// when a Deployment is deleted the app of its pods, grouped by the owner based application ID, is completed
+func (os *Manager) deleteDeployment(obj interface{}) {
+	if t, ok := obj.(k8sCache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	if deployment, ok := obj.(*appsv1.Deployment); ok {
+		os.removeOwnerApp(deployment, "Deployment")
+	}
+}
+
+// a Job that completed or failed has no running pods left, its app is completed before the Job is deleted
+func (os *Manager) updateJob(_, new interface{}) {
+	if job, ok := new.(*batchv1.Job); ok && isJobFinished(job) {
+		os.removeJobApp(job)
+	}
+}
+
+func (os *Manager) deleteJob(obj interface{}) {
+	if t, ok := obj.(k8sCache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	if job, ok := obj.(*batchv1.Job); ok {
+		os.removeJobApp(job)
+	}
+}
+
+// the pods of a Job created by a CronJob are grouped by the CronJob, the app is removed once no Job of the CronJob
+// has running tasks
+func (os *Manager) removeJobApp(job *batchv1.Job) {
+	if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+		os.removeControllerApp(owner.Kind, job.Namespace, owner.Name, utils.GetOwnerApplicationID(job.Namespace, owner))
+		return
+	}
+	os.removeOwnerApp(job, "Job")
+}
+
+func (os *Manager) removeOwnerApp(obj metav1.Object, kind string) {
+	owner := &metav1.OwnerReference{Kind: kind, Name: obj.GetName(), UID: obj.GetUID()}
+	os.removeControllerApp(kind, obj.GetNamespace(), obj.GetName(), utils.GetOwnerApplicationID(obj.GetNamespace(), owner))
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// removeControllerApp removes the app the pods of a controller were grouped into, once the controller has no pods
+// left. If the tasks of the app are not completed yet the removal is retried.
+func (os *Manager) removeControllerApp(kind, namespace, name, appID string) {
+	amProtocol := os.podEventHandler.amProtocol
+	if amProtocol.GetApplication(appID) == nil {
+		return
+	}
+	log.Logger().Info("controller finished, removing application",
+		zap.String("appType", os.Name()),
+		zap.String("namespace", namespace),
+		zap.String("kind", kind),
+		zap.String("name", name),
+		zap.String("appID", appID))
+	if err := amProtocol.RemoveApplication(appID); err != nil {
+		log.Logger().Info("application of controller still has tasks, retrying removal",
+			zap.String("appID", appID),
+			zap.Error(err))
+		os.retryAppRemoval(appID)
+	}
+}
+
+// retryAppRemoval retries the removal of the app in the background until all its tasks are completed. If
+// the pods take longer than the timeout to terminate the app stays pending, the removal is retried when the next pod
+// of the app is deleted.
+func (os *Manager) retryAppRemoval(appID string) {
+	os.Lock()
+	defer os.Unlock()
+	if os.pendingRemovals[appID] {
+		return
+	}
+	os.pendingRemovals[appID] = true
+	go func() {
+		amProtocol := os.podEventHandler.amProtocol
+		err := utils.WaitForCondition(func() bool {
+			return !os.isPendingRemoval(appID) ||
+				amProtocol.GetApplication(appID) == nil ||
+				amProtocol.RemoveApplication(appID) == nil
+		}, appRemovalInterval, appRemovalTimeout)
+		os.Lock()
+		defer os.Unlock()
+		if _, ok := os.pendingRemovals[appID]; !ok {
+			return
+		}
+		if err != nil {
+			log.Logger().Warn("application of controller still has running tasks, removal is retried when its pods are deleted",
+				zap.String("appID", appID))
+			os.pendingRemovals[appID] = false
+			return
+		}
+		log.Logger().Info("application of controller removed",
+			zap.String("appID", appID))
+		delete(os.pendingRemovals, appID)
+	}()
+}
+
+func (os *Manager) isPendingRemoval(appID string) bool {
+	os.Lock()
+	defer os.Unlock()
+	_, ok := os.pendingRemovals[appID]
+	return ok
+}
+
+func (os *Manager) cancelPendingRemoval(appID string) {
+	os.Lock()
+	defer os.Unlock()
+	delete(os.pendingRemovals, appID)
 }
 
 func (os *Manager) ListPods() ([]*v1.Pod, error) {
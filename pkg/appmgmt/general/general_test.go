@@ -19,11 +19,17 @@
 package general
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	apis "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sCache "k8s.io/client-go/tools/cache"
 
 	"github.com/apache/yunikorn-k8shim/pkg/cache"
 	"github.com/apache/yunikorn-k8shim/pkg/client"
@@ -390,6 +396,190 @@ func TestDeletePod(t *testing.T) {
 	assert.Equal(t, task.GetTaskState(), cache.TaskStates().Completed)
 }
 
+func TestReplicaSetScaledDown(t *testing.T) {
+	amProtocol := cache.NewMockedAMProtocol()
+	am := NewManager(client.NewMockedAPIProvider(false), NewPodEventHandler(amProtocol, false))
+
+	replicas := int32(1)
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: apis.ObjectMeta{
+			Name:      "web-5d8f7c6b9",
+			Namespace: "default",
+			UID:       "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d",
+			Labels:    map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "5d8f7c6b9"},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{Replicas: 1},
+	}
+	appID := utils.GetControllerApplicationID(rs.Name, rs.UID)
+	assert.Equal(t, appID, "web-5d8f7c6b9-1a2b3c4d")
+	pod := &v1.Pod{
+		ObjectMeta: apis.ObjectMeta{
+			Name:      "web-5d8f7c6b9-x7k2p",
+			Namespace: "default",
+			UID:       "UID-POD-00001",
+			Labels: map[string]string{
+				"applicationId": appID,
+				"queue":         "root.a",
+			},
+		},
+		Spec: v1.PodSpec{SchedulerName: constants.SchedulerName},
+	}
+	am.AddPod(pod)
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+
+	// only the ReplicaSets of a Deployment are watched
+	assert.Assert(t, am.filterReplicaSets(rs))
+	assert.Assert(t, am.filterReplicaSets(k8sCache.DeletedFinalStateUnknown{Obj: rs}))
+	assert.Assert(t, !am.filterReplicaSets(&appsv1.ReplicaSet{}))
+	assert.Assert(t, !am.filterReplicaSets(pod))
+
+	// the app is kept while the ReplicaSet has pods
+	am.updateReplicaSet(rs, rs)
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+	scaledDown := rs.DeepCopy()
+	zero := int32(0)
+	scaledDown.Spec.Replicas = &zero
+	am.updateReplicaSet(rs, scaledDown)
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+
+	// the app is removed once all pods are gone
+	am.deletePod(pod)
+	scaledDown.Status.Replicas = 0
+	am.updateReplicaSet(rs, scaledDown)
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+
+	// deleting the ReplicaSet removes the app as well
+	am.AddPod(pod)
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+	am.deleteReplicaSet(k8sCache.DeletedFinalStateUnknown{Obj: rs})
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+}
+
+// taskCheckingAMProtocol refuses to remove an app with running tasks, like the scheduler cache does
+type taskCheckingAMProtocol struct {
+	*cache.MockedAMProtocol
+	taskIDs []string
+}
+
+func (p *taskCheckingAMProtocol) RemoveApplication(appID string) error {
+	if app := p.GetApplication(appID); app != nil {
+		for _, taskID := range p.taskIDs {
+			if task, err := app.GetTask(taskID); err == nil && task.GetTaskState() != cache.TaskStates().Completed {
+				return fmt.Errorf("application %s still has running task %s", appID, taskID)
+			}
+		}
+	}
+	return p.MockedAMProtocol.RemoveApplication(appID)
+}
+
+func TestReplicaSetAppRemovalRetried(t *testing.T) {
+	zero := int32(0)
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: apis.ObjectMeta{
+			Name:      "web-5d8f7c6b9",
+			Namespace: "default",
+			UID:       "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d",
+			Labels:    map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "5d8f7c6b9"},
+		},
+		Spec: appsv1.ReplicaSetSpec{Replicas: &zero},
+	}
+	appID := utils.GetControllerApplicationID(rs.Name, rs.UID)
+	pod := &v1.Pod{
+		ObjectMeta: apis.ObjectMeta{
+			Name:      "web-5d8f7c6b9-x7k2p",
+			Namespace: "default",
+			UID:       "UID-POD-00001",
+			Labels: map[string]string{
+				"applicationId": appID,
+				"queue":         "root.a",
+			},
+		},
+		Spec: v1.PodSpec{SchedulerName: constants.SchedulerName},
+	}
+	newManager := func() (*Manager, *taskCheckingAMProtocol) {
+		amProtocol := &taskCheckingAMProtocol{MockedAMProtocol: cache.NewMockedAMProtocol(), taskIDs: []string{string(pod.UID)}}
+		am := NewManager(client.NewMockedAPIProvider(false), NewPodEventHandler(amProtocol, false))
+		am.AddPod(pod)
+		assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+		return am, amProtocol
+	}
+
+	// the ReplicaSet is scaled down while its pod is still terminating: the app is removed once the task completes
+	am, amProtocol := newManager()
+	am.updateReplicaSet(rs, rs)
+	assert.Assert(t, am.isPendingRemoval(appID), "removal not retried")
+	am.deletePod(pod)
+	assert.NilError(t, utils.WaitForCondition(func() bool {
+		return !am.isPendingRemoval(appID)
+	}, 100*time.Millisecond, 5*time.Second))
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+
+	// the ReplicaSet is scaled up again before the task completes: the app is kept
+	am, amProtocol = newManager()
+	am.deleteReplicaSet(rs)
+	assert.Assert(t, am.isPendingRemoval(appID), "removal not retried")
+	scaledUp := rs.DeepCopy()
+	replicas := int32(1)
+	scaledUp.Spec.Replicas = &replicas
+	am.updateReplicaSet(rs, scaledUp)
+	assert.Assert(t, !am.isPendingRemoval(appID), "removal not cancelled")
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+}
+
+func TestOwnerAppRemoved(t *testing.T) {
+	amProtocol := cache.NewMockedAMProtocol()
+	am := NewManager(client.NewMockedAPIProvider(false), NewPodEventHandler(amProtocol, false))
+	addApp := func(appID string) {
+		am.AddPod(&v1.Pod{
+			ObjectMeta: apis.ObjectMeta{
+				Name:      "pod-" + appID,
+				Namespace: "default",
+				UID:       types.UID("UID-" + appID),
+				Labels: map[string]string{
+					"applicationId": appID,
+					"queue":         "root.a",
+				},
+			},
+			Spec: v1.PodSpec{SchedulerName: constants.SchedulerName},
+		})
+		assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+	}
+
+	// deleting a Deployment removes its app
+	deployment := &appsv1.Deployment{ObjectMeta: apis.ObjectMeta{Name: "web", Namespace: "default", UID: "deploy-uid"}}
+	appID := utils.GetOwnerApplicationID("default", &apis.OwnerReference{Kind: "Deployment", Name: "web", UID: "deploy-uid"})
+	assert.Equal(t, appID, "yunikorn-default-deploy-uid")
+	addApp(appID)
+	am.deleteDeployment(k8sCache.DeletedFinalStateUnknown{Obj: deployment})
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+
+	// a Job is removed once it is finished
+	job := &batchv1.Job{ObjectMeta: apis.ObjectMeta{Name: "pi", Namespace: "default", UID: "job-uid"}}
+	appID = utils.GetOwnerApplicationID("default", &apis.OwnerReference{Kind: "Job", Name: "pi", UID: "job-uid"})
+	addApp(appID)
+	am.updateJob(job, job)
+	assert.Assert(t, amProtocol.GetApplication(appID) != nil)
+	finished := job.DeepCopy()
+	finished.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}
+	am.updateJob(job, finished)
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+
+	// the Jobs of a CronJob share the app of the CronJob
+	isController := true
+	cronOwner := apis.OwnerReference{Kind: "CronJob", Name: "nightly", UID: "cron-uid", Controller: &isController}
+	cronJob := &batchv1.Job{ObjectMeta: apis.ObjectMeta{
+		Name:            "nightly-27000000",
+		Namespace:       "default",
+		UID:             "cron-job-uid",
+		OwnerReferences: []apis.OwnerReference{cronOwner},
+	}}
+	appID = utils.GetOwnerApplicationID("default", &cronOwner)
+	addApp(appID)
+	am.deleteJob(cronJob)
+	assert.Assert(t, amProtocol.GetApplication(appID) == nil)
+}
+
 func toApplication(something interface{}) (*cache.Application, bool) {
 	if app, valid := something.(*cache.Application); valid {
 		return app, true
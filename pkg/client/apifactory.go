@@ -25,6 +25,8 @@ import (
 	"go.uber.org/zap"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/client-go/informers"
+	appsInformerV1 "k8s.io/client-go/informers/apps/v1"
+	batchInformerV1 "k8s.io/client-go/informers/batch/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kubernetes/pkg/features"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/volumebinding"
@@ -48,6 +50,9 @@ const (
 	PVInformerHandlers
 	PVCInformerHandlers
 	ApplicationInformerHandlers
+	ReplicaSetInformerHandlers
+	DeploymentInformerHandlers
+	JobInformerHandlers
 )
 
 type APIProvider interface {
@@ -107,6 +112,20 @@ func NewAPIFactory(scheduler api.SchedulerAPI, informerFactory informers.SharedI
 		applicationInformer = appinformers.NewSharedInformerFactory(appClient, time.Minute*1).Apache().V1alpha1().Applications()
 	}
 
+	// ReplicaSets are only watched to remove the app of a rollout generation
+	var replicaSetInformer appsInformerV1.ReplicaSetInformer = nil
+	if configs.EnableReplicaSetApps {
+		replicaSetInformer = informerFactory.Apps().V1().ReplicaSets()
+	}
+
+	// the top-level controllers are only watched to remove the app of an owner based application ID
+	var deploymentInformer appsInformerV1.DeploymentInformer = nil
+	var jobInformer batchInformerV1.JobInformer = nil
+	if configs.EnableOwnerApps {
+		deploymentInformer = informerFactory.Apps().V1().Deployments()
+		jobInformer = informerFactory.Batch().V1().Jobs()
+	}
+
 	// create a volume binder (needs the informers)
 	volumeBinder := volumebinding.NewVolumeBinder(
 		kubeClient.GetClientSet(),
@@ -121,20 +140,23 @@ func NewAPIFactory(scheduler api.SchedulerAPI, informerFactory informers.SharedI
 
 	return &APIFactory{
 		clients: &Clients{
-			conf:              configs,
-			KubeClient:        kubeClient,
-			AppClient:         appClient,
-			SchedulerAPI:      scheduler,
-			InformerFactory:   informerFactory,
-			PodInformer:       podInformer,
-			NodeInformer:      nodeInformer,
-			ConfigMapInformer: configMapInformer,
-			PVInformer:        pvInformer,
-			PVCInformer:       pvcInformer,
-			NamespaceInformer: namespaceInformer,
-			StorageInformer:   storageInformer,
-			VolumeBinder:      volumeBinder,
-			AppInformer:       applicationInformer,
+			conf:               configs,
+			KubeClient:         kubeClient,
+			AppClient:          appClient,
+			SchedulerAPI:       scheduler,
+			InformerFactory:    informerFactory,
+			PodInformer:        podInformer,
+			NodeInformer:       nodeInformer,
+			ConfigMapInformer:  configMapInformer,
+			PVInformer:         pvInformer,
+			PVCInformer:        pvcInformer,
+			NamespaceInformer:  namespaceInformer,
+			ReplicaSetInformer: replicaSetInformer,
+			DeploymentInformer: deploymentInformer,
+			JobInformer:        jobInformer,
+			StorageInformer:    storageInformer,
+			VolumeBinder:       volumeBinder,
+			AppInformer:        applicationInformer,
 		},
 		testMode: testMode,
 		stopChan: make(chan struct{}),
@@ -199,6 +221,15 @@ func (s *APIFactory) addEventHandlers(
 	case ApplicationInformerHandlers:
 		s.GetAPIs().AppInformer.Informer().
 			AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	case ReplicaSetInformerHandlers:
+		s.GetAPIs().ReplicaSetInformer.Informer().
+			AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	case DeploymentInformerHandlers:
+		s.GetAPIs().DeploymentInformer.Informer().
+			AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	case JobInformerHandlers:
+		s.GetAPIs().JobInformer.Informer().
+			AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
 	}
 }
 
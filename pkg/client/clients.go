@@ -24,6 +24,8 @@ import (
 	"github.com/apache/yunikorn-k8shim/pkg/client/informers/externalversions/yunikorn.apache.org/v1alpha1"
 
 	"k8s.io/client-go/informers"
+	appsInformerV1 "k8s.io/client-go/informers/apps/v1"
+	batchInformerV1 "k8s.io/client-go/informers/batch/v1"
 	coreInformerV1 "k8s.io/client-go/informers/core/v1"
 	storageInformerV1 "k8s.io/client-go/informers/storage/v1"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/volumebinding"
@@ -50,14 +52,17 @@ type Clients struct {
 	InformerFactory informers.SharedInformerFactory
 
 	// resource informers
-	PodInformer       coreInformerV1.PodInformer
-	NodeInformer      coreInformerV1.NodeInformer
-	ConfigMapInformer coreInformerV1.ConfigMapInformer
-	PVInformer        coreInformerV1.PersistentVolumeInformer
-	PVCInformer       coreInformerV1.PersistentVolumeClaimInformer
-	StorageInformer   storageInformerV1.StorageClassInformer
-	NamespaceInformer coreInformerV1.NamespaceInformer
-	AppInformer       v1alpha1.ApplicationInformer
+	PodInformer        coreInformerV1.PodInformer
+	NodeInformer       coreInformerV1.NodeInformer
+	ConfigMapInformer  coreInformerV1.ConfigMapInformer
+	PVInformer         coreInformerV1.PersistentVolumeInformer
+	PVCInformer        coreInformerV1.PersistentVolumeClaimInformer
+	StorageInformer    storageInformerV1.StorageClassInformer
+	NamespaceInformer  coreInformerV1.NamespaceInformer
+	ReplicaSetInformer appsInformerV1.ReplicaSetInformer
+	DeploymentInformer appsInformerV1.DeploymentInformer
+	JobInformer        batchInformerV1.JobInformer
+	AppInformer        v1alpha1.ApplicationInformer
 
 	// volume binder handles PV/PVC related operations
 	VolumeBinder volumebinding.SchedulerVolumeBinder
@@ -77,6 +82,9 @@ func (c *Clients) WaitForSync(interval time.Duration, timeout time.Duration) err
 			c.StorageInformer.Informer().HasSynced() &&
 			c.ConfigMapInformer.Informer().HasSynced() &&
 			c.NamespaceInformer.Informer().HasSynced() &&
+			(c.ReplicaSetInformer == nil || c.ReplicaSetInformer.Informer().HasSynced()) &&
+			(c.DeploymentInformer == nil || c.DeploymentInformer.Informer().HasSynced()) &&
+			(c.JobInformer == nil || c.JobInformer.Informer().HasSynced()) &&
 			(c.AppInformer == nil || c.AppInformer.Informer().HasSynced())
 	}, interval, timeout)
 }
@@ -89,6 +97,15 @@ func (c *Clients) Run(stopCh <-chan struct{}) {
 	go c.StorageInformer.Informer().Run(stopCh)
 	go c.ConfigMapInformer.Informer().Run(stopCh)
 	go c.NamespaceInformer.Informer().Run(stopCh)
+	if c.ReplicaSetInformer != nil {
+		go c.ReplicaSetInformer.Informer().Run(stopCh)
+	}
+	if c.DeploymentInformer != nil {
+		go c.DeploymentInformer.Informer().Run(stopCh)
+	}
+	if c.JobInformer != nil {
+		go c.JobInformer.Informer().Run(stopCh)
+	}
 	if c.AppInformer != nil {
 		go c.AppInformer.Informer().Run(stopCh)
 	}
@@ -39,6 +39,7 @@ const AppTagImagePullSecrets = "imagePullSecrets"
 const DefaultAppNamespace = "default"
 const DefaultUserLabel = "yunikorn.apache.org/username"
 const DefaultUser = "nobody"
+const LabelIgnore = "yunikorn.apache.org/ignore"
 
 // Spark
 const SparkLabelAppID = "spark-app-selector"
@@ -65,6 +66,7 @@ const AnnotationPlaceholderFlag = "yunikorn.apache.org/placeholder"
 const AnnotationTaskGroupName = "yunikorn.apache.org/task-group-name"
 const AnnotationTaskGroups = "yunikorn.apache.org/task-groups"
 const AnnotationSchedulingPolicyParam = "yunikorn.apache.org/schedulingPolicyParameters"
+const AnnotationAllowPreemption = "yunikorn.apache.org/allow-preemption"
 const SchedulingPolicyTimeoutParam = "placeholderTimeoutInSeconds"
 const SchedulingPolicyParamDelimiter = " "
 const SchedulingPolicyStyleParam = "gangSchedulingStyle"
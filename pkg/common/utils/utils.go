@@ -32,6 +32,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	apis "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	podv1 "k8s.io/kubernetes/pkg/api/v1/pod"
 
 	"github.com/apache/yunikorn-k8shim/pkg/common"
@@ -43,6 +44,12 @@ import (
 
 const userInfoKey = siCommon.DomainYuniKorn + "user.info"
 
+const (
+	maxApplicationIDLength = 63
+	controllerUIDLength    = 8
+	autoGenAppPrefix       = "yunikorn"
+)
+
 func Convert2Pod(obj interface{}) (*v1.Pod, error) {
 	pod, ok := obj.(*v1.Pod)
 	if !ok {
@@ -250,6 +257,46 @@ func MergeMaps(first, second map[string]string) map[string]string {
 	return result
 }
 
+// GetControllerApplicationID returns the application ID shared by the pods of a controller: the name of the controller
+// followed by the start of its UID, so that a controller which is recreated with the same name becomes a new app. The
+// name is truncated to keep the max length of the ID at 63 chars. The admission controller generates the ID for the
+// pods, the shim uses it to find the app of the controller.
+func GetControllerApplicationID(name string, uid types.UID) string {
+	trim := func(value string) string {
+		return strings.TrimRightFunc(value, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		})
+	}
+	prefix := string(uid)
+	if len(prefix) > controllerUIDLength {
+		prefix = prefix[:controllerUIDLength]
+	}
+	if prefix == "" {
+		return trim(fmt.Sprintf("%.63s", name))
+	}
+	if maxLength := maxApplicationIDLength - len(prefix) - 1; len(name) > maxLength {
+		name = trim(name[:maxLength])
+	}
+	return name + "-" + prefix
+}
+
+// GetOwnerApplicationID returns the application ID shared by the pods of a top-level controller, e.g. a Deployment or
+// a Job: ${AUTO_GEN_PREFIX}-${NAMESPACE}-${OWNER_UID}. The owner UID (or kind and name if the UID is not set) is always
+// kept in full, the namespace is truncated to keep the max length of the ID at 63 chars. The admission controller
+// generates the ID for the pods, the shim uses it to remove the app when the controller is gone.
+func GetOwnerApplicationID(namespace string, owner *apis.OwnerReference) string {
+	ownerID := string(owner.UID)
+	if ownerID == "" {
+		ownerID = strings.ToLower(owner.Kind + "-" + owner.Name)
+	}
+	prefix := fmt.Sprintf("%s-%s", autoGenAppPrefix, namespace)
+	prefixLen := maxApplicationIDLength - len(ownerID) - 1
+	if prefixLen < len(autoGenAppPrefix) {
+		return fmt.Sprintf("%.63s", prefix+"-"+ownerID)
+	}
+	return fmt.Sprintf("%.*s-%s", prefixLen, prefix, ownerID)
+}
+
 // GetUserFromPod find username from pod annotation or label
 func GetUserFromPod(pod *v1.Pod) (string, []string) {
 	if pod.Annotations[userInfoKey] != "" {
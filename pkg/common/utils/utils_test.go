@@ -20,6 +20,7 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -771,6 +772,29 @@ func TestGetExtraConfigFromConfigMapQueuesYaml(t *testing.T) {
 	assert.Equal(t, 0, len(res))
 }
 
+func TestGetControllerApplicationID(t *testing.T) {
+	appID := GetControllerApplicationID("db", "9f4c2e1a-3b5d-4c6e-8f7a-0b1c2d3e4f50")
+	assert.Equal(t, appID, "db-9f4c2e1a")
+
+	appID = GetControllerApplicationID(strings.Repeat("x", 100), "9f4c2e1a-3b5d")
+	assert.Equal(t, len(appID), 63)
+	assert.Assert(t, strings.HasSuffix(appID, "x-9f4c2e1a"))
+
+	appID = GetControllerApplicationID("db", "")
+	assert.Equal(t, appID, "db")
+}
+
+func TestGetOwnerApplicationID(t *testing.T) {
+	appID := GetOwnerApplicationID("ns", &metav1.OwnerReference{Kind: "Job", Name: "pi"})
+	assert.Equal(t, appID, "yunikorn-ns-job-pi")
+
+	appID = GetOwnerApplicationID("ns", &metav1.OwnerReference{Kind: "Job", Name: strings.Repeat("x", 100)})
+	assert.Equal(t, len(appID), 63)
+
+	appID = GetOwnerApplicationID("ns", &metav1.OwnerReference{Kind: "Deployment", Name: "web", UID: "1a2b3c4d-5e6f"})
+	assert.Equal(t, appID, "yunikorn-ns-1a2b3c4d-5e6f")
+}
+
 func TestGetExtraConfigFromConfigMap(t *testing.T) {
 	cm := map[string]string{
 		"key": "value",
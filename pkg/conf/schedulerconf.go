@@ -63,6 +63,8 @@ const (
 	CMSvcDisableGangScheduling  = PrefixService + "disableGangScheduling"
 	CMSvcEnableConfigHotRefresh = PrefixService + "enableConfigHotRefresh"
 	CMSvcPlaceholderImage       = PrefixService + "placeholderImage"
+	CMSvcEnableReplicaSetApps   = PrefixService + "enableReplicaSetApps"
+	CMSvcEnableOwnerApps        = PrefixService + "enableOwnerApps"
 
 	// log
 	CMLogLevel = PrefixLog + "level"
@@ -82,6 +84,8 @@ const (
 	DefaultOperatorPlugins        = "general"
 	DefaultDisableGangScheduling  = false
 	DefaultEnableConfigHotRefresh = true
+	DefaultEnableReplicaSetApps   = false
+	DefaultEnableOwnerApps        = false
 	DefaultLoggingLevel           = 0
 	DefaultLogEncoding            = "console"
 	DefaultKubeQPS                = 1000
@@ -114,6 +118,8 @@ type SchedulerConf struct {
 	OperatorPlugins        string        `json:"operatorPlugins"`
 	EnableConfigHotRefresh bool          `json:"enableConfigHotRefresh"`
 	DisableGangScheduling  bool          `json:"disableGangScheduling"`
+	EnableReplicaSetApps   bool          `json:"enableReplicaSetApps"`
+	EnableOwnerApps        bool          `json:"enableOwnerApps"`
 	UserLabelKey           string        `json:"userLabelKey"`
 	PlaceHolderImage       string        `json:"placeHolderImage"`
 	Namespace              string        `json:"namespace"`
@@ -141,6 +147,8 @@ func (conf *SchedulerConf) Clone() *SchedulerConf {
 		OperatorPlugins:        conf.OperatorPlugins,
 		EnableConfigHotRefresh: conf.EnableConfigHotRefresh,
 		DisableGangScheduling:  conf.DisableGangScheduling,
+		EnableReplicaSetApps:   conf.EnableReplicaSetApps,
+		EnableOwnerApps:        conf.EnableOwnerApps,
 		UserLabelKey:           conf.UserLabelKey,
 		PlaceHolderImage:       conf.PlaceHolderImage,
 		Namespace:              conf.Namespace,
@@ -200,6 +208,8 @@ func handleNonReloadableConfig(old *SchedulerConf, new *SchedulerConf) {
 	checkNonReloadableString(CMSvcOperatorPlugins, &old.OperatorPlugins, &new.OperatorPlugins)
 	checkNonReloadableBool(CMSvcDisableGangScheduling, &old.DisableGangScheduling, &new.DisableGangScheduling)
 	checkNonReloadableString(CMSvcPlaceholderImage, &old.PlaceHolderImage, &new.PlaceHolderImage)
+	checkNonReloadableBool(CMSvcEnableReplicaSetApps, &old.EnableReplicaSetApps, &new.EnableReplicaSetApps)
+	checkNonReloadableBool(CMSvcEnableOwnerApps, &old.EnableOwnerApps, &new.EnableOwnerApps)
 }
 
 const warningNonReloadable = "ignoring non-reloadable configuration change (restart required to update)"
@@ -327,6 +337,8 @@ func CreateDefaultConfig() *SchedulerConf {
 		OperatorPlugins:        DefaultOperatorPlugins,
 		EnableConfigHotRefresh: DefaultEnableConfigHotRefresh,
 		DisableGangScheduling:  DefaultDisableGangScheduling,
+		EnableReplicaSetApps:   DefaultEnableReplicaSetApps,
+		EnableOwnerApps:        DefaultEnableOwnerApps,
 		UserLabelKey:           constants.DefaultUserLabel,
 		PlaceHolderImage:       constants.PlaceholderContainerImage,
 	}
@@ -353,6 +365,8 @@ func parseConfig(config map[string]string, prev *SchedulerConf) (*SchedulerConf,
 	parser.boolVar(&conf.DisableGangScheduling, CMSvcDisableGangScheduling)
 	parser.boolVar(&conf.EnableConfigHotRefresh, CMSvcEnableConfigHotRefresh)
 	parser.stringVar(&conf.PlaceHolderImage, CMSvcPlaceholderImage)
+	parser.boolVar(&conf.EnableReplicaSetApps, CMSvcEnableReplicaSetApps)
+	parser.boolVar(&conf.EnableOwnerApps, CMSvcEnableOwnerApps)
 
 	// log
 	parser.intVar(&conf.LoggingLevel, CMLogLevel)
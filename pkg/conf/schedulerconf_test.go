@@ -89,6 +89,8 @@ func TestParseConfigMap(t *testing.T) {
 		{CMSvcDisableGangScheduling, "DisableGangScheduling", true},
 		{CMSvcEnableConfigHotRefresh, "EnableConfigHotRefresh", false},
 		{CMSvcPlaceholderImage, "PlaceHolderImage", "test-image"},
+		{CMSvcEnableReplicaSetApps, "EnableReplicaSetApps", true},
+		{CMSvcEnableOwnerApps, "EnableOwnerApps", true},
 		{CMLogLevel, "LoggingLevel", -1},
 		{CMKubeQPS, "KubeQPS", 2345},
 		{CMKubeBurst, "KubeBurst", 3456},
@@ -121,6 +123,8 @@ func TestUpdateConfigMapNonReloadable(t *testing.T) {
 		{CMSvcOperatorPlugins, "OperatorPlugins", "test-operators", false},
 		{CMSvcDisableGangScheduling, "DisableGangScheduling", true, false},
 		{CMSvcPlaceholderImage, "PlaceHolderImage", "test-image", false},
+		{CMSvcEnableReplicaSetApps, "EnableReplicaSetApps", true, false},
+		{CMSvcEnableOwnerApps, "EnableOwnerApps", true, false},
 		{CMLogLevel, "LoggingLevel", -1, true},
 		{CMKubeQPS, "KubeQPS", 2345, false},
 		{CMKubeBurst, "KubeBurst", 3456, false},
@@ -19,24 +19,39 @@
 package main
 
 import (
-	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/events"
+	resourcehelper "k8s.io/kubernetes/pkg/api/v1/resource"
 
 	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/common/utils"
 	schedulerconf "github.com/apache/yunikorn-k8shim/pkg/conf"
 	"github.com/apache/yunikorn-k8shim/pkg/log"
 	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/annotation"
@@ -45,27 +60,85 @@ import (
 )
 
 const (
-	autoGenAppPrefix                = "yunikorn"
-	autoGenAppSuffix                = "autogen"
-	yunikornPod                     = "yunikorn"
-	defaultQueue                    = "root.default"
-	admissionReviewAPIVersion       = "admission.k8s.io/v1"
-	admissionReviewKind             = "AdmissionReview"
-	userInfoAnnotation              = siCommon.DomainYuniKorn + "user.info"
-	schedulerValidateConfURLPattern = "http://%s/ws/v1/validate-conf"
-	mutateURL                       = "/mutate"
-	validateConfURL                 = "/validate-conf"
+	autoGenAppPrefix                   = "yunikorn"
+	maxAppIDLength                     = 63
+	appIDHashLength                    = 8
+	yunikornPod                        = "yunikorn"
+	admissionReviewAPIVersion          = "admission.k8s.io/v1"
+	admissionReviewV1beta1APIVersion   = "admission.k8s.io/v1beta1"
+	admissionReviewKind                = "AdmissionReview"
+	userInfoAnnotation                 = siCommon.DomainYuniKorn + "user.info"
+	namespaceQueueAnnotation           = siCommon.DomainYuniKorn + "namespace.queue"
+	namespaceDefaultQueueAnnotation    = siCommon.DomainYuniKorn + "default-queue"
+	taskGroupParametersAnnotation      = siCommon.DomainYuniKorn + "task-group-parameters"
+	admissionWarningsAnnotation        = siCommon.DomainYuniKorn + "admission-warnings"
+	priorityBucketLabel                = siCommon.DomainYuniKorn + "priority-bucket"
+	statefulSetOrdinalLabel            = siCommon.DomainYuniKorn + "statefulset-ordinal"
+	maxWarningsAnnotationLength        = 1024
+	schedulerValidateConfURLPattern    = "%s://%s%s"
+	schedulerQueueAppsURLPattern       = "%s://%s/ws/v1/partition/%s/queue/%s/applications"
+	schedulerHealthCheckURLPattern     = "%s://%s/ws/v1/scheduler/healthcheck"
+	schedulerPartitionQueuesURLPattern = "%s://%s/ws/v1/partition/%s/queues"
+	mutateURL                          = "/mutate"
+	validateConfURL                    = "/validate-conf"
+	validateURL                        = "/validate"
+	metadataPath                       = "/metadata"
+	annotationsPath                    = "/metadata/annotations"
+	terminationGracePeriodPath         = "/spec/terminationGracePeriodSeconds"
+	labelsPath                         = "/metadata/labels"
+	validateConfMaxAttempts            = 3
+	validateConfInitialBackoff         = 100 * time.Millisecond
+	validateConfDeadlineMargin         = 500 * time.Millisecond
 )
 
 var (
-	runtimeScheme = runtime.NewScheme()
+	runtimeScheme = newRuntimeScheme()
 	codecs        = serializer.NewCodecFactory(runtimeScheme)
 	deserializer  = codecs.UniversalDeserializer()
+
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+	errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+	errRequestTooLarge     = errors.New("request body exceeds the maximum size")
+
+	// longRunningOwnerKinds are the controllers of pods that run until they are removed, pods created by a Deployment
+	// are owned by its ReplicaSet.
+	longRunningOwnerKinds = map[string]bool{
+		"ReplicaSet":            true,
+		"ReplicationController": true,
+		"StatefulSet":           true,
+		"DaemonSet":             true,
+	}
+
+	// application states reported by the scheduler for applications that no longer use their queue
+	terminatedApplicationStates = map[string]bool{
+		"Completed": true,
+		"Rejected":  true,
+		"Failed":    true,
+		"Expired":   true,
+	}
 )
 
 type admissionController struct {
 	conf              *conf.AdmissionControllerConf
 	annotationHandler *annotation.UserGroupAnnotationHandler
+	nsCache           *NamespaceCache
+	cmCache           *ConfigMapCache
+	scheduler         *schedulerClient
+	policyClient      *http.Client
+	appIDs            *appIDIndex
+	recorder          events.EventRecorder
+	auditSink         auditSink
+	debugLogger       *zap.Logger
+	podUsage          *PodUsageCache
+	owners            *OwnerCache
+	queueState        *queueStateCache
+	rejections        *rejectionSamples
+	overload          *overloadProtection
+	handlers          *admissionHandlers
+	mutators          *podMutators
+	ready             int32
 }
 
 type patchOperation struct {
@@ -79,11 +152,41 @@ type ValidateConfResponse struct {
 	Reason  string `json:"reason"`
 }
 
-func initAdmissionController(conf *conf.AdmissionControllerConf) *admissionController {
+// QueueApplication is the subset of the application information returned by the scheduler for a queue that is
+// needed to decide whether the queue is still in use.
+type QueueApplication struct {
+	ApplicationID string `json:"applicationID"`
+	State         string `json:"applicationState"`
+}
+
+func initAdmissionController(conf *conf.AdmissionControllerConf, nsCache *NamespaceCache, cmCache *ConfigMapCache) *admissionController {
 	hook := &admissionController{
 		conf:              conf,
 		annotationHandler: annotation.NewUserGroupAnnotationHandler(conf),
+		nsCache:           nsCache,
+		cmCache:           cmCache,
+		scheduler:         newSchedulerClient(conf),
+		policyClient:      &http.Client{},
+		appIDs:            newAppIDIndex(),
+		debugLogger:       newDebugLogger(),
+		podUsage:          NewPodUsageCache(nil),
+		owners:            NewOwnerCache(nil, nil),
+		queueState:        &queueStateCache{},
+		rejections:        &rejectionSamples{},
+		overload:          newOverloadProtection(conf),
 	}
+	hook.handlers = newAdmissionHandlers(map[string]admissionHandler{
+		mutateURL:       hook.mutate,
+		validateConfURL: hook.validateConf,
+		validateURL:     hook.validatePod,
+	})
+	hook.mutators = newPodMutators(hook.defaultMutators())
+	sink, err := newAuditSink(conf)
+	if err != nil {
+		log.Logger().Error("Unable to create the audit sink, recording admission decisions in the log", zap.Error(err))
+		sink = newLoggerAuditSink()
+	}
+	hook.auditSink = sink
 
 	log.Logger().Info("Initialized YuniKorn Admission Controller")
 	return hook
@@ -106,10 +209,13 @@ func parseRegexes(patterns string) ([]*regexp.Regexp, error) {
 	return result, nil
 }
 
-func admissionResponseBuilder(uid string, allowed bool, resultMessage string, patch []byte) *admissionv1.AdmissionResponse {
+func admissionResponseBuilder(uid string, allowed bool, resultMessage string, patch []byte, warnings ...string) *admissionv1.AdmissionResponse {
 	res := &admissionv1.AdmissionResponse{}
 	res.Allowed = allowed
 	res.UID = types.UID(uid)
+	if len(warnings) != 0 {
+		res.Warnings = warnings
+	}
 
 	if len(resultMessage) != 0 {
 		res.Result = &metav1.Status{
@@ -130,11 +236,152 @@ func (c *admissionController) mutate(req *admissionv1.AdmissionRequest) *admissi
 		return admissionResponseBuilder("", false, "", nil)
 	}
 
+	if c.conf.GetDrainMode() {
+		log.Logger().Info("drain mode is active, allowing request without mutation",
+			zap.String("UID", string(req.UID)),
+			zap.String("kind", req.Kind.Kind))
+		return admissionResponseBuilder(string(req.UID), true, "", nil)
+	}
+
+	var resp *admissionv1.AdmissionResponse
 	if req.Kind.Kind == "Pod" {
-		return c.processPod(req)
+		resp = c.processPod(req)
+	} else {
+		resp = c.processWorkload(req)
+	}
+	if c.conf.GetDryRun() {
+		return dryRunResponse(req, resp)
+	}
+	c.recordDenial(req, resp)
+	return resp
+}
+
+// dryRunResponse logs the decision taken for the request and replaces the response with one that allows the request
+// without a patch.
+func dryRunResponse(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	log.Logger().Info("dry run mode is active, allowing request without changes", dryRunFields(req, resp)...)
+	return admissionResponseBuilder(string(req.UID), true, "", nil)
+}
+
+// dryRunFields describes the decision that would have been taken for the request outside of dry run mode.
+func dryRunFields(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) []zap.Field {
+	reason := ""
+	if resp.Result != nil {
+		reason = resp.Result.Message
+	}
+	return []zap.Field{
+		zap.String("UID", string(req.UID)),
+		zap.String("kind", req.Kind.Kind),
+		zap.String("namespace", req.Namespace),
+		zap.Bool("wouldAllow", resp.Allowed),
+		zap.String("reason", reason),
+		zap.Bool("wouldPatch", len(resp.Patch) != 0),
+		zap.ByteString("patch", resp.Patch),
+		zap.Strings("warnings", resp.Warnings),
+	}
+}
+
+// validatePod denies pods in namespaces that require explicit labels if the pod does not set both a queue and an
+// application ID. Pods scheduled by YuniKorn are also checked against the maximum resource of their queue: the
+// validating webhook sees the requests of the pod after all mutating webhooks, including those that add containers
+// after the pod was mutated by the admission controller. All other requests are allowed.
+func (c *admissionController) validatePod(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req == nil {
+		log.Logger().Warn("empty request received")
+		return admissionResponseBuilder("", false, "", nil)
+	}
+
+	uid := string(req.UID)
+	if c.conf.GetDrainMode() {
+		log.Logger().Info("drain mode is active, allowing request without validation",
+			zap.String("UID", uid))
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	if req.Kind.Kind != "Pod" {
+		log.Logger().Warn("request kind is not pod", zap.String("requestKind", req.Kind.Kind))
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	var pod v1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		log.Logger().Error("unmarshal failed", zap.Error(err))
+		return denyResponse(uid, rejectionInvalidRequest, err.Error())
+	}
+
+	namespace, err := c.resolveNamespace(req.Namespace, &pod)
+	if err != nil {
+		log.Logger().Error("namespace validation failed", zap.Error(err))
+		return denyResponse(uid, rejectionNamespace, err.Error())
+	}
+
+	if c.requiresLabels(namespace) {
+		if resp := c.checkRequiredLabels(uid, namespace, &pod); resp != nil {
+			return c.validationDenied(req, resp)
+		}
+	}
+
+	if pod.Spec.SchedulerName == c.conf.GetSchedulerName() {
+		if err = c.checkQueueCapacity(c.newQueueStateLookup(), &pod, pod.Labels); err != nil {
+			log.Logger().Info("pod denied, requests exceed the queue maximum",
+				zap.String("podName", pod.Name),
+				zap.String("generateName", pod.GenerateName),
+				zap.String("namespace", namespace),
+				zap.Error(err))
+			return c.validationDenied(req, denyResponse(uid, rejectionQueueCapacity, err.Error()))
+		}
+	}
+
+	return admissionResponseBuilder(uid, true, "", nil)
+}
+
+// checkRequiredLabels denies the pod if it does not set both a queue and an application ID.
+func (c *admissionController) checkRequiredLabels(uid string, namespace string, pod *v1.Pod) *admissionv1.AdmissionResponse {
+	var missing []string
+	if _, ok := pod.Labels[constants.LabelQueueName]; !ok {
+		missing = append(missing, fmt.Sprintf("'%s'", constants.LabelQueueName))
+	}
+	_, hasAppID := pod.Labels[constants.LabelApplicationID]
+	_, hasSparkAppID := pod.Labels[constants.SparkLabelAppID]
+	if !hasAppID && !hasSparkAppID {
+		missing = append(missing, fmt.Sprintf("'%s'", constants.LabelApplicationID))
+	}
+	if len(missing) != 0 {
+		errMsg := fmt.Sprintf("pods in namespace %s must set the %s label(s) explicitly", namespace, strings.Join(missing, " and "))
+		log.Logger().Info("pod denied, required labels are missing",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("namespace", namespace),
+			zap.Strings("missing", missing))
+		return denyResponse(uid, rejectionLabelsRequired, errMsg)
+	}
+	return nil
+}
+
+// validationDenied returns the denial of a validation request, which is replaced by an allowed response in dry run
+// mode.
+func (c *admissionController) validationDenied(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if c.conf.GetDryRun() {
+		return dryRunResponse(req, resp)
+	}
+	c.recordDenial(req, resp)
+	return resp
+}
+
+func (c *admissionController) requiresLabels(namespace string) bool {
+	for _, re := range c.conf.GetRequireLabelNamespaces() {
+		if re.MatchString(namespace) {
+			return true
+		}
 	}
+	return false
+}
 
-	return c.processWorkload(req)
+// podCheck is a validation of a pod in processPod. A failing check denies the pod, the rejection is counted for the
+// reason of the check.
+type podCheck struct {
+	reason string
+	check  func() error
 }
 
 func (c *admissionController) processPod(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
@@ -154,13 +401,34 @@ func (c *admissionController) processPod(req *admissionv1.AdmissionRequest) *adm
 	var pod v1.Pod
 	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
 		log.Logger().Error("unmarshal failed", zap.Error(err))
-		return admissionResponseBuilder(uid, false, err.Error(), nil)
+		return denyResponse(uid, rejectionInvalidRequest, err.Error())
+	}
+
+	// updates of terminated pods are not scheduled again, there is nothing to mutate
+	if req.Operation == admissionv1.Update && isTerminalPod(&pod) {
+		log.Logger().Debug("ignore update of terminated pod",
+			zap.String("podName", pod.Name),
+			zap.String("namespace", namespace),
+			zap.String("phase", string(pod.Status.Phase)))
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	// mirror pods are managed by the kubelet, a mutation makes its updates of the pod fail
+	if isMirrorPod(&pod) {
+		log.Logger().Debug("ignore mirror pod",
+			zap.String("podName", pod.Name),
+			zap.String("namespace", namespace))
+		metrics.mirrorPods.WithLabelValues(namespace).Inc()
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	namespace, err := c.resolveNamespace(req.Namespace, &pod)
+	if err != nil {
+		log.Logger().Error("namespace validation failed", zap.Error(err))
+		return denyResponse(uid, rejectionNamespace, err.Error())
 	}
 
-	if failureResponse := c.checkUserInfoAnnotation(func() (string, bool) {
-		a, ok := pod.Annotations[userInfoAnnotation]
-		return a, ok
-	}, req.UserInfo.Username, req.UserInfo.Groups, uid); failureResponse != nil {
+	if failureResponse := c.checkUserInfoAnnotation(pod.Annotations, namespace, req.UserInfo.Username, req.UserInfo.Groups, uid); failureResponse != nil {
 		return failureResponse
 	}
 
@@ -171,226 +439,1003 @@ func (c *admissionController) processPod(req *admissionv1.AdmissionRequest) *adm
 		}
 	}
 
-	if !c.shouldProcessNamespace(namespace) {
-		log.Logger().Info("bypassing namespace", zap.String("namespace", namespace))
-		return admissionResponseBuilder(uid, true, "", nil)
-	}
-	patch = updateSchedulerName(patch)
-
-	if c.shouldLabelNamespace(namespace) {
-		patch = updateLabels(namespace, &pod, patch)
-	} else {
-		log.Logger().Info("skipping update of pod labels since namespace is set to no-label",
+	if !c.shouldProcessPod(namespace, &pod) {
+		log.Logger().Info("bypassing pod",
 			zap.String("podName", pod.Name),
 			zap.String("generateName", pod.GenerateName),
 			zap.String("namespace", namespace))
+		metrics.bypassedPods.WithLabelValues(namespace).Inc()
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+	patch = c.mutatePod(namespace, &pod, patch)
+
+	// the warnings returned by the checks, only used if the pod is admitted
+	var checkWarnings []string
+	warn := func(warning string, err error) error {
+		if warning != "" {
+			checkWarnings = append(checkWarnings, warning)
+		}
+		return err
+	}
+	// the queue checks share the queue state, it is retrieved from the scheduler at most once for the request
+	queues := c.newQueueStateLookup()
+	dryRun := req.DryRun != nil && *req.DryRun
+	// the policies run before the other checks so that the labels they set are validated like all other labels, the
+	// application ID uniqueness must be the last check: the application ID is only recorded for pods which are admitted
+	checks := []podCheck{
+		{rejectionPolicy, func() (err error) {
+			patch, err = c.checkPolicy(req, patch)
+			return err
+		}},
+		{rejectionQueueRequired, func() error { return c.checkQueueDeclared(namespace, &pod, patch) }},
+		{rejectionAppIDPattern, func() error { return c.checkAppIDPattern(&pod, patch) }},
+		{rejectionTaskGroups, func() error { return c.checkTaskGroups(&pod) }},
+		{rejectionAppIDConflict, func() error { return warn(c.checkOwnerAppIDConflict(namespace, &pod)) }},
+		{rejectionNamespaceResourceCap, func() error { return warn(c.checkNamespaceResourceCap(namespace, &pod)) }},
+		{rejectionUnknownQueue, func() (err error) {
+			patch, err = c.checkQueueExists(queues, &pod, patch)
+			return err
+		}},
+		{rejectionAppQueueRules, func() error { return c.checkAppQueueRules(effectiveLabels(&pod, patch)) }},
+		{rejectionQueueDraining, func() error { return c.checkQueueDraining(queues, effectiveLabels(&pod, patch)) }},
+		{rejectionQueueCapacity, func() error { return c.checkQueueCapacity(queues, &pod, effectiveLabels(&pod, patch)) }},
+		{rejectionAppIDUnique, func() error { return c.checkAppIDUnique(namespace, &pod, dryRun) }},
+	}
+	for _, check := range checks {
+		if err = check.check(); err != nil {
+			log.Logger().Error("pod validation failed",
+				zap.String("reason", check.reason),
+				zap.String("podName", pod.Name),
+				zap.String("generateName", pod.GenerateName),
+				zap.Error(err))
+			return denyResponse(uid, check.reason, err.Error())
+		}
+	}
+
+	warnings := labelWarnings(&pod, patch)
+	if c.conf.GetWarnMissingProbes() {
+		warnings = append(warnings, probeWarnings(&pod)...)
+	}
+	warnings = append(warnings, checkWarnings...)
+	if len(warnings) != 0 && c.conf.GetAnnotateWarnings() {
+		patch = updateAnnotation(&pod, patch, admissionWarningsAnnotation, warningsAnnotationValue(warnings))
+	}
+	if c.conf.GetAnnotateDecision() {
+		patch = updateAnnotation(&pod, patch, admissionDecisionAnnotation, c.decisionAnnotationValue(namespace, &pod, patch, warnings))
 	}
 	log.Logger().Info("generated patch",
 		zap.String("podName", pod.Name),
 		zap.String("generateName", pod.GenerateName),
-		zap.Any("patch", patch))
+		zap.Any("patch", c.loggablePatch(patch)))
 
 	patchBytes, err := json.Marshal(patch)
 	if err != nil {
 		log.Logger().Error("failed to marshal patch", zap.Error(err))
-		return admissionResponseBuilder(uid, false, err.Error(), nil)
+		return denyResponse(uid, rejectionInternalError, err.Error())
 	}
 
-	return admissionResponseBuilder(uid, true, "", patchBytes)
+	if _, ok := pod.Labels[constants.LabelApplicationID]; !ok && !c.conf.GetDryRun() {
+		if appID := effectiveLabels(&pod, patch)[constants.LabelApplicationID]; appID != "" {
+			c.recordEvent(requestEventTarget(req), v1.EventTypeNormal, eventReasonAppIDGenerated, eventActionMutate,
+				fmt.Sprintf("no %s label found, generated %s", constants.LabelApplicationID, appID))
+		}
+	}
+	return admissionResponseBuilder(uid, true, "", patchBytes, warnings...)
+}
+
+// warningsAnnotationValue joins the warnings into one annotation value, truncated to a length that keeps the pod
+// object readable.
+func warningsAnnotationValue(warnings []string) string {
+	value := []rune(strings.Join(warnings, "; "))
+	if len(value) <= maxWarningsAnnotationLength {
+		return string(value)
+	}
+	return string(value[:maxWarningsAnnotationLength-3]) + "..."
+}
+
+// isTerminalPod returns true if all containers of the pod have terminated and will not be restarted.
+func isTerminalPod(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+// isMirrorPod returns true if the pod is the mirror of a static pod. The kubelet creates the mirror pod with the mirror
+// annotation and the node as its owner.
+func isMirrorPod(pod *v1.Pod) bool {
+	if _, ok := pod.Annotations[v1.MirrorPodAnnotationKey]; ok {
+		return true
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.APIVersion == "v1" && ref.Kind == "Node" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveNamespace determines the namespace whose rules apply to the pod. If the namespace in the pod object conflicts
+// with the namespace of the request the configured source of truth is used, or the request is rejected in strict mode.
+func (c *admissionController) resolveNamespace(requestNamespace string, pod *v1.Pod) (string, error) {
+	namespace := requestNamespace
+	if pod.Namespace != "" && requestNamespace != "" && pod.Namespace != requestNamespace {
+		log.Logger().Warn("pod namespace does not match request namespace",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("podNamespace", pod.Namespace),
+			zap.String("requestNamespace", requestNamespace))
+		if c.conf.GetStrictNamespace() {
+			return "", fmt.Errorf("pod namespace %s does not match request namespace %s", pod.Namespace, requestNamespace)
+		}
+		if c.conf.GetNamespaceSource() == conf.NamespaceSourceObject {
+			namespace = pod.Namespace
+		}
+	}
+	if namespace == "" {
+		namespace = pod.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return namespace, nil
 }
 
 func (c *admissionController) processWorkload(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
 	var uid = string(req.UID)
-	requestKind := req.Kind.Kind
 
-	annotations, supported, err := c.annotationHandler.GetAnnotationsFromRequestKind(requestKind, req)
+	templates, supported, err := c.annotationHandler.GetWorkloadAnnotations(req)
 	if !supported {
 		// Unknown request kind - pass
 		return admissionResponseBuilder(uid, true, "", nil)
 	}
 	if err != nil {
-		return admissionResponseBuilder(uid, false, err.Error(), nil)
+		return denyResponse(uid, rejectionInvalidRequest, err.Error())
 	}
 
-	if failureResponse := c.checkUserInfoAnnotation(func() (string, bool) {
-		a, ok := annotations[userInfoAnnotation]
-		return a, ok
-	}, req.UserInfo.Username, req.UserInfo.Groups, uid); failureResponse != nil {
-		return failureResponse
+	for _, annotations := range templates {
+		if failureResponse := c.checkUserInfoAnnotation(annotations, req.Namespace, req.UserInfo.Username, req.UserInfo.Groups, uid); failureResponse != nil {
+			return failureResponse
+		}
 	}
 
-	return admissionResponseBuilder(uid, true, "", nil)
+	patch, err := c.updateTaskGroups(req, nil)
+	if err != nil {
+		log.Logger().Error("task group parameters validation failed", zap.Error(err))
+		return denyResponse(uid, rejectionTaskGroupParameters, err.Error())
+	}
+	patch, err = c.updatePodTemplate(req, patch)
+	if err != nil {
+		log.Logger().Error("pod template mutation failed", zap.Error(err))
+		return denyResponse(uid, rejectionPodTemplate, err.Error())
+	}
+	if len(patch) == 0 {
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		log.Logger().Error("failed to marshal patch", zap.Error(err))
+		return denyResponse(uid, rejectionInternalError, err.Error())
+	}
+	return admissionResponseBuilder(uid, true, "", patchBytes)
 }
 
-func (c *admissionController) checkUserInfoAnnotation(getAnnotation func() (string, bool), userName string, groups []string, uid string) *admissionv1.AdmissionResponse {
-	if annotation, ok := getAnnotation(); ok && !c.conf.GetBypassAuth() {
-		if allowed := c.annotationHandler.IsAnnotationAllowed(userName, groups); !allowed {
+// checkUserInfoAnnotation verifies that the submitter may set the user info annotation and that the annotation is
+// valid. In namespaces where auth is bypassed the submitter is not checked, but the annotation must still be valid.
+func (c *admissionController) checkUserInfoAnnotation(annotations map[string]string, namespace string, userName string, groups []string, uid string) *admissionv1.AdmissionResponse {
+	var keys []string
+	for _, key := range c.annotationHandler.UserInfoAnnotationKeys() {
+		if _, ok := annotations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	access := c.conf.GetAccessControl()
+	if len(keys) != 0 && !access.BypassAuth {
+		if regexpsMatch(access.BypassAuthNamespaces, namespace) {
+			log.Logger().Debug("bypassing user info submitter check for namespace", zap.String("namespace", namespace))
+		} else if allowed := c.annotationHandler.IsAccessAllowed(access, namespace, userName, groups); !allowed {
 			errMsg := fmt.Sprintf("user %s with groups [%s] is not allowed to set user annotation", userName,
 				strings.Join(groups, ","))
 			log.Logger().Error("user info validation failed - submitter is not allowed to set user annotation",
 				zap.String("user", userName),
 				zap.Strings("groups", groups))
-			return admissionResponseBuilder(uid, false, errMsg, nil)
+			c.recordUserInfoRejection(namespace, userName, groups, errMsg)
+			return denyResponse(uid, rejectionUserInfo, errMsg)
 		}
 
-		if err := c.annotationHandler.IsAnnotationValid(annotation); err != nil {
-			log.Logger().Error("invalid user info annotation", zap.Error(err))
-			return admissionResponseBuilder(uid, false, err.Error(), nil)
+		for _, key := range keys {
+			if err := c.annotationHandler.IsAnnotationValid(annotations[key]); err != nil {
+				log.Logger().Error("invalid user info annotation", zap.String("annotation", key), zap.Error(err))
+				c.recordUserInfoRejection(namespace, userName, groups, err.Error())
+				return denyResponse(uid, rejectionUserInfo, err.Error())
+			}
 		}
 	}
 
 	return nil
 }
 
-func updateSchedulerName(patch []patchOperation) []patchOperation {
-	log.Logger().Info("updating scheduler name")
+// shouldUpdateSchedulerName checks if the scheduler name of the pod can be changed. A pod which explicitly requests a
+// scheduler other than the Kubernetes default is left alone, unless overriding is configured.
+func (c *admissionController) shouldUpdateSchedulerName(pod *v1.Pod) bool {
+	schedulerName := pod.Spec.SchedulerName
+	if schedulerName == "" || schedulerName == v1.DefaultSchedulerName || schedulerName == c.conf.GetSchedulerName() {
+		return true
+	}
+	return c.conf.GetOverrideExistingSchedulerName()
+}
+
+func updateSchedulerName(patch []patchOperation, schedulerName string) []patchOperation {
+	log.Logger().Info("updating scheduler name", zap.String("schedulerName", schedulerName))
 	return append(patch, patchOperation{
 		Op:    "add",
-		Path:  "/spec/schedulerName",
-		Value: constants.SchedulerName,
+		Path:  schedulerNamePath,
+		Value: schedulerName,
 	})
 }
 
-// generate appID based on the namespace value,
-// and the max length of the ID is 63 chars.
-func generateAppID(namespace string) string {
-	generatedID := fmt.Sprintf("%s-%s-%s", autoGenAppPrefix, namespace, autoGenAppSuffix)
-	appID := fmt.Sprintf("%.63s", generatedID)
-	return appID
-}
-
-func updateLabels(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
-	log.Logger().Info("updating pod labels",
+// updateTerminationGracePeriod sets the termination grace period of the pod if the pod does not set one. The API
+// server defaults the grace period before admission, a pod with the Kubernetes default is treated as not set.
+func updateTerminationGracePeriod(pod *v1.Pod, patch []patchOperation, seconds int64) []patchOperation {
+	if current := pod.Spec.TerminationGracePeriodSeconds; current != nil && *current != v1.DefaultTerminationGracePeriodSeconds {
+		return patch
+	}
+	log.Logger().Info("updating termination grace period",
 		zap.String("podName", pod.Name),
 		zap.String("generateName", pod.GenerateName),
-		zap.String("namespace", namespace),
-		zap.Any("labels", pod.Labels))
+		zap.Int64("seconds", seconds))
+	return append(patch, patchOperation{
+		Op:    "add",
+		Path:  terminationGracePeriodPath,
+		Value: seconds,
+	})
+}
 
-	existingLabels := pod.Labels
-	result := make(map[string]string)
-	for k, v := range existingLabels {
-		result[k] = v
+// updateSchedulingPolicyParameters injects the configured default scheduling policy parameters into gang
+// scheduling pods which do not specify their own.
+func (c *admissionController) updateSchedulingPolicyParameters(pod *v1.Pod, patch []patchOperation) []patchOperation {
+	params := c.conf.GetDefaultSchedulingPolicyParameters()
+	if params == "" {
+		return patch
+	}
+	if _, ok := pod.Annotations[constants.AnnotationSchedulingPolicyParam]; ok {
+		return patch
 	}
+	_, hasTaskGroups := pod.Annotations[constants.AnnotationTaskGroups]
+	_, hasTaskGroupName := pod.Annotations[constants.AnnotationTaskGroupName]
+	if !hasTaskGroups && !hasTaskGroupName {
+		return patch
+	}
+	log.Logger().Info("injecting default scheduling policy parameters",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("parameters", params))
+	return updateAnnotation(pod, patch, constants.AnnotationSchedulingPolicyParam, params)
+}
 
-	if _, ok := existingLabels[constants.SparkLabelAppID]; !ok {
-		if _, ok := existingLabels[constants.LabelApplicationID]; !ok {
-			// if app id not exist, generate one
-			// for each namespace, we group unnamed pods to one single app
-			// application ID convention: ${AUTO_GEN_PREFIX}-${NAMESPACE}-${AUTO_GEN_SUFFIX}
-			generatedID := generateAppID(namespace)
-			result[constants.LabelApplicationID] = generatedID
+// updateAnnotation adds a patch operation for a single pod annotation.
+func updateAnnotation(pod *v1.Pod, patch []patchOperation, key string, value string) []patchOperation {
+	return addMapEntry(patch, annotationsPath, len(pod.Annotations) != 0, key, value)
+}
 
-			// if we generate an app ID, disable state-aware scheduling for this app
-			if _, ok := existingLabels[constants.LabelDisableStateAware]; !ok {
-				result[constants.LabelDisableStateAware] = "true"
-			}
-		}
-	}
+// updateLabel adds a patch operation for a single pod label.
+func updateLabel(pod *v1.Pod, patch []patchOperation, key string, value string) []patchOperation {
+	return addMapEntry(patch, labelsPath, len(pod.Labels) != 0, key, value)
+}
 
-	if _, ok := existingLabels[constants.LabelQueueName]; !ok {
-		result[constants.LabelQueueName] = defaultQueue
+// addMapEntry adds a patch operation for a single key of a string map in the object. The map is created first
+// if neither the object nor an earlier patch operation provides one, so entries added by other patches are kept.
+func addMapEntry(patch []patchOperation, path string, exists bool, key string, value string) []patchOperation {
+	if !exists && !hasPatchPath(patch, path) {
+		patch = append(patch, patchOperation{
+			Op:    "add",
+			Path:  path,
+			Value: map[string]string{},
+		})
 	}
-
-	patch = append(patch, patchOperation{
+	return append(patch, patchOperation{
 		Op:    "add",
-		Path:  "/metadata/labels",
-		Value: result,
+		Path:  path + "/" + jsonPointerEscaper.Replace(key),
+		Value: value,
 	})
-
-	return patch
 }
 
-func (c *admissionController) validateConf(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
-	if req == nil {
-		log.Logger().Warn("empty request received")
-		return admissionResponseBuilder("", false, "", nil)
+// loggablePatch returns the patch in the form it should be logged. Annotation values may contain personal information
+// and are masked, showing only their length, if configured. Values are always shown when debug logging is enabled.
+func (c *admissionController) loggablePatch(patch []patchOperation) []patchOperation {
+	if !c.conf.GetMaskAnnotations() || log.GetZapConfigs().Level.Enabled(zapcore.DebugLevel) {
+		return patch
 	}
-
-	uid := string(req.UID)
-
-	var requestKind = req.Kind.Kind
-	if requestKind != "ConfigMap" {
-		log.Logger().Warn("request kind is not configmap", zap.String("requestKind", requestKind))
-		return admissionResponseBuilder(uid, true, "", nil)
+	result := make([]patchOperation, 0, len(patch))
+	for _, op := range patch {
+		if op.Path == annotationsPath {
+			if value, ok := op.Value.(map[string]string); ok {
+				op.Value = maskAnnotationValues(value)
+			}
+		} else if strings.HasPrefix(op.Path, annotationsPath+"/") {
+			if value, ok := op.Value.(string); ok {
+				op.Value = maskAnnotationValue(value)
+			}
+		}
+		result = append(result, op)
 	}
+	return result
+}
 
-	namespace := req.Namespace
-	if namespace == "" {
-		namespace = "default"
+func maskAnnotationValues(annotations map[string]string) map[string]string {
+	result := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		result[k] = maskAnnotationValue(v)
 	}
+	return result
+}
 
-	var configmap v1.ConfigMap
-	if err := json.Unmarshal(req.Object.Raw, &configmap); err != nil {
-		log.Logger().Error("failed to unmarshal configmap", zap.Error(err))
-		return admissionResponseBuilder(uid, false, err.Error(), nil)
-	}
+func maskAnnotationValue(value string) string {
+	return fmt.Sprintf("<masked, length %d>", len(value))
+}
 
-	// validate new/updated config map
-	if err := c.validateConfigMap(namespace, &configmap); err != nil {
-		log.Logger().Error("failed to validate yunikorn configs", zap.Error(err))
-		return admissionResponseBuilder(uid, false, err.Error(), nil)
+// effectiveLabels returns the labels of the pod as they will be after the patch has been applied.
+func effectiveLabels(pod *v1.Pod, patch []patchOperation) map[string]string {
+	result := make(map[string]string)
+	for k, v := range pod.Labels {
+		result[k] = v
 	}
-
-	return admissionResponseBuilder(uid, true, "", nil)
+	for _, op := range patch {
+		if op.Op != "add" {
+			continue
+		}
+		if op.Path == labelsPath {
+			if value, ok := op.Value.(map[string]string); ok {
+				result = make(map[string]string)
+				for k, v := range value {
+					result[k] = v
+				}
+			}
+		} else if strings.HasPrefix(op.Path, labelsPath+"/") {
+			if value, ok := op.Value.(string); ok {
+				key := jsonPointerUnescaper.Replace(strings.TrimPrefix(op.Path, labelsPath+"/"))
+				result[key] = value
+			}
+		}
+	}
+	return result
 }
 
-func (c *admissionController) namespaceMatchesProcessList(namespace string) bool {
-	processNamespaces := c.conf.GetProcessNamespaces()
-	if len(processNamespaces) == 0 {
-		return true
+// checkAppQueueRules verifies that the application is submitted to a queue allowed for its application ID.
+func (c *admissionController) checkAppQueueRules(labels map[string]string) error {
+	appID, ok := labels[constants.LabelApplicationID]
+	if !ok {
+		appID = labels[constants.SparkLabelAppID]
 	}
-	for _, re := range processNamespaces {
-		if re.MatchString(namespace) {
-			return true
+	if appID == "" {
+		return nil
+	}
+	queue := labels[constants.LabelQueueName]
+	for _, rule := range c.conf.GetAppQueueRules() {
+		if !rule.Matches(appID, queue) {
+			return fmt.Errorf("application %s must be submitted to a queue under %s, requested queue '%s'",
+				appID, rule.QueuePrefix, queue)
 		}
 	}
-	return false
+	return nil
 }
 
-func (c *admissionController) namespaceMatchesBypassList(namespace string) bool {
-	bypassNamespaces := c.conf.GetBypassNamespaces()
-	for _, re := range bypassNamespaces {
-		if re.MatchString(namespace) {
+func hasPatchPath(patch []patchOperation, path string) bool {
+	for _, op := range patch {
+		if op.Path == path {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *admissionController) namespaceMatchesLabelList(namespace string) bool {
-	labelNamespaces := c.conf.GetLabelNamespaces()
-	if len(labelNamespaces) == 0 {
-		return true
+// getDefaultQueue returns the queue for a pod which does not specify one. The queue mapping rules are checked first.
+// Pods requesting GPUs are placed in the GPU queue if configured. Otherwise the queue mapped from the priority class of
+// the pod is used, followed by the service queue for pods exposing container ports and the queue set via annotation on
+// the namespace, the namespace.queue annotation takes precedence over the default-queue annotation. If none applies, or
+// the namespace is no longer known, the configured default queue is returned. The source of the queue is returned as
+// well.
+func (c *admissionController) getDefaultQueue(namespace string, pod *v1.Pod) (string, string) {
+	if queue, ok := c.mappedQueue(namespace, pod); ok {
+		return queue, queueSourceRule
 	}
-	for _, re := range labelNamespaces {
-		if re.MatchString(namespace) {
-			return true
+	if gpuQueue := c.conf.GetGPUQueue(); gpuQueue != "" && requestsResource(pod, c.conf.GetGPUResourceNames()) {
+		log.Logger().Debug("using GPU queue for pod requesting GPUs",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("queue", gpuQueue))
+		return gpuQueue, queueSourceGPU
+	}
+	if queue, ok := c.conf.GetPriorityClassQueues()[pod.Spec.PriorityClassName]; ok && pod.Spec.PriorityClassName != "" {
+		log.Logger().Debug("using queue mapped from priority class",
+			zap.String("podName", pod.Name),
+			zap.String("priorityClass", pod.Spec.PriorityClassName),
+			zap.String("queue", queue))
+		return queue, queueSourcePriorityClass
+	}
+	if serviceQueue := c.conf.GetServiceQueue(); serviceQueue != "" && exposesPorts(pod) {
+		log.Logger().Debug("using service queue for pod exposing ports",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("queue", serviceQueue))
+		return serviceQueue, queueSourceService
+	}
+	for _, annotation := range []string{namespaceQueueAnnotation, namespaceDefaultQueueAnnotation} {
+		if queue, ok := c.nsCache.getAnnotation(namespace, annotation); ok && queue != "" {
+			log.Logger().Debug("using queue from namespace annotation",
+				zap.String("namespace", namespace),
+				zap.String("annotation", annotation),
+				zap.String("queue", queue))
+			return queue, queueSourceNamespace
 		}
 	}
-	return false
+	return c.conf.GetDefaultQueue(), queueSourceDefault
 }
 
-func (c *admissionController) namespaceMatchesNoLabelList(namespace string) bool {
-	noLabelNamespaces := c.conf.GetNoLabelNamespaces()
-	for _, re := range noLabelNamespaces {
-		if re.MatchString(namespace) {
+// exposesPorts checks if any container of the pod declares a port, which marks a long-running service rather than a
+// batch workload. Ports of init containers are ignored as they do not serve traffic.
+func exposesPorts(pod *v1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if len(container.Ports) != 0 {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *admissionController) shouldProcessNamespace(namespace string) bool {
-	return c.namespaceMatchesProcessList(namespace) && !c.namespaceMatchesBypassList(namespace)
+// requestsResource checks if any container of the pod requests or limits one of the named resources.
+func requestsResource(pod *v1.Pod, resourceNames []string) bool {
+	containers := make([]v1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, name := range resourceNames {
+			if quantity, ok := container.Resources.Requests[v1.ResourceName(name)]; ok && !quantity.IsZero() {
+				return true
+			}
+			if quantity, ok := container.Resources.Limits[v1.ResourceName(name)]; ok && !quantity.IsZero() {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func (c *admissionController) shouldLabelNamespace(namespace string) bool {
-	return c.namespaceMatchesLabelList(namespace) && !c.namespaceMatchesNoLabelList(namespace)
+// generate appID by rendering the template with the namespace, the generate name and owner of the pod
+// and the current date, and the max length of the ID is 63 chars. IDs that are too long are truncated
+// and end with a short hash of the full ID, so long namespaces sharing a prefix still get distinct IDs.
+func generateAppID(template string, namespace string, pod *v1.Pod) string {
+	var generateName, ownerKind, ownerName string
+	if pod != nil {
+		generateName = strings.TrimRight(pod.GenerateName, "-")
+		if owner := getPodOwner(pod); owner != nil {
+			ownerKind = strings.ToLower(owner.Kind)
+			ownerName = owner.Name
+		}
+	}
+	generatedID := strings.NewReplacer(
+		conf.AppIDTemplateNamespace, namespace,
+		conf.AppIDTemplateGenerateName, generateName,
+		conf.AppIDTemplateOwnerKind, ownerKind,
+		conf.AppIDTemplateOwnerName, ownerName,
+		conf.AppIDTemplateDate, time.Now().UTC().Format("20060102"),
+	).Replace(template)
+	appID := generatedID
+	if len(appID) > maxAppIDLength {
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(generatedID)))[:appIDHashLength]
+		appID = trimNonAlphanumeric(appID[:maxAppIDLength-appIDHashLength-1]) + "-" + hash
+	}
+	// empty placeholders may leave separators at the end, label values must end alphanumeric
+	appID = trimNonAlphanumeric(appID)
+	if appID == "" {
+		return generateAppID(conf.DefaultMutationAppIDTemplate, namespace, nil)
+	}
+	return appID
 }
 
-func (c *admissionController) validateConfigMap(namespace string, cm *v1.ConfigMap) error {
-	if namespace != c.conf.GetNamespace() {
-		log.Logger().Debug("Configmap does not belong to YuniKorn", zap.String("namespace", namespace), zap.String("Name", cm.Name))
+// checkQueueDeclared verifies, if required, that the pod has a queue after mutation. A queue is only missing if the
+// namespace is excluded from labelling, or the labels mutator is disabled, and the pod does not set the queue itself.
+func (c *admissionController) checkQueueDeclared(namespace string, pod *v1.Pod, patch []patchOperation) error {
+	if !c.conf.GetRequireQueue() {
+		return nil
+	}
+	if queue := effectiveLabels(pod, patch)[constants.LabelQueueName]; queue != "" {
+		return nil
+	}
+	if queue := pod.Annotations[constants.AnnotationQueueName]; queue != "" {
+		return nil
+	}
+	return fmt.Errorf("pod does not declare a queue and automatic queue assignment is disabled for namespace %s: "+
+		"set the '%s' label or the '%s' annotation", namespace, constants.LabelQueueName, constants.AnnotationQueueName)
+}
+
+// checkAppIDPattern verifies the application ID label of the pod against the configured naming convention.
+// Generated application IDs are only checked if configured.
+func (c *admissionController) checkAppIDPattern(pod *v1.Pod, patch []patchOperation) error {
+	pattern := c.conf.GetAppIDPattern()
+	if pattern == nil {
+		return nil
+	}
+	appID, explicit := pod.Labels[constants.LabelApplicationID]
+	if !explicit {
+		if !c.conf.GetAppIDPatternGenerated() {
+			return nil
+		}
+		if appID = effectiveLabels(pod, patch)[constants.LabelApplicationID]; appID == "" {
+			return nil
+		}
+	}
+	if !pattern.MatchString(appID) {
+		return fmt.Errorf("application ID '%s' does not match the required pattern '%s'", appID, pattern.String())
+	}
+	return nil
+}
+
+// checkAppIDUnique denies an explicit application ID which is already in use in another namespace. Generated
+// application IDs include the namespace and are not checked. A dry run request is not checked: it would record the
+// application ID for a pod which is never created.
+func (c *admissionController) checkAppIDUnique(namespace string, pod *v1.Pod, dryRun bool) error {
+	if !c.conf.GetUniqueAppID() || dryRun {
+		return nil
+	}
+	appID, ok := pod.Labels[constants.LabelApplicationID]
+	if !ok || appID == "" {
+		return nil
+	}
+	if owner, ok := c.appIDs.claim(appID, namespace, c.conf.GetUniqueAppIDIndexSize(), c.conf.GetUniqueAppIDTTL()); !ok {
+		return fmt.Errorf("application ID '%s' is already in use in namespace '%s', application IDs must be unique across namespaces", appID, owner)
+	}
+	return nil
+}
+
+// checkOwnerAppIDConflict compares an explicit application ID on the pod with the ID that would be derived from the
+// owner of the pod. A conflict is handled according to the configured action: denying returns an error, warning
+// returns the warning for the response.
+func (c *admissionController) checkOwnerAppIDConflict(namespace string, pod *v1.Pod) (string, error) {
+	action := c.conf.GetOwnerAppIDConflict()
+	if action == conf.ConflictActionAllow || !c.conf.GetOwnerBasedAppID() {
+		return "", nil
+	}
+	appID, ok := pod.Labels[constants.LabelApplicationID]
+	if !ok {
+		if appID, ok = pod.Labels[constants.SparkLabelAppID]; !ok {
+			return "", nil
+		}
+	}
+	owner := getPodOwner(pod)
+	if owner == nil {
+		return "", nil
+	}
+	ownerAppID := utils.GetOwnerApplicationID(namespace, owner)
+	if appID == ownerAppID {
+		return "", nil
+	}
+	conflict := fmt.Sprintf("application ID %s conflicts with application ID %s derived from owner %s/%s", appID, ownerAppID, owner.Kind, owner.Name)
+	if action == conf.ConflictActionDeny {
+		return "", errors.New(conflict)
+	}
+	log.Logger().Warn("application ID conflicts with the application ID derived from the pod owner",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("applicationID", appID),
+		zap.String("ownerApplicationID", ownerAppID))
+	return conflict, nil
+}
+
+// checkNamespaceResourceCap compares the requests of the pod plus the usage of the namespace with the resource cap of
+// the namespace. The usage is estimated from the pods known to the informer, concurrent admissions are not included.
+// Depending on the configured action exceeding the cap is allowed, returned as a warning or returned as an error.
+func (c *admissionController) checkNamespaceResourceCap(namespace string, pod *v1.Pod) (string, error) {
+	action := c.conf.GetNamespaceResourceCapAction()
+	resourceCap := c.conf.GetNamespaceResourceCap(namespace)
+	if action == conf.ConflictActionAllow || len(resourceCap) == 0 || c.podUsage == nil {
+		return "", nil
+	}
+	requests, _ := resourcehelper.PodRequestsAndLimits(pod)
+	usage := c.podUsage.getUsage(namespace)
+	var exceeded []string
+	for name, limit := range resourceCap {
+		request, ok := requests[name]
+		if !ok || request.IsZero() {
+			continue
+		}
+		inUse := usage[name]
+		total := inUse.DeepCopy()
+		total.Add(request)
+		if total.Cmp(limit) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s (requested %s, in use %s, cap %s)", name, request.String(), inUse.String(), limit.String()))
+		}
+	}
+	if len(exceeded) == 0 {
+		return "", nil
+	}
+	sort.Strings(exceeded)
+	message := fmt.Sprintf("pod exceeds the resource cap of namespace %s: %s", namespace, strings.Join(exceeded, ", "))
+	if action == conf.ConflictActionDeny {
+		return "", errors.New(message)
+	}
+	log.Logger().Warn("pod exceeds the resource cap of the namespace",
+		zap.String("namespace", namespace),
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.Strings("exceeded", exceeded))
+	return message, nil
+}
+
+// labelWarnings describes the labels that were generated on behalf of the user, so that the mutation is visible to
+// the submitter.
+func labelWarnings(pod *v1.Pod, patch []patchOperation) []string {
+	var warnings []string
+	labels := effectiveLabels(pod, patch)
+	if appID, ok := labels[constants.LabelApplicationID]; ok {
+		if _, exists := pod.Labels[constants.LabelApplicationID]; !exists {
+			warnings = append(warnings, fmt.Sprintf("no %s label found, generated %s", constants.LabelApplicationID, appID))
+		}
+	}
+	if _, ok := labels[constants.LabelDisableStateAware]; ok {
+		if _, exists := pod.Labels[constants.LabelDisableStateAware]; !exists {
+			warnings = append(warnings, "state-aware scheduling disabled for the generated application")
+		}
+	}
+	return warnings
+}
+
+// probeWarnings lists the containers of a long-running pod that have no liveness or readiness probe. Pods are
+// long-running if they are owned by a controller that keeps them running, pods owned by a Job and bare pods are not
+// checked.
+func probeWarnings(pod *v1.Pod) []string {
+	owner := getPodOwner(pod)
+	if owner == nil || !longRunningOwnerKinds[owner.Kind] {
+		return nil
+	}
+	var noLiveness, noReadiness []string
+	for _, container := range pod.Spec.Containers {
+		if container.LivenessProbe == nil {
+			noLiveness = append(noLiveness, container.Name)
+		}
+		if container.ReadinessProbe == nil {
+			noReadiness = append(noReadiness, container.Name)
+		}
+	}
+	var warnings []string
+	if len(noLiveness) != 0 {
+		warnings = append(warnings, fmt.Sprintf("%s owned pod has containers without a liveness probe: %s",
+			owner.Kind, strings.Join(noLiveness, ", ")))
+	}
+	if len(noReadiness) != 0 {
+		warnings = append(warnings, fmt.Sprintf("%s owned pod has containers without a readiness probe: %s",
+			owner.Kind, strings.Join(noReadiness, ", ")))
+	}
+	return warnings
+}
+
+// priorityBucket returns the bucket with the highest minimum priority that the priority of the pod reaches. A pod
+// without a priority has the Kubernetes default priority of zero. If the priority is below all buckets the result is
+// empty.
+func priorityBucket(buckets []*conf.PriorityBucket, pod *v1.Pod) string {
+	var priority int32
+	if pod.Spec.Priority != nil {
+		priority = *pod.Spec.Priority
+	}
+	for _, bucket := range buckets {
+		if priority >= bucket.MinPriority {
+			return bucket.Name
+		}
+	}
+	return ""
+}
+
+// getCostCenter resolves the cost center of the namespace from the lookup table configmap. If the table is not
+// configured or has no entry for the namespace the configured default is returned, which may be empty.
+func (c *admissionController) getCostCenter(namespace string) string {
+	table := c.conf.GetCostCenterConfigMap()
+	if table == "" {
+		return ""
+	}
+	if costCenter, ok := c.cmCache.getValue(table, namespace); ok {
+		return costCenter
+	}
+	log.Logger().Debug("no cost center mapping found for namespace, using default",
+		zap.String("namespace", namespace),
+		zap.String("configMap", table))
+	return c.conf.GetDefaultCostCenter()
+}
+
+// updateNamespaceFieldLabels copies the configured fields of the namespace to the pod labels. Labels set on the pod, or
+// added earlier in the patch, are not overridden. Fields that are not set on the namespace, or that are not valid label
+// values, are skipped.
+func (c *admissionController) updateNamespaceFieldLabels(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	mapping := c.conf.GetNamespaceFieldLabels()
+	if len(mapping) == 0 {
+		return patch
+	}
+	ns := c.nsCache.getNamespace(namespace)
+	if ns == nil {
+		log.Logger().Debug("namespace not found, skipping namespace field labels", zap.String("namespace", namespace))
+		return patch
+	}
+	for _, entry := range mapping {
+		if _, ok := pod.Labels[entry.Label]; ok || hasPatchPath(patch, labelsPath+"/"+jsonPointerEscaper.Replace(entry.Label)) {
+			continue
+		}
+		value, ok := namespaceFieldValue(ns, entry)
+		if !ok {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			log.Logger().Debug("namespace field is not a valid label value, skipping",
+				zap.String("namespace", namespace),
+				zap.String("field", entry.String()),
+				zap.Strings("errors", errs))
+			continue
+		}
+		patch = updateLabel(pod, patch, entry.Label, value)
+	}
+	return patch
+}
+
+// namespaceFieldValue returns the value of the mapped field of the namespace, false is returned if it is not set.
+func namespaceFieldValue(ns *v1.Namespace, entry *conf.NamespaceFieldLabel) (string, bool) {
+	var value string
+	var ok bool
+	switch entry.Field {
+	case conf.NamespaceFieldName:
+		value, ok = ns.Name, true
+	case conf.NamespaceFieldUID:
+		value, ok = string(ns.UID), true
+	case conf.NamespaceFieldLabels:
+		value, ok = ns.Labels[entry.Key]
+	case conf.NamespaceFieldAnnotations:
+		value, ok = ns.Annotations[entry.Key]
+	}
+	return value, ok && value != ""
+}
+
+// trimNonAlphanumeric removes all trailing characters which are not alphanumeric.
+func trimNonAlphanumeric(value string) string {
+	return strings.TrimRightFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+}
+
+// statefulSetOwner returns the StatefulSet that controls the pod, or nil if the pod is not part of a StatefulSet.
+func statefulSetOwner(pod *v1.Pod) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "StatefulSet" || !strings.HasPrefix(owner.APIVersion, "apps/") {
+		return nil
+	}
+	return owner
+}
+
+// replicaSetOwner returns the ReplicaSet of a Deployment that controls the pod, or nil if the pod is not part of a
+// rollout generation. The Deployment controller sets the pod-template-hash label on the pods and in the name of each
+// ReplicaSet it creates.
+func replicaSetOwner(pod *v1.Pod) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "ReplicaSet" || !strings.HasPrefix(owner.APIVersion, "apps/") {
+		return nil
+	}
+	if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash == "" || !strings.HasSuffix(owner.Name, "-"+hash) {
+		return nil
+	}
+	return owner
+}
+
+// statefulSetOrdinal returns the ordinal of a pod of the StatefulSet, which the controller appends to the name of the
+// set to name the pod.
+func statefulSetOrdinal(pod *v1.Pod, set *metav1.OwnerReference) (int, bool) {
+	suffix := strings.TrimPrefix(pod.Name, set.Name+"-")
+	if suffix == pod.Name || suffix == "" || strings.TrimLeft(suffix, "0123456789") != "" {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(suffix)
+	return ordinal, err == nil
+}
+
+// getPodOwner returns the controller of the pod, or the first owner if no controller is set.
+func getPodOwner(pod *v1.Pod) *metav1.OwnerReference {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return owner
+	}
+	if len(pod.OwnerReferences) > 0 {
+		return &pod.OwnerReferences[0]
+	}
+	return nil
+}
+
+func (c *admissionController) updateLabels(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	log.Logger().Info("updating pod labels",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("namespace", namespace),
+		zap.Any("labels", pod.Labels))
+
+	existingLabels := pod.Labels
+	if _, ok := existingLabels[constants.SparkLabelAppID]; !ok {
+		if _, ok := existingLabels[constants.LabelApplicationID]; !ok {
+			// if app id not exist, generate one
+			// for each namespace, we group unnamed pods to one single app
+			// application ID convention is set by the template, default: yunikorn-{namespace}-autogen
+			// when grouping by owner, pods created by the same top-level controller share an app
+			// application ID convention: ${AUTO_GEN_PREFIX}-${NAMESPACE}-${OWNER_UID}
+			// pods of a StatefulSet share an app per set: ${SET_NAME}-${SET_UID_PREFIX}
+			// pods of a Deployment share an app per rollout generation: ${REPLICASET_NAME}-${REPLICASET_UID_PREFIX}
+			generatedID := generateAppID(c.conf.GetAppIDTemplate(), namespace, pod)
+			if set := statefulSetOwner(pod); set != nil && c.conf.GetStatefulSetAppID() {
+				generatedID = utils.GetControllerApplicationID(set.Name, set.UID)
+			} else if rs := replicaSetOwner(pod); rs != nil && c.conf.GetReplicaSetAppID() {
+				generatedID = utils.GetControllerApplicationID(rs.Name, rs.UID)
+			} else if c.conf.GetOwnerBasedAppID() {
+				if owner := getPodOwner(pod); owner != nil {
+					generatedID = utils.GetOwnerApplicationID(namespace, c.owners.getTopLevelOwner(owner))
+				}
+			}
+			patch = updateLabel(pod, patch, constants.LabelApplicationID, generatedID)
+
+			// if we generate an app ID, disable state-aware scheduling for this app
+			if _, ok := existingLabels[constants.LabelDisableStateAware]; !ok {
+				patch = updateLabel(pod, patch, constants.LabelDisableStateAware, "true")
+			}
+			if gracePeriod := c.conf.GetAutogenTerminationGracePeriod(); gracePeriod > 0 {
+				patch = updateTerminationGracePeriod(pod, patch, int64(gracePeriod))
+			}
+		}
+	}
+
+	if set := statefulSetOwner(pod); set != nil && c.conf.GetStatefulSetAppID() {
+		if _, ok := existingLabels[statefulSetOrdinalLabel]; !ok {
+			if ordinal, ok := statefulSetOrdinal(pod, set); ok {
+				patch = updateLabel(pod, patch, statefulSetOrdinalLabel, strconv.Itoa(ordinal))
+			}
+		}
+	}
+
+	if _, ok := existingLabels[constants.LabelQueueName]; !ok {
+		queue, _ := c.getDefaultQueue(namespace, pod)
+		patch = updateLabel(pod, patch, constants.LabelQueueName, queue)
+	}
+
+	if label := c.conf.GetCostCenterLabel(); label != "" {
+		if _, ok := existingLabels[label]; !ok {
+			if costCenter := c.getCostCenter(namespace); costCenter != "" {
+				patch = updateLabel(pod, patch, label, costCenter)
+			}
+		}
+	}
+
+	patch = c.updateNamespaceFieldLabels(namespace, pod, patch)
+
+	// like the generation, the bucket is derived from the pod and replaces a value set by the user
+	if bucket := priorityBucket(c.conf.GetPriorityBuckets(), pod); bucket != "" {
+		patch = updateLabel(pod, patch, priorityBucketLabel, bucket)
+	}
+
+	// the generation is recorded even if the pod sets the label, it reflects the configuration that processed the pod
+	if label := c.conf.GetGenerationLabel(); label != "" {
+		patch = updateLabel(pod, patch, label, strconv.FormatUint(c.conf.GetGeneration(), 10))
+	}
+
+	return patch
+}
+
+func (c *admissionController) validateConf(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req == nil {
+		log.Logger().Warn("empty request received")
+		return admissionResponseBuilder("", false, "", nil)
+	}
+
+	uid := string(req.UID)
+
+	if c.conf.GetDrainMode() {
+		log.Logger().Info("drain mode is active, allowing request without validation",
+			zap.String("UID", uid))
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	var requestKind = req.Kind.Kind
+	if requestKind != "ConfigMap" {
+		log.Logger().Warn("request kind is not configmap", zap.String("requestKind", requestKind))
+		return admissionResponseBuilder(uid, true, "", nil)
+	}
+
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	var configmap v1.ConfigMap
+	if err := json.Unmarshal(req.Object.Raw, &configmap); err != nil {
+		log.Logger().Error("failed to unmarshal configmap", zap.Error(err))
+		return denyResponse(uid, rejectionInvalidRequest, err.Error())
+	}
+
+	// validate new/updated config map
+	if err := c.validateConfigMap(namespace, &configmap); err != nil {
+		log.Logger().Error("failed to validate yunikorn configs", zap.Error(err))
+		metrics.configValidations.WithLabelValues("invalid").Inc()
+		resp := denyResponse(uid, rejectionInvalidConfig, err.Error())
+		if c.conf.GetDryRun() {
+			return dryRunResponse(req, resp)
+		}
+		c.recordDenial(req, resp)
+		return resp
+	}
+	metrics.configValidations.WithLabelValues("valid").Inc()
+
+	return admissionResponseBuilder(uid, true, "", nil)
+}
+
+// shouldProcessNamespace checks the namespace selectors before the namespace lists. A namespace matching the bypass
+// selector is never processed, a namespace matching the process selector is always processed.
+func (c *admissionController) shouldProcessNamespace(namespace string) bool {
+	return c.processNamespace(c.conf.GetNamespaceFilters(), namespace)
+}
+
+func (c *admissionController) processNamespace(filters *conf.NamespaceFilters, namespace string) bool {
+	nsLabels := c.namespaceLabels(namespace)
+	if selectorMatches(filters.BypassNamespaceSelector, nsLabels) {
+		return false
+	}
+	if selectorMatches(filters.ProcessNamespaceSelector, nsLabels) {
+		return true
+	}
+	return (len(filters.ProcessNamespaces) == 0 || regexpsMatch(filters.ProcessNamespaces, namespace)) &&
+		!regexpsMatch(filters.BypassNamespaces, namespace)
+}
+
+// namespaceLabels returns the labels of the namespace. A namespace that is not known has no labels.
+func (c *admissionController) namespaceLabels(namespace string) labels.Set {
+	ns := c.nsCache.getNamespace(namespace)
+	if ns == nil {
+		return labels.Set{}
+	}
+	return labels.Set(ns.Labels)
+}
+
+// shouldProcessPod checks the pod selectors before the namespace lists. A pod matching the bypass selector is never
+// processed, even in a processed namespace. Otherwise a pod matching the process selector is processed, even in a
+// bypassed namespace. Pods matching neither selector follow the namespace lists.
+func (c *admissionController) shouldProcessPod(namespace string, pod *v1.Pod) bool {
+	filters := c.conf.GetNamespaceFilters()
+	podLabels := labels.Set(pod.Labels)
+	if selectorMatches(filters.BypassPodSelector, podLabels) {
+		return false
+	}
+	if selectorMatches(filters.ProcessPodSelector, podLabels) {
+		return true
+	}
+	return c.processNamespace(filters, namespace)
+}
+
+// shouldLabelNamespace checks the namespace selectors before the namespace lists, in the same way as for processing.
+func (c *admissionController) shouldLabelNamespace(namespace string) bool {
+	filters := c.conf.GetNamespaceFilters()
+	nsLabels := c.namespaceLabels(namespace)
+	if selectorMatches(filters.NoLabelNamespaceSelector, nsLabels) {
+		return false
+	}
+	if selectorMatches(filters.LabelNamespaceSelector, nsLabels) {
+		return true
+	}
+	return (len(filters.LabelNamespaces) == 0 || regexpsMatch(filters.LabelNamespaces, namespace)) &&
+		!regexpsMatch(filters.NoLabelNamespaces, namespace)
+}
+
+// selectorMatches returns true if the selector is configured and matches the labels.
+func selectorMatches(selector labels.Selector, set labels.Set) bool {
+	return selector != nil && selector.Matches(set)
+}
+
+// regexpsMatch returns true if any of the expressions matches the value.
+func regexpsMatch(expressions []*regexp.Regexp, value string) bool {
+	for _, re := range expressions {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *admissionController) validateConfigMap(namespace string, cm *v1.ConfigMap) error {
+	if namespace != c.conf.GetNamespace() {
+		log.Logger().Debug("Configmap does not belong to YuniKorn", zap.String("namespace", namespace), zap.String("Name", cm.Name))
 		return nil
 	}
 
@@ -406,66 +1451,442 @@ func (c *admissionController) validateConfigMap(namespace string, cm *v1.ConfigM
 	}
 
 	configs := schedulerconf.FlattenConfigMaps(configMaps)
-	policyGroup := conf.GetPendingPolicyGroup(configs)
-	confKey := fmt.Sprintf("%s.yaml", policyGroup)
+	pending := conf.GetPendingPolicyGroup(configs)
+	// the validation of all policy groups must complete before the API server gives up on the webhook
+	ctx, cancel := context.WithTimeout(context.Background(), c.validateConfDeadline())
+	defer cancel()
+	var failures []string
+	for _, policyGroup := range policyGroups(configs, pending) {
+		confKey := policyGroupKey(policyGroup)
+		content, ok := configs[confKey]
+		if !ok {
+			log.Logger().Info("Configmap missing policygroup config, using default", zap.String("entry", confKey))
+			content = ""
+		}
+		if err := c.validatePolicyGroup(ctx, policyGroup, content, policyGroup == pending); err != nil {
+			failures = append(failures, fmt.Sprintf("policy group %s: %v", policyGroup, err))
+		}
+	}
+	if len(failures) != 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
 
-	content, ok := configs[confKey]
-	if !ok {
-		log.Logger().Info("Configmap missing policygroup config, using default", zap.String("entry", confKey))
-		content = ""
+	log.Logger().Info("Successfully validated YuniKorn configuration")
+	return nil
+}
+
+// policyGroups returns the names of the policy groups defined in the configuration, sorted, and the pending policy
+// group even if it is not defined.
+func policyGroups(configs map[string]string, pending string) []string {
+	result := []string{pending}
+	for key := range configs {
+		if policyGroup := strings.TrimSuffix(key, ".yaml"); policyGroup != key && policyGroup != pending {
+			result = append(result, policyGroup)
+		}
 	}
+	sort.Strings(result)
+	return result
+}
+
+func policyGroupKey(policyGroup string) string {
+	return fmt.Sprintf("%s.yaml", policyGroup)
+}
+
+// validateConfDeadline returns the time available to validate a configmap: the webhook timeout of the API server
+// minus a margin to return the response.
+func (c *admissionController) validateConfDeadline() time.Duration {
+	deadline := time.Duration(c.conf.GetWebhookTimeoutSeconds())*time.Second - validateConfDeadlineMargin
+	if deadline < validateConfDeadlineMargin {
+		deadline = validateConfDeadlineMargin
+	}
+	return deadline
+}
 
+// validatePolicyGroup validates the configuration document of one policy group. Queues with active applications can
+// only be removed from the pending policy group, as that is the configuration the scheduler runs.
+func (c *admissionController) validatePolicyGroup(ctx context.Context, policyGroup string, content string, pending bool) error {
 	checksum := fmt.Sprintf("%X", sha256.Sum256([]byte(content)))
-	log.Logger().Info("Validating YuniKorn configuration", zap.String("checksum", checksum))
+	log.Logger().Info("Validating YuniKorn configuration",
+		zap.String("policyGroup", policyGroup),
+		zap.String("checksum", checksum))
 	log.Logger().Debug("Configmap data", zap.ByteString("content", []byte(content)))
-	response, err := http.Post(fmt.Sprintf(schedulerValidateConfURLPattern, c.conf.GetSchedulerServiceAddress()), "application/json", bytes.NewBuffer([]byte(content)))
+	if err := c.validateConfigLocally(content); err != nil {
+		log.Logger().Error("Configmap validation failed, aborting", zap.Error(err))
+		return c.rejectConfig(policyGroup, "the admission controller", content, err)
+	}
+	response, err := c.postValidateConf(ctx, content)
 	if err != nil {
-		log.Logger().Error("YuniKorn scheduler is unreachable, assuming configmap is valid", zap.Error(err))
-		return nil
+		return c.schedulerUnreachable("YuniKorn scheduler is unreachable", err)
 	}
 	defer response.Body.Close()
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		log.Logger().Error("YuniKorn scheduler responded with unexpected status, assuming configmap is valid",
-			zap.Int("status", response.StatusCode))
-		return nil
-	}
-	responseBytes, err := io.ReadAll(response.Body)
-	if err != nil {
-		log.Logger().Error("Unable to read response from YuniKorn scheduler, assuming configmap is valid", zap.Error(err))
-		return nil
+		return c.schedulerUnreachable("YuniKorn scheduler responded with unexpected status",
+			fmt.Errorf("unexpected status %d", response.StatusCode))
 	}
 	var responseData ValidateConfResponse
-	if err = json.Unmarshal(responseBytes, &responseData); err != nil {
-		log.Logger().Error("Unable to parse response from YuniKorn scheduler, assuming configmap is valid", zap.Error(err))
-		return nil
+	if err = c.scheduler.decodeSignedResponse(response, &responseData); err != nil {
+		return c.schedulerUnreachable("Unable to read response from YuniKorn scheduler", err)
 	}
 	if !responseData.Allowed {
 		err = fmt.Errorf(responseData.Reason)
 		log.Logger().Error("Configmap validation failed, aborting", zap.Error(err))
+		return c.rejectConfig(policyGroup, "the scheduler", content, err)
+	}
+	if pending {
+		if err = c.checkActiveQueueRemoval(ctx, policyGroup, content); err != nil {
+			log.Logger().Error("Configmap validation failed, aborting", zap.Error(err))
+			return c.rejectConfig(policyGroup, "the active queue check", content, err)
+		}
+	}
+	return nil
+}
+
+// rejectConfig describes the rejection of the proposed configuration: the check that failed, its reason and the
+// changes against the current configuration. The changes are left out if either configuration cannot be parsed.
+func (c *admissionController) rejectConfig(policyGroup string, check string, content string, err error) error {
+	message := fmt.Sprintf("configuration rejected by %s: %v", check, err)
+	current, currentErr := c.currentSchedulerConfig(policyGroup)
+	proposed, proposedErr := parseSchedulerConfig(content)
+	if currentErr == nil && proposedErr == nil {
+		if changes := configChanges(current, proposed); len(changes) != 0 {
+			message = fmt.Sprintf("%s (changes: %s)", message, summarizeChanges(changes))
+		}
+	}
+	return errors.New(message)
+}
+
+// currentSchedulerConfig parses the current scheduler configuration of the policy group. A policy group that is not
+// defined yet has an empty configuration.
+func (c *admissionController) currentSchedulerConfig(policyGroup string) (*schedulerConfig, error) {
+	configs := schedulerconf.FlattenConfigMaps(c.conf.GetConfigMaps())
+	return parseSchedulerConfig(configs[policyGroupKey(policyGroup)])
+}
+
+// postValidateConf sends the configuration to the scheduler for validation. Each attempt is bounded by the configured
+// timeout (zero disables it) and all attempts by the context. Only attempts that fail to connect are retried with an
+// exponential backoff, a scheduler that does not answer in time is not tried again.
+func (c *admissionController) postValidateConf(ctx context.Context, content string) (*http.Response, error) {
+	client, err := c.scheduler.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.scheduler.validateConfURL()
+	timeout := c.conf.GetSchedulerValidateTimeout()
+	backoff := validateConfInitialBackoff
+	for attempt := 1; attempt <= validateConfMaxAttempts; attempt++ {
+		var response *http.Response
+		response, err = c.postValidateConfAttempt(ctx, client, endpoint, content, timeout)
+		if err == nil {
+			return response, nil
+		}
+		if attempt == validateConfMaxAttempts || !isConnectError(err) {
+			break
+		}
+		log.Logger().Warn("Validation request to YuniKorn scheduler failed to connect, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return nil, err
+}
+
+// isConnectError returns true if the request failed because no connection to the scheduler could be made. The request
+// did not reach the scheduler and can be retried.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return !opErr.Timeout()
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func (c *admissionController) postValidateConfAttempt(parent context.Context, client *http.Client, endpoint string, content string, timeout time.Duration) (*http.Response, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(content))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the context must stay alive until the body has been read
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+// cancelOnClose releases the request context once the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// schedulerUnreachable handles a validation call to the scheduler which did not produce a result. The configmap is
+// assumed to be valid, unless the admission controller is configured to fail closed.
+func (c *admissionController) schedulerUnreachable(message string, err error) error {
+	if c.conf.GetFailOnSchedulerUnreachable() {
+		log.Logger().Error(message+", rejecting configmap", zap.Error(err))
+		return fmt.Errorf("configuration could not be validated because the YuniKorn scheduler was unreachable: %v", err)
+	}
+	log.Logger().Error(message+", assuming configmap is valid", zap.Error(err))
+	return nil
+}
+
+// validateConfigLocally runs the optional checks which do not need the scheduler. Content which cannot be parsed is
+// left for the scheduler to reject.
+func (c *admissionController) validateConfigLocally(content string) error {
+	config, err := parseSchedulerConfig(content)
+	if err != nil {
+		log.Logger().Debug("Unable to parse configuration locally, leaving validation to the scheduler", zap.Error(err))
+		return nil
+	}
+	if c.conf.GetRequireRootQueue() {
+		if err = checkRootQueue(config); err != nil {
+			return err
+		}
+	}
+	if err = checkReservedProperties(config, c.conf.GetReservedQueueProperties()); err != nil {
 		return err
 	}
+	return checkQueueLimits(config, c.conf.GetMaxQueueDepth(), c.conf.GetMaxQueueCount())
+}
 
-	log.Logger().Info("Successfully validated YuniKorn configuration")
+// checkActiveQueueRemoval denies the proposed configuration if it removes a queue that still has active applications
+// in the scheduler. Configurations that cannot be parsed locally are left to the scheduler.
+func (c *admissionController) checkActiveQueueRemoval(ctx context.Context, policyGroup string, content string) error {
+	if !c.conf.GetDenyActiveQueueRemoval() {
+		return nil
+	}
+	current, err := c.currentSchedulerConfig(policyGroup)
+	if err != nil {
+		log.Logger().Debug("Unable to parse current configuration locally, skipping queue removal check", zap.Error(err))
+		return nil
+	}
+	proposed, err := parseSchedulerConfig(content)
+	if err != nil {
+		log.Logger().Debug("Unable to parse configuration locally, skipping queue removal check", zap.Error(err))
+		return nil
+	}
+	for _, queue := range removedQueues(current, proposed) {
+		apps, err := c.getQueueApplications(ctx, queue.partition, queue.path)
+		if err != nil {
+			if err = c.schedulerUnreachable("Unable to retrieve applications of removed queue from YuniKorn scheduler", err); err != nil {
+				return err
+			}
+			continue
+		}
+		active := make([]string, 0)
+		for _, app := range apps {
+			if !terminatedApplicationStates[app.State] {
+				active = append(active, app.ApplicationID)
+			}
+		}
+		if len(active) > 0 {
+			return fmt.Errorf("queue %s in partition %s cannot be removed, it has active applications: %s",
+				queue.path, queue.partition, strings.Join(active, ", "))
+		}
+	}
 	return nil
 }
 
-func (c *admissionController) health(w http.ResponseWriter, r *http.Request) {
-	// for now, always healthy
+// getQueueApplications retrieves the applications of a queue from the scheduler. A queue unknown to the scheduler
+// has no applications.
+func (c *admissionController) getQueueApplications(ctx context.Context, partition string, queue string) ([]QueueApplication, error) {
+	var apps []QueueApplication
+	endpoint := c.scheduler.url(schedulerQueueAppsURLPattern, url.PathEscape(partition), url.PathEscape(queue))
+	if _, err := c.getSchedulerResource(ctx, endpoint, &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// getSchedulerResource retrieves a resource from the scheduler REST API and decodes it. False is returned if the
+// resource does not exist.
+func (c *admissionController) getSchedulerResource(ctx context.Context, endpoint string, v interface{}) (bool, error) {
+	if timeout := c.conf.GetSchedulerValidateTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	client, err := c.scheduler.httpClient()
+	if err != nil {
+		return false, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return false, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	if err = c.scheduler.decodeResponse(response.Body, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// markReady marks the admission controller as ready to handle requests, once the initialisation has completed.
+func (c *admissionController) markReady() {
+	atomic.StoreInt32(&c.ready, 1)
+}
+
+// markNotReady marks the admission controller as not ready, the server is about to shut down.
+func (c *admissionController) markNotReady() {
+	atomic.StoreInt32(&c.ready, 0)
+}
+
+func (c *admissionController) isReady() bool {
+	return atomic.LoadInt32(&c.ready) == 1
+}
+
+// livez reports that the process is alive, it does not depend on the state of the admission controller.
+func (c *admissionController) livez(w http.ResponseWriter, r *http.Request) {
+	writeHealthCheckResult(w, http.StatusOK, "OK")
+}
+
+// readyz reports whether the admission controller can handle requests: the initialisation must have completed and,
+// if configured, the scheduler must be reachable.
+func (c *admissionController) readyz(w http.ResponseWriter, r *http.Request) {
+	if !c.isReady() {
+		writeHealthCheckResult(w, http.StatusServiceUnavailable, "not ready: initialisation has not completed")
+		return
+	}
+	if c.conf.GetReadinessCheckScheduler() {
+		if err := c.checkSchedulerHealth(); err != nil {
+			log.Logger().Warn("readiness check failed, scheduler is unreachable", zap.Error(err))
+			writeHealthCheckResult(w, http.StatusServiceUnavailable, "not ready: scheduler is unreachable")
+			return
+		}
+	}
+	writeHealthCheckResult(w, http.StatusOK, "OK")
+}
+
+func writeHealthCheckResult(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-type", "text/plain")
-	w.WriteHeader(200)
-	_, err := w.Write([]byte("OK\r\n"))
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(message + "\r\n"))
 	if err != nil {
 		log.Logger().Error("Unable to write health check result", zap.Error(err))
 		return
 	}
 }
 
+// checkSchedulerHealth calls the health check endpoint of the scheduler, any response with a success status passes.
+func (c *admissionController) checkSchedulerHealth() error {
+	ctx := context.Background()
+	if timeout := c.conf.GetSchedulerValidateTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	client, err := c.scheduler.httpClient()
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scheduler.url(schedulerHealthCheckURLPattern), nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// requestBodyReader returns a reader for the decoded request body based on the content encoding of the request.
+func (c *admissionController) requestBodyReader(r *http.Request) (io.Reader, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip":
+		if !c.conf.GetAcceptCompressedRequests() {
+			return nil, fmt.Errorf("%w `%s`, compressed requests are not accepted", errUnsupportedEncoding, encoding)
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return gz, nil
+	default:
+		return nil, fmt.Errorf("%w `%s`", errUnsupportedEncoding, encoding)
+	}
+}
+
 func (c *admissionController) serve(w http.ResponseWriter, r *http.Request) {
 	log.Logger().Debug("request", zap.Any("httpRequest", r))
+	limit, release := c.overload.acquire()
+	if limit != "" {
+		throttle(w, limit)
+		return
+	}
+	defer release()
+
 	var body []byte
+	maxSize := int64(c.conf.GetMaxRequestSize())
+	if maxSize > 0 && r.ContentLength > maxSize {
+		log.Logger().Debug("illegal request received: body too large", zap.Int64("contentLength", r.ContentLength))
+		http.Error(w, errRequestTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
 	if r.Body != nil {
-		var err error
-		body, err = io.ReadAll(r.Body)
+		if maxSize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		}
+		reader, err := c.requestBodyReader(r)
+		if errors.Is(err, errUnsupportedEncoding) {
+			log.Logger().Debug("illegal request received: unsupported content encoding", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			log.Logger().Debug("illegal request received: body invalid", zap.Error(err))
+			http.Error(w, "empty or invalid body", http.StatusBadRequest)
+			return
+		}
+		if maxSize > 0 {
+			// the raw body is limited by the MaxBytesReader, a decompressed body must fit within the limit too
+			reader = &limitedReader{reader: reader, remaining: maxSize, tooLarge: errRequestTooLarge}
+		}
+		body, err = io.ReadAll(reader)
+		if errors.Is(err, errRequestTooLarge) {
+			log.Logger().Debug("illegal request received: body too large", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		if err != nil || len(body) == 0 {
 			log.Logger().Debug("illegal request received: body invalid", zap.Error(err))
 			http.Error(w, "empty or invalid body", http.StatusBadRequest)
@@ -482,40 +1903,32 @@ func (c *admissionController) serve(w http.ResponseWriter, r *http.Request) {
 	}
 
 	urlPath := r.URL.Path
-	if urlPath != mutateURL && urlPath != validateConfURL {
+	handler, ok := c.handlers.get(urlPath)
+	if !ok {
 		log.Logger().Debug("unsupported request received", zap.String("urlPath", urlPath))
 		http.Error(w, "request is neither mutation nor validation", http.StatusNotFound)
 		return
 	}
-	ar := admissionv1.AdmissionReview{TypeMeta: metav1.TypeMeta{
-		APIVersion: admissionReviewAPIVersion,
-		Kind:       admissionReviewKind,
-	}}
 
 	var admissionResponse *admissionv1.AdmissionResponse
-	_, _, err := deserializer.Decode(body, nil, &ar)
-	if err != nil || ar.Request == nil {
+	start := time.Now()
+	req, apiVersion, err := decodeAdmissionReview(body)
+	if err == nil && req != nil && !c.overload.allowClient(req.UserInfo.Username) {
+		throttle(w, throttleClient)
+		return
+	}
+	if err != nil || req == nil {
 		log.Logger().Error("request body decode failed or request empty", zap.Error(err))
-		admissionResponse = admissionResponseBuilder("yunikorn-invalid-body", false, "body decode failed", nil)
+		admissionResponse = denyResponse("yunikorn-invalid-body", rejectionInvalidRequest, "body decode failed")
 	} else {
-		req := ar.Request
-		switch urlPath {
-		case mutateURL:
-			admissionResponse = c.mutate(req)
-		case validateConfURL:
-			admissionResponse = c.validateConf(req)
-		}
-	}
-	admissionReview := admissionv1.AdmissionReview{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: admissionReviewAPIVersion,
-			Kind:       admissionReviewKind,
-		},
-		Response: admissionResponse,
+		admissionResponse = handler(req)
 	}
+	metrics.observeRequest(urlPath, req, admissionResponse, start)
+	c.audit(req, admissionResponse)
+	c.logDebugRequest(req, admissionResponse)
 
 	var resp []byte
-	resp, err = json.Marshal(admissionReview)
+	resp, err = encodeAdmissionReview(apiVersion, admissionResponse)
 	if err != nil {
 		errMessage := fmt.Sprintf("could not encode response: %v", err)
 		log.Logger().Error(errMessage)
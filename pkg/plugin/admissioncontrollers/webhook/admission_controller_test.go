@@ -19,25 +19,41 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap/zapcore"
 	"gotest.tools/assert"
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	authv1 "k8s.io/api/authentication/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
 
 	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	schedulerconf "github.com/apache/yunikorn-k8shim/pkg/conf"
 )
 
 type responseMode int
@@ -53,6 +69,7 @@ func TestUpdateLabels(t *testing.T) {
 	// verify when appId/queue are not given,
 	// we patch it correctly
 	var patch []patchOperation
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
 
 	pod := &v1.Pod{
 		TypeMeta: metav1.TypeMeta{
@@ -72,20 +89,14 @@ func TestUpdateLabels(t *testing.T) {
 		Status: v1.PodStatus{},
 	}
 
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
-	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 4)
-		assert.Equal(t, updatedMap["random"], "random")
-		assert.Equal(t, updatedMap["queue"], "root.default")
-		assert.Equal(t, updatedMap["disableStateAware"], "true")
-		assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
-	} else {
-		t.Fatal("patch info content is not as expected")
-	}
+	updatedMap := effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 4)
+	assert.Equal(t, updatedMap["random"], "random")
+	assert.Equal(t, updatedMap["queue"], "root.default")
+	assert.Equal(t, updatedMap["disableStateAware"], "true")
+	assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
 
 	// verify if applicationId is given in the labels,
 	// we won't modify it
@@ -109,19 +120,13 @@ func TestUpdateLabels(t *testing.T) {
 		Spec:   v1.PodSpec{},
 		Status: v1.PodStatus{},
 	}
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
-	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 3)
-		assert.Equal(t, updatedMap["random"], "random")
-		assert.Equal(t, updatedMap["queue"], "root.default")
-		assert.Equal(t, updatedMap["applicationId"], "app-0001")
-	} else {
-		t.Fatal("patch info content is not as expected")
-	}
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 3)
+	assert.Equal(t, updatedMap["random"], "random")
+	assert.Equal(t, updatedMap["queue"], "root.default")
+	assert.Equal(t, updatedMap["applicationId"], "app-0001")
 
 	// verify if queue is given in the labels,
 	// we won't modify it
@@ -146,20 +151,14 @@ func TestUpdateLabels(t *testing.T) {
 		Status: v1.PodStatus{},
 	}
 
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
-	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 4)
-		assert.Equal(t, updatedMap["random"], "random")
-		assert.Equal(t, updatedMap["queue"], "root.abc")
-		assert.Equal(t, updatedMap["disableStateAware"], "true")
-		assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
-	} else {
-		t.Fatal("patch info content is not as expected")
-	}
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 4)
+	assert.Equal(t, updatedMap["random"], "random")
+	assert.Equal(t, updatedMap["queue"], "root.abc")
+	assert.Equal(t, updatedMap["disableStateAware"], "true")
+	assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
 
 	// namespace might be empty
 	// labels might be empty
@@ -179,19 +178,13 @@ func TestUpdateLabels(t *testing.T) {
 		Status: v1.PodStatus{},
 	}
 
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
-	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 3)
-		assert.Equal(t, updatedMap["queue"], "root.default")
-		assert.Equal(t, updatedMap["disableStateAware"], "true")
-		assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
-	} else {
-		t.Fatal("patch info content is not as expected")
-	}
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 3)
+	assert.Equal(t, updatedMap["queue"], "root.default")
+	assert.Equal(t, updatedMap["disableStateAware"], "true")
+	assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
 
 	// pod name might be empty, it can comes from generatedName
 	patch = make([]patchOperation, 0)
@@ -208,19 +201,13 @@ func TestUpdateLabels(t *testing.T) {
 		Status: v1.PodStatus{},
 	}
 
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
-	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 3)
-		assert.Equal(t, updatedMap["queue"], "root.default")
-		assert.Equal(t, updatedMap["disableStateAware"], "true")
-		assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
-	} else {
-		t.Fatal("patch info content is not as expected")
-	}
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 3)
+	assert.Equal(t, updatedMap["queue"], "root.default")
+	assert.Equal(t, updatedMap["disableStateAware"], "true")
+	assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
 
 	// pod name and generate name could be both empty
 	patch = make([]patchOperation, 0)
@@ -235,24 +222,603 @@ func TestUpdateLabels(t *testing.T) {
 		Status:     v1.PodStatus{},
 	}
 
-	patch = updateLabels("default", pod, patch)
+	patch = ac.updateLabels("default", pod, patch)
 
-	assert.Equal(t, len(patch), 1)
-	assert.Equal(t, patch[0].Op, "add")
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, len(updatedMap), 3)
+	assert.Equal(t, updatedMap["queue"], "root.default")
+	assert.Equal(t, updatedMap["disableStateAware"], "true")
+	assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
+}
+
+func TestUpdateLabelsDefaultQueue(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMDefaultQueue: "root.sandbox",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{}}
+	patch := ac.updateLabels("default", pod, nil)
+	updatedMap := effectiveLabels(pod, patch)
+	assert.Equal(t, updatedMap["queue"], "root.sandbox")
+
+	// an explicit queue still wins over the configured default
+	pod.Labels = map[string]string{"queue": "root.abc"}
+	patch = ac.updateLabels("default", pod, nil)
+	updatedMap = effectiveLabels(pod, patch)
+	assert.Equal(t, updatedMap["queue"], "root.abc")
+}
+
+func TestUpdateLabelsPatchPaths(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationCostCenterConfigMap: "cost-centers",
+		conf.AMMutationCostCenterLabel:     "finance/cost-center",
+		conf.AMMutationDefaultCostCenter:   "cc-0000",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// pod without labels: the labels object is created first
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{}}
+	patch := ac.updateLabels("default", pod, nil)
+	assert.Equal(t, len(patch), 5)
 	assert.Equal(t, patch[0].Path, "/metadata/labels")
-	if updatedMap, ok := patch[0].Value.(map[string]string); ok {
-		assert.Equal(t, len(updatedMap), 3)
-		assert.Equal(t, updatedMap["queue"], "root.default")
-		assert.Equal(t, updatedMap["disableStateAware"], "true")
-		assert.Equal(t, strings.HasPrefix(updatedMap["applicationId"], autoGenAppPrefix), true)
-	} else {
-		t.Fatal("patch info content is not as expected")
+	assert.DeepEqual(t, patch[0].Value, map[string]string{})
+	assert.Equal(t, patch[1].Path, "/metadata/labels/applicationId")
+	assert.Equal(t, patch[2].Path, "/metadata/labels/disableStateAware")
+	assert.Equal(t, patch[3].Path, "/metadata/labels/queue")
+	assert.Equal(t, patch[4].Path, "/metadata/labels/finance~1cost-center")
+	assert.Equal(t, patch[4].Value, "cc-0000")
+	for _, op := range patch {
+		assert.Equal(t, op.Op, "add")
+	}
+
+	// pod with labels: only the keys that are set are patched
+	pod.Labels = map[string]string{"random": "random", "queue": "root.abc"}
+	patch = ac.updateLabels("default", pod, nil)
+	assert.Equal(t, len(patch), 3)
+	assert.Equal(t, patch[0].Path, "/metadata/labels/applicationId")
+	assert.Equal(t, patch[1].Path, "/metadata/labels/disableStateAware")
+	assert.Equal(t, patch[2].Path, "/metadata/labels/finance~1cost-center")
+	updatedMap := effectiveLabels(pod, patch)
+	assert.Equal(t, updatedMap["random"], "random")
+	assert.Equal(t, updatedMap["queue"], "root.abc")
+
+	// labels object created by an earlier patch is not replaced
+	pod.Labels = nil
+	patch = []patchOperation{{Op: "add", Path: "/metadata/labels", Value: map[string]string{"earlier": "value"}}}
+	patch = ac.updateLabels("default", pod, patch)
+	assert.Equal(t, len(patch), 5)
+	assert.Equal(t, effectiveLabels(pod, patch)["earlier"], "value")
+}
+
+func TestUpdateLabelsGeneration(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMMutationGenerationLabel: "yunikorn.apache.org/config-generation",
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{}}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["yunikorn.apache.org/config-generation"], "1")
+
+	// the generation increases with each reload
+	ac.conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["yunikorn.apache.org/config-generation"], "2")
+	ac.conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["yunikorn.apache.org/config-generation"], "3")
+
+	// disabled by default
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	_, ok := effectiveLabels(pod, ac.updateLabels("default", pod, nil))["yunikorn.apache.org/config-generation"]
+	assert.Assert(t, !ok, "generation label set without configuration")
+}
+
+func TestUpdateLabelsNamespaceQueue(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-ns",
+		Annotations: map[string]string{namespaceQueueAnnotation: "root.team"},
+	}})
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: "plain-ns",
+	}})
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default-queue-ns",
+		Annotations: map[string]string{namespaceDefaultQueueAnnotation: "root.tenant"},
+	}})
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: "both-ns",
+		Annotations: map[string]string{
+			namespaceQueueAnnotation:        "root.team",
+			namespaceDefaultQueueAnnotation: "root.tenant",
+		},
+	}})
+	ac := initAdmissionController(createConfig(), nsCache, NewConfigMapCache(nil))
+
+	// namespace with queue annotation
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{}}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-ns", pod, nil))["queue"], "root.team")
+
+	// namespace with default queue annotation, the queue annotation takes precedence
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default-queue-ns", pod, nil))["queue"], "root.tenant")
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("both-ns", pod, nil))["queue"], "root.team")
+
+	// namespace without queue annotation
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("plain-ns", pod, nil))["queue"], "root.default")
+
+	// unknown namespace
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("unknown-ns", pod, nil))["queue"], "root.default")
+
+	// explicit queue on the pod wins
+	pod.Labels = map[string]string{"queue": "root.abc"}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-ns", pod, nil))["queue"], "root.abc")
+
+	// namespace deleted after being cached
+	pod.Labels = nil
+	handler := &namespaceUpdateHandler{cache: nsCache}
+	handler.OnDelete(cache.DeletedFinalStateUnknown{
+		Key: "team-ns",
+		Obj: &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-ns"}},
+	})
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-ns", pod, nil))["queue"], "root.default")
+}
+
+func TestUpdateLabelsGPUQueue(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-ns",
+		Annotations: map[string]string{namespaceQueueAnnotation: "root.team"},
+	}})
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationGPUQueue: "root.gpu",
+	}), nsCache, NewConfigMapCache(nil))
+
+	gpuPod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{
+		Resources: v1.ResourceRequirements{
+			Limits: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+		},
+	}}}}
+	cpuPod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+		},
+	}}}}
+
+	// GPU pod goes to the GPU queue, even in a namespace with a queue
+	assert.Equal(t, effectiveLabels(gpuPod, ac.updateLabels("team-ns", gpuPod, nil))["queue"], "root.gpu")
+	assert.Equal(t, effectiveLabels(gpuPod, ac.updateLabels("default", gpuPod, nil))["queue"], "root.gpu")
+
+	// non-GPU pod uses the normal default
+	assert.Equal(t, effectiveLabels(cpuPod, ac.updateLabels("team-ns", cpuPod, nil))["queue"], "root.team")
+	assert.Equal(t, effectiveLabels(cpuPod, ac.updateLabels("default", cpuPod, nil))["queue"], "root.default")
+
+	// explicit queue wins
+	gpuPod.Labels = map[string]string{"queue": "root.abc"}
+	assert.Equal(t, effectiveLabels(gpuPod, ac.updateLabels("default", gpuPod, nil))["queue"], "root.abc")
+
+	// GPU queue not configured
+	gpuPod.Labels = nil
+	ac = initAdmissionController(createConfig(), nsCache, NewConfigMapCache(nil))
+	assert.Equal(t, effectiveLabels(gpuPod, ac.updateLabels("default", gpuPod, nil))["queue"], "root.default")
+}
+
+func TestUpdateLabelsServiceQueue(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-ns",
+		Annotations: map[string]string{namespaceQueueAnnotation: "root.team"},
+	}})
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationServiceQueue: "root.services",
+	}), nsCache, NewConfigMapCache(nil))
+
+	servicePod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{
+		{Name: "sidecar"},
+		{Name: "web", Ports: []v1.ContainerPort{{ContainerPort: 8080}}},
+	}}}
+	batchPod := &v1.Pod{Spec: v1.PodSpec{
+		InitContainers: []v1.Container{{Name: "init", Ports: []v1.ContainerPort{{ContainerPort: 9090}}}},
+		Containers:     []v1.Container{{Name: "job"}},
+	}}
+
+	// pod exposing ports goes to the service queue, even in a namespace with a queue
+	assert.Equal(t, effectiveLabels(servicePod, ac.updateLabels("team-ns", servicePod, nil))["queue"], "root.services")
+	assert.Equal(t, effectiveLabels(servicePod, ac.updateLabels("default", servicePod, nil))["queue"], "root.services")
+
+	// pod without ports uses the normal default, init container ports are ignored
+	assert.Equal(t, effectiveLabels(batchPod, ac.updateLabels("team-ns", batchPod, nil))["queue"], "root.team")
+	assert.Equal(t, effectiveLabels(batchPod, ac.updateLabels("default", batchPod, nil))["queue"], "root.default")
+
+	// explicit queue wins
+	servicePod.Labels = map[string]string{"queue": "root.abc"}
+	assert.Equal(t, effectiveLabels(servicePod, ac.updateLabels("default", servicePod, nil))["queue"], "root.abc")
+
+	// service queue not configured
+	servicePod.Labels = nil
+	ac = initAdmissionController(createConfig(), nsCache, NewConfigMapCache(nil))
+	assert.Equal(t, effectiveLabels(servicePod, ac.updateLabels("default", servicePod, nil))["queue"], "root.default")
+}
+
+func TestUpdateLabelsPriorityClassQueue(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-ns",
+		Annotations: map[string]string{namespaceQueueAnnotation: "root.team"},
+	}})
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationPriorityClassQueues: "high-priority=root.prod, system-critical=root.system",
+	}), nsCache, NewConfigMapCache(nil))
+
+	// mapped priority class wins over the namespace and default queue
+	pod := &v1.Pod{Spec: v1.PodSpec{PriorityClassName: "high-priority"}}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-ns", pod, nil))["queue"], "root.prod")
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["queue"], "root.prod")
+
+	// unmapped priority class falls back to the namespace and default queue
+	pod.Spec.PriorityClassName = "low-priority"
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-ns", pod, nil))["queue"], "root.team")
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["queue"], "root.default")
+	pod.Spec.PriorityClassName = ""
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["queue"], "root.default")
+
+	// explicit queue wins
+	pod.Spec.PriorityClassName = "high-priority"
+	pod.Labels = map[string]string{"queue": "root.abc"}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))["queue"], "root.abc")
+}
+
+func TestAutogenTerminationGracePeriod(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationAutogenTerminationGracePeriod: "120",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	gracePeriod := func(patch []patchOperation) (interface{}, bool) {
+		for _, op := range patch {
+			if op.Path == "/spec/terminationGracePeriodSeconds" {
+				return op.Value, true
+			}
+		}
+		return nil, false
+	}
+	seconds := func(value int64) *int64 {
+		return &value
+	}
+
+	// injected for generated application IDs, unset or defaulted by the API server
+	pod := &v1.Pod{}
+	value, ok := gracePeriod(ac.updateLabels("default", pod, nil))
+	assert.Check(t, ok, "grace period not injected")
+	assert.Equal(t, value, int64(120))
+	pod = &v1.Pod{Spec: v1.PodSpec{TerminationGracePeriodSeconds: seconds(v1.DefaultTerminationGracePeriodSeconds)}}
+	value, ok = gracePeriod(ac.updateLabels("default", pod, nil))
+	assert.Check(t, ok, "grace period not injected for defaulted pod")
+	assert.Equal(t, value, int64(120))
+
+	// an explicit value is not overridden
+	pod = &v1.Pod{Spec: v1.PodSpec{TerminationGracePeriodSeconds: seconds(5)}}
+	_, ok = gracePeriod(ac.updateLabels("default", pod, nil))
+	assert.Check(t, !ok, "explicit grace period overridden")
+
+	// pods with an application ID are not changed
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.LabelApplicationID: "my-app"}}}
+	_, ok = gracePeriod(ac.updateLabels("default", pod, nil))
+	assert.Check(t, !ok, "grace period injected for pod with an application ID")
+
+	// not configured
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod = &v1.Pod{}
+	_, ok = gracePeriod(ac.updateLabels("default", pod, nil))
+	assert.Check(t, !ok, "grace period injected without configuration")
+}
+
+func TestUpdateLabelsPriorityBucket(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationPriorityBuckets: "low=-100, medium=1000, high=100000",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	bucket := func(priority *int32) string {
+		pod := &v1.Pod{Spec: v1.PodSpec{Priority: priority}}
+		return effectiveLabels(pod, ac.updateLabels("default", pod, nil))[priorityBucketLabel]
+	}
+	priority := func(value int32) *int32 {
+		return &value
+	}
+
+	// across the thresholds
+	assert.Equal(t, bucket(priority(-100)), "low")
+	assert.Equal(t, bucket(priority(999)), "low")
+	assert.Equal(t, bucket(priority(1000)), "medium")
+	assert.Equal(t, bucket(priority(99999)), "medium")
+	assert.Equal(t, bucket(priority(100000)), "high")
+	assert.Equal(t, bucket(priority(2000000000)), "high")
+
+	// below the lowest threshold
+	pod := &v1.Pod{Spec: v1.PodSpec{Priority: priority(-101)}}
+	label, ok := effectiveLabels(pod, ac.updateLabels("default", pod, nil))[priorityBucketLabel]
+	assert.Check(t, !ok, "bucket label %s set for pod below all thresholds", label)
+
+	// nil priority is the default priority
+	assert.Equal(t, bucket(nil), "low")
+
+	// a label set by the user is replaced
+	pod = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{priorityBucketLabel: "high"}},
+		Spec:       v1.PodSpec{Priority: priority(0)},
+	}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("default", pod, nil))[priorityBucketLabel], "low")
+
+	// buckets not configured
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod = &v1.Pod{Spec: v1.PodSpec{Priority: priority(0)}}
+	_, ok = effectiveLabels(pod, ac.updateLabels("default", pod, nil))[priorityBucketLabel]
+	assert.Check(t, !ok, "bucket label set without configuration")
+}
+
+func TestUpdateLabelsOwnerBasedAppID(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationOwnerBasedAppID: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	isController := true
+
+	jobPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+			Name:       "pi",
+			UID:        "6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11",
+			Controller: &isController,
+		}},
+	}}
+	rsPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Name:       "web-7d9f8b",
+			UID:        "0b8e3f1a-77c2-4d2e-8c1e-5e0f9a6b4c22",
+			Controller: &isController,
+		}},
+	}}
+	ownerless := &v1.Pod{}
+
+	labels := effectiveLabels(jobPod, ac.updateLabels("default", jobPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11")
+	assert.Equal(t, labels[constants.LabelDisableStateAware], "true")
+
+	// a second pod of the same job shares the app
+	jobPod2 := jobPod.DeepCopy()
+	labels = effectiveLabels(jobPod2, ac.updateLabels("default", jobPod2, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11")
+
+	labels = effectiveLabels(rsPod, ac.updateLabels("default", rsPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-0b8e3f1a-77c2-4d2e-8c1e-5e0f9a6b4c22")
+	assert.Equal(t, labels[constants.LabelDisableStateAware], "true")
+
+	labels = effectiveLabels(ownerless, ac.updateLabels("default", ownerless, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-autogen")
+
+	// the owner UID is kept in full, the namespace is truncated
+	longNs := strings.Repeat("namespace", 10)
+	labels = effectiveLabels(jobPod, ac.updateLabels(longNs, jobPod, nil))
+	appID := labels[constants.LabelApplicationID]
+	assert.Equal(t, len(appID), 63)
+	assert.Assert(t, strings.HasSuffix(appID, "-6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11"))
+
+	// the top-level controller is used if the owner chain is known
+	handler := &ownerUpdateHandler{cache: ac.owners}
+	handler.OnAdd(&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		UID: "0b8e3f1a-77c2-4d2e-8c1e-5e0f9a6b4c22",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "web",
+			UID:        "d2f0c7e4-1b9a-4c3d-8e5f-6a7b8c9d0e33",
+			Controller: &isController,
+		}},
+	}})
+	labels = effectiveLabels(rsPod, ac.updateLabels("default", rsPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-d2f0c7e4-1b9a-4c3d-8e5f-6a7b8c9d0e33")
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Obj: &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		UID: "0b8e3f1a-77c2-4d2e-8c1e-5e0f9a6b4c22",
+	}}})
+	labels = effectiveLabels(rsPod, ac.updateLabels("default", rsPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-0b8e3f1a-77c2-4d2e-8c1e-5e0f9a6b4c22")
+
+	// an owner cycle does not loop forever
+	handler.OnAdd(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		UID:             "6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "pi", UID: "6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11", Controller: &isController}},
+	}})
+	labels = effectiveLabels(jobPod, ac.updateLabels("default", jobPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11")
+
+	// option disabled keeps the namespace based app
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	labels = effectiveLabels(jobPod, ac.updateLabels("default", jobPod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-autogen")
+}
+
+func TestUpdateLabelsCostCenter(t *testing.T) {
+	cmCache := NewConfigMapCache(nil)
+	cmCache.addConfigMap(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cost-centers"},
+		Data: map[string]string{
+			"team-a": "cc-1001",
+		},
+	})
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationCostCenterConfigMap: "cost-centers",
+	}), NewNamespaceCache(nil), cmCache)
+
+	// mapped namespace
+	pod := &v1.Pod{}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))["cost-center"], "cc-1001")
+
+	// unmapped namespace without a default does not get the label
+	_, ok := effectiveLabels(pod, ac.updateLabels("team-b", pod, nil))["cost-center"]
+	assert.Assert(t, !ok, "cost center label not expected")
+
+	// existing label is kept
+	pod.Labels = map[string]string{"cost-center": "cc-2002"}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))["cost-center"], "cc-2002")
+
+	// unmapped namespace with a default and a custom label
+	pod.Labels = nil
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationCostCenterConfigMap: "cost-centers",
+		conf.AMMutationCostCenterLabel:     "finance/cost-center",
+		conf.AMMutationDefaultCostCenter:   "cc-0000",
+	}), NewNamespaceCache(nil), cmCache)
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-b", pod, nil))["finance/cost-center"], "cc-0000")
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))["finance/cost-center"], "cc-1001")
+
+	// table removed falls back to the default
+	cmCache.removeConfigMap(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cost-centers"}})
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))["finance/cost-center"], "cc-0000")
+
+	// feature not configured
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), cmCache)
+	_, ok = effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))["cost-center"]
+	assert.Assert(t, !ok, "cost center label not expected")
+}
+
+func TestUpdateLabelsNamespaceFields(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		UID:         "ns-uid-1",
+		Labels:      map[string]string{"team": "alpha"},
+		Annotations: map[string]string{"example.com/owner": "jane", "description": "not a label value"},
+	}})
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationNamespaceFieldLabels: "ns=metadata.name,ns-uid=metadata.uid,example.com/team=metadata.labels['team']," +
+			"owner=metadata.annotations['example.com/owner'],description=metadata.annotations['description']," +
+			"tier=metadata.labels['tier'],queue=metadata.labels['team']",
+	}), nsCache, NewConfigMapCache(nil))
+
+	// each mapped field, sources that are missing or not a valid label value are skipped
+	pod := &v1.Pod{}
+	result := effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))
+	assert.Equal(t, result["ns"], "team-a")
+	assert.Equal(t, result["ns-uid"], "ns-uid-1")
+	assert.Equal(t, result["example.com/team"], "alpha")
+	assert.Equal(t, result["owner"], "jane")
+	_, ok := result["description"]
+	assert.Assert(t, !ok, "invalid label value not expected")
+	_, ok = result["tier"]
+	assert.Assert(t, !ok, "missing namespace label not expected")
+	// the queue label set earlier is not replaced
+	assert.Equal(t, result[constants.LabelQueueName], "root.default")
+
+	// existing pod labels are kept
+	pod.Labels = map[string]string{"ns": "custom", "owner": "john"}
+	result = effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))
+	assert.Equal(t, result["ns"], "custom")
+	assert.Equal(t, result["owner"], "john")
+	assert.Equal(t, result["example.com/team"], "alpha")
+
+	// unknown namespace
+	pod.Labels = nil
+	result = effectiveLabels(pod, ac.updateLabels("team-b", pod, nil))
+	_, ok = result["ns"]
+	assert.Assert(t, !ok, "namespace field label not expected for unknown namespace")
+}
+
+func TestUpdateLabelsStatefulSetAppID(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationStatefulSetAppID: "true",
+		conf.AMMutationOwnerBasedAppID:  "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	isController := true
+	setPod := func(name string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "StatefulSet",
+				Name:       "db",
+				UID:        "9f4c2e1a-3b5d-4c6e-8f7a-0b1c2d3e4f50",
+				Controller: &isController,
+			}},
+		}}
 	}
+
+	pod := setPod("db-0")
+	labels := effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "db-9f4c2e1a")
+	assert.Equal(t, labels[constants.LabelDisableStateAware], "true")
+	assert.Equal(t, labels[statefulSetOrdinalLabel], "0")
+
+	// all pods of the set share the app
+	pod = setPod("db-12")
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "db-9f4c2e1a")
+	assert.Equal(t, labels[statefulSetOrdinalLabel], "12")
+
+	// an explicit application ID and ordinal are kept
+	pod.Labels = map[string]string{constants.LabelApplicationID: "my-db", statefulSetOrdinalLabel: "1"}
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "my-db")
+	assert.Equal(t, labels[statefulSetOrdinalLabel], "1")
+
+	// a name without ordinal is not labelled
+	pod = setPod("cache-0")
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "db-9f4c2e1a")
+	_, ok := labels[statefulSetOrdinalLabel]
+	assert.Check(t, !ok, "ordinal label set")
+
+	// other owners keep the owner based app
+	pod = setPod("db-0")
+	pod.OwnerReferences[0].APIVersion = "example.com/v1"
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-9f4c2e1a-3b5d-4c6e-8f7a-0b1c2d3e4f50")
+	_, ok = labels[statefulSetOrdinalLabel]
+	assert.Check(t, !ok, "ordinal label set")
+}
+
+func TestUpdateLabelsReplicaSetAppID(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationReplicaSetAppID: "true",
+		conf.AMMutationOwnerBasedAppID: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	isController := true
+	rolloutPod := func(hash string, uid types.UID) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:   "web-" + hash + "-x7k2p",
+			Labels: map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       "web-" + hash,
+				UID:        uid,
+				Controller: &isController,
+			}},
+		}}
+	}
+
+	// each rollout generation is a separate app
+	pod := rolloutPod("5d8f7c6b9", "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d")
+	labels := effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "web-5d8f7c6b9-1a2b3c4d")
+	assert.Equal(t, labels[constants.LabelDisableStateAware], "true")
+	pod = rolloutPod("7f9b6c5d4", "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b")
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "web-7f9b6c5d4-9e8d7c6b")
+
+	// an explicit application ID is kept
+	pod.Labels[constants.LabelApplicationID] = "my-web"
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "my-web")
+
+	// a ReplicaSet not created by a Deployment keeps the owner based app
+	pod = rolloutPod("5d8f7c6b9", "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d")
+	pod.Labels = nil
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d")
+
+	// the mode is disabled by default
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod = rolloutPod("5d8f7c6b9", "1a2b3c4d-5e6f-4a8b-9c0d-1e2f3a4b5c6d")
+	labels = effectiveLabels(pod, ac.updateLabels("default", pod, nil))
+	assert.Equal(t, labels[constants.LabelApplicationID], "yunikorn-default-autogen")
 }
 
 func TestUpdateSchedulerName(t *testing.T) {
 	var patch []patchOperation
-	patch = updateSchedulerName(patch)
+	patch = updateSchedulerName(patch, constants.SchedulerName)
 	assert.Equal(t, len(patch), 1)
 	assert.Equal(t, patch[0].Op, "add")
 	assert.Equal(t, patch[0].Path, "/spec/schedulerName")
@@ -263,8 +829,58 @@ func TestUpdateSchedulerName(t *testing.T) {
 	}
 }
 
+func TestCustomSchedulerName(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// default scheduler is replaced
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"},
+		Spec:       v1.PodSpec{SchedulerName: v1.DefaultSchedulerName},
+	}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, schedulerName(t, resp.Patch), "yunikorn", "yunikorn not set as scheduler for pod")
+
+	// custom scheduler is left alone, labels are still updated
+	pod.Spec.SchedulerName = "my-custom-scheduler"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, schedulerName(t, resp.Patch), "", "scheduler name patched for custom scheduler pod")
+	assert.Equal(t, labels(t, resp.Patch)["applicationId"], "yunikorn-test-ns-autogen", "wrong applicationId label")
+
+	// custom scheduler is replaced when override is configured
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationOverrideExistingSchedulerName: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, schedulerName(t, resp.Patch), "yunikorn", "yunikorn not set as scheduler for pod")
+}
+
+func TestConfiguredSchedulerName(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationSchedulerName: "yunikorn-canary",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// the configured name is patched in
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, schedulerName(t, resp.Patch), "yunikorn-canary", "configured scheduler not set for pod")
+
+	// the compiled in name is another scheduler
+	pod.Spec.SchedulerName = constants.SchedulerName
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, schedulerName(t, resp.Patch), "", "scheduler name patched for pod requesting another scheduler")
+
+	pod.Spec.SchedulerName = "yunikorn-canary"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Equal(t, schedulerName(t, resp.Patch), "yunikorn-canary", "configured scheduler not set for pod")
+}
+
 func TestValidateConfigMapEmpty(t *testing.T) {
-	controller := initAdmissionController(createConfig())
+	controller := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
 	configmap := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: constants.ConfigMapName,
@@ -311,7 +927,7 @@ func TestValidateConfigMapInvalidConfig(t *testing.T) {
 	controller := prepareController(t, strings.Replace(srv.URL, "http://", "", 1), "", "", "", "", false, true)
 	err := controller.validateConfigMap("default", configmap)
 	assert.Assert(t, err != nil, "error not found")
-	assert.Equal(t, "Invalid config", err.Error(),
+	assert.Equal(t, "policy group queues: configuration rejected by the scheduler: Invalid config (changes: partition default added)", err.Error(),
 		"Other error returned than the expected one")
 }
 
@@ -341,6 +957,129 @@ func TestValidateConfigMapServerError(t *testing.T) {
 	assert.NilError(t, err, "No error expected")
 }
 
+func TestValidateConfigMapFailOnSchedulerUnreachable(t *testing.T) {
+	configmap := prepareConfigMap(ConfigData)
+
+	// the url is wrong, so the POST request will fail
+	srv := serverMock(Success)
+	defer srv.Close()
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    srv.URL,
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err := ac.validateConfigMap("default", configmap)
+	assert.ErrorContains(t, err, "could not be validated because the YuniKorn scheduler was unreachable")
+
+	// server error
+	errSrv := serverMock(Error)
+	defer errSrv.Close()
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    strings.Replace(errSrv.URL, "http://", "", 1),
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err = ac.validateConfigMap("default", configmap)
+	assert.ErrorContains(t, err, "unexpected status 500")
+
+	// a reachable scheduler still decides
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err = ac.validateConfigMap("default", configmap)
+	assert.NilError(t, err, "No error expected")
+}
+
+func TestValidateConfigMapSchedulerTimeout(t *testing.T) {
+	configmap := prepareConfigMap(ConfigData)
+
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	// a hung scheduler is treated as unreachable after the first attempt times out, it is not retried
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:  strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookSchedulerValidateTimeout: "50ms",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	start := time.Now()
+	err := ac.validateConfigMap("default", configmap)
+	assert.NilError(t, err, "fail-open expected")
+	assert.Assert(t, time.Since(start) < 5*time.Second, "request was not bounded by the timeout")
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	// all attempts are bounded by the webhook timeout, even without a validate timeout
+	atomic.StoreInt32(&attempts, 0)
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:  strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookSchedulerValidateTimeout: "0s",
+		conf.AMWebHookTimeoutSeconds:           "1",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	start = time.Now()
+	err = ac.validateConfigMap("default", configmap)
+	assert.NilError(t, err, "fail-open expected")
+	assert.Assert(t, time.Since(start) < time.Second, "request was not bounded by the webhook timeout")
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookSchedulerValidateTimeout:   "50ms",
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err = ac.validateConfigMap("default", configmap)
+	assert.ErrorContains(t, err, "could not be validated because the YuniKorn scheduler was unreachable")
+}
+
+func TestValidateConfigMapSchedulerRetry(t *testing.T) {
+	configmap := prepareConfigMap(ConfigData)
+
+	// refuse the first connection, answer the second attempt
+	var attempts, dials int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		failedResponseMock(w, r)
+	}))
+	defer srv.Close()
+
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	var dialer net.Dialer
+	ac.scheduler.files = schedulerTLSFiles{scheme: ac.conf.GetSchedulerServiceScheme()}
+	ac.scheduler.client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+	}}
+	err := ac.validateConfigMap("default", configmap)
+	assert.ErrorContains(t, err, "Invalid config")
+	assert.Equal(t, atomic.LoadInt32(&dials), int32(2))
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+
+	// errors returned by the scheduler are not retried
+	atomic.StoreInt32(&attempts, 0)
+	errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		errorResponseMock(w, r)
+	}))
+	defer errSrv.Close()
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: strings.Replace(errSrv.URL, "http://", "", 1),
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err = ac.validateConfigMap("default", configmap)
+	assert.NilError(t, err, "fail-open expected")
+	assert.Equal(t, atomic.LoadInt32(&attempts), int32(1))
+}
+
 func prepareConfigMap(data string) *v1.ConfigMap {
 	configmap := &v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -367,7 +1106,7 @@ func prepareController(t *testing.T, url string, processNs string, bypassNs stri
 		conf.AMAccessControlExternalUsers:     "testExtUser",
 		conf.AMAccessControlExternalGroups:    "testExtGroup",
 	})
-	return initAdmissionController(config)
+	return initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
 }
 
 func serverMock(mode responseMode) *httptest.Server {
@@ -409,17 +1148,76 @@ func errorResponseMock(w http.ResponseWriter, r *http.Request) {
 }
 
 func TestGenerateAppID(t *testing.T) {
-	appID := generateAppID("this-is-a-namespace")
+	appID := generateAppID(conf.DefaultMutationAppIDTemplate, "this-is-a-namespace", nil)
 	assert.Equal(t, strings.HasPrefix(appID, fmt.Sprintf("%s-this-is-a-namespace", autoGenAppPrefix)), true)
 	assert.Equal(t, len(appID), 36)
 
-	appID = generateAppID("short")
+	appID = generateAppID(conf.DefaultMutationAppIDTemplate, "short", nil)
 	assert.Equal(t, strings.HasPrefix(appID, fmt.Sprintf("%s-short", autoGenAppPrefix)), true)
 	assert.Equal(t, len(appID), 22)
 
-	appID = generateAppID(strings.Repeat("long", 100))
+	appID = generateAppID(conf.DefaultMutationAppIDTemplate, strings.Repeat("long", 100), nil)
 	assert.Equal(t, strings.HasPrefix(appID, fmt.Sprintf("%s-long", autoGenAppPrefix)), true)
 	assert.Equal(t, len(appID), 63)
+	assert.Equal(t, generateAppID(conf.DefaultMutationAppIDTemplate, strings.Repeat("long", 100), nil), appID)
+
+	// long namespaces sharing a prefix used to truncate to the same ID
+	first := generateAppID(conf.DefaultMutationAppIDTemplate, strings.Repeat("team", 15)+"-first", nil)
+	second := generateAppID(conf.DefaultMutationAppIDTemplate, strings.Repeat("team", 15)+"-second", nil)
+	assert.Equal(t, len(first), 63)
+	assert.Equal(t, len(second), 63)
+	assert.Assert(t, first != second, "long namespaces generated the same ID %s", first)
+	assert.Equal(t, first[:54], second[:54])
+}
+
+func TestGenerateAppIDTemplate(t *testing.T) {
+	appID := generateAppID("{namespace}.batch", "team-a", nil)
+	assert.Equal(t, appID, "team-a.batch")
+
+	appID = generateAppID("{namespace}-{generateName}", "team-a", &v1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "web-7d9f8b-"}})
+	assert.Equal(t, appID, "team-a-web-7d9f8b")
+
+	// empty generate name does not leave a trailing separator
+	appID = generateAppID("{namespace}-{generateName}", "team-a", nil)
+	assert.Equal(t, appID, "team-a")
+
+	// truncation is deterministic and does not leave a separator before the hash
+	template := "{namespace}.{generateName}"
+	namespace := strings.Repeat("a", 53) + "-" + strings.Repeat("b", 20)
+	job := &v1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "job-"}}
+	cron := &v1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "cron-"}}
+	appID = generateAppID(template, namespace, job)
+	assert.Equal(t, len(appID), 62)
+	assert.Assert(t, strings.HasPrefix(appID, strings.Repeat("a", 53)+"-"), "unexpected prefix %s", appID)
+	assert.Equal(t, generateAppID(template, namespace, job), appID)
+	assert.Assert(t, generateAppID(template, namespace, cron) != appID, "generate name not part of the hash")
+
+	// IDs that fit are not changed
+	appID = generateAppID("{namespace}", strings.Repeat("a", 63), nil)
+	assert.Equal(t, appID, strings.Repeat("a", 63))
+
+	// owner and date placeholders
+	owned := &v1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{
+		{Kind: "ReplicaSet", Name: "web-7d9f8b"},
+	}}}
+	appID = generateAppID("{namespace}-{ownerKind}-{ownerName}", "team-a", owned)
+	assert.Equal(t, appID, "team-a-replicaset-web-7d9f8b")
+	appID = generateAppID("{namespace}-{ownerKind}-{ownerName}", "team-a", &v1.Pod{})
+	assert.Equal(t, appID, "team-a")
+	date := time.Now().UTC().Format("20060102")
+	appID = generateAppID("{namespace}-{date}", "team-a", nil)
+	assert.Equal(t, appID, "team-a-"+date)
+
+	// a template rendering to nothing falls back to the default
+	appID = generateAppID("{generateName}", "team-a", nil)
+	assert.Equal(t, appID, "yunikorn-team-a-autogen")
+
+	// template configured on the controller
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationAppIDTemplate: "{namespace}.batch",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{}
+	assert.Equal(t, effectiveLabels(pod, ac.updateLabels("team-a", pod, nil))[constants.LabelApplicationID], "team-a.batch")
 }
 
 func TestMutate(t *testing.T) {
@@ -479,7 +1277,8 @@ func TestMutate(t *testing.T) {
 	resp = ac.mutate(req)
 	assert.Check(t, resp.Allowed, "response not allowed for pod")
 	assert.Equal(t, schedulerName(t, resp.Patch), "yunikorn", "yunikorn not set as scheduler for pod")
-	assert.Equal(t, labels(t, resp.Patch)["applicationId"], "test-app", "wrong applicationId label")
+	_, ok := labels(t, resp.Patch)["applicationId"]
+	assert.Assert(t, !ok, "existing applicationId label patched")
 
 	// pod in bypassed namespace
 	pod = v1.Pod{ObjectMeta: metav1.ObjectMeta{
@@ -532,6 +1331,40 @@ func TestMutate(t *testing.T) {
 	assert.Equal(t, len(resp.Patch), 0, "non-empty patch for unknown object type")
 }
 
+func TestMutateConflictingNamespace(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "object-ns"}}
+	req := createPodRequest(t, pod)
+	req.Namespace = "request-ns"
+
+	// request namespace is used by default
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp := ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, labels(t, resp.Patch)["applicationId"], "yunikorn-request-ns-autogen", "wrong applicationId label")
+
+	// object namespace is used if configured
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringNamespaceSource: conf.NamespaceSourceObject,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, labels(t, resp.Patch)["applicationId"], "yunikorn-object-ns-autogen", "wrong applicationId label")
+
+	// strict mode denies the mismatch
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationStrictNamespace: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response allowed for mismatched namespace")
+	assert.Check(t, strings.Contains(resp.Result.Message, "does not match request namespace"))
+
+	// strict mode allows matching namespaces
+	req = createPodRequest(t, pod)
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed for matching namespace")
+	assert.Equal(t, labels(t, resp.Patch)["applicationId"], "yunikorn-object-ns-autogen", "wrong applicationId label")
+}
+
 func TestExternalAuthentication(t *testing.T) {
 	ac := prepareController(t, "", "", "^kube-system$,^bypass$", "", "^nolabel$", false, true)
 
@@ -589,6 +1422,15 @@ func TestExternalAuthentication(t *testing.T) {
 	assert.Check(t, !resp.Allowed, "response was allowed")
 	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
 
+	// annotation with the wrong shape
+	pod.Annotations[userInfoAnnotation] = "{\"user\":\"test\",\"groups\":\"devops\"}"
+	podJSON, err = json.Marshal(pod)
+	assert.NilError(t, err, "failed to marshal pod")
+	req.Object = runtime.RawExtension{Raw: podJSON}
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Equal(t, resp.Result.Message, "groups field of the user info annotation must be an array of strings")
+
 	// deployment
 	deployment := appsv1.Deployment{
 		Spec: appsv1.DeploymentSpec{
@@ -618,6 +1460,135 @@ func TestExternalAuthentication(t *testing.T) {
 	resp = ac.mutate(req)
 	assert.Check(t, !resp.Allowed, "response was allowed")
 	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
+
+	// custom resource - every pod template is checked
+	sparkApp := fmt.Sprintf(`{"spec": {"driver": {"annotations": {%q: %q}}, "executor": {"annotations": {%q: "xyzxyz"}}}}`,
+		userInfoAnnotation, validUserInfoAnnotation, userInfoAnnotation)
+	req.Object = runtime.RawExtension{Raw: []byte(sparkApp)}
+	req.Kind = metav1.GroupVersionKind{Group: "sparkoperator.k8s.io", Version: "v1beta2", Kind: "SparkApplication"}
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
+}
+
+func TestExternalAuthenticationBypassNamespaces(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMAccessControlBypassAuthNamespaces: "^trusted-.*$",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	userInfo := authv1.UserInfo{
+		Username: "test",
+		Groups:   []string{"dev"},
+	}
+	mutate := func(namespace string, annotation string) *admissionv1.AdmissionResponse {
+		pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Annotations: map[string]string{userInfoAnnotation: annotation},
+		}}
+		podJSON, err := json.Marshal(pod)
+		assert.NilError(t, err, "failed to marshal pod")
+		return ac.mutate(&admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Namespace: namespace,
+			Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+			UserInfo:  userInfo,
+			Object:    runtime.RawExtension{Raw: podJSON},
+		})
+	}
+
+	// submitter is not checked in a trusted namespace
+	resp := mutate("trusted-system", validUserInfoAnnotation)
+	assert.Check(t, resp.Allowed, "response not allowed in trusted namespace")
+
+	// the annotation must still be valid
+	resp = mutate("trusted-system", "xyzxyz")
+	assert.Check(t, !resp.Allowed, "invalid annotation allowed in trusted namespace")
+	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
+
+	// other namespaces still check the submitter
+	resp = mutate("test-ns", validUserInfoAnnotation)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "not allowed to set user annotation"))
+
+	// workloads use the namespace of the request
+	deployment := appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{userInfoAnnotation: validUserInfoAnnotation},
+				},
+			},
+		},
+	}
+	deploymentJSON, err := json.Marshal(deployment)
+	assert.NilError(t, err, "failed to marshal deployment")
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "trusted-system",
+		Kind:      metav1.GroupVersionKind{Kind: "Deployment"},
+		UserInfo:  userInfo,
+		Object:    runtime.RawExtension{Raw: deploymentJSON},
+	}
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "deployment not allowed in trusted namespace")
+	req.Namespace = "test-ns"
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "deployment was allowed")
+}
+
+func TestExternalAuthenticationCronJob(t *testing.T) {
+	ac := prepareController(t, "", "", "^kube-system$,^bypass$", "", "^nolabel$", false, true)
+
+	cronJob := batchv1.CronJob{
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{
+								userInfoAnnotation: validUserInfoAnnotation,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	cronJobJSON, err := json.Marshal(cronJob)
+	assert.NilError(t, err, "failed to marshal cronjob")
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "test-ns",
+		Kind:      metav1.GroupVersionKind{Kind: "CronJob"},
+		UserInfo: authv1.UserInfo{
+			Username: "test",
+			Groups:   []string{"dev"},
+		},
+		Object: runtime.RawExtension{Raw: cronJobJSON},
+	}
+
+	// submitter is not allowed to set the annotation on the job template
+	resp := ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "not allowed to set user annotation"))
+
+	// allowed submitter
+	req.UserInfo.Username = "testExtUser"
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed")
+
+	// invalid annotation
+	cronJob.Spec.JobTemplate.Spec.Template.Annotations[userInfoAnnotation] = "xyzxyz"
+	cronJobJSON, err = json.Marshal(cronJob)
+	assert.NilError(t, err, "failed to marshal cronjob")
+	req.Object = runtime.RawExtension{Raw: cronJobJSON}
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
+
+	// a cronjob that cannot be decoded is denied, not passed through
+	req.Object = runtime.RawExtension{Raw: []byte{0, 1, 2, 3, 4}}
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
 }
 
 func parsePatch(t *testing.T, patch []byte) []patchOperation {
@@ -641,13 +1612,794 @@ func schedulerName(t *testing.T, patch []byte) string {
 }
 
 func labels(t *testing.T, patch []byte) map[string]interface{} {
+	result := make(map[string]interface{})
+	ops := parsePatch(t, patch)
+	for _, op := range ops {
+		if op.Path == labelsPath {
+			for k, v := range op.Value.(map[string]interface{}) {
+				result[k] = v
+			}
+		} else if strings.HasPrefix(op.Path, labelsPath+"/") {
+			key := strings.TrimPrefix(op.Path, labelsPath+"/")
+			key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+			result[key] = op.Value
+		}
+	}
+	return result
+}
+
+func annotations(t *testing.T, patch []byte) map[string]interface{} {
+	result := make(map[string]interface{})
 	ops := parsePatch(t, patch)
 	for _, op := range ops {
-		if op.Path == "/metadata/labels" {
-			return op.Value.(map[string]interface{})
+		if op.Path == annotationsPath {
+			for k, v := range op.Value.(map[string]interface{}) {
+				result[k] = v
+			}
+		} else if strings.HasPrefix(op.Path, annotationsPath+"/") {
+			key := strings.TrimPrefix(op.Path, annotationsPath+"/")
+			key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+			result[key] = op.Value
+		}
+	}
+	return result
+}
+
+func TestUpdateSchedulingPolicyParameters(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationDefaultSchedulingPolicyParameters: "placeholderTimeoutInSeconds=60,gangSchedulingStyle=Soft",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	expected := "placeholderTimeoutInSeconds=60 gangSchedulingStyle=Soft"
+
+	// gang pod without parameters
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{constants.AnnotationTaskGroupName: "tg-1"},
+	}}
+	patch := ac.updateSchedulingPolicyParameters(pod, nil)
+	assert.Equal(t, len(patch), 1)
+	assert.Equal(t, patch[0].Path, "/metadata/annotations/yunikorn.apache.org~1schedulingPolicyParameters")
+	assert.Equal(t, patch[0].Value, expected)
+
+	// gang pod which already has parameters
+	pod.Annotations[constants.AnnotationSchedulingPolicyParam] = "placeholderTimeoutInSeconds=10"
+	patch = ac.updateSchedulingPolicyParameters(pod, nil)
+	assert.Equal(t, len(patch), 0, "existing parameters overridden")
+
+	// non-gang pod
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{}}
+	patch = ac.updateSchedulingPolicyParameters(pod, nil)
+	assert.Equal(t, len(patch), 0, "parameters injected into non-gang pod")
+
+	// processed gang pod through mutate
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{constants.AnnotationTaskGroups: "[]"},
+	}}
+	podJSON, err := json.Marshal(pod)
+	assert.NilError(t, err, "failed to marshal pod")
+	req := &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "test-ns",
+		Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+		Object:    runtime.RawExtension{Raw: podJSON},
+	}
+	resp := ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed for gang pod")
+	assert.Equal(t, annotations(t, resp.Patch)[constants.AnnotationSchedulingPolicyParam], expected)
+	assert.Equal(t, annotations(t, resp.Patch)[constants.AnnotationTaskGroups], nil, "existing annotations clobbered")
+
+	// nothing configured
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{constants.AnnotationTaskGroupName: "tg-1"},
+	}}
+	patch = ac.updateSchedulingPolicyParameters(pod, nil)
+	assert.Equal(t, len(patch), 0, "parameters injected without configuration")
+}
+
+func createPodRequest(t *testing.T, pod *v1.Pod) *admissionv1.AdmissionRequest {
+	podJSON, err := json.Marshal(pod)
+	assert.NilError(t, err, "failed to marshal pod")
+	return &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: pod.Namespace,
+		Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: podJSON},
+	}
+}
+
+func TestOwnerAppIDConflict(t *testing.T) {
+	isController := true
+	ownerRefs := []metav1.OwnerReference{{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       "pi",
+		UID:        "6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11",
+		Controller: &isController,
+	}}
+	agreeing := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "test-ns",
+		OwnerReferences: ownerRefs,
+		Labels:          map[string]string{"applicationId": "yunikorn-test-ns-6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11"},
+	}}
+	conflicting := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "test-ns",
+		OwnerReferences: ownerRefs,
+		Labels:          map[string]string{"applicationId": "my-app"},
+	}}
+	ownerless := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{"applicationId": "my-app"},
+	}}
+
+	tests := []struct {
+		action      string
+		pod         *v1.Pod
+		allowed     bool
+		description string
+	}{
+		{conf.ConflictActionDeny, agreeing, true, "matching application ID"},
+		{conf.ConflictActionDeny, conflicting, false, "conflicting application ID denied"},
+		{conf.ConflictActionWarn, conflicting, true, "conflicting application ID with warning"},
+		{conf.ConflictActionAllow, conflicting, true, "conflicting application ID allowed"},
+		{conf.ConflictActionDeny, ownerless, true, "pod without owner"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+				conf.AMMutationOwnerBasedAppID:      "true",
+				conf.AMValidationOwnerAppIDConflict: test.action,
+			}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+			resp := ac.mutate(createPodRequest(t, test.pod))
+			assert.Equal(t, resp.Allowed, test.allowed)
+			if !test.allowed {
+				assert.Check(t, strings.Contains(resp.Result.Message, "conflicts with application ID"))
+			}
+		})
+	}
+
+	// no owner derived application IDs means there is nothing to conflict with
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationOwnerAppIDConflict: conf.ConflictActionDeny,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp := ac.mutate(createPodRequest(t, conflicting))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+}
+
+func TestValidatePod(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationRequireLabelNamespaces: "^strict-",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// namespace without requirements
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp := ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+
+	// both labels missing
+	pod.Namespace = "strict-team"
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for pod without labels")
+	assert.Equal(t, resp.Result.Message, "pods in namespace strict-team must set the 'queue' and 'applicationId' label(s) explicitly")
+
+	// application ID missing
+	pod.Labels = map[string]string{"queue": "root.abc"}
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for pod without application ID")
+	assert.Equal(t, resp.Result.Message, "pods in namespace strict-team must set the 'applicationId' label(s) explicitly")
+
+	// queue missing
+	pod.Labels = map[string]string{"applicationId": "my-app"}
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for pod without queue")
+	assert.Equal(t, resp.Result.Message, "pods in namespace strict-team must set the 'queue' label(s) explicitly")
+
+	// all labels set, spark application ID is accepted
+	pod.Labels = map[string]string{"spark-app-selector": "spark-123", "queue": "root.abc"}
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for labelled pod")
+
+	// other kinds are not validated
+	req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "strict-team"}})
+	req.Kind = metav1.GroupVersionKind{Kind: "Deployment"}
+	resp = ac.validatePod(req)
+	assert.Check(t, resp.Allowed, "response not allowed for deployment")
+}
+
+func TestServeValidate(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationRequireLabelNamespaces: "^strict-",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "strict-team"}}
+	r := httptest.NewRequest(http.MethodPost, validateURL, bytes.NewReader(admissionReviewBody(t, createPodRequest(t, pod))))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusOK)
+
+	response := admissionReviewResponse(t, w)
+	assert.Check(t, !response.Allowed, "response allowed for pod without labels")
+	assert.Equal(t, string(response.UID), "test-uid")
+}
+
+func TestServeAdmissionReviewVersions(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	serveBody := func(body []byte) map[string]interface{} {
+		r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		assert.Equal(t, w.Code, http.StatusOK)
+		var review map[string]interface{}
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &review), "failed to unmarshal admission review")
+		return review
+	}
+
+	// v1beta1 request gets a v1beta1 response
+	var req admissionv1beta1.AdmissionRequest
+	assert.NilError(t, convertAdmissionType(createPodRequest(t, pod), &req))
+	body, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request:  &req,
+	})
+	assert.NilError(t, err, "failed to marshal admission review")
+	review := serveBody(body)
+	assert.Equal(t, review["apiVersion"], "admission.k8s.io/v1beta1")
+	assert.Equal(t, review["kind"], "AdmissionReview")
+	var beta admissionv1beta1.AdmissionReview
+	assert.NilError(t, convertAdmissionType(review, &beta))
+	assert.Assert(t, beta.Response != nil)
+	assert.Check(t, beta.Response.Allowed, "response not allowed for v1beta1 request")
+	assert.Equal(t, string(beta.Response.UID), "test-uid")
+	assert.Assert(t, len(beta.Response.Patch) > 0, "no patch returned for v1beta1 request")
+	assert.Equal(t, *beta.Response.PatchType, admissionv1beta1.PatchTypeJSONPatch)
+
+	// v1 request gets a v1 response
+	review = serveBody(admissionReviewBody(t, createPodRequest(t, pod)))
+	assert.Equal(t, review["apiVersion"], "admission.k8s.io/v1")
+
+	// missing apiVersion and kind default to v1
+	body, err = json.Marshal(map[string]interface{}{"request": createPodRequest(t, pod)})
+	assert.NilError(t, err, "failed to marshal admission review")
+	review = serveBody(body)
+	assert.Equal(t, review["apiVersion"], "admission.k8s.io/v1")
+	assert.Equal(t, review["response"].(map[string]interface{})["allowed"], true)
+
+	// unsupported version
+	body, err = json.Marshal(map[string]interface{}{
+		"apiVersion": "admission.k8s.io/v2",
+		"kind":       "AdmissionReview",
+		"request":    createPodRequest(t, pod),
+	})
+	assert.NilError(t, err, "failed to marshal admission review")
+	review = serveBody(body)
+	assert.Equal(t, review["apiVersion"], "admission.k8s.io/v1")
+	assert.Equal(t, review["response"].(map[string]interface{})["uid"], "yunikorn-invalid-body")
+}
+
+func TestServeCompressedRequest(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	body := admissionReviewBody(t, createPodRequest(t, pod))
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(body)
+	assert.NilError(t, err, "failed to compress body")
+	assert.NilError(t, gz.Close(), "failed to compress body")
+
+	// plain request
+	r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Check(t, admissionReviewResponse(t, w).Allowed, "response not allowed for plain request")
+
+	// gzip request
+	r = httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(compressed.Bytes()))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusOK)
+	response := admissionReviewResponse(t, w)
+	assert.Check(t, response.Allowed, "response not allowed for compressed request")
+	assert.Equal(t, labels(t, response.Patch)["applicationId"], "yunikorn-test-ns-autogen")
+
+	// corrupt gzip request
+	r = httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+
+	// unsupported encoding
+	r = httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(compressed.Bytes()))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusUnsupportedMediaType)
+
+	// compression disabled
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookAcceptCompressedRequests: "false",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	r = httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(compressed.Bytes()))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	ac.serve(w, r)
+	assert.Equal(t, w.Code, http.StatusUnsupportedMediaType)
+}
+
+func TestServeRequestSizeLimit(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	body := admissionReviewBody(t, createPodRequest(t, pod))
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(body)
+	assert.NilError(t, err, "failed to compress body")
+	assert.NilError(t, gz.Close(), "failed to compress body")
+
+	config := createConfigWithOverrides(map[string]string{
+		conf.AMWebHookMaxRequestSize: strconv.Itoa(len(body)),
+	})
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	sink := &recordingAuditSink{}
+	ac.auditSink = sink
+	serve := func(body []byte, encoding string) int {
+		r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		return w.Code
+	}
+
+	// at the limit
+	assert.Equal(t, serve(body, ""), http.StatusOK)
+	assert.Equal(t, len(sink.entries), 1)
+
+	// over the limit, rejected before decoding
+	pod.Annotations = map[string]string{"padding": strings.Repeat("x", 1024)}
+	assert.Equal(t, serve(admissionReviewBody(t, createPodRequest(t, pod)), ""), http.StatusRequestEntityTooLarge)
+	assert.Equal(t, len(sink.entries), 1)
+
+	// compressed body within the limit which exceeds the limit after decompression
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		conf.AMWebHookMaxRequestSize: strconv.Itoa(len(body) - 1),
+	}}})
+	assert.Assert(t, compressed.Len() < len(body)-1, "compressed body is not smaller than the limit")
+	assert.Equal(t, serve(compressed.Bytes(), "gzip"), http.StatusRequestEntityTooLarge)
+	assert.Equal(t, len(sink.entries), 1)
+
+	// limit disabled
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		conf.AMWebHookMaxRequestSize: "0",
+	}}})
+	assert.Equal(t, serve(admissionReviewBody(t, createPodRequest(t, pod)), ""), http.StatusOK)
+	assert.Equal(t, len(sink.entries), 2)
+}
+
+func admissionReviewBody(t *testing.T, req *admissionv1.AdmissionRequest) []byte {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionReviewAPIVersion, Kind: admissionReviewKind},
+		Request:  req,
+	}
+	body, err := json.Marshal(review)
+	assert.NilError(t, err, "failed to marshal admission review")
+	return body
+}
+
+func admissionReviewResponse(t *testing.T, w *httptest.ResponseRecorder) *admissionv1.AdmissionResponse {
+	var review admissionv1.AdmissionReview
+	err := json.Unmarshal(w.Body.Bytes(), &review)
+	assert.NilError(t, err, "failed to unmarshal admission review")
+	assert.Assert(t, review.Response != nil)
+	return review.Response
+}
+
+func TestMutateWarnings(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// generated application ID
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.DeepEqual(t, resp.Warnings, []string{
+		"no applicationId label found, generated yunikorn-test-ns-autogen",
+		"state-aware scheduling disabled for the generated application",
+	})
+
+	// user supplied labels
+	pod.Labels = map[string]string{"applicationId": "my-app", "queue": "root.abc"}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, len(resp.Warnings), 0)
+
+	// conflict with the owner in warn mode
+	isController := true
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		Kind:       "Job",
+		Name:       "pi",
+		UID:        "6c2a1c3e-4fa0-4e8b-9a53-1f3b2a0c8d11",
+		Controller: &isController,
+	}}
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationOwnerBasedAppID:      "true",
+		conf.AMValidationOwnerAppIDConflict: conf.ConflictActionWarn,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, len(resp.Warnings), 1)
+	assert.Check(t, strings.Contains(resp.Warnings[0], "conflicts with application ID"))
+}
+
+func TestProbeWarnings(t *testing.T) {
+	isController := true
+	owned := func(kind string, containers ...v1.Container) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-ns",
+				Labels:    map[string]string{"applicationId": "my-app", "queue": "root.abc"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: kind, Name: "owner", UID: "owner-uid", Controller: &isController},
+				},
+			},
+			Spec: v1.PodSpec{Containers: containers},
 		}
 	}
-	return make(map[string]interface{})
+	probe := &v1.Probe{ProbeHandler: v1.ProbeHandler{TCPSocket: &v1.TCPSocketAction{}}}
+	withProbes := v1.Container{Name: "web", LivenessProbe: probe, ReadinessProbe: probe}
+	noLiveness := v1.Container{Name: "sidecar", ReadinessProbe: probe}
+	noProbes := v1.Container{Name: "proxy"}
+
+	// probes present
+	assert.Equal(t, len(probeWarnings(owned("ReplicaSet", withProbes))), 0)
+
+	// probes absent on long-running pods
+	assert.DeepEqual(t, probeWarnings(owned("ReplicaSet", withProbes, noLiveness, noProbes)), []string{
+		"ReplicaSet owned pod has containers without a liveness probe: sidecar, proxy",
+		"ReplicaSet owned pod has containers without a readiness probe: proxy",
+	})
+	assert.DeepEqual(t, probeWarnings(owned("StatefulSet", noLiveness)), []string{
+		"StatefulSet owned pod has containers without a liveness probe: sidecar",
+	})
+
+	// batch and bare pods are exempt
+	assert.Equal(t, len(probeWarnings(owned("Job", noProbes))), 0)
+	bare := owned("Job", noProbes)
+	bare.OwnerReferences = nil
+	assert.Equal(t, len(probeWarnings(bare)), 0)
+
+	// warnings are advisory and only returned if configured
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationWarnMissingProbes: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp := ac.mutate(createPodRequest(t, owned("DaemonSet", noProbes)))
+	assert.Check(t, resp.Allowed, "response not allowed for pod without probes")
+	assert.DeepEqual(t, resp.Warnings, []string{
+		"DaemonSet owned pod has containers without a liveness probe: proxy",
+		"DaemonSet owned pod has containers without a readiness probe: proxy",
+	})
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, owned("DaemonSet", noProbes)))
+	assert.Check(t, resp.Allowed, "response not allowed for pod without probes")
+	assert.Equal(t, len(resp.Warnings), 0)
+}
+
+func TestAppIDUnique(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationUniqueAppID:          "true",
+		conf.AMValidationUniqueAppIDIndexSize: "2",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := func(namespace string, appID string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Labels:    map[string]string{"applicationId": appID},
+		}}
+	}
+
+	// unique application ID, further pods of the application in the same namespace
+	resp := ac.mutate(createPodRequest(t, pod("team-a", "app-1")))
+	assert.Check(t, resp.Allowed, "response not allowed for unique application ID")
+	resp = ac.mutate(createPodRequest(t, pod("team-a", "app-1")))
+	assert.Check(t, resp.Allowed, "response not allowed for second pod of the application")
+
+	// duplicate from another namespace
+	resp = ac.mutate(createPodRequest(t, pod("team-b", "app-1")))
+	assert.Check(t, !resp.Allowed, "response allowed for duplicate application ID")
+	assert.Equal(t, resp.Result.Message,
+		"application ID 'app-1' is already in use in namespace 'team-a', application IDs must be unique across namespaces")
+
+	// generated application IDs are not tracked
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}}))
+	assert.Check(t, resp.Allowed, "response not allowed for generated application ID")
+	assert.Equal(t, ac.appIDs.size(), 1)
+
+	// the index is bounded, the least recently used application ID is dropped
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-b", "app-2"))).Allowed)
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-b", "app-3"))).Allowed)
+	assert.Equal(t, ac.appIDs.size(), 2)
+	resp = ac.mutate(createPodRequest(t, pod("team-b", "app-1")))
+	assert.Check(t, resp.Allowed, "response not allowed for application ID dropped from the index")
+
+	// dry run requests are not checked and not recorded
+	dryRun := true
+	req := createPodRequest(t, pod("team-a", "app-1"))
+	req.DryRun = &dryRun
+	assert.Check(t, ac.mutate(req).Allowed, "response not allowed for dry run request")
+	req = createPodRequest(t, pod("team-a", "app-4"))
+	req.DryRun = &dryRun
+	assert.Check(t, ac.mutate(req).Allowed, "response not allowed for dry run request")
+	resp = ac.mutate(createPodRequest(t, pod("team-b", "app-4")))
+	assert.Check(t, resp.Allowed, "application ID recorded for dry run request")
+
+	// the application ID is released after the TTL without admitted pods
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationUniqueAppID:    "true",
+		conf.AMValidationUniqueAppIDTTL: "1m",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	now := time.Now()
+	ac.appIDs.now = func() time.Time { return now }
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-a", "app-1"))).Allowed)
+	now = now.Add(50 * time.Second)
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-a", "app-1"))).Allowed)
+	now = now.Add(50 * time.Second)
+	assert.Check(t, !ac.mutate(createPodRequest(t, pod("team-b", "app-1"))).Allowed, "application ID released while in use")
+	now = now.Add(2 * time.Minute)
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-b", "app-1"))).Allowed, "application ID not released after TTL")
+	assert.Check(t, !ac.mutate(createPodRequest(t, pod("team-a", "app-1"))).Allowed, "released application ID not claimed")
+	assert.Equal(t, ac.appIDs.size(), 1)
+
+	// denied pods are not recorded
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationUniqueAppID:  "true",
+		conf.AMValidationAppIDPattern: "^app-",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Check(t, !ac.mutate(createPodRequest(t, pod("team-a", "other"))).Allowed)
+	assert.Equal(t, ac.appIDs.size(), 0)
+
+	// check disabled
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-a", "app-1"))).Allowed)
+	assert.Check(t, ac.mutate(createPodRequest(t, pod("team-b", "app-1"))).Allowed)
+}
+
+func TestMutateWarningsAnnotation(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationAnnotateWarnings: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// warnings are returned and stamped on the pod
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod")
+	assert.Equal(t, len(resp.Warnings), 2)
+	assert.Equal(t, annotations(t, resp.Patch)[admissionWarningsAnnotation], strings.Join(resp.Warnings, "; "))
+
+	// no warnings, no annotation
+	pod.Labels = map[string]string{"applicationId": "my-app", "queue": "root.abc"}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Equal(t, len(resp.Warnings), 0)
+	_, ok := annotations(t, resp.Patch)[admissionWarningsAnnotation]
+	assert.Assert(t, !ok, "annotation set without warnings")
+
+	// disabled by default
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod.Labels = nil
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Equal(t, len(resp.Warnings), 2)
+	_, ok = annotations(t, resp.Patch)[admissionWarningsAnnotation]
+	assert.Assert(t, !ok, "annotation set without configuration")
+}
+
+func TestWarningsAnnotationValue(t *testing.T) {
+	assert.Equal(t, warningsAnnotationValue([]string{"first", "second"}), "first; second")
+	long := strings.Repeat("x", maxWarningsAnnotationLength)
+	assert.Equal(t, warningsAnnotationValue([]string{long}), long)
+	value := warningsAnnotationValue([]string{long, "more"})
+	assert.Equal(t, len(value), maxWarningsAnnotationLength)
+	assert.Assert(t, strings.HasSuffix(value, "..."), "truncated value not marked")
+}
+
+func TestRequireQueue(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringNoLabelNamespaces: "^nolabel$",
+		conf.AMValidationRequireQueue:     "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// no queue in a namespace without defaulting
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "nolabel"}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for pod without queue")
+	assert.Check(t, strings.Contains(resp.Result.Message, "pod does not declare a queue"))
+
+	// queue label
+	pod.Labels = map[string]string{"queue": "root.abc"}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod with queue label")
+
+	// queue annotation
+	pod.Labels = nil
+	pod.Annotations = map[string]string{constants.AnnotationQueueName: "root.abc"}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod with queue annotation")
+
+	// the default queue is assigned in other namespaces
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod in labelled namespace")
+
+	// not required
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringNoLabelNamespaces: "^nolabel$",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "nolabel"}}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod without queue")
+}
+
+func TestAppIDPattern(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMValidationAppIDPattern: "^[a-z]+-[a-z0-9]+-(dev|prod)$",
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// compliant
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{"applicationId": "team-app1-prod"},
+	}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for compliant application ID")
+
+	// non-compliant
+	pod.Labels["applicationId"] = "App1"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for non-compliant application ID")
+	assert.Equal(t, resp.Result.Message, "application ID 'App1' does not match the required pattern '^[a-z]+-[a-z0-9]+-(dev|prod)$'")
+
+	// generated IDs are exempt by default
+	pod.Labels = nil
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for generated application ID")
+
+	// generated IDs must comply if configured
+	overrides[conf.AMValidationAppIDPatternGenerated] = "true"
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for non-compliant generated application ID")
+	assert.Check(t, strings.Contains(resp.Result.Message, "application ID 'yunikorn-test-ns-autogen' does not match"))
+	overrides[conf.AMMutationAppIDTemplate] = "team-{namespace}-dev"
+	overrides[conf.AMValidationAppIDPattern] = "^[a-z]+-[a-z-]+-(dev|prod)$"
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for compliant generated application ID")
+
+	// not configured
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod.Labels = map[string]string{"applicationId": "App1"}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed without naming convention")
+}
+
+func TestLoggablePatch(t *testing.T) {
+	pod := &v1.Pod{}
+	patch := updateAnnotation(pod, nil, userInfoAnnotation, validUserInfoAnnotation)
+	patch = append(patch, patchOperation{
+		Op:    "add",
+		Path:  annotationsPath,
+		Value: map[string]string{"secret": "abc"},
+	})
+	patch = updateSchedulerName(patch, constants.SchedulerName)
+
+	// masked at info level
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMLoggingMaskAnnotations: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	masked := ac.loggablePatch(patch)
+	assert.Equal(t, len(masked), len(patch))
+	assert.Equal(t, masked[1].Value, fmt.Sprintf("<masked, length %d>", len(validUserInfoAnnotation)))
+	assert.DeepEqual(t, masked[2].Value, map[string]string{"secret": "<masked, length 3>"})
+	assert.Equal(t, masked[3].Value, constants.SchedulerName)
+	// the patch itself is not modified
+	assert.Equal(t, patch[1].Value, validUserInfoAnnotation)
+
+	// visible at debug level
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMLoggingMaskAnnotations: "true",
+		schedulerconf.CMLogLevel:      "-1",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, ac.loggablePatch(patch)[1].Value, validUserInfoAnnotation)
+
+	// visible when masking is disabled
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, ac.loggablePatch(patch)[1].Value, validUserInfoAnnotation)
+}
+
+func TestAppQueueRules(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationAppQueueRules: "^team-a-=root.team-a, ^yunikorn-.*-autogen$=root.default",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// compliant explicit application
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{"applicationId": "team-a-app", "queue": "root.team-a.batch"},
+	}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "compliant application denied")
+
+	// mismatched queue
+	pod.Labels["queue"] = "root.team-ab"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "mismatched application allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "must be submitted to a queue under root.team-a"))
+
+	// application not covered by any rule
+	pod.Labels["applicationId"] = "team-b-app"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "application without rule denied")
+
+	// autogen application in the default queue
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "compliant autogen application denied")
+
+	// autogen application must comply as well
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMValidationAppQueueRules: "^yunikorn-.*-autogen$=root.sandbox",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "non-compliant autogen application allowed")
+}
+
+func TestDrainMode(t *testing.T) {
+	srv := serverMock(Failure)
+	defer srv.Close()
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookDrainMode:               "true",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{userInfoAnnotation: "xyzxyz"},
+	}}
+	configMap := prepareConfigMap(ConfigData)
+	configMap.Namespace = "default"
+	configMapJSON, err := json.Marshal(configMap)
+	assert.NilError(t, err, "failed to marshal configmap")
+	cmReq := &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "default",
+		Kind:      metav1.GroupVersionKind{Kind: "ConfigMap"},
+		Object:    runtime.RawExtension{Raw: configMapJSON},
+	}
+
+	// drain mode bypasses all checks and mutations
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "pod not allowed in drain mode")
+	assert.Equal(t, len(resp.Patch), 0, "pod mutated in drain mode")
+	resp = ac.validateConf(cmReq)
+	assert.Check(t, resp.Allowed, "configmap not allowed in drain mode")
+
+	// normal processing resumes once drain mode is switched off
+	overrides[conf.AMWebHookDrainMode] = "false"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "invalid pod allowed after drain mode")
+	resp = ac.validateConf(cmReq)
+	assert.Check(t, !resp.Allowed, "invalid configmap allowed after drain mode")
 }
 
 func TestShouldProcessNamespace(t *testing.T) {
@@ -665,6 +2417,137 @@ func TestShouldProcessNamespace(t *testing.T) {
 	assert.Check(t, !ac.shouldProcessNamespace("allow-except-this"), "allow-except-this namespace allowed when on bypass list")
 }
 
+func TestShouldProcessPod(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringBypassNamespaces:   "^kube-system$,^legacy-",
+		conf.AMFilteringProcessPodSelector: "scheduling.team/engine=yunikorn",
+		conf.AMFilteringBypassPodSelector:  "scheduling.team/engine in (default, kube)",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := func(engine string) *v1.Pod {
+		if engine == "" {
+			return &v1.Pod{}
+		}
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"scheduling.team/engine": engine}}}
+	}
+
+	// no matching selector, the namespace lists apply
+	assert.Check(t, ac.shouldProcessPod("shared", pod("")), "pod in processed namespace bypassed")
+	assert.Check(t, !ac.shouldProcessPod("legacy-ns", pod("")), "pod in bypassed namespace processed")
+	assert.Check(t, ac.shouldProcessPod("shared", pod("other")), "pod with other engine bypassed")
+
+	// bypass selector wins over a processed namespace
+	assert.Check(t, !ac.shouldProcessPod("shared", pod("default")), "bypassed pod processed")
+	assert.Check(t, !ac.shouldProcessPod("shared", pod("kube")), "bypassed pod processed")
+
+	// process selector wins over a bypassed namespace
+	assert.Check(t, ac.shouldProcessPod("legacy-ns", pod("yunikorn")), "selected pod in bypassed namespace bypassed")
+	assert.Check(t, ac.shouldProcessPod("shared", pod("yunikorn")), "selected pod bypassed")
+
+	// bypass selector wins over the process selector
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringProcessPodSelector: "team",
+		conf.AMFilteringBypassPodSelector:  "!scheduling.team/engine",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	both := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "a"}}}
+	assert.Check(t, !ac.shouldProcessPod("shared", both), "pod matching both selectors processed")
+
+	// the default bypass selector skips pods with the ignore label
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	ignored := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.LabelIgnore: "true"}}}
+	assert.Check(t, !ac.shouldProcessPod("shared", ignored), "pod with ignore label processed")
+	ignored.Labels[constants.LabelIgnore] = "false"
+	assert.Check(t, ac.shouldProcessPod("shared", ignored), "pod with ignore label set to false bypassed")
+
+	// processPod skips bypassed pods
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringBypassPodSelector: "scheduling.team/engine=default",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	bypassed := pod("default")
+	bypassed.Namespace = "shared"
+	resp := ac.mutate(createPodRequest(t, bypassed))
+	assert.Check(t, resp.Allowed, "response not allowed for bypassed pod")
+	assert.Equal(t, len(resp.Patch), 0)
+	processed := pod("")
+	processed.Namespace = "shared"
+	resp = ac.mutate(createPodRequest(t, processed))
+	assert.Check(t, resp.Allowed, "response not allowed for processed pod")
+	assert.Assert(t, len(resp.Patch) > 0, "no patch for processed pod")
+}
+
+func TestNamespaceSelectors(t *testing.T) {
+	nsCache := NewNamespaceCache(nil)
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMFilteringProcessNamespaces:        "^team-",
+		conf.AMFilteringProcessNamespaceSelector: "yunikorn.apache.org/enabled=true",
+		conf.AMFilteringBypassNamespaceSelector:  "yunikorn.apache.org/enabled=false",
+		conf.AMFilteringNoLabelNamespaceSelector: "yunikorn.apache.org/labels=false",
+		conf.AMFilteringLabelNamespaceSelector:   "yunikorn.apache.org/labels=true",
+		conf.AMFilteringLabelNamespaces:          "^team-",
+	}), nsCache, NewConfigMapCache(nil))
+	addNamespace := func(name string, nsLabels map[string]string) {
+		nsCache.addNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nsLabels}})
+	}
+	addNamespace("team-a", nil)
+	addNamespace("team-b", map[string]string{"yunikorn.apache.org/enabled": "false", "yunikorn.apache.org/labels": "false"})
+	addNamespace("dynamic-1", map[string]string{"yunikorn.apache.org/enabled": "true", "yunikorn.apache.org/labels": "true"})
+
+	// no matching selector, the namespace lists apply
+	assert.Check(t, ac.shouldProcessNamespace("team-a"), "team-a namespace not processed")
+	assert.Check(t, ac.shouldLabelNamespace("team-a"), "team-a namespace not labelled")
+	assert.Check(t, !ac.shouldProcessNamespace("unknown"), "unknown namespace processed")
+	assert.Check(t, !ac.shouldLabelNamespace("unknown"), "unknown namespace labelled")
+
+	// bypass selectors win over the lists
+	assert.Check(t, !ac.shouldProcessNamespace("team-b"), "opted out namespace processed")
+	assert.Check(t, !ac.shouldLabelNamespace("team-b"), "opted out namespace labelled")
+
+	// process selectors win over the lists
+	assert.Check(t, ac.shouldProcessNamespace("dynamic-1"), "opted in namespace not processed")
+	assert.Check(t, ac.shouldLabelNamespace("dynamic-1"), "opted in namespace not labelled")
+}
+
+func TestShouldProcessNamespaceReload(t *testing.T) {
+	overrides := map[string]string{conf.AMFilteringBypassNamespaces: "^kube-system$"}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Check(t, ac.shouldProcessNamespace("team-a"), "team-a namespace not allowed")
+	assert.Check(t, !ac.shouldProcessNamespace("kube-system"), "kube-system namespace allowed")
+
+	// check namespaces concurrently with the updates
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				ac.shouldProcessNamespace("team-a")
+			}
+		}
+	}()
+
+	// updated lists apply without re-initialising the controller
+	overrides[conf.AMFilteringBypassNamespaces] = "^kube-system$,^team-"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Check(t, !ac.shouldProcessNamespace("team-a"), "team-a namespace allowed after update")
+	overrides[conf.AMFilteringProcessNamespaces] = "^kube-"
+	overrides[conf.AMFilteringBypassNamespaces] = "^kube-system$"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Check(t, !ac.shouldProcessNamespace("team-a"), "team-a namespace allowed when not on process list")
+	assert.Check(t, ac.shouldProcessNamespace("kube-public"), "kube-public namespace not allowed after update")
+
+	// an invalid list keeps the previous list
+	overrides[conf.AMFilteringProcessNamespaces] = "^team-(a"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Check(t, !ac.shouldProcessNamespace("team-a"), "team-a namespace allowed after invalid update")
+	assert.Check(t, ac.shouldProcessNamespace("kube-public"), "kube-public namespace not allowed after invalid update")
+
+	close(done)
+	<-stopped
+}
+
 func TestShouldLabelNamespace(t *testing.T) {
 	ac := prepareController(t, "", "", "", "", "^skip$", false, true)
 	assert.Check(t, ac.shouldLabelNamespace("test"), "test namespace not allowed")
@@ -708,30 +2591,427 @@ func TestParseRegexes(t *testing.T) {
 }
 
 func TestInitAdmissionControllerRegexErrorHandling(t *testing.T) {
-	ac := initAdmissionController(createConfig())
-	assert.Equal(t, 1, len(ac.conf.GetBypassNamespaces()))
-	assert.Equal(t, conf.DefaultFilteringBypassNamespaces, ac.conf.GetBypassNamespaces()[0].String(), "didn't set default bypassNamespaces")
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 1, len(ac.conf.GetNamespaceFilters().BypassNamespaces))
+	assert.Equal(t, conf.DefaultFilteringBypassNamespaces, ac.conf.GetNamespaceFilters().BypassNamespaces[0].String(), "didn't set default bypassNamespaces")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringProcessNamespaces: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 0, len(ac.conf.GetNamespaceFilters().ProcessNamespaces), "didn't fail on bad processNamespaces list")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringBypassNamespaces: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 1, len(ac.conf.GetNamespaceFilters().BypassNamespaces))
+	assert.Equal(t, conf.DefaultFilteringBypassNamespaces, ac.conf.GetNamespaceFilters().BypassNamespaces[0].String(), "didn't fail on bad bypassNamespaces list")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringLabelNamespaces: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 0, len(ac.conf.GetNamespaceFilters().LabelNamespaces), "didn't fail on bad labelNamespaces list")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringNoLabelNamespaces: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 0, len(ac.conf.GetNamespaceFilters().NoLabelNamespaces), "didn't fail on bad noLabelNamespaces list")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlSystemUsers: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 1, len(ac.conf.GetAccessControl().SystemUsers))
+	assert.Equal(t, conf.DefaultAccessControlSystemUsers, ac.conf.GetAccessControl().SystemUsers[0].String(), "didn't fail on bad systemUsers list")
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlExternalUsers: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 0, len(ac.conf.GetAccessControl().ExternalUsers), "didn't fail on bad externalUsers list")
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringProcessNamespaces: "("}))
-	assert.Equal(t, 0, len(ac.conf.GetProcessNamespaces()), "didn't fail on bad processNamespaces list")
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlExternalGroups: "("}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.Equal(t, 0, len(ac.conf.GetAccessControl().ExternalGroups), "didn't fail on bad externalGroups list")
+}
+
+func podWithRequests(namespace string, uid string, cpu string, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, UID: types.UID(uid)},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "main",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			}},
+		}}},
+	}
+}
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringBypassNamespaces: "("}))
-	assert.Equal(t, 1, len(ac.conf.GetBypassNamespaces()))
-	assert.Equal(t, conf.DefaultFilteringBypassNamespaces, ac.conf.GetBypassNamespaces()[0].String(), "didn't fail on bad bypassNamespaces list")
+func TestPodUsageCache(t *testing.T) {
+	puc := NewPodUsageCache(nil)
+	handler := &podUsageUpdateHandler{cache: puc}
+	handler.OnAdd(podWithRequests("team-a", "1", "1", "1Gi"))
+	handler.OnAdd(podWithRequests("team-a", "2", "500m", "1Gi"))
+	handler.OnAdd(podWithRequests("team-b", "3", "2", "2Gi"))
+	usage := puc.getUsage("team-a")
+	assert.Equal(t, usage.Cpu().MilliValue(), int64(1500))
+	assert.Equal(t, usage.Memory().Value(), int64(2*1024*1024*1024))
+
+	// terminated pods no longer count
+	completed := podWithRequests("team-a", "2", "500m", "1Gi")
+	completed.Status.Phase = v1.PodSucceeded
+	handler.OnUpdate(nil, completed)
+	assert.Equal(t, puc.getUsage("team-a").Cpu().MilliValue(), int64(1000))
+
+	// deleted pods, including tombstones
+	handler.OnDelete(podWithRequests("team-a", "1", "1", "1Gi"))
+	assert.Equal(t, len(puc.getUsage("team-a")), 0)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Obj: podWithRequests("team-b", "3", "2", "2Gi")})
+	assert.Equal(t, len(puc.getUsage("team-b")), 0)
+}
+
+func TestNamespaceResourceCap(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMValidationNamespaceResourceCaps: "team-a:cpu=2,team-a:memory=4Gi",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	ac.podUsage.addPod(podWithRequests("team-a", "1", "1", "2Gi"))
+	ac.podUsage.addPod(podWithRequests("team-b", "2", "8", "8Gi"))
+
+	// under the cap, or namespace without a cap
+	warning, err := ac.checkNamespaceResourceCap("team-a", podWithRequests("team-a", "", "1", "1Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, warning, "")
+	warning, err = ac.checkNamespaceResourceCap("team-b", podWithRequests("team-b", "", "8", "8Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, warning, "")
+
+	// over the cap warns by default
+	warning, err = ac.checkNamespaceResourceCap("team-a", podWithRequests("team-a", "", "1500m", "1Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, warning, "pod exceeds the resource cap of namespace team-a: cpu (requested 1500m, in use 1, cap 2)")
+	resp := ac.mutate(createPodRequest(t, podWithRequests("team-a", "", "2", "4Gi")))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Assert(t, len(resp.Warnings) > 0, "no warnings returned")
+	assert.Equal(t, resp.Warnings[len(resp.Warnings)-1],
+		"pod exceeds the resource cap of namespace team-a: cpu (requested 2, in use 1, cap 2), memory (requested 4Gi, in use 2Gi, cap 4Gi)")
+
+	// over the cap denied
+	overrides[conf.AMValidationNamespaceResourceCapAction] = conf.ConflictActionDeny
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	_, err = ac.checkNamespaceResourceCap("team-a", podWithRequests("team-a", "", "1500m", "1Gi"))
+	assert.ErrorContains(t, err, "pod exceeds the resource cap of namespace team-a")
+	resp = ac.mutate(createPodRequest(t, podWithRequests("team-a", "", "2", "1Gi")))
+	assert.Check(t, !resp.Allowed, "response was allowed")
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringLabelNamespaces: "("}))
-	assert.Equal(t, 0, len(ac.conf.GetLabelNamespaces()), "didn't fail on bad labelNamespaces list")
+	// check disabled
+	overrides[conf.AMValidationNamespaceResourceCapAction] = conf.ConflictActionAllow
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	warning, err = ac.checkNamespaceResourceCap("team-a", podWithRequests("team-a", "", "2", "1Gi"))
+	assert.NilError(t, err)
+	assert.Equal(t, warning, "")
+}
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMFilteringNoLabelNamespaces: "("}))
-	assert.Equal(t, 0, len(ac.conf.GetNoLabelNamespaces()), "didn't fail on bad noLabelNamespaces list")
+func TestCustomUserInfoAnnotation(t *testing.T) {
+	const customAnnotation = "example.com/submitter"
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMAccessControlUserInfoAnnotation: customAnnotation,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	userInfo := authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	podRequest := func(annotations map[string]string) *admissionv1.AdmissionRequest {
+		req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Annotations: annotations}})
+		req.UserInfo = userInfo
+		return req
+	}
+	deploymentRequest := func(annotations map[string]string) *admissionv1.AdmissionRequest {
+		deploymentJSON, err := json.Marshal(appsv1.Deployment{Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}},
+		}})
+		assert.NilError(t, err, "failed to marshal deployment")
+		return &admissionv1.AdmissionRequest{
+			UID:       "test-uid",
+			Namespace: "test-ns",
+			Kind:      metav1.GroupVersionKind{Kind: "Deployment"},
+			UserInfo:  userInfo,
+			Object:    runtime.RawExtension{Raw: deploymentJSON},
+		}
+	}
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlSystemUsers: "("}))
-	assert.Equal(t, 1, len(ac.conf.GetSystemUsers()))
-	assert.Equal(t, conf.DefaultAccessControlSystemUsers, ac.conf.GetSystemUsers()[0].String(), "didn't fail on bad systemUsers list")
+	// the custom key is recognized on pods and workloads
+	for _, req := range []*admissionv1.AdmissionRequest{
+		podRequest(map[string]string{customAnnotation: validUserInfoAnnotation}),
+		deploymentRequest(map[string]string{customAnnotation: validUserInfoAnnotation}),
+	} {
+		resp := ac.mutate(req)
+		assert.Check(t, !resp.Allowed, "response was allowed for %s", req.Kind.Kind)
+		assert.Equal(t, resp.Result.Message, "user test with groups [dev] is not allowed to set user annotation")
+	}
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlExternalUsers: "("}))
-	assert.Equal(t, 0, len(ac.conf.GetExternalUsers()), "didn't fail on bad externalUsers list")
+	// the default key is still checked as the scheduler reads it
+	resp := ac.mutate(podRequest(map[string]string{userInfoAnnotation: validUserInfoAnnotation}))
+	assert.Check(t, !resp.Allowed, "response was allowed for the default key")
+
+	// other annotations are not checked
+	resp = ac.mutate(podRequest(map[string]string{"example.com/other": "xyzxyz"}))
+	assert.Check(t, resp.Allowed, "response not allowed without user info")
+
+	// allowed submitter with an invalid custom annotation
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMAccessControlUserInfoAnnotation: customAnnotation,
+		conf.AMAccessControlExternalUsers:      "^test$",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(deploymentRequest(map[string]string{customAnnotation: "xyzxyz"}))
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "invalid character 'x'"))
+	resp = ac.mutate(podRequest(map[string]string{customAnnotation: validUserInfoAnnotation}))
+	assert.Check(t, resp.Allowed, "response not allowed for external user")
+}
+
+func TestHealthChecks(t *testing.T) {
+	handler := http.NewServeMux()
+	var schedulerStatus int32 = http.StatusOK
+	handler.HandleFunc("/ws/v1/scheduler/healthcheck", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&schedulerStatus)))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	check := func(handler http.HandlerFunc) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	// not ready until initialisation has completed, always alive
+	assert.Equal(t, check(ac.livez), http.StatusOK)
+	assert.Equal(t, check(ac.readyz), http.StatusServiceUnavailable)
+	ac.markReady()
+	assert.Equal(t, check(ac.livez), http.StatusOK)
+	assert.Equal(t, check(ac.readyz), http.StatusOK)
+
+	// not ready while draining, still alive
+	ac.markNotReady()
+	assert.Equal(t, check(ac.readyz), http.StatusServiceUnavailable)
+	assert.Equal(t, check(ac.livez), http.StatusOK)
+	ac.markReady()
+
+	// scheduler connectivity is only checked if enabled
+	atomic.StoreInt32(&schedulerStatus, http.StatusInternalServerError)
+	assert.Equal(t, check(ac.readyz), http.StatusOK)
+	overrides[conf.AMWebHookReadinessCheckScheduler] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	assert.Equal(t, check(ac.readyz), http.StatusServiceUnavailable)
+	atomic.StoreInt32(&schedulerStatus, http.StatusOK)
+	assert.Equal(t, check(ac.readyz), http.StatusOK)
+
+	// unreachable scheduler
+	srv.Close()
+	assert.Equal(t, check(ac.readyz), http.StatusServiceUnavailable)
+	assert.Equal(t, check(ac.livez), http.StatusOK)
+}
+
+func TestDryRun(t *testing.T) {
+	srv := serverMock(Failure)
+	defer srv.Close()
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:   strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookDryRun:                    "true",
+		conf.AMValidationRequireLabelNamespaces: "^strict-",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	decision := func(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) map[string]interface{} {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, field := range dryRunFields(req, resp) {
+			field.AddTo(enc)
+		}
+		return enc.Fields
+	}
+
+	// the patch is computed but not returned
+	req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}})
+	resp := ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+	assert.Check(t, resp.PatchType == nil, "patch type set in dry run")
+	wouldResp := ac.processPod(req)
+	fields := decision(req, wouldResp)
+	assert.Equal(t, fields["wouldAllow"], true)
+	assert.Equal(t, fields["wouldPatch"], true)
+	assert.Equal(t, fields["patch"], string(wouldResp.Patch))
+
+	// a denied pod is allowed
+	req = createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{userInfoAnnotation: validUserInfoAnnotation},
+	}})
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+	fields = decision(req, ac.processPod(req))
+	assert.Equal(t, fields["wouldAllow"], false)
+	assert.Equal(t, fields["reason"], "user test with groups [dev] is not allowed to set user annotation")
+
+	// validation is allowed
+	resp = ac.validatePod(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "strict-team"}}))
+	assert.Check(t, resp.Allowed, "pod validation not allowed")
+	configMap := prepareConfigMap(ConfigData)
+	configMap.Namespace = "default"
+	configMapJSON, err := json.Marshal(configMap)
+	assert.NilError(t, err, "failed to marshal configmap")
+	confReq := &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "default",
+		Kind:      metav1.GroupVersionKind{Kind: "ConfigMap"},
+		Object:    runtime.RawExtension{Raw: configMapJSON},
+	}
+	resp = ac.validateConf(confReq)
+	assert.Check(t, resp.Allowed, "configmap validation not allowed")
+
+	// dry run disabled
+	overrides[conf.AMWebHookDryRun] = "false"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	resp = ac.validateConf(confReq)
+	assert.Check(t, !resp.Allowed, "configmap validation was allowed")
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}))
+	assert.Check(t, len(resp.Patch) > 0, "no patch returned")
+}
+
+func TestMutateTerminalPod(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+
+	// create is mutated
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for create")
+	assert.Check(t, len(resp.Patch) != 0, "empty patch for create")
+
+	// update of a running pod is mutated
+	pod.Status.Phase = v1.PodRunning
+	req := createPodRequest(t, pod)
+	req.Operation = admissionv1.Update
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed for running pod")
+	assert.Check(t, len(resp.Patch) != 0, "empty patch for running pod")
+
+	// update of a terminated pod is not mutated
+	for _, phase := range []v1.PodPhase{v1.PodSucceeded, v1.PodFailed} {
+		pod.Status.Phase = phase
+		req = createPodRequest(t, pod)
+		req.Operation = admissionv1.Update
+		resp = ac.mutate(req)
+		assert.Check(t, resp.Allowed, "response not allowed for %s pod", phase)
+		assert.Equal(t, len(resp.Patch), 0, "non-empty patch for %s pod", phase)
+	}
+}
+
+func TestMutateMirrorPod(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	skipped := testutil.ToFloat64(metrics.mirrorPods.WithLabelValues("kube-system"))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "etcd-node-1",
+		Namespace:   "kube-system",
+		Annotations: map[string]string{v1.MirrorPodAnnotationKey: "hash"},
+	}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for mirror pod")
+	assert.Equal(t, len(resp.Patch), 0, "non-empty patch for mirror pod")
+
+	pod = &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "kube-proxy-node-1",
+		Namespace:       "kube-system",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node-1"}},
+	}}
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for static pod")
+	assert.Equal(t, len(resp.Patch), 0, "non-empty patch for static pod")
+	assert.Equal(t, testutil.ToFloat64(metrics.mirrorPods.WithLabelValues("kube-system")), skipped+2)
+
+	// other owners are mutated
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "kube-proxy"}}
+	pod.Namespace = "test-ns"
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for daemonset pod")
+	assert.Check(t, len(resp.Patch) != 0, "empty patch for daemonset pod")
+}
+
+func TestAnnotateDecision(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMMutationAnnotateDecision:    "true",
+		conf.AMMutationPriorityClassQueues: "high=root.high",
+		conf.AMFilteringNoLabelNamespaces:  "^nolabel$",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	decision := func(pod *v1.Pod) *admissionDecision {
+		resp := ac.mutate(createPodRequest(t, pod))
+		assert.Check(t, resp.Allowed, "response not allowed")
+		value, ok := annotations(t, resp.Patch)[admissionDecisionAnnotation].(string)
+		assert.Assert(t, ok, "decision annotation not found")
+		result := &admissionDecision{}
+		assert.NilError(t, json.Unmarshal([]byte(value), result))
+		return result
+	}
+
+	// generated application ID and default queue
+	result := decision(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}})
+	assert.Equal(t, result.SchedulerName, constants.SchedulerName)
+	assert.Check(t, result.SchedulerNameSet, "scheduler name not set")
+	assert.Equal(t, result.ApplicationIDSource, appIDSourceGenerated)
+	assert.Equal(t, result.QueueSource, queueSourceDefault)
+	assert.DeepEqual(t, result.Warnings, []string{
+		"no applicationId label found, generated yunikorn-test-ns-autogen",
+		"state-aware scheduling disabled for the generated application",
+	})
+
+	// application ID and queue set on the pod
+	result = decision(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{constants.LabelApplicationID: "app-1", constants.LabelQueueName: "root.a"},
+	}})
+	assert.Equal(t, result.ApplicationIDSource, sourcePod)
+	assert.Equal(t, result.QueueSource, sourcePod)
+	assert.Equal(t, len(result.Warnings), 0)
+
+	// application ID from the owner and queue from the priority class
+	overrides[conf.AMMutationOwnerBasedAppID] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	isController := true
+	result = decision(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "batch/v1",
+				Kind:       "Job",
+				Name:       "pi",
+				UID:        "job-uid",
+				Controller: &isController,
+			}},
+		},
+		Spec: v1.PodSpec{PriorityClassName: "high"},
+	})
+	assert.Equal(t, result.ApplicationIDSource, appIDSourceOwner)
+	assert.Equal(t, result.QueueSource, queueSourcePriorityClass)
+
+	// another scheduler requested and labelling disabled for the namespace
+	result = decision(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "nolabel"},
+		Spec:       v1.PodSpec{SchedulerName: "other-scheduler"},
+	})
+	assert.Equal(t, result.SchedulerName, "other-scheduler")
+	assert.Check(t, !result.SchedulerNameSet, "scheduler name set")
+	assert.Equal(t, result.ApplicationIDSource, sourceNone)
+	assert.Equal(t, result.QueueSource, sourceNone)
+
+	// disabled
+	overrides[conf.AMMutationAnnotateDecision] = "false"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp := ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}))
+	_, ok := annotations(t, resp.Patch)[admissionDecisionAnnotation]
+	assert.Check(t, !ok, "decision annotation not expected")
+}
 
-	ac = initAdmissionController(createConfigWithOverrides(map[string]string{conf.AMAccessControlExternalGroups: "("}))
-	assert.Equal(t, 0, len(ac.conf.GetExternalGroups()), "didn't fail on bad externalGroups list")
+func TestDecisionAnnotationValueTruncated(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	warnings := []string{strings.Repeat("a", 1000), strings.Repeat("b", 1000), strings.Repeat("c", 1000)}
+	value := ac.decisionAnnotationValue("test-ns", &v1.Pod{}, nil, warnings)
+	assert.Assert(t, len(value) <= maxDecisionAnnotationLength, "annotation value too long: %d", len(value))
+	result := &admissionDecision{}
+	assert.NilError(t, json.Unmarshal([]byte(value), result))
+	assert.DeepEqual(t, result.Warnings, warnings[:1])
+	assert.Check(t, result.WarningsTruncated, "warnings not marked as truncated")
 }
@@ -0,0 +1,92 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// defaultReviewKind is used for request bodies that do not set the apiVersion or kind.
+var defaultReviewKind = admissionv1.SchemeGroupVersion.WithKind(admissionReviewKind)
+
+// newRuntimeScheme returns the scheme with all supported AdmissionReview versions registered.
+func newRuntimeScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(admissionv1.AddToScheme(scheme))
+	utilruntime.Must(admissionv1beta1.AddToScheme(scheme))
+	return scheme
+}
+
+// decodeAdmissionReview decodes a v1 or v1beta1 AdmissionReview and returns the request in its v1 form, together with
+// the API version that must be used for the response. The request is nil if the review does not contain one.
+func decodeAdmissionReview(body []byte) (*admissionv1.AdmissionRequest, string, error) {
+	obj, gvk, err := deserializer.Decode(body, &defaultReviewKind, nil)
+	if err != nil {
+		return nil, admissionReviewAPIVersion, err
+	}
+	switch review := obj.(type) {
+	case *admissionv1.AdmissionReview:
+		return review.Request, admissionReviewAPIVersion, nil
+	case *admissionv1beta1.AdmissionReview:
+		if review.Request == nil {
+			return nil, admissionReviewV1beta1APIVersion, nil
+		}
+		var req admissionv1.AdmissionRequest
+		if err = convertAdmissionType(review.Request, &req); err != nil {
+			return nil, admissionReviewV1beta1APIVersion, err
+		}
+		return &req, admissionReviewV1beta1APIVersion, nil
+	}
+	return nil, admissionReviewAPIVersion, fmt.Errorf("unsupported admission review %s", gvk)
+}
+
+// encodeAdmissionReview wraps the response in an AdmissionReview of the given API version.
+func encodeAdmissionReview(apiVersion string, response *admissionv1.AdmissionResponse) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{
+		APIVersion: apiVersion,
+		Kind:       admissionReviewKind,
+	}
+	if apiVersion != admissionReviewV1beta1APIVersion {
+		return json.Marshal(admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: response})
+	}
+	review := admissionv1beta1.AdmissionReview{TypeMeta: typeMeta}
+	if response != nil {
+		review.Response = &admissionv1beta1.AdmissionResponse{}
+		if err := convertAdmissionType(response, review.Response); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(review)
+}
+
+// convertAdmissionType converts between the v1 and v1beta1 admission types, which share the same wire format.
+func convertAdmissionType(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,177 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+	"github.com/apache/yunikorn-scheduler-interface/lib/go/si"
+)
+
+const (
+	// maximum number of users kept in the identity cache before it is reset
+	maxIdentityCacheEntries = 10000
+	// maximum size of an identity read from the identity service
+	maxIdentityResponseSize = 64 * 1024
+)
+
+// IdentityResponse is the document returned by the identity service for a user lookup:
+// GET <identityServiceURL>?user=<name> returns 200 with the user and its groups, or 404 for an unknown user.
+// If a token file is configured the token is sent as a bearer token, which allows the service to be a gateway in
+// front of an OIDC provider or an LDAP directory that only answers authenticated lookups.
+type IdentityResponse struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups"`
+}
+
+type identityEntry struct {
+	known   bool
+	groups  map[string]bool
+	expires time.Time
+}
+
+// IdentityVerifier checks the user and groups claimed in the user info annotation against an external identity
+// service. Lookups are cached for the configured TTL.
+type IdentityVerifier struct {
+	conf  *conf.AdmissionControllerConf
+	cache map[string]*identityEntry
+
+	sync.Mutex
+}
+
+func NewIdentityVerifier(conf *conf.AdmissionControllerConf) *IdentityVerifier {
+	return &IdentityVerifier{
+		conf:  conf,
+		cache: make(map[string]*identityEntry),
+	}
+}
+
+// Verify checks that the user exists and belongs to all claimed groups. If no identity service is configured all
+// claims are accepted. If the identity service cannot be queried the configured failure policy applies.
+func (v *IdentityVerifier) Verify(userGroups *si.UserGroupInformation) error {
+	serviceURL := v.conf.GetIdentityServiceURL()
+	if serviceURL == "" {
+		return nil
+	}
+	entry, err := v.lookup(serviceURL, userGroups.User)
+	if err != nil {
+		if v.conf.GetIdentityServiceFailurePolicy() == conf.FailurePolicyIgnore {
+			log.Logger().Warn("Unable to verify user with identity service, accepting annotation",
+				zap.String("user", userGroups.User),
+				zap.Error(err))
+			return nil
+		}
+		log.Logger().Error("Unable to verify user with identity service", zap.String("user", userGroups.User), zap.Error(err))
+		return fmt.Errorf("unable to verify user %s with identity service: %v", userGroups.User, err)
+	}
+	if !entry.known {
+		return fmt.Errorf("user %s is not known to the identity service", userGroups.User)
+	}
+	for _, group := range userGroups.Groups {
+		if !entry.groups[group] {
+			return fmt.Errorf("user %s is not a member of group %s", userGroups.User, group)
+		}
+	}
+	return nil
+}
+
+func (v *IdentityVerifier) lookup(serviceURL string, user string) (*identityEntry, error) {
+	now := time.Now()
+	v.Lock()
+	entry, ok := v.cache[user]
+	v.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry, nil
+	}
+
+	lookupURL, err := url.Parse(serviceURL)
+	if err != nil {
+		return nil, err
+	}
+	query := lookupURL.Query()
+	query.Set("user", user)
+	lookupURL.RawQuery = query.Encode()
+	request, err := http.NewRequest(http.MethodGet, lookupURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if tokenFile := v.conf.GetIdentityServiceTokenFile(); tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read identity service token: %v", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	client := &http.Client{Timeout: v.conf.GetIdentityServiceTimeout()}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	entry = &identityEntry{
+		groups:  make(map[string]bool),
+		expires: now.Add(v.conf.GetIdentityServiceCacheTTL()),
+	}
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		entry.known = false
+	case response.StatusCode >= 200 && response.StatusCode <= 299:
+		body, err := io.ReadAll(io.LimitReader(response.Body, maxIdentityResponseSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxIdentityResponseSize {
+			return nil, fmt.Errorf("identity of user %s exceeds %d bytes", user, maxIdentityResponseSize)
+		}
+		var identity IdentityResponse
+		if err = json.Unmarshal(body, &identity); err != nil {
+			return nil, err
+		}
+		if identity.User != user {
+			return nil, fmt.Errorf("identity service returned user %s for user %s", identity.User, user)
+		}
+		entry.known = true
+		for _, group := range identity.Groups {
+			entry.groups[group] = true
+		}
+	default:
+		return nil, fmt.Errorf("identity service responded with unexpected status %d", response.StatusCode)
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	if len(v.cache) >= maxIdentityCacheEntries {
+		v.cache = make(map[string]*identityEntry)
+	}
+	v.cache[user] = entry
+	return entry, nil
+}
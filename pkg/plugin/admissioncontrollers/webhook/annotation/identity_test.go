@@ -0,0 +1,206 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func newIdentityServer(t *testing.T, identities map[string][]string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		user := r.URL.Query().Get("user")
+		groups, ok := identities[user]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		err := json.NewEncoder(w).Encode(&IdentityResponse{User: user, Groups: groups})
+		assert.NilError(t, err)
+	}))
+}
+
+func TestIdentityServiceVerification(t *testing.T) {
+	var calls int32
+	server := newIdentityServer(t, map[string][]string{
+		"test":  {"devops", "system:authenticated"},
+		"other": {"system:authenticated"},
+	}, &calls)
+	defer server.Close()
+
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL: server.URL,
+	})
+
+	err := ah.IsAnnotationValid(testAnnotation)
+	assert.NilError(t, err)
+
+	err = ah.IsAnnotationValid("{\"user\":\"other\",\"groups\":[\"devops\"]}")
+	assert.ErrorContains(t, err, "user other is not a member of group devops")
+
+	err = ah.IsAnnotationValid("{\"user\":\"unknown\",\"groups\":[]}")
+	assert.ErrorContains(t, err, "user unknown is not known to the identity service")
+
+	// all users are cached, including the unknown one
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(3))
+	err = ah.IsAnnotationValid(testAnnotation)
+	assert.NilError(t, err)
+	err = ah.IsAnnotationValid("{\"user\":\"unknown\",\"groups\":[]}")
+	assert.ErrorContains(t, err, "is not known")
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(3))
+}
+
+func TestIdentityServiceCacheExpiry(t *testing.T) {
+	var calls int32
+	server := newIdentityServer(t, map[string][]string{
+		"test": {"devops", "system:authenticated"},
+	}, &calls)
+	defer server.Close()
+
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL:      server.URL,
+		conf.AMAccessControlIdentityServiceCacheTTL: "1ms",
+	})
+
+	assert.NilError(t, ah.IsAnnotationValid(testAnnotation))
+	time.Sleep(5 * time.Millisecond)
+	assert.NilError(t, ah.IsAnnotationValid(testAnnotation))
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestIdentityServiceUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	// default policy is to fail
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL: server.URL,
+	})
+	err := ah.IsAnnotationValid(testAnnotation)
+	assert.ErrorContains(t, err, "unable to verify user test with identity service")
+
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL:           server.URL,
+		conf.AMAccessControlIdentityServiceFailurePolicy: conf.FailurePolicyIgnore,
+	})
+	err = ah.IsAnnotationValid(testAnnotation)
+	assert.NilError(t, err)
+}
+
+func TestIdentityServiceResponse(t *testing.T) {
+	var identity IdentityResponse
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		err := json.NewEncoder(w).Encode(&identity)
+		assert.NilError(t, err)
+	}))
+	defer server.Close()
+
+	// the user is added to the query of the service URL
+	identity = IdentityResponse{User: "test", Groups: []string{"devops", "system:authenticated"}}
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL: server.URL + "/lookup?realm=corp",
+	})
+	err := ah.IsAnnotationValid(testAnnotation)
+	assert.NilError(t, err)
+	assert.Equal(t, query, "realm=corp&user=test")
+
+	// the identity of another user is rejected
+	identity = IdentityResponse{User: "other", Groups: []string{"devops", "system:authenticated"}}
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL: server.URL,
+	})
+	err = ah.IsAnnotationValid(testAnnotation)
+	assert.ErrorContains(t, err, "identity service returned user other for user test")
+
+	// oversized identities are rejected
+	identity = IdentityResponse{User: "test", Groups: []string{strings.Repeat("x", maxIdentityResponseSize)}}
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL: server.URL,
+	})
+	err = ah.IsAnnotationValid(testAnnotation)
+	assert.ErrorContains(t, err, "identity of user test exceeds")
+}
+
+func TestIdentityServiceTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL:     server.URL,
+		conf.AMAccessControlIdentityServiceTimeout: "50ms",
+	})
+	err := ah.IsAnnotationValid(testAnnotation)
+	assert.ErrorContains(t, err, "unable to verify user test with identity service")
+}
+
+func TestIdentityServiceToken(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		err := json.NewEncoder(w).Encode(&IdentityResponse{User: "test", Groups: []string{"devops", "system:authenticated"}})
+		assert.NilError(t, err)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NilError(t, os.WriteFile(tokenFile, []byte("secret-token\n"), 0600))
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL:       server.URL,
+		conf.AMAccessControlIdentityServiceTokenFile: tokenFile,
+	})
+	err := ah.IsAnnotationValid(testAnnotation)
+	assert.NilError(t, err)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// missing token file applies the failure policy
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlIdentityServiceURL:       server.URL,
+		conf.AMAccessControlIdentityServiceTokenFile: filepath.Join(t.TempDir(), "missing"),
+	})
+	err = ah.IsAnnotationValid(testAnnotation)
+	assert.ErrorContains(t, err, "unable to read identity service token")
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+}
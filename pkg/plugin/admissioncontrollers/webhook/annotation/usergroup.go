@@ -20,6 +20,7 @@ package annotation
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
 
@@ -27,7 +28,6 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
-	batchv1Beta "k8s.io/api/batch/v1beta1"
 
 	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
 
@@ -36,12 +36,14 @@ import (
 )
 
 type UserGroupAnnotationHandler struct {
-	conf *conf.AdmissionControllerConf
+	conf     *conf.AdmissionControllerConf
+	verifier *IdentityVerifier
 }
 
 func NewUserGroupAnnotationHandler(conf *conf.AdmissionControllerConf) *UserGroupAnnotationHandler {
 	return &UserGroupAnnotationHandler{
-		conf: conf,
+		conf:     conf,
+		verifier: NewIdentityVerifier(conf),
 	}
 }
 
@@ -53,7 +55,7 @@ var (
 	StatefulSet = reflect.TypeOf(appsv1.StatefulSet{}).Name()
 	ReplicaSet  = reflect.TypeOf(appsv1.ReplicaSet{}).Name()
 	Job         = reflect.TypeOf(batchv1.Job{}).Name()
-	CronJob     = reflect.TypeOf(batchv1Beta.CronJob{}).Name()
+	CronJob     = reflect.TypeOf(batchv1.CronJob{}).Name()
 
 	extractors = map[string]Extractor{
 		Deployment:  fromDeployment,
@@ -65,9 +67,11 @@ var (
 	}
 )
 
-func (u *UserGroupAnnotationHandler) IsAnnotationAllowed(userName string, groups []string) bool {
-	if u.conf.GetTrustControllers() {
-		for _, sysUser := range u.conf.GetSystemUsers() {
+// IsAccessAllowed checks the submitter in the namespace against the given access control view, so that a single
+// admission decision does not mix the user lists of two configurations.
+func (u *UserGroupAnnotationHandler) IsAccessAllowed(access *conf.AccessControl, namespace string, userName string, groups []string) bool {
+	if access.TrustControllers {
+		for _, sysUser := range access.SystemUsers {
 			if sysUser.MatchString(userName) {
 				log.Logger().Debug("Request submitted from a system user, bypassing",
 					zap.String("userName", userName))
@@ -76,7 +80,7 @@ func (u *UserGroupAnnotationHandler) IsAnnotationAllowed(userName string, groups
 		}
 	}
 
-	for _, allowedUser := range u.conf.GetExternalUsers() {
+	for _, allowedUser := range access.ExternalUsers {
 		if allowedUser.MatchString(userName) {
 			log.Logger().Debug("Request submitted from an allowed external user",
 				zap.String("userName", userName))
@@ -84,7 +88,7 @@ func (u *UserGroupAnnotationHandler) IsAnnotationAllowed(userName string, groups
 		}
 	}
 
-	for _, allowedGroup := range u.conf.GetExternalGroups() {
+	for _, allowedGroup := range access.ExternalGroups {
 		for _, group := range groups {
 			if allowedGroup.MatchString(group) {
 				log.Logger().Debug("Request submitted from an allowed external group",
@@ -95,22 +99,88 @@ func (u *UserGroupAnnotationHandler) IsAnnotationAllowed(userName string, groups
 		}
 	}
 
+	for _, identity := range access.TrustedIdentities {
+		if identity.Matches(namespace, userName, groups) {
+			log.Logger().Debug("Request submitted from a trusted identity",
+				zap.String("userName", userName),
+				zap.String("namespace", namespace))
+			return true
+		}
+	}
+
 	return false
 }
 
+// UserInfoAnnotationKeys returns the keys of the annotations that carry user info. The scheduler reads the user info
+// from the default key, which is returned as well if a different key is configured.
+func (u *UserGroupAnnotationHandler) UserInfoAnnotationKeys() []string {
+	key := u.conf.GetUserInfoAnnotation()
+	if key == conf.DefaultAccessControlUserInfoAnnotation {
+		return []string{key}
+	}
+	return []string{key, conf.DefaultAccessControlUserInfoAnnotation}
+}
+
 func (u *UserGroupAnnotationHandler) IsAnnotationValid(userInfoAnnotation string) error {
+	if err := validateStructure(userInfoAnnotation); err != nil {
+		return err
+	}
+
 	var userGroups si.UserGroupInformation
 	err := json.Unmarshal([]byte(userInfoAnnotation), &userGroups)
 	if err != nil {
 		return err
 	}
 
+	if maxGroups := u.conf.GetUserInfoMaxGroups(); maxGroups > 0 && len(userGroups.Groups) > maxGroups {
+		return fmt.Errorf("user info annotation lists %d groups, the maximum is %d", len(userGroups.Groups), maxGroups)
+	}
+
+	if err = u.verifier.Verify(&userGroups); err != nil {
+		return err
+	}
+
 	log.Logger().Debug("Successfully validated user info annotation", zap.String("externally provided user", userGroups.User),
 		zap.String("externally provided groups", strings.Join(userGroups.Groups, ",")))
 
 	return nil
 }
 
+// validateStructure checks the shape of the user info annotation: a JSON object with a non-empty user string and a
+// groups array of strings. A value of the wrong type would otherwise be silently dropped when unmarshalling.
+func validateStructure(userInfoAnnotation string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(userInfoAnnotation), &fields); err != nil {
+		return err
+	}
+
+	rawUser, ok := fields["user"]
+	if !ok {
+		return fmt.Errorf("user info annotation is missing the user field")
+	}
+	var user string
+	if err := json.Unmarshal(rawUser, &user); err != nil || isJSONNull(rawUser) {
+		return fmt.Errorf("user field of the user info annotation must be a string")
+	}
+	if user == "" {
+		return fmt.Errorf("user field of the user info annotation must not be empty")
+	}
+
+	rawGroups, ok := fields["groups"]
+	if !ok {
+		return fmt.Errorf("user info annotation is missing the groups field")
+	}
+	var groups []string
+	if err := json.Unmarshal(rawGroups, &groups); err != nil || isJSONNull(rawGroups) {
+		return fmt.Errorf("groups field of the user info annotation must be an array of strings")
+	}
+	return nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return strings.TrimSpace(string(raw)) == "null"
+}
+
 func (u *UserGroupAnnotationHandler) GetAnnotationsFromRequestKind(kind string, req *admissionv1.AdmissionRequest) (map[string]string, bool, error) {
 	extractFn, ok := extractors[kind]
 	if !ok {
@@ -120,6 +190,21 @@ func (u *UserGroupAnnotationHandler) GetAnnotationsFromRequestKind(kind string,
 	return result, true, err
 }
 
+// GetWorkloadAnnotations returns the annotations of each pod template of the workload in the request. Handlers
+// registered for the group and kind take precedence over the built-in kinds. The second return value is false if the
+// kind is not supported.
+func (u *UserGroupAnnotationHandler) GetWorkloadAnnotations(req *admissionv1.AdmissionRequest) ([]map[string]string, bool, error) {
+	if handler := getWorkloadHandler(req.Kind.Group, req.Kind.Kind); handler != nil {
+		result, err := handler(req)
+		return result, true, err
+	}
+	annotations, supported, err := u.GetAnnotationsFromRequestKind(req.Kind.Kind, req)
+	if !supported || err != nil {
+		return nil, supported, err
+	}
+	return []map[string]string{annotations}, true, nil
+}
+
 func fromDeployment(req *admissionv1.AdmissionRequest) (map[string]string, error) {
 	var deployment appsv1.Deployment
 	err := json.Unmarshal(req.Object.Raw, &deployment)
@@ -170,8 +255,10 @@ func fromJob(req *admissionv1.AdmissionRequest) (map[string]string, error) {
 	return job.Spec.Template.Annotations, nil
 }
 
+// fromCronJob extracts the annotations of the pod template nested in the job template. The batch/v1beta1 and
+// batch/v1 versions share the same structure, so both are decoded by the batch/v1 type.
 func fromCronJob(req *admissionv1.AdmissionRequest) (map[string]string, error) {
-	var cronJob batchv1Beta.CronJob
+	var cronJob batchv1.CronJob
 	err := json.Unmarshal(req.Object.Raw, &cronJob)
 	if err != nil {
 		return nil, err
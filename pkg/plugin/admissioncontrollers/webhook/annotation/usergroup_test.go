@@ -57,9 +57,55 @@ func TestValidateAnnotation(t *testing.T) {
 	assert.NilError(t, err)
 }
 
+func TestValidateAnnotationStructure(t *testing.T) {
+	ah := getAnnotationHandler()
+	tests := map[string]string{
+		"[]":                        "cannot unmarshal array",
+		"{\"groups\":[\"devops\"]}": "user info annotation is missing the user field",
+		"{\"user\":null,\"groups\":[\"devops\"]}":   "user field of the user info annotation must be a string",
+		"{\"user\":42,\"groups\":[\"devops\"]}":     "user field of the user info annotation must be a string",
+		"{\"user\":\"\",\"groups\":[\"devops\"]}":   "user field of the user info annotation must not be empty",
+		"{\"user\":\"test\"}":                       "user info annotation is missing the groups field",
+		"{\"user\":\"test\",\"groups\":\"devops\"}": "groups field of the user info annotation must be an array of strings",
+		"{\"user\":\"test\",\"groups\":null}":       "groups field of the user info annotation must be an array of strings",
+		"{\"user\":\"test\",\"groups\":[\"a\",1]}":  "groups field of the user info annotation must be an array of strings",
+	}
+	for value, expected := range tests {
+		t.Run(value, func(t *testing.T) {
+			assert.ErrorContains(t, ah.IsAnnotationValid(value), expected)
+		})
+	}
+
+	assert.NilError(t, ah.IsAnnotationValid(testAnnotation))
+	assert.NilError(t, ah.IsAnnotationValid("{\"user\":\"test\",\"groups\":[]}"))
+}
+
+func TestValidateAnnotationMaxGroups(t *testing.T) {
+	// testAnnotation lists two groups
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlUserInfoMaxGroups: "2",
+	})
+	assert.NilError(t, ah.IsAnnotationValid(testAnnotation))
+
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlUserInfoMaxGroups: "1",
+	})
+	assert.ErrorContains(t, ah.IsAnnotationValid(testAnnotation), "user info annotation lists 2 groups, the maximum is 1")
+}
+
+func TestUserInfoAnnotationKeys(t *testing.T) {
+	ah := getAnnotationHandler()
+	assert.DeepEqual(t, ah.UserInfoAnnotationKeys(), []string{"yunikorn.apache.org/user.info"})
+
+	ah = getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlUserInfoAnnotation: "example.com/submitter",
+	})
+	assert.DeepEqual(t, ah.UserInfoAnnotationKeys(), []string{"example.com/submitter", "yunikorn.apache.org/user.info"})
+}
+
 func TestBypassControllers(t *testing.T) {
 	ah := getAnnotationHandler()
-	allowed := ah.IsAnnotationAllowed("system:serviceaccount:kube-system:job-controller", groups)
+	allowed := ah.IsAccessAllowed(ah.conf.GetAccessControl(), "default", "system:serviceaccount:kube-system:job-controller", groups)
 	assert.Assert(t, allowed)
 }
 
@@ -67,7 +113,7 @@ func TestExternalUsers(t *testing.T) {
 	ah := getAnnotationHandlerWithOverrides(map[string]string{
 		conf.AMAccessControlExternalUsers: "yunikorn",
 	})
-	allowed := ah.IsAnnotationAllowed("yunikorn", groups)
+	allowed := ah.IsAccessAllowed(ah.conf.GetAccessControl(), "default", "yunikorn", groups)
 	assert.Assert(t, allowed)
 }
 
@@ -75,13 +121,29 @@ func TestExternalGroups(t *testing.T) {
 	ah := getAnnotationHandlerWithOverrides(map[string]string{
 		conf.AMAccessControlExternalGroups: "devs",
 	})
-	allowed := ah.IsAnnotationAllowed(userName, groups)
+	allowed := ah.IsAccessAllowed(ah.conf.GetAccessControl(), "default", userName, groups)
 	assert.Assert(t, allowed)
 }
 
+func TestTrustedIdentities(t *testing.T) {
+	ah := getAnnotationHandlerWithOverrides(map[string]string{
+		conf.AMAccessControlTrustedIdentities: `[
+			{"users": ["system:serviceaccount:spark-*:spark-operator"], "namespaces": ["spark-*"]},
+			{"groups": ["regex:^ci-(build|test)$"]}
+		]`,
+	})
+	access := ah.conf.GetAccessControl()
+	assert.Assert(t, ah.IsAccessAllowed(access, "spark-a", "system:serviceaccount:spark-a:spark-operator", nil))
+	assert.Assert(t, ah.IsAccessAllowed(access, "spark-b", "system:serviceaccount:spark-a:spark-operator", nil))
+	assert.Assert(t, !ah.IsAccessAllowed(access, "default", "system:serviceaccount:spark-a:spark-operator", nil), "trusted outside of namespaces")
+	assert.Assert(t, !ah.IsAccessAllowed(access, "spark-a", "system:serviceaccount:spark-a:spark-operator-2", nil), "glob not anchored")
+	assert.Assert(t, ah.IsAccessAllowed(access, "default", userName, []string{"devs", "ci-test"}))
+	assert.Assert(t, !ah.IsAccessAllowed(access, "default", userName, []string{"ci-deploy"}), "group trusted")
+}
+
 func TestExternalAuthenticationDenied(t *testing.T) {
 	ah := getAnnotationHandler()
-	allowed := ah.IsAnnotationAllowed("yunikorn", groups)
+	allowed := ah.IsAccessAllowed(ah.conf.GetAccessControl(), "default", "yunikorn", groups)
 	assert.Assert(t, !allowed)
 }
 
@@ -168,6 +230,22 @@ func TestGetAnnotationFromRequest(t *testing.T) {
 			},
 			kind: CronJob,
 		},
+		{
+			obj: &batchv1.CronJob{
+				Spec: batchv1.CronJobSpec{
+					JobTemplate: batchv1.JobTemplateSpec{
+						Spec: batchv1.JobSpec{
+							Template: v1.PodTemplateSpec{
+								ObjectMeta: metav1.ObjectMeta{
+									Annotations: annotation,
+								},
+							},
+						},
+					},
+				},
+			},
+			kind: CronJob,
+		},
 	}
 	ah := getAnnotationHandler()
 
@@ -234,15 +312,13 @@ func getAnnotationHandler() *UserGroupAnnotationHandler {
 }
 
 func getAnnotationHandlerWithOverrides(overrides map[string]string) *UserGroupAnnotationHandler {
-	return &UserGroupAnnotationHandler{
-		conf: conf.NewAdmissionControllerConf([]*v1.ConfigMap{{
-			Data: map[string]string{
-				conf.AMAccessControlSystemUsers:    "system:serviceaccount:kube-system:*",
-				conf.AMAccessControlExternalUsers:  "",
-				conf.AMAccessControlExternalGroups: "",
-			},
-		}, {
-			Data: overrides,
-		}}),
-	}
+	return NewUserGroupAnnotationHandler(conf.NewAdmissionControllerConf([]*v1.ConfigMap{{
+		Data: map[string]string{
+			conf.AMAccessControlSystemUsers:    "system:serviceaccount:kube-system:*",
+			conf.AMAccessControlExternalUsers:  "",
+			conf.AMAccessControlExternalGroups: "",
+		},
+	}, {
+		Data: overrides,
+	}}))
 }
@@ -0,0 +1,143 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WorkloadHandler returns the annotations of each pod template of a workload. Workloads which create pods from more
+// than one template, like the driver and executors of a Spark application, return the annotations of every template.
+type WorkloadHandler func(*admissionv1.AdmissionRequest) ([]map[string]string, error)
+
+var (
+	workloadHandlersLock sync.RWMutex
+	workloadHandlers     = map[schema.GroupKind]WorkloadHandler{}
+)
+
+func init() {
+	RegisterWorkloadHandler("sparkoperator.k8s.io", "SparkApplication",
+		TemplateAnnotations("spec.driver.annotations", "spec.executor.annotations"))
+	RegisterWorkloadHandler("sparkoperator.k8s.io", "ScheduledSparkApplication",
+		TemplateAnnotations("spec.template.driver.annotations", "spec.template.executor.annotations"))
+	RegisterWorkloadHandler("flink.apache.org", "FlinkDeployment",
+		TemplateAnnotations("spec.podTemplate.metadata.annotations",
+			"spec.jobManager.podTemplate.metadata.annotations",
+			"spec.taskManager.podTemplate.metadata.annotations"))
+	RegisterWorkloadHandler("ray.io", "RayCluster",
+		TemplateAnnotations("spec.headGroupSpec.template.metadata.annotations",
+			"spec.workerGroupSpecs.*.template.metadata.annotations"))
+	RegisterWorkloadHandler("ray.io", "RayJob",
+		TemplateAnnotations("spec.rayClusterSpec.headGroupSpec.template.metadata.annotations",
+			"spec.rayClusterSpec.workerGroupSpecs.*.template.metadata.annotations"))
+	RegisterWorkloadHandler("kubeflow.org", "TFJob",
+		TemplateAnnotations("spec.tfReplicaSpecs.*.template.metadata.annotations"))
+	RegisterWorkloadHandler("kubeflow.org", "PyTorchJob",
+		TemplateAnnotations("spec.pytorchReplicaSpecs.*.template.metadata.annotations"))
+	RegisterWorkloadHandler("kubeflow.org", "MPIJob",
+		TemplateAnnotations("spec.mpiReplicaSpecs.*.template.metadata.annotations"))
+	RegisterWorkloadHandler("kubeflow.org", "XGBoostJob",
+		TemplateAnnotations("spec.xgbReplicaSpecs.*.template.metadata.annotations"))
+}
+
+// RegisterWorkloadHandler registers the handler for the kind in the API group, replacing an earlier registration.
+func RegisterWorkloadHandler(group string, kind string, handler WorkloadHandler) {
+	workloadHandlersLock.Lock()
+	defer workloadHandlersLock.Unlock()
+	workloadHandlers[schema.GroupKind{Group: group, Kind: kind}] = handler
+}
+
+func getWorkloadHandler(group string, kind string) WorkloadHandler {
+	workloadHandlersLock.RLock()
+	defer workloadHandlersLock.RUnlock()
+	return workloadHandlers[schema.GroupKind{Group: group, Kind: kind}]
+}
+
+// TemplateAnnotations creates a handler which locates the annotations of the pod templates by their field paths in the
+// object. Path elements are separated by a dot, a "*" element matches every entry of a list or map. Paths that are not
+// set in the object are skipped.
+func TemplateAnnotations(paths ...string) WorkloadHandler {
+	return func(req *admissionv1.AdmissionRequest) ([]map[string]string, error) {
+		var object map[string]interface{}
+		if err := json.Unmarshal(req.Object.Raw, &object); err != nil {
+			return nil, err
+		}
+		var result []map[string]string
+		for _, path := range paths {
+			for _, value := range findFields(object, strings.Split(path, ".")) {
+				annotations, err := toAnnotations(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid annotations at %s: %v", path, err)
+				}
+				result = append(result, annotations)
+			}
+		}
+		return result, nil
+	}
+}
+
+// findFields returns the values at the path, expanding "*" elements to all entries of a list or map.
+func findFields(value interface{}, path []string) []interface{} {
+	if value == nil {
+		return nil
+	}
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+	var result []interface{}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if path[0] != "*" {
+			return findFields(typed[path[0]], path[1:])
+		}
+		for _, entry := range typed {
+			result = append(result, findFields(entry, path[1:])...)
+		}
+	case []interface{}:
+		if path[0] != "*" {
+			return nil
+		}
+		for _, entry := range typed {
+			result = append(result, findFields(entry, path[1:])...)
+		}
+	}
+	return result
+}
+
+func toAnnotations(value interface{}) (map[string]string, error) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map but got %T", value)
+	}
+	annotations := make(map[string]string, len(entries))
+	for key, entry := range entries {
+		str, ok := entry.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is not a string", key)
+		}
+		annotations[key] = str
+	}
+	return annotations, nil
+}
@@ -0,0 +1,102 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package annotation
+
+import (
+	"sort"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetWorkloadAnnotations(t *testing.T) {
+	rayCluster := `{"spec": {
+		"headGroupSpec": {"template": {"metadata": {"annotations": {"key": "head"}}}},
+		"workerGroupSpecs": [
+			{"template": {"metadata": {"annotations": {"key": "small"}}}},
+			{"template": {"spec": {}}},
+			{"template": {"metadata": {"annotations": {"key": "large"}}}}
+		]}}`
+	sparkApp := `{"spec": {"driver": {"annotations": {"key": "driver"}}, "executor": {}}}`
+	pyTorchJob := `{"spec": {"pytorchReplicaSpecs": {
+		"Master": {"template": {"metadata": {"annotations": {"key": "master"}}}},
+		"Worker": {"template": {"metadata": {"annotations": {"key": "worker"}}}}}}}`
+	tests := []struct {
+		name     string
+		kind     metav1.GroupVersionKind
+		raw      string
+		expected []string
+	}{
+		{"ray cluster", metav1.GroupVersionKind{Group: "ray.io", Version: "v1", Kind: "RayCluster"}, rayCluster, []string{"head", "large", "small"}},
+		{"spark application", metav1.GroupVersionKind{Group: "sparkoperator.k8s.io", Version: "v1beta2", Kind: "SparkApplication"}, sparkApp, []string{"driver"}},
+		{"kubeflow job", metav1.GroupVersionKind{Group: "kubeflow.org", Version: "v1", Kind: "PyTorchJob"}, pyTorchJob, []string{"master", "worker"}},
+		{"built-in kind", metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: Deployment}, `{"spec": {"template": {"metadata": {"annotations": {"key": "deployment"}}}}}`, []string{"deployment"}},
+	}
+	ah := getAnnotationHandler()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &admissionv1.AdmissionRequest{Kind: tc.kind, Object: runtime.RawExtension{Raw: []byte(tc.raw)}}
+			templates, supported, err := ah.GetWorkloadAnnotations(req)
+			assert.NilError(t, err)
+			assert.Assert(t, supported)
+			var values []string
+			for _, annotations := range templates {
+				values = append(values, annotations["key"])
+			}
+			sort.Strings(values)
+			assert.DeepEqual(t, values, tc.expected)
+		})
+	}
+
+	// unknown kinds and kinds in other groups are not supported
+	req := &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Group: "example.com", Kind: "RayCluster"}}
+	_, supported, err := ah.GetWorkloadAnnotations(req)
+	assert.NilError(t, err)
+	assert.Assert(t, !supported)
+
+	// annotations must be a map of strings
+	req = &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Group: "sparkoperator.k8s.io", Kind: "SparkApplication"},
+		Object: runtime.RawExtension{Raw: []byte(`{"spec": {"driver": {"annotations": {"key": 1}}}}`)},
+	}
+	_, supported, err = ah.GetWorkloadAnnotations(req)
+	assert.Assert(t, supported)
+	assert.ErrorContains(t, err, "invalid annotations at spec.driver.annotations: value of key is not a string")
+}
+
+func TestRegisterWorkloadHandler(t *testing.T) {
+	RegisterWorkloadHandler("example.com", "Workload", TemplateAnnotations("spec.pods.*.metadata.annotations"))
+	defer func() {
+		workloadHandlersLock.Lock()
+		delete(workloadHandlers, schema.GroupKind{Group: "example.com", Kind: "Workload"})
+		workloadHandlersLock.Unlock()
+	}()
+	req := &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Workload"},
+		Object: runtime.RawExtension{Raw: []byte(`{"spec": {"pods": [{"metadata": {"annotations": {"key": "value"}}}]}}`)},
+	}
+	templates, supported, err := getAnnotationHandler().GetWorkloadAnnotations(req)
+	assert.NilError(t, err)
+	assert.Assert(t, supported)
+	assert.DeepEqual(t, templates, []map[string]string{{"key": "value"}})
+}
@@ -0,0 +1,85 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// appIDIndex tracks the namespace in which each application ID was last admitted. The index is bounded, the least
+// recently used application IDs are dropped first. Entries expire when no pod of the application was admitted within
+// the TTL. The admission controller does not see pod deletes or pods which are not persisted after admission, the
+// expiry releases those application IDs. It is kept in memory, each admission controller instance has its own index.
+type appIDIndex struct {
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+
+	sync.Mutex
+}
+
+type appIDEntry struct {
+	appID     string
+	namespace string
+	lastUsed  time.Time
+}
+
+func newAppIDIndex() *appIDIndex {
+	return &appIDIndex{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// claim records the application ID for the namespace. If the application ID is already in use by another namespace
+// the index is not changed and the other namespace is returned with false. An entry which was not used within the TTL
+// is released and can be claimed by any namespace. A TTL of zero or less never expires entries. A maximum size of zero
+// or less leaves the index unbounded.
+func (idx *appIDIndex) claim(appID string, namespace string, maxSize int, ttl time.Duration) (string, bool) {
+	idx.Lock()
+	defer idx.Unlock()
+	now := idx.now()
+	if elem, ok := idx.entries[appID]; ok {
+		entry := elem.Value.(*appIDEntry)
+		expired := ttl > 0 && now.Sub(entry.lastUsed) > ttl
+		if entry.namespace != namespace && !expired {
+			return entry.namespace, false
+		}
+		entry.namespace = namespace
+		entry.lastUsed = now
+		idx.order.MoveToFront(elem)
+		return namespace, true
+	}
+	idx.entries[appID] = idx.order.PushFront(&appIDEntry{appID: appID, namespace: namespace, lastUsed: now})
+	for maxSize > 0 && idx.order.Len() > maxSize {
+		oldest := idx.order.Back()
+		idx.order.Remove(oldest)
+		delete(idx.entries, oldest.Value.(*appIDEntry).appID)
+	}
+	return namespace, true
+}
+
+func (idx *appIDIndex) size() int {
+	idx.Lock()
+	defer idx.Unlock()
+	return idx.order.Len()
+}
@@ -0,0 +1,203 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+const (
+	auditLoggerName = "audit"
+	// entries waiting to be posted by the HTTP sink, further entries are dropped
+	auditHTTPQueueSize = 1000
+	auditHTTPTimeout   = 5 * time.Second
+)
+
+// auditEntry is the record of a single admission decision. Only the user name and groups of the submitter are
+// recorded, in line with what is logged for each request.
+type auditEntry struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name,omitempty"`
+	Kind      string          `json:"kind"`
+	Operation string          `json:"operation"`
+	Username  string          `json:"username"`
+	Groups    []string        `json:"groups"`
+	Allowed   bool            `json:"allowed"`
+	Reason    string          `json:"reason"`
+	Patch     json.RawMessage `json:"patch,omitempty"`
+}
+
+// auditSink receives an entry for every admission decision taken by the admission controller.
+type auditSink interface {
+	record(entry *auditEntry)
+}
+
+// newAuditSink creates the configured audit sink. Without a sink no decisions are recorded.
+func newAuditSink(amConf *conf.AdmissionControllerConf) (auditSink, error) {
+	target := amConf.GetAuditSinkTarget()
+	switch amConf.GetAuditSink() {
+	case conf.AuditSinkNone:
+		return nil, nil
+	case conf.AuditSinkStdout:
+		return newWriterAuditSink(os.Stdout), nil
+	case conf.AuditSinkFile:
+		if target == "" {
+			return nil, fmt.Errorf("audit sink %s requires a target file", conf.AuditSinkFile)
+		}
+		file, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return newWriterAuditSink(file), nil
+	case conf.AuditSinkHTTP:
+		if target == "" {
+			return nil, fmt.Errorf("audit sink %s requires a target URL", conf.AuditSinkHTTP)
+		}
+		return newHTTPAuditSink(target), nil
+	default:
+		return newLoggerAuditSink(), nil
+	}
+}
+
+// loggerAuditSink writes the audit entries to a dedicated named logger, separate from the debug logging.
+type loggerAuditSink struct {
+	logger *zap.Logger
+}
+
+func newLoggerAuditSink() *loggerAuditSink {
+	return &loggerAuditSink{logger: log.Logger().Named(auditLoggerName)}
+}
+
+func (s *loggerAuditSink) record(entry *auditEntry) {
+	s.logger.Info("admission decision", zap.Any("decision", entry))
+}
+
+// writerAuditSink writes the audit entries as JSON lines, for instance to stdout or a file.
+type writerAuditSink struct {
+	encoder *json.Encoder
+
+	sync.Mutex
+}
+
+func newWriterAuditSink(w io.Writer) *writerAuditSink {
+	return &writerAuditSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *writerAuditSink) record(entry *auditEntry) {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.encoder.Encode(entry); err != nil {
+		log.Logger().Warn("Unable to write audit entry", zap.String("uid", entry.UID), zap.Error(err))
+	}
+}
+
+// httpAuditSink posts each audit entry as JSON to an HTTP endpoint. Entries are posted in the background so that the
+// endpoint does not delay admissions. If the endpoint cannot keep up entries are dropped.
+type httpAuditSink struct {
+	url     string
+	client  *http.Client
+	entries chan *auditEntry
+}
+
+func newHTTPAuditSink(url string) *httpAuditSink {
+	s := &httpAuditSink{
+		url:     url,
+		client:  &http.Client{Timeout: auditHTTPTimeout},
+		entries: make(chan *auditEntry, auditHTTPQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+func (s *httpAuditSink) record(entry *auditEntry) {
+	select {
+	case s.entries <- entry:
+	default:
+		log.Logger().Warn("Audit queue is full, dropping audit entry", zap.String("uid", entry.UID))
+	}
+}
+
+func (s *httpAuditSink) run() {
+	for entry := range s.entries {
+		if err := s.post(entry); err != nil {
+			log.Logger().Warn("Unable to post audit entry", zap.String("uid", entry.UID), zap.Error(err))
+		}
+	}
+}
+
+func (s *httpAuditSink) post(entry *auditEntry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("audit endpoint returned status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// newAuditEntry builds the audit entry for the response to the request. A request which could not be decoded is
+// recorded with the response details only.
+func newAuditEntry(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) *auditEntry {
+	entry := &auditEntry{
+		UID:     string(resp.UID),
+		Allowed: resp.Allowed,
+		Patch:   resp.Patch,
+	}
+	if resp.Result != nil {
+		entry.Reason = resp.Result.Message
+	}
+	if req != nil {
+		entry.UID = string(req.UID)
+		entry.Namespace = req.Namespace
+		entry.Name = requestObjectMetadata(req).Name
+		entry.Kind = req.Kind.Kind
+		entry.Operation = string(req.Operation)
+		entry.Username = req.UserInfo.Username
+		entry.Groups = req.UserInfo.Groups
+	}
+	return entry
+}
+
+// audit passes the decision to the audit sink, if one is set.
+func (c *admissionController) audit(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if c.auditSink == nil || resp == nil {
+		return
+	}
+	c.auditSink.record(newAuditEntry(req, resp))
+}
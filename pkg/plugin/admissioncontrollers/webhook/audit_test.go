@@ -0,0 +1,160 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+type recordingAuditSink struct {
+	entries []*auditEntry
+}
+
+func (s *recordingAuditSink) record(entry *auditEntry) {
+	s.entries = append(s.entries, entry)
+}
+
+func TestAuditDecisions(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	sink := &recordingAuditSink{}
+	ac.auditSink = sink
+	serve := func(body []byte) {
+		r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		assert.Equal(t, w.Code, http.StatusOK)
+	}
+
+	// allowed request
+	req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}})
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}, UID: "secret-uid"}
+	serve(admissionReviewBody(t, req))
+	assert.Equal(t, len(sink.entries), 1)
+	entry := *sink.entries[0]
+	assert.Check(t, len(entry.Patch) != 0, "patch not recorded")
+	entry.Patch = nil
+	assert.DeepEqual(t, entry, auditEntry{
+		UID:       "test-uid",
+		Namespace: "test-ns",
+		Kind:      "Pod",
+		Operation: "CREATE",
+		Username:  "test",
+		Groups:    []string{"dev"},
+		Allowed:   true,
+	})
+
+	// denied request
+	req = createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test-pod",
+		Namespace:   "test-ns",
+		Annotations: map[string]string{userInfoAnnotation: validUserInfoAnnotation},
+	}})
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	serve(admissionReviewBody(t, req))
+	assert.Equal(t, len(sink.entries), 2)
+	assert.Check(t, !sink.entries[1].Allowed, "denial recorded as allowed")
+	assert.Equal(t, sink.entries[1].Name, "test-pod")
+	assert.Equal(t, len(sink.entries[1].Patch), 0)
+	assert.Equal(t, sink.entries[1].Reason, "user test with groups [dev] is not allowed to set user annotation")
+
+	// undecodable request
+	serve([]byte("{"))
+	assert.Equal(t, len(sink.entries), 3)
+	assert.DeepEqual(t, *sink.entries[2], auditEntry{UID: "yunikorn-invalid-body", Reason: "body decode failed"})
+
+	// no sink
+	ac.auditSink = nil
+	serve(admissionReviewBody(t, req))
+}
+
+func TestNewAuditSink(t *testing.T) {
+	newSink := func(sink string, target string) (auditSink, error) {
+		return newAuditSink(createConfigWithOverrides(map[string]string{
+			conf.AMWebHookAuditSink:       sink,
+			conf.AMWebHookAuditSinkTarget: target,
+		}))
+	}
+	entry := &auditEntry{UID: "test-uid", Namespace: "test-ns", Allowed: true, Patch: json.RawMessage(`[]`)}
+
+	sink, err := newSink(conf.AuditSinkNone, "")
+	assert.NilError(t, err)
+	assert.Check(t, sink == nil, "sink created")
+	sink, err = newSink(conf.AuditSinkLog, "")
+	assert.NilError(t, err)
+	_, ok := sink.(*loggerAuditSink)
+	assert.Check(t, ok, "unexpected sink type %T", sink)
+	sink, err = newSink(conf.AuditSinkStdout, "")
+	assert.NilError(t, err)
+	_, ok = sink.(*writerAuditSink)
+	assert.Check(t, ok, "unexpected sink type %T", sink)
+
+	// file sink appends JSON lines
+	_, err = newSink(conf.AuditSinkFile, "")
+	assert.ErrorContains(t, err, "requires a target file")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err = newSink(conf.AuditSinkFile, path)
+	assert.NilError(t, err)
+	sink.record(entry)
+	sink.record(entry)
+	content, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, len(lines), 2)
+	var written auditEntry
+	assert.NilError(t, json.Unmarshal([]byte(lines[0]), &written))
+	assert.DeepEqual(t, written, *entry)
+
+	// HTTP sink posts each entry
+	_, err = newSink(conf.AuditSinkHTTP, "")
+	assert.ErrorContains(t, err, "requires a target URL")
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+	sink, err = newSink(conf.AuditSinkHTTP, srv.URL)
+	assert.NilError(t, err)
+	sink.record(entry)
+	select {
+	case body := <-received:
+		written = auditEntry{}
+		assert.NilError(t, json.Unmarshal(body, &written))
+		assert.DeepEqual(t, written, *entry)
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit entry not posted")
+	}
+}
@@ -19,8 +19,11 @@
 package conf
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +32,10 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/informers"
 	informersv1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -38,6 +45,7 @@ import (
 	"github.com/apache/yunikorn-k8shim/pkg/common/utils"
 	schedulerconf "github.com/apache/yunikorn-k8shim/pkg/conf"
 	"github.com/apache/yunikorn-k8shim/pkg/log"
+	siCommon "github.com/apache/yunikorn-scheduler-interface/lib/go/common"
 )
 
 const (
@@ -45,63 +53,546 @@ const (
 	WebHookPrefix             = AdmissionControllerPrefix + "webHook."
 	FilteringPrefix           = AdmissionControllerPrefix + "filtering."
 	AccessControlPrefix       = AdmissionControllerPrefix + "accessControl."
+	MutationPrefix            = AdmissionControllerPrefix + "mutation."
+	ValidationPrefix          = AdmissionControllerPrefix + "validation."
+	LoggingPrefix             = AdmissionControllerPrefix + "logging."
+
+	// queue of pods that are not placed by any other rule
+	AMDefaultQueue = AdmissionControllerPrefix + "defaultQueue"
 
 	// webhook configuration
-	AMWebHookAMServiceName           = WebHookPrefix + "amServiceName"
-	AMWebHookSchedulerServiceAddress = WebHookPrefix + "schedulerServiceAddress"
+	AMWebHookAMServiceName                  = WebHookPrefix + "amServiceName"
+	AMWebHookSchedulerServiceAddress        = WebHookPrefix + "schedulerServiceAddress"
+	AMWebHookDrainMode                      = WebHookPrefix + "drainMode"
+	AMWebHookDryRun                         = WebHookPrefix + "dryRun"
+	AMWebHookFailOnSchedulerUnreachable     = WebHookPrefix + "failOnSchedulerUnreachable"
+	AMWebHookSchedulerValidateTimeout       = WebHookPrefix + "schedulerValidateTimeout"
+	AMWebHookAcceptCompressedRequests       = WebHookPrefix + "acceptCompressedRequests"
+	AMWebHookMaxRequestSize                 = WebHookPrefix + "maxRequestSize"
+	AMWebHookSchedulerServiceScheme         = WebHookPrefix + "schedulerServiceScheme"
+	AMWebHookSchedulerValidateConfPath      = WebHookPrefix + "schedulerValidateConfPath"
+	AMWebHookSchedulerClientCertFile        = WebHookPrefix + "schedulerClientCertFile"
+	AMWebHookSchedulerClientKeyFile         = WebHookPrefix + "schedulerClientKeyFile"
+	AMWebHookSchedulerCAFile                = WebHookPrefix + "schedulerCAFile"
+	AMWebHookSchedulerMaxResponseSize       = WebHookPrefix + "schedulerMaxResponseSize"
+	AMWebHookSchedulerResponsePublicKeyFile = WebHookPrefix + "schedulerResponsePublicKeyFile"
+	AMWebHookEmitEvents                     = WebHookPrefix + "emitEvents"
+	AMWebHookAllowDebugAnnotation           = WebHookPrefix + "allowDebugAnnotation"
+	AMWebHookReadinessCheckScheduler        = WebHookPrefix + "readinessCheckScheduler"
+	AMWebHookReconcileInterval              = WebHookPrefix + "reconcileInterval"
+	AMWebHookLeaderElection                 = WebHookPrefix + "leaderElection"
+	AMWebHookAuditSink                      = WebHookPrefix + "auditSink"
+	AMWebHookAuditSinkTarget                = WebHookPrefix + "auditSinkTarget"
+	AMWebHookReadTimeout                    = WebHookPrefix + "readTimeout"
+	AMWebHookWriteTimeout                   = WebHookPrefix + "writeTimeout"
+	AMWebHookMaxConnections                 = WebHookPrefix + "maxConnections"
+	AMWebHookMaxInFlightRequests            = WebHookPrefix + "maxInFlightRequests"
+	AMWebHookRateLimitQPS                   = WebHookPrefix + "rateLimitQPS"
+	AMWebHookRateLimitBurst                 = WebHookPrefix + "rateLimitBurst"
+	AMWebHookClientRateLimitQPS             = WebHookPrefix + "clientRateLimitQPS"
+	AMWebHookClientRateLimitBurst           = WebHookPrefix + "clientRateLimitBurst"
+	AMWebHookShutdownDelay                  = WebHookPrefix + "shutdownDelay"
+	AMWebHookShutdownTimeout                = WebHookPrefix + "shutdownTimeout"
+	AMWebHookFailurePolicy                  = WebHookPrefix + "failurePolicy"
+	AMWebHookTimeoutSeconds                 = WebHookPrefix + "timeoutSeconds"
+	AMWebHookNamespaceSelector              = WebHookPrefix + "namespaceSelector"
+	AMWebHookDebugAddress                   = WebHookPrefix + "debugAddress"
 
 	// filtering configuration
-	AMFilteringProcessNamespaces = FilteringPrefix + "processNamespaces"
-	AMFilteringBypassNamespaces  = FilteringPrefix + "bypassNamespaces"
-	AMFilteringLabelNamespaces   = FilteringPrefix + "labelNamespaces"
-	AMFilteringNoLabelNamespaces = FilteringPrefix + "noLabelNamespaces"
+	AMFilteringProcessNamespaces        = FilteringPrefix + "processNamespaces"
+	AMFilteringBypassNamespaces         = FilteringPrefix + "bypassNamespaces"
+	AMFilteringLabelNamespaces          = FilteringPrefix + "labelNamespaces"
+	AMFilteringNoLabelNamespaces        = FilteringPrefix + "noLabelNamespaces"
+	AMFilteringNamespaceSource          = FilteringPrefix + "namespaceSource"
+	AMFilteringProcessPodSelector       = FilteringPrefix + "processPodSelector"
+	AMFilteringBypassPodSelector        = FilteringPrefix + "bypassPodSelector"
+	AMFilteringProcessNamespaceSelector = FilteringPrefix + "processNamespaceSelector"
+	AMFilteringBypassNamespaceSelector  = FilteringPrefix + "bypassNamespaceSelector"
+	AMFilteringLabelNamespaceSelector   = FilteringPrefix + "labelNamespaceSelector"
+	AMFilteringNoLabelNamespaceSelector = FilteringPrefix + "noLabelNamespaceSelector"
 
 	// access control configuration
-	AMAccessControlBypassAuth       = AccessControlPrefix + "bypassAuth"
-	AMAccessControlTrustControllers = AccessControlPrefix + "trustControllers"
-	AMAccessControlSystemUsers      = AccessControlPrefix + "systemUsers"
-	AMAccessControlExternalUsers    = AccessControlPrefix + "externalUsers"
-	AMAccessControlExternalGroups   = AccessControlPrefix + "externalGroups"
+	AMAccessControlBypassAuth                   = AccessControlPrefix + "bypassAuth"
+	AMAccessControlBypassAuthNamespaces         = AccessControlPrefix + "bypassAuthNamespaces"
+	AMAccessControlTrustControllers             = AccessControlPrefix + "trustControllers"
+	AMAccessControlSystemUsers                  = AccessControlPrefix + "systemUsers"
+	AMAccessControlExternalUsers                = AccessControlPrefix + "externalUsers"
+	AMAccessControlExternalGroups               = AccessControlPrefix + "externalGroups"
+	AMAccessControlTrustedIdentities            = AccessControlPrefix + "trustedIdentities"
+	AMAccessControlIdentityServiceURL           = AccessControlPrefix + "identityServiceURL"
+	AMAccessControlIdentityServiceTimeout       = AccessControlPrefix + "identityServiceTimeout"
+	AMAccessControlIdentityServiceCacheTTL      = AccessControlPrefix + "identityServiceCacheTTL"
+	AMAccessControlIdentityServiceFailurePolicy = AccessControlPrefix + "identityServiceFailurePolicy"
+	AMAccessControlIdentityServiceTokenFile     = AccessControlPrefix + "identityServiceTokenFile"
+	AMAccessControlUserInfoAnnotation           = AccessControlPrefix + "userInfoAnnotation"
+	AMAccessControlUserInfoMaxGroups            = AccessControlPrefix + "userInfoMaxGroups"
+	AMAccessControlTrackRejections              = AccessControlPrefix + "trackRejections"
+
+	// mutation configuration
+	AMMutationDefaultSchedulingPolicyParameters = MutationPrefix + "defaultSchedulingPolicyParameters"
+	AMMutationOverrideExistingSchedulerName     = MutationPrefix + "overrideExistingSchedulerName"
+	AMMutationSchedulerName                     = MutationPrefix + "schedulerName"
+	AMMutationGPUResourceNames                  = MutationPrefix + "gpuResourceNames"
+	AMMutationGPUQueue                          = MutationPrefix + "gpuQueue"
+	AMMutationPriorityClassQueues               = MutationPrefix + "priorityClassQueues"
+	AMMutationPriorityClassPreemption           = MutationPrefix + "priorityClassPreemption"
+	AMMutationServiceQueue                      = MutationPrefix + "serviceQueue"
+	AMMutationPriorityBuckets                   = MutationPrefix + "priorityBuckets"
+	AMMutationAutogenTerminationGracePeriod     = MutationPrefix + "autogenTerminationGracePeriodSeconds"
+	AMMutationOwnerBasedAppID                   = MutationPrefix + "ownerBasedAppId"
+	AMMutationStatefulSetAppID                  = MutationPrefix + "statefulSetAppId"
+	AMMutationReplicaSetAppID                   = MutationPrefix + "replicaSetAppId"
+	AMMutationAppIDTemplate                     = MutationPrefix + "appIdTemplate"
+	AMMutationCostCenterConfigMap               = MutationPrefix + "costCenterConfigMap"
+	AMMutationCostCenterLabel                   = MutationPrefix + "costCenterLabel"
+	AMMutationDefaultCostCenter                 = MutationPrefix + "defaultCostCenter"
+	AMMutationNamespaceFieldLabels              = MutationPrefix + "namespaceFieldLabels"
+	AMMutationGenerationLabel                   = MutationPrefix + "generationLabel"
+	AMMutationExpandTaskGroupParameters         = MutationPrefix + "expandTaskGroupParameters"
+	AMMutationJobTaskGroups                     = MutationPrefix + "jobTaskGroups"
+	AMMutationAnnotateWarnings                  = MutationPrefix + "annotateWarnings"
+	AMMutationAnnotateDecision                  = MutationPrefix + "annotateDecision"
+	AMMutationPodTemplates                      = MutationPrefix + "podTemplates"
+	AMMutationDisabledMutators                  = MutationPrefix + "disabledMutators"
+	AMMutationQueueNodePools                    = MutationPrefix + "queueNodePools"
+	AMMutationQueueMappingRules                 = MutationPrefix + "queueMappingRules"
+	AMMutationDefaultResourceRequests           = MutationPrefix + "defaultResourceRequests"
+
+	// validation configuration
+	AMValidationAppQueueRules              = ValidationPrefix + "appQueueRules"
+	AMValidationMaxQueueDepth              = ValidationPrefix + "maxQueueDepth"
+	AMValidationMaxQueueCount              = ValidationPrefix + "maxQueueCount"
+	AMValidationRequireRootQueue           = ValidationPrefix + "requireRootQueue"
+	AMValidationStrictNamespace            = ValidationPrefix + "strictNamespace"
+	AMValidationOwnerAppIDConflict         = ValidationPrefix + "ownerAppIdConflict"
+	AMValidationRequireQueue               = ValidationPrefix + "requireQueue"
+	AMValidationRequireLabelNamespaces     = ValidationPrefix + "requireLabelNamespaces"
+	AMValidationDenyActiveQueueRemoval     = ValidationPrefix + "denyActiveQueueRemoval"
+	AMValidationDenyDrainingQueues         = ValidationPrefix + "denyDrainingQueues"
+	AMValidationDrainingQueueCacheTTL      = ValidationPrefix + "drainingQueueCacheTTL"
+	AMValidationQueueStateTimeout          = ValidationPrefix + "queueStateTimeout"
+	AMValidationDenyExceedingQueueMax      = ValidationPrefix + "denyExceedingQueueMax"
+	AMValidationUnknownQueueAction         = ValidationPrefix + "unknownQueueAction"
+	AMValidationUnknownQueueFallback       = ValidationPrefix + "unknownQueueFallback"
+	AMValidationAppIDPattern               = ValidationPrefix + "appIdPattern"
+	AMValidationAppIDPatternGenerated      = ValidationPrefix + "appIdPatternGenerated"
+	AMValidationUniqueAppID                = ValidationPrefix + "uniqueAppId"
+	AMValidationUniqueAppIDIndexSize       = ValidationPrefix + "uniqueAppIdIndexSize"
+	AMValidationUniqueAppIDTTL             = ValidationPrefix + "uniqueAppIdTTL"
+	AMValidationWarnMissingProbes          = ValidationPrefix + "warnMissingProbes"
+	AMValidationNamespaceResourceCaps      = ValidationPrefix + "namespaceResourceCaps"
+	AMValidationNamespaceResourceCapAction = ValidationPrefix + "namespaceResourceCapAction"
+	AMValidationReservedQueueProperties    = ValidationPrefix + "reservedQueueProperties"
+	AMValidationTaskGroups                 = ValidationPrefix + "taskGroups"
+	AMValidationPolicyURL                  = ValidationPrefix + "policyURL"
+	AMValidationPolicyTimeout              = ValidationPrefix + "policyTimeout"
+	AMValidationPolicyFailurePolicy        = ValidationPrefix + "policyFailurePolicy"
+
+	// logging configuration
+	AMLoggingMaskAnnotations = LoggingPrefix + "maskAnnotations"
 )
 
 const (
 	// webhook defaults
-	DefaultWebHookAmServiceName           = "yunikorn-admission-controller-service"
-	DefaultWebHookSchedulerServiceAddress = "yunikorn-service:9080"
+	DefaultWebHookAmServiceName                  = "yunikorn-admission-controller-service"
+	DefaultWebHookSchedulerServiceAddress        = "yunikorn-service:9080"
+	DefaultWebHookDrainMode                      = false
+	DefaultWebHookDryRun                         = false
+	DefaultWebHookFailOnSchedulerUnreachable     = false
+	DefaultWebHookSchedulerValidateTimeout       = 10 * time.Second
+	DefaultWebHookAcceptCompressedRequests       = true
+	DefaultWebHookMaxRequestSize                 = 3 * 1024 * 1024
+	DefaultWebHookSchedulerServiceScheme         = ""
+	DefaultWebHookSchedulerValidateConfPath      = "/ws/v1/validate-conf"
+	DefaultWebHookSchedulerClientCertFile        = ""
+	DefaultWebHookSchedulerClientKeyFile         = ""
+	DefaultWebHookSchedulerCAFile                = ""
+	DefaultWebHookSchedulerMaxResponseSize       = 1024 * 1024
+	DefaultWebHookSchedulerResponsePublicKeyFile = ""
+	DefaultWebHookEmitEvents                     = false
+	DefaultWebHookAllowDebugAnnotation           = false
+	DefaultWebHookReadinessCheckScheduler        = false
+	DefaultWebHookReconcileInterval              = 5 * time.Minute
+	DefaultWebHookLeaderElection                 = false
+	DefaultWebHookAuditSink                      = AuditSinkLog
+	DefaultWebHookAuditSinkTarget                = ""
+	DefaultWebHookReadTimeout                    = 10 * time.Second
+	DefaultWebHookWriteTimeout                   = 30 * time.Second
+	DefaultWebHookMaxConnections                 = 0
+	DefaultWebHookMaxInFlightRequests            = 0
+	DefaultWebHookRateLimitQPS                   = 0
+	DefaultWebHookRateLimitBurst                 = 0
+	DefaultWebHookClientRateLimitQPS             = 0
+	DefaultWebHookClientRateLimitBurst           = 0
+	DefaultWebHookShutdownDelay                  = 5 * time.Second
+	DefaultWebHookShutdownTimeout                = 20 * time.Second
+	DefaultWebHookFailurePolicy                  = FailurePolicyIgnore
+	DefaultWebHookTimeoutSeconds                 = 10
+	DefaultWebHookNamespaceSelector              = false
+	DefaultWebHookDebugAddress                   = "127.0.0.1:9090"
+
+	// admission controller defaults
+	DefaultDefaultQueue = "root.default"
 
 	// filtering defaults
-	DefaultFilteringProcessNamespaces = ""
-	DefaultFilteringBypassNamespaces  = "^kube-system$"
-	DefaultFilteringLabelNamespaces   = ""
-	DefaultFilteringNoLabelNamespaces = ""
+	DefaultFilteringProcessNamespaces        = ""
+	DefaultFilteringBypassNamespaces         = "^kube-system$"
+	DefaultFilteringLabelNamespaces          = ""
+	DefaultFilteringNoLabelNamespaces        = ""
+	DefaultFilteringNamespaceSource          = NamespaceSourceRequest
+	DefaultFilteringProcessPodSelector       = ""
+	DefaultFilteringBypassPodSelector        = constants.LabelIgnore + "=true"
+	DefaultFilteringProcessNamespaceSelector = ""
+	DefaultFilteringBypassNamespaceSelector  = ""
+	DefaultFilteringLabelNamespaceSelector   = ""
+	DefaultFilteringNoLabelNamespaceSelector = ""
 
 	// access control defaults
-	DefaultAccessControlBypassAuth       = false
-	DefaultAccessControlTrustControllers = true
-	DefaultAccessControlSystemUsers      = "system:serviceaccount:kube-system:*"
-	DefaultAccessControlExternalUsers    = ""
-	DefaultAccessControlExternalGroups   = ""
+	DefaultAccessControlBypassAuth                   = false
+	DefaultAccessControlBypassAuthNamespaces         = ""
+	DefaultAccessControlTrustControllers             = true
+	DefaultAccessControlSystemUsers                  = "system:serviceaccount:kube-system:*"
+	DefaultAccessControlExternalUsers                = ""
+	DefaultAccessControlExternalGroups               = ""
+	DefaultAccessControlTrustedIdentities            = ""
+	DefaultAccessControlIdentityServiceURL           = ""
+	DefaultAccessControlIdentityServiceTimeout       = 5 * time.Second
+	DefaultAccessControlIdentityServiceCacheTTL      = 5 * time.Minute
+	DefaultAccessControlIdentityServiceFailurePolicy = FailurePolicyFail
+	DefaultAccessControlIdentityServiceTokenFile     = ""
+	DefaultAccessControlUserInfoAnnotation           = siCommon.DomainYuniKorn + "user.info"
+	DefaultAccessControlUserInfoMaxGroups            = 0
+	DefaultAccessControlTrackRejections              = false
+
+	// mutation defaults
+	DefaultMutationDefaultSchedulingPolicyParameters = ""
+	DefaultMutationOverrideExistingSchedulerName     = false
+	DefaultMutationSchedulerName                     = constants.SchedulerName
+	DefaultMutationGPUResourceNames                  = "nvidia.com/gpu"
+	DefaultMutationGPUQueue                          = ""
+	DefaultMutationPriorityClassQueues               = ""
+	DefaultMutationPriorityClassPreemption           = ""
+	DefaultMutationServiceQueue                      = ""
+	DefaultMutationPriorityBuckets                   = ""
+	DefaultMutationAutogenTerminationGracePeriod     = 0
+	DefaultMutationOwnerBasedAppID                   = false
+	DefaultMutationStatefulSetAppID                  = false
+	DefaultMutationReplicaSetAppID                   = false
+	DefaultMutationAppIDTemplate                     = "yunikorn-" + AppIDTemplateNamespace + "-autogen"
+	DefaultMutationCostCenterConfigMap               = ""
+	DefaultMutationCostCenterLabel                   = "cost-center"
+	DefaultMutationDefaultCostCenter                 = ""
+	DefaultMutationNamespaceFieldLabels              = ""
+	DefaultMutationGenerationLabel                   = ""
+	DefaultMutationExpandTaskGroupParameters         = false
+	DefaultMutationJobTaskGroups                     = false
+	DefaultMutationAnnotateWarnings                  = false
+	DefaultMutationAnnotateDecision                  = false
+	DefaultMutationPodTemplates                      = false
+	DefaultMutationDisabledMutators                  = ""
+	DefaultMutationQueueNodePools                    = ""
+	DefaultMutationQueueMappingRules                 = ""
+	DefaultMutationDefaultResourceRequests           = ""
+
+	// validation defaults
+	DefaultValidationAppQueueRules              = ""
+	DefaultValidationMaxQueueDepth              = 0
+	DefaultValidationMaxQueueCount              = 0
+	DefaultValidationRequireRootQueue           = false
+	DefaultValidationStrictNamespace            = false
+	DefaultValidationOwnerAppIDConflict         = ConflictActionAllow
+	DefaultValidationRequireQueue               = false
+	DefaultValidationRequireLabelNamespaces     = ""
+	DefaultValidationDenyActiveQueueRemoval     = false
+	DefaultValidationDenyDrainingQueues         = false
+	DefaultValidationDrainingQueueCacheTTL      = 30 * time.Second
+	DefaultValidationQueueStateTimeout          = 2 * time.Second
+	DefaultValidationDenyExceedingQueueMax      = false
+	DefaultValidationUnknownQueueAction         = UnknownQueueActionAllow
+	DefaultValidationUnknownQueueFallback       = "root.default"
+	DefaultValidationAppIDPattern               = ""
+	DefaultValidationAppIDPatternGenerated      = false
+	DefaultValidationUniqueAppID                = false
+	DefaultValidationUniqueAppIDIndexSize       = 10000
+	DefaultValidationUniqueAppIDTTL             = time.Hour
+	DefaultValidationWarnMissingProbes          = false
+	DefaultValidationNamespaceResourceCaps      = ""
+	DefaultValidationNamespaceResourceCapAction = ConflictActionWarn
+	DefaultValidationReservedQueueProperties    = ""
+	DefaultValidationTaskGroups                 = true
+	DefaultValidationPolicyURL                  = ""
+	DefaultValidationPolicyTimeout              = 2 * time.Second
+	DefaultValidationPolicyFailurePolicy        = FailurePolicyFail
+
+	// logging defaults
+	DefaultLoggingMaskAnnotations = false
+)
+
+const (
+	// NamespaceSourceRequest uses the namespace of the admission request if it conflicts with the object
+	NamespaceSourceRequest = "request"
+	// NamespaceSourceObject uses the namespace of the object if it conflicts with the admission request
+	NamespaceSourceObject = "object"
+
+	// SchemeHTTP calls the scheduler over plaintext HTTP
+	SchemeHTTP = "http"
+	// SchemeHTTPS calls the scheduler over TLS
+	SchemeHTTPS = "https"
+
+	// FailurePolicyFail rejects the request if an external service cannot be reached
+	FailurePolicyFail = "Fail"
+	// FailurePolicyIgnore admits the request if an external service cannot be reached
+	FailurePolicyIgnore = "Ignore"
+
+	// ConflictActionAllow admits a conflicting request silently
+	ConflictActionAllow = "allow"
+	// ConflictActionWarn admits a conflicting request and logs a warning
+	ConflictActionWarn = "warn"
+	// ConflictActionDeny rejects a conflicting request
+	ConflictActionDeny = "deny"
+
+	// UnknownQueueActionAllow admits pods for a queue that does not exist in the scheduler
+	UnknownQueueActionAllow = "allow"
+	// UnknownQueueActionDeny rejects pods for a queue that does not exist in the scheduler
+	UnknownQueueActionDeny = "deny"
+	// UnknownQueueActionFallback places pods for a queue that does not exist in the scheduler in the fallback queue
+	UnknownQueueActionFallback = "fallback"
+
+	// AuditSinkNone does not record admission decisions
+	AuditSinkNone = "none"
+	// AuditSinkLog records admission decisions through the audit logger
+	AuditSinkLog = "log"
+	// AuditSinkStdout writes admission decisions to stdout as JSON lines
+	AuditSinkStdout = "stdout"
+	// AuditSinkFile appends admission decisions to the target file as JSON lines
+	AuditSinkFile = "file"
+	// AuditSinkHTTP posts admission decisions as JSON to the target URL
+	AuditSinkHTTP = "http"
+
+	// AppIDTemplateNamespace is replaced by the namespace of the pod in the application ID template
+	AppIDTemplateNamespace = "{namespace}"
+	// AppIDTemplateGenerateName is replaced by the generate name of the pod in the application ID template
+	AppIDTemplateGenerateName = "{generateName}"
+	// AppIDTemplateOwnerKind is replaced by the lower case kind of the owner of the pod in the application ID template
+	AppIDTemplateOwnerKind = "{ownerKind}"
+	// AppIDTemplateOwnerName is replaced by the name of the owner of the pod in the application ID template
+	AppIDTemplateOwnerName = "{ownerName}"
+	// AppIDTemplateDate is replaced by the UTC date of the admission as YYYYMMDD in the application ID template
+	AppIDTemplateDate = "{date}"
+
+	// AllNamespaces sets the default resource requests of pods in every namespace
+	AllNamespaces = "*"
+)
+
+// same restrictions the scheduler core applies to each queue name in a path
+var queueNameRegExp = regexp.MustCompile(`^[a-zA-Z0-9_:#/@-]{1,64}$`)
+
+var (
+	appIDTemplatePlaceholderRegExp = regexp.MustCompile(`\{[^{}]*\}`)
+	// characters outside of the placeholders must be valid in a label value
+	appIDTemplateLiteralRegExp = regexp.MustCompile(`^[a-zA-Z0-9._-]*$`)
+	appIDTemplatePlaceholders  = []string{AppIDTemplateNamespace, AppIDTemplateGenerateName, AppIDTemplateOwnerKind,
+		AppIDTemplateOwnerName, AppIDTemplateDate}
+)
+
+// AppQueueRule binds application IDs matching a pattern to the queues under a queue prefix.
+type AppQueueRule struct {
+	AppID       *regexp.Regexp
+	QueuePrefix string
+}
+
+// Matches returns true if the rule does not apply to the application or the queue is within the queue prefix.
+func (r *AppQueueRule) Matches(appID string, queue string) bool {
+	if !r.AppID.MatchString(appID) {
+		return true
+	}
+	return queue == r.QueuePrefix || strings.HasPrefix(queue, r.QueuePrefix+".")
+}
+
+func (r *AppQueueRule) String() string {
+	return fmt.Sprintf("%s=%s", r.AppID.String(), r.QueuePrefix)
+}
+
+// Namespace fields that can be mapped to pod labels, using the field path syntax of the downward API.
+const (
+	NamespaceFieldName        = "metadata.name"
+	NamespaceFieldUID         = "metadata.uid"
+	NamespaceFieldLabels      = "metadata.labels"
+	NamespaceFieldAnnotations = "metadata.annotations"
 )
 
+// NamespaceFieldLabel maps a field of the namespace to a pod label. The key selects the entry for the labels and
+// annotations fields.
+type NamespaceFieldLabel struct {
+	Label string
+	Field string
+	Key   string
+}
+
+func (f *NamespaceFieldLabel) String() string {
+	if f.Key == "" {
+		return fmt.Sprintf("%s=%s", f.Label, f.Field)
+	}
+	return fmt.Sprintf("%s=%s['%s']", f.Label, f.Field, f.Key)
+}
+
+// PriorityBucket names the range of pod priorities starting at the minimum priority, up to the next bucket.
+type PriorityBucket struct {
+	Name        string
+	MinPriority int32
+}
+
+// QueueNodePool is the dedicated node pool of a queue: the node selector and tolerations set on the pods of the queue.
+type QueueNodePool struct {
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Tolerations  []v1.Toleration   `json:"tolerations,omitempty"`
+}
+
+// NamespaceFilters is a consistent view of the namespace and pod filtering configuration. A new view is created on
+// every reload and is never modified, so a filtering decision never mixes the lists of two configurations.
+// The selectors are nil if none is configured.
+type NamespaceFilters struct {
+	ProcessNamespaces []*regexp.Regexp
+	BypassNamespaces  []*regexp.Regexp
+	LabelNamespaces   []*regexp.Regexp
+	NoLabelNamespaces []*regexp.Regexp
+	// pods which are processed or bypassed regardless of their namespace, by default pods labelled with
+	// yunikorn.apache.org/ignore=true are bypassed
+	ProcessPodSelector labels.Selector
+	BypassPodSelector  labels.Selector
+	// labels of the namespaces which are processed, bypassed, labelled or never labelled regardless of the lists
+	ProcessNamespaceSelector labels.Selector
+	BypassNamespaceSelector  labels.Selector
+	LabelNamespaceSelector   labels.Selector
+	NoLabelNamespaceSelector labels.Selector
+}
+
+// AccessControl is a consistent view of the configuration deciding who may set the user info annotation. A new view
+// is created on every reload and is never modified.
+type AccessControl struct {
+	BypassAuth           bool
+	BypassAuthNamespaces []*regexp.Regexp
+	TrustControllers     bool
+	SystemUsers          []*regexp.Regexp
+	ExternalUsers        []*regexp.Regexp
+	ExternalGroups       []*regexp.Regexp
+	TrustedIdentities    []*TrustedIdentity
+}
+
 type AdmissionControllerConf struct {
 	namespace  string
 	kubeConfig string
 
 	// mutable values require locking
-	enableConfigHotRefresh  bool
-	policyGroup             string
-	amServiceName           string
-	schedulerServiceAddress string
-	processNamespaces       []*regexp.Regexp
-	bypassNamespaces        []*regexp.Regexp
-	labelNamespaces         []*regexp.Regexp
-	noLabelNamespaces       []*regexp.Regexp
-	bypassAuth              bool
-	trustControllers        bool
-	systemUsers             []*regexp.Regexp
-	externalUsers           []*regexp.Regexp
-	externalGroups          []*regexp.Regexp
-	configMaps              []*v1.ConfigMap
+	enableConfigHotRefresh        bool
+	policyGroup                   string
+	amServiceName                 string
+	schedulerServiceAddress       string
+	drainMode                     bool
+	dryRun                        bool
+	failOnSchedulerUnreach        bool
+	schedulerValidateTimeout      time.Duration
+	acceptCompressedRequests      bool
+	maxRequestSize                int
+	schedulerServiceScheme        string
+	schedulerValidateConfPath     string
+	schedulerClientCertFile       string
+	schedulerClientKeyFile        string
+	schedulerCAFile               string
+	schedulerMaxResponseSize      int
+	schedulerResponseKeyFile      string
+	emitEvents                    bool
+	allowDebugAnnotation          bool
+	readinessCheckScheduler       bool
+	reconcileInterval             time.Duration
+	leaderElection                bool
+	auditSink                     string
+	auditSinkTarget               string
+	readTimeout                   time.Duration
+	writeTimeout                  time.Duration
+	maxConnections                int
+	maxInFlightRequests           int
+	rateLimitQPS                  int
+	rateLimitBurst                int
+	clientRateLimitQPS            int
+	clientRateLimitBurst          int
+	shutdownDelay                 time.Duration
+	shutdownTimeout               time.Duration
+	webhookFailurePolicy          string
+	webhookTimeoutSeconds         int
+	webhookNamespaceSelector      bool
+	debugAddress                  string
+	namespaceFilters              *NamespaceFilters
+	defaultQueue                  string
+	namespaceSource               string
+	accessControl                 *AccessControl
+	identityServiceURL            string
+	identityServiceTimeout        time.Duration
+	identityServiceCacheTTL       time.Duration
+	identityFailurePolicy         string
+	identityServiceTokenFile      string
+	userInfoAnnotation            string
+	userInfoMaxGroups             int
+	trackRejections               bool
+	schedulingPolicyParams        string
+	overrideSchedulerName         bool
+	schedulerName                 string
+	gpuResourceNames              []string
+	gpuQueue                      string
+	priorityClassQueues           map[string]string
+	priorityClassPreemption       map[string]string
+	serviceQueue                  string
+	priorityBuckets               []*PriorityBucket
+	autogenTerminationGracePeriod int
+	ownerBasedAppID               bool
+	statefulSetAppID              bool
+	replicaSetAppID               bool
+	appIDTemplate                 string
+	costCenterConfigMap           string
+	costCenterLabel               string
+	namespaceFieldLabels          []*NamespaceFieldLabel
+	defaultCostCenter             string
+	generationLabel               string
+	expandTaskGroupParams         bool
+	jobTaskGroups                 bool
+	annotateWarnings              bool
+	annotateDecision              bool
+	mutatePodTemplates            bool
+	disabledMutators              []string
+	queueNodePools                map[string]*QueueNodePool
+	queueMappingRules             []*QueueMappingRule
+	defaultResourceRequests       map[string]v1.ResourceList
+	appQueueRules                 []*AppQueueRule
+	maxQueueDepth                 int
+	maxQueueCount                 int
+	requireRootQueue              bool
+	strictNamespace               bool
+	ownerAppIDConflict            string
+	requireQueue                  bool
+	requireLabelNamespaces        []*regexp.Regexp
+	denyActiveQueueRemoval        bool
+	denyDrainingQueues            bool
+	drainingQueueCacheTTL         time.Duration
+	queueStateTimeout             time.Duration
+	denyExceedingQueueMax         bool
+	unknownQueueAction            string
+	unknownQueueFallback          string
+	appIDPattern                  *regexp.Regexp
+	appIDPatternGenerated         bool
+	uniqueAppID                   bool
+	uniqueAppIDIndexSize          int
+	uniqueAppIDTTL                time.Duration
+	warnMissingProbes             bool
+	namespaceResourceCaps         map[string]v1.ResourceList
+	namespaceResourceCapAction    string
+	reservedQueueProperties       []string
+	validateTaskGroups            bool
+	policyURL                     string
+	policyTimeout                 time.Duration
+	policyFailurePolicy           string
+	maskAnnotations               bool
+	configMaps                    []*v1.ConfigMap
+	generation                    uint64
 
 	configMapInformer informersv1.ConfigMapInformer
 	stopChan          chan struct{}
@@ -110,8 +601,10 @@ type AdmissionControllerConf struct {
 
 func NewAdmissionControllerConf(configMaps []*v1.ConfigMap) *AdmissionControllerConf {
 	acc := &AdmissionControllerConf{
-		namespace:  schedulerconf.GetSchedulerNamespace(),
-		kubeConfig: schedulerconf.GetDefaultKubeConfigPath(),
+		namespace:        schedulerconf.GetSchedulerNamespace(),
+		kubeConfig:       schedulerconf.GetDefaultKubeConfigPath(),
+		namespaceFilters: &NamespaceFilters{},
+		accessControl:    &AccessControl{},
 	}
 	acc.updateConfigMaps(configMaps, true)
 	return acc
@@ -159,70 +652,752 @@ func GetPendingPolicyGroup(configs map[string]string) string {
 	return parseConfigString(configs, schedulerconf.CMSvcPolicyGroup, schedulerconf.DefaultPolicyGroup)
 }
 
-func (acc *AdmissionControllerConf) GetAmServiceName() string {
+// GetGeneration returns the generation of the configuration, which starts at 1 and increases with every applied reload.
+func (acc *AdmissionControllerConf) GetGeneration() uint64 {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.generation
+}
+
+func (acc *AdmissionControllerConf) GetAmServiceName() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.amServiceName
+}
+
+func (acc *AdmissionControllerConf) GetSchedulerServiceAddress() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerServiceAddress
+}
+
+// GetSchedulerServiceScheme returns the URL scheme used to reach the scheduler REST API. Unless configured
+// explicitly, HTTPS is used when TLS material for the scheduler is provided and plaintext HTTP otherwise.
+func (acc *AdmissionControllerConf) GetSchedulerServiceScheme() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerServiceScheme
+}
+
+// GetSchedulerValidateConfPath returns the path of the configuration validation endpoint of the scheduler REST API.
+func (acc *AdmissionControllerConf) GetSchedulerValidateConfPath() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerValidateConfPath
+}
+
+func (acc *AdmissionControllerConf) GetSchedulerClientCertFile() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerClientCertFile
+}
+
+func (acc *AdmissionControllerConf) GetSchedulerClientKeyFile() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerClientKeyFile
+}
+
+func (acc *AdmissionControllerConf) GetSchedulerCAFile() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerCAFile
+}
+
+// GetSchedulerMaxResponseSize returns the maximum size in bytes of a response from the scheduler REST API.
+func (acc *AdmissionControllerConf) GetSchedulerMaxResponseSize() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerMaxResponseSize
+}
+
+// GetSchedulerResponsePublicKeyFile returns the public key used to verify the signature of validation responses from
+// the scheduler. Signatures are not verified if no key is configured.
+func (acc *AdmissionControllerConf) GetSchedulerResponsePublicKeyFile() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerResponseKeyFile
+}
+
+// GetEmitEvents returns true if admission decisions are reported as Kubernetes events.
+func (acc *AdmissionControllerConf) GetEmitEvents() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.emitEvents
+}
+
+// GetAllowDebugAnnotation returns true if objects can request verbose logging of their admission using an annotation.
+func (acc *AdmissionControllerConf) GetAllowDebugAnnotation() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.allowDebugAnnotation
+}
+
+// GetReadinessCheckScheduler returns true if the admission controller is only ready while the scheduler is reachable.
+func (acc *AdmissionControllerConf) GetReadinessCheckScheduler() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.readinessCheckScheduler
+}
+
+// GetReconcileInterval returns how often the webhook configurations are checked against the CA certificates and
+// repaired if needed. Zero or less disables the check.
+func (acc *AdmissionControllerConf) GetReconcileInterval() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.reconcileInterval
+}
+
+// GetLeaderElection returns true if only the elected replica installs and reconciles the webhook configurations. It is
+// only read at startup.
+func (acc *AdmissionControllerConf) GetLeaderElection() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.leaderElection
+}
+
+// GetAuditSink returns where the admission decisions are recorded. It is only read at startup.
+func (acc *AdmissionControllerConf) GetAuditSink() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.auditSink
+}
+
+// GetAuditSinkTarget returns the file path or URL the audit sink writes to. It is only read at startup.
+func (acc *AdmissionControllerConf) GetAuditSinkTarget() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.auditSinkTarget
+}
+
+// GetReadTimeout returns the maximum time for reading an admission request. It is applied when the server is started.
+func (acc *AdmissionControllerConf) GetReadTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.readTimeout
+}
+
+// GetWriteTimeout returns the maximum time for handling an admission request and writing the response. It is applied
+// when the server is started.
+func (acc *AdmissionControllerConf) GetWriteTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.writeTimeout
+}
+
+// GetMaxConnections returns the maximum number of concurrent connections accepted by the server. Zero or less does not
+// limit the connections. It is applied when the server is started.
+func (acc *AdmissionControllerConf) GetMaxConnections() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.maxConnections
+}
+
+// GetMaxInFlightRequests returns the maximum number of admission requests processed at the same time. Requests above
+// the limit are refused with a 429 response. Zero or less does not limit the requests.
+func (acc *AdmissionControllerConf) GetMaxInFlightRequests() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.maxInFlightRequests
+}
+
+// GetRateLimit returns the number of admission requests per second accepted over all clients, and the burst allowed on
+// top of it. A rate of zero or less does not limit the requests. A burst of zero or less defaults to the rate.
+func (acc *AdmissionControllerConf) GetRateLimit() (int, int) {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.rateLimitQPS, acc.rateLimitBurst
+}
+
+// GetClientRateLimit returns the number of admission requests per second accepted for a single requesting user, and
+// the burst allowed on top of it. A rate of zero or less does not limit the requests. A burst of zero or less defaults
+// to the rate.
+func (acc *AdmissionControllerConf) GetClientRateLimit() (int, int) {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.clientRateLimitQPS, acc.clientRateLimitBurst
+}
+
+// GetShutdownDelay returns how long the server keeps accepting requests after it reported not ready on termination, so
+// that the service endpoints can be updated before the listener is closed.
+func (acc *AdmissionControllerConf) GetShutdownDelay() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.shutdownDelay
+}
+
+// GetShutdownTimeout returns how long in-flight requests are allowed to complete when the server is shut down.
+func (acc *AdmissionControllerConf) GetShutdownTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.shutdownTimeout
+}
+
+// GetWebhookFailurePolicy returns the failure policy registered for the pod webhooks: whether the API server admits a
+// pod when the admission controller cannot be called.
+func (acc *AdmissionControllerConf) GetWebhookFailurePolicy() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.webhookFailurePolicy
+}
+
+// GetWebhookTimeoutSeconds returns how long the API server waits for a response of the admission controller.
+func (acc *AdmissionControllerConf) GetWebhookTimeoutSeconds() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.webhookTimeoutSeconds
+}
+
+// GetWebhookNamespaceSelector returns true if the process and bypass namespaces are registered as a namespace selector
+// of the mutating webhook, so that the API server does not call the webhook for bypassed namespaces. The user info
+// annotation of pods in those namespaces is not checked.
+func (acc *AdmissionControllerConf) GetWebhookNamespaceSelector() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.webhookNamespaceSelector
+}
+
+// GetDebugAddress returns the address of the plain HTTP server for the debug endpoints. The debug endpoints expose
+// user identities, the address should be on the loopback interface. An empty address disables the debug server. It
+// is applied when the server is started.
+func (acc *AdmissionControllerConf) GetDebugAddress() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.debugAddress
+}
+
+func (acc *AdmissionControllerConf) GetDrainMode() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.drainMode
+}
+
+// GetDryRun returns true if requests are allowed without changes, only logging the decision that would have been taken.
+func (acc *AdmissionControllerConf) GetDryRun() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.dryRun
+}
+
+// GetFailOnSchedulerUnreachable returns whether configmaps are rejected (fail-closed) instead of assumed valid
+// (fail-open) when the scheduler cannot validate them. Fail-closed also sets the failure policy of the configmap
+// validation webhook to Fail.
+func (acc *AdmissionControllerConf) GetFailOnSchedulerUnreachable() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.failOnSchedulerUnreach
+}
+
+func (acc *AdmissionControllerConf) GetSchedulerValidateTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerValidateTimeout
+}
+
+func (acc *AdmissionControllerConf) GetAcceptCompressedRequests() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.acceptCompressedRequests
+}
+
+// GetMaxRequestSize returns the maximum size in bytes of an admission request body, after decompression. Zero or less
+// disables the limit.
+func (acc *AdmissionControllerConf) GetMaxRequestSize() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.maxRequestSize
+}
+
+// GetNamespaceFilters returns the namespace and pod filtering configuration as a single consistent view.
+func (acc *AdmissionControllerConf) GetNamespaceFilters() *NamespaceFilters {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.namespaceFilters
+}
+
+// GetDefaultQueue returns the queue of pods that are not placed in a queue by any other rule.
+func (acc *AdmissionControllerConf) GetDefaultQueue() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.defaultQueue
+}
+
+func (acc *AdmissionControllerConf) GetNamespaceSource() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.namespaceSource
+}
+
+// GetAccessControl returns the user info annotation access configuration as a single consistent view.
+func (acc *AdmissionControllerConf) GetAccessControl() *AccessControl {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.accessControl
+}
+
+func (acc *AdmissionControllerConf) GetIdentityServiceURL() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.identityServiceURL
+}
+
+func (acc *AdmissionControllerConf) GetIdentityServiceTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.identityServiceTimeout
+}
+
+func (acc *AdmissionControllerConf) GetIdentityServiceCacheTTL() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.identityServiceCacheTTL
+}
+
+func (acc *AdmissionControllerConf) GetIdentityServiceFailurePolicy() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.identityFailurePolicy
+}
+
+// GetIdentityServiceTokenFile returns the file holding the bearer token sent to the identity service. The file is
+// read for each lookup, so that a rotated token is picked up.
+func (acc *AdmissionControllerConf) GetIdentityServiceTokenFile() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.identityServiceTokenFile
+}
+
+// GetUserInfoAnnotation returns the key of the annotation that carries the user info of the submitter.
+func (acc *AdmissionControllerConf) GetUserInfoAnnotation() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.userInfoAnnotation
+}
+
+// GetUserInfoMaxGroups returns the maximum number of groups in the user info annotation. Zero or less disables the
+// check.
+func (acc *AdmissionControllerConf) GetUserInfoMaxGroups() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.userInfoMaxGroups
+}
+
+// GetTrackRejections returns true if user info rejections are counted by user and group, and recent rejections are
+// kept for the debug endpoint. The number of users and groups used as metric labels is bounded.
+func (acc *AdmissionControllerConf) GetTrackRejections() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.trackRejections
+}
+
+func (acc *AdmissionControllerConf) GetDefaultSchedulingPolicyParameters() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulingPolicyParams
+}
+
+func (acc *AdmissionControllerConf) GetOverrideExistingSchedulerName() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.overrideSchedulerName
+}
+
+// GetSchedulerName returns the scheduler name set on processed pods.
+func (acc *AdmissionControllerConf) GetSchedulerName() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.schedulerName
+}
+
+func (acc *AdmissionControllerConf) GetGPUResourceNames() []string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.gpuResourceNames
+}
+
+func (acc *AdmissionControllerConf) GetGPUQueue() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.gpuQueue
+}
+
+// GetPriorityClassQueues returns the queue for each mapped priority class. The map must not be modified.
+func (acc *AdmissionControllerConf) GetPriorityClassQueues() map[string]string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.priorityClassQueues
+}
+
+// GetPriorityClassPreemption returns the allow preemption annotation value, true or false, for each mapped priority
+// class. The map must not be modified.
+func (acc *AdmissionControllerConf) GetPriorityClassPreemption() map[string]string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.priorityClassPreemption
+}
+
+func (acc *AdmissionControllerConf) GetServiceQueue() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.serviceQueue
+}
+
+// GetPriorityBuckets returns the priority buckets ordered from the highest to the lowest minimum priority.
+func (acc *AdmissionControllerConf) GetPriorityBuckets() []*PriorityBucket {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.priorityBuckets
+}
+
+// GetAutogenTerminationGracePeriod returns the termination grace period in seconds set on pods with a generated
+// application ID. Zero or less disables the default.
+func (acc *AdmissionControllerConf) GetAutogenTerminationGracePeriod() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.autogenTerminationGracePeriod
+}
+
+// GetOwnerBasedAppID returns true if pods without an application ID are grouped into an application per top-level
+// controller, e.g. the Deployment or Job. If service.enableOwnerApps is set on the scheduler, it removes the
+// application when the Deployment is deleted or the Job is finished.
+func (acc *AdmissionControllerConf) GetOwnerBasedAppID() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.ownerBasedAppID
+}
+
+// GetStatefulSetAppID returns true if pods controlled by a StatefulSet without an application ID are grouped into an
+// application per StatefulSet, and labelled with their ordinal. It takes precedence over the owner based application ID.
+func (acc *AdmissionControllerConf) GetStatefulSetAppID() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.statefulSetAppID
+}
+
+// GetReplicaSetAppID returns true if pods of a Deployment without an application ID are grouped into an application
+// per ReplicaSet, so that each rollout generation is a separate application. If service.enableReplicaSetApps is set
+// on the scheduler, it removes the application when the ReplicaSet is scaled down to zero. It takes precedence over
+// the owner based application ID.
+func (acc *AdmissionControllerConf) GetReplicaSetAppID() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.replicaSetAppID
+}
+
+func (acc *AdmissionControllerConf) GetAppIDTemplate() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.appIDTemplate
+}
+
+func (acc *AdmissionControllerConf) GetCostCenterConfigMap() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.costCenterConfigMap
+}
+
+func (acc *AdmissionControllerConf) GetCostCenterLabel() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.costCenterLabel
+}
+
+// GetNamespaceFieldLabels returns the namespace fields that are copied to pod labels, in configuration order.
+func (acc *AdmissionControllerConf) GetNamespaceFieldLabels() []*NamespaceFieldLabel {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.namespaceFieldLabels
+}
+
+func (acc *AdmissionControllerConf) GetDefaultCostCenter() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.defaultCostCenter
+}
+
+func (acc *AdmissionControllerConf) GetExpandTaskGroupParameters() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.expandTaskGroupParams
+}
+
+// GetJobTaskGroups returns true if task groups are generated for Jobs that run more than one pod in parallel, without
+// a task group parameters annotation.
+func (acc *AdmissionControllerConf) GetJobTaskGroups() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.jobTaskGroups
+}
+
+func (acc *AdmissionControllerConf) GetAnnotateWarnings() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.annotateWarnings
+}
+
+// GetAnnotateDecision returns true if a summary of the mutation decision must be recorded as an annotation on the pod.
+func (acc *AdmissionControllerConf) GetAnnotateDecision() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.annotateDecision
+}
+
+// GetMutatePodTemplates returns true if the pod templates of Deployments, StatefulSets, Jobs and CronJobs must be
+// mutated like pods. The application ID is still set on each pod. The controllers are sent to the mutating webhook
+// only while this is enabled.
+func (acc *AdmissionControllerConf) GetMutatePodTemplates() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.mutatePodTemplates
+}
+
+// GetDisabledMutators returns the names of the pod mutators that must not be applied.
+func (acc *AdmissionControllerConf) GetDisabledMutators() []string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.disabledMutators
+}
+
+// GetQueueNodePools returns the node pool for each mapped queue. The pool of a queue also applies to its child queues.
+// The map must not be modified.
+func (acc *AdmissionControllerConf) GetQueueNodePools() map[string]*QueueNodePool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.queueNodePools
+}
+
+// GetQueueMappingRules returns the rules resolving the queue of pods without a queue label, in the order they are
+// checked. The rules must not be modified.
+func (acc *AdmissionControllerConf) GetQueueMappingRules() []*QueueMappingRule {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.queueMappingRules
+}
+
+// GetDefaultResourceRequests returns the requests set on containers of pods in the namespace that do not request the
+// resource. Defaults of the namespace replace the defaults for all namespaces per resource.
+func (acc *AdmissionControllerConf) GetDefaultResourceRequests(namespace string) v1.ResourceList {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	result := make(v1.ResourceList)
+	for name, quantity := range acc.defaultResourceRequests[AllNamespaces] {
+		result[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range acc.defaultResourceRequests[namespace] {
+		result[name] = quantity.DeepCopy()
+	}
+	return result
+}
+
+func (acc *AdmissionControllerConf) GetGenerationLabel() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.generationLabel
+}
+
+func (acc *AdmissionControllerConf) GetAppQueueRules() []*AppQueueRule {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.appQueueRules
+}
+
+func (acc *AdmissionControllerConf) GetMaxQueueDepth() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.maxQueueDepth
+}
+
+func (acc *AdmissionControllerConf) GetMaxQueueCount() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.maxQueueCount
+}
+
+func (acc *AdmissionControllerConf) GetRequireRootQueue() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.requireRootQueue
+}
+
+func (acc *AdmissionControllerConf) GetStrictNamespace() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.strictNamespace
+}
+
+func (acc *AdmissionControllerConf) GetOwnerAppIDConflict() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.ownerAppIDConflict
+}
+
+func (acc *AdmissionControllerConf) GetRequireQueue() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.requireQueue
+}
+
+func (acc *AdmissionControllerConf) GetRequireLabelNamespaces() []*regexp.Regexp {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.requireLabelNamespaces
+}
+
+func (acc *AdmissionControllerConf) GetDenyActiveQueueRemoval() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.denyActiveQueueRemoval
+}
+
+// GetDenyDrainingQueues returns true if pods submitted to a queue the scheduler is draining must be denied.
+func (acc *AdmissionControllerConf) GetDenyDrainingQueues() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.denyDrainingQueues
+}
+
+// GetDrainingQueueCacheTTL returns how long the queue state retrieved from the scheduler is cached. The cached state
+// is used for the queue existence, draining queue and queue maximum resource checks.
+func (acc *AdmissionControllerConf) GetDrainingQueueCacheTTL() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.drainingQueueCacheTTL
+}
+
+// GetQueueStateTimeout returns the timeout for retrieving the queue state from the scheduler. The admission request
+// waits for the queue state, the timeout must be well below the webhook timeout. Zero disables the timeout.
+func (acc *AdmissionControllerConf) GetQueueStateTimeout() time.Duration {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.queueStateTimeout
+}
+
+// GetDenyExceedingQueueMax returns true if pods that request more than the maximum resource of their queue
+// must be denied, both when they are mutated and when they are validated.
+func (acc *AdmissionControllerConf) GetDenyExceedingQueueMax() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.denyExceedingQueueMax
+}
+
+// GetUnknownQueueAction returns the action taken for pods which specify a queue that does not exist in the scheduler.
+func (acc *AdmissionControllerConf) GetUnknownQueueAction() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.unknownQueueAction
+}
+
+// GetUnknownQueueFallback returns the queue used for pods which specify a queue that does not exist in the scheduler,
+// if the unknown queue action is fallback.
+func (acc *AdmissionControllerConf) GetUnknownQueueFallback() string {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.unknownQueueFallback
+}
+
+// GetAppIDPattern returns the naming convention for application IDs, or nil if application IDs are not checked.
+func (acc *AdmissionControllerConf) GetAppIDPattern() *regexp.Regexp {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.appIDPattern
+}
+
+func (acc *AdmissionControllerConf) GetAppIDPatternGenerated() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.appIDPatternGenerated
+}
+
+func (acc *AdmissionControllerConf) GetUniqueAppID() bool {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.uniqueAppID
+}
+
+// GetUniqueAppIDIndexSize returns the maximum number of application IDs tracked for the uniqueness check.
+func (acc *AdmissionControllerConf) GetUniqueAppIDIndexSize() int {
+	acc.lock.RLock()
+	defer acc.lock.RUnlock()
+	return acc.uniqueAppIDIndexSize
+}
+
+// GetUniqueAppIDTTL returns how long an application ID stays recorded for its namespace after the last pod of the
+// application was admitted. Zero keeps the application ID until it is dropped from the bounded index.
+func (acc *AdmissionControllerConf) GetUniqueAppIDTTL() time.Duration {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.amServiceName
+	return acc.uniqueAppIDTTL
 }
 
-func (acc *AdmissionControllerConf) GetSchedulerServiceAddress() string {
+func (acc *AdmissionControllerConf) GetWarnMissingProbes() bool {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.schedulerServiceAddress
+	return acc.warnMissingProbes
 }
 
-func (acc *AdmissionControllerConf) GetProcessNamespaces() []*regexp.Regexp {
+// GetNamespaceResourceCap returns the resource cap for the namespace, or nil if the namespace is not capped.
+// The returned list is shared and must not be modified.
+func (acc *AdmissionControllerConf) GetNamespaceResourceCap(namespace string) v1.ResourceList {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.processNamespaces
+	return acc.namespaceResourceCaps[namespace]
 }
 
-func (acc *AdmissionControllerConf) GetBypassNamespaces() []*regexp.Regexp {
+// HasNamespaceResourceCaps returns true if a resource cap is configured for any namespace and pods exceeding a cap are
+// not silently allowed. The usage of the namespaces is only tracked if caps are configured at startup.
+func (acc *AdmissionControllerConf) HasNamespaceResourceCaps() bool {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.bypassNamespaces
+	return len(acc.namespaceResourceCaps) != 0 && acc.namespaceResourceCapAction != ConflictActionAllow
 }
 
-func (acc *AdmissionControllerConf) GetLabelNamespaces() []*regexp.Regexp {
+// GetNamespaceResourceCapAction returns the action taken for pods which would exceed the resource cap of the namespace.
+func (acc *AdmissionControllerConf) GetNamespaceResourceCapAction() string {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.labelNamespaces
+	return acc.namespaceResourceCapAction
 }
 
-func (acc *AdmissionControllerConf) GetNoLabelNamespaces() []*regexp.Regexp {
+// GetReservedQueueProperties returns the queue property keys that must not be set in a queue configuration.
+func (acc *AdmissionControllerConf) GetReservedQueueProperties() []string {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.noLabelNamespaces
+	return acc.reservedQueueProperties
 }
 
-func (acc *AdmissionControllerConf) GetBypassAuth() bool {
+// GetValidateTaskGroups returns true if the task groups annotation of a pod must be valid for the pod to be admitted.
+func (acc *AdmissionControllerConf) GetValidateTaskGroups() bool {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.bypassAuth
+	return acc.validateTaskGroups
 }
 
-func (acc *AdmissionControllerConf) GetTrustControllers() bool {
+// GetPolicyURL returns the URL of the policy decision document on an OPA compatible policy engine, or an empty string
+// if pods are not evaluated against policies.
+func (acc *AdmissionControllerConf) GetPolicyURL() string {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.trustControllers
+	return acc.policyURL
 }
 
-func (acc *AdmissionControllerConf) GetSystemUsers() []*regexp.Regexp {
+func (acc *AdmissionControllerConf) GetPolicyTimeout() time.Duration {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.systemUsers
+	return acc.policyTimeout
 }
 
-func (acc *AdmissionControllerConf) GetExternalUsers() []*regexp.Regexp {
+// GetPolicyFailurePolicy returns whether pods are denied (Fail) or admitted (Ignore) if the policy engine cannot be
+// queried.
+func (acc *AdmissionControllerConf) GetPolicyFailurePolicy() string {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.externalUsers
+	return acc.policyFailurePolicy
 }
 
-func (acc *AdmissionControllerConf) GetExternalGroups() []*regexp.Regexp {
+func (acc *AdmissionControllerConf) GetMaskAnnotations() bool {
 	acc.lock.RLock()
 	defer acc.lock.RUnlock()
-	return acc.externalGroups
+	return acc.maskAnnotations
 }
 
 func (acc *AdmissionControllerConf) waitForSync(interval time.Duration, timeout time.Duration) error {
@@ -297,6 +1472,11 @@ func (acc *AdmissionControllerConf) GetConfigMaps() []*v1.ConfigMap {
 	return result
 }
 
+// UpdateConfigMaps applies updated configmaps in the same way as a change detected by the informers.
+func (acc *AdmissionControllerConf) UpdateConfigMaps(configMaps []*v1.ConfigMap) {
+	acc.updateConfigMaps(configMaps, false)
+}
+
 func (acc *AdmissionControllerConf) updateConfigMaps(configMaps []*v1.ConfigMap, initial bool) {
 	acc.lock.Lock()
 	defer acc.lock.Unlock()
@@ -308,6 +1488,7 @@ func (acc *AdmissionControllerConf) updateConfigMaps(configMaps []*v1.ConfigMap,
 	}
 
 	acc.configMaps = configMaps
+	acc.generation++
 	configs := schedulerconf.FlattenConfigMaps(configMaps)
 
 	// hot refresh
@@ -316,6 +1497,7 @@ func (acc *AdmissionControllerConf) updateConfigMaps(configMaps []*v1.ConfigMap,
 	// logging
 	logLevel := parseConfigInt(configs, schedulerconf.CMLogLevel, schedulerconf.DefaultLoggingLevel)
 	log.GetZapConfigs().Level.SetLevel(zapcore.Level(logLevel))
+	acc.maskAnnotations = parseConfigBool(configs, AMLoggingMaskAnnotations, DefaultLoggingMaskAnnotations)
 
 	// scheduler
 	acc.policyGroup = parseConfigString(configs, schedulerconf.CMSvcPolicyGroup, schedulerconf.DefaultPolicyGroup)
@@ -323,19 +1505,167 @@ func (acc *AdmissionControllerConf) updateConfigMaps(configMaps []*v1.ConfigMap,
 	// webhook
 	acc.amServiceName = parseConfigString(configs, AMWebHookAMServiceName, DefaultWebHookAmServiceName)
 	acc.schedulerServiceAddress = parseConfigString(configs, AMWebHookSchedulerServiceAddress, DefaultWebHookSchedulerServiceAddress)
+	acc.drainMode = parseConfigBool(configs, AMWebHookDrainMode, DefaultWebHookDrainMode)
+	acc.dryRun = parseConfigBool(configs, AMWebHookDryRun, DefaultWebHookDryRun)
+	acc.failOnSchedulerUnreach = parseConfigBool(configs, AMWebHookFailOnSchedulerUnreachable, DefaultWebHookFailOnSchedulerUnreachable)
+	acc.schedulerValidateTimeout = parseConfigDuration(configs, AMWebHookSchedulerValidateTimeout, DefaultWebHookSchedulerValidateTimeout)
+	acc.acceptCompressedRequests = parseConfigBool(configs, AMWebHookAcceptCompressedRequests, DefaultWebHookAcceptCompressedRequests)
+	acc.maxRequestSize = parseConfigInt(configs, AMWebHookMaxRequestSize, DefaultWebHookMaxRequestSize)
+	acc.schedulerClientCertFile = parseConfigString(configs, AMWebHookSchedulerClientCertFile, DefaultWebHookSchedulerClientCertFile)
+	acc.schedulerClientKeyFile = parseConfigString(configs, AMWebHookSchedulerClientKeyFile, DefaultWebHookSchedulerClientKeyFile)
+	acc.schedulerCAFile = parseConfigString(configs, AMWebHookSchedulerCAFile, DefaultWebHookSchedulerCAFile)
+	acc.schedulerMaxResponseSize = parseConfigInt(configs, AMWebHookSchedulerMaxResponseSize, DefaultWebHookSchedulerMaxResponseSize)
+	acc.schedulerResponseKeyFile = parseConfigString(configs, AMWebHookSchedulerResponsePublicKeyFile, DefaultWebHookSchedulerResponsePublicKeyFile)
+	acc.emitEvents = parseConfigBool(configs, AMWebHookEmitEvents, DefaultWebHookEmitEvents)
+	acc.allowDebugAnnotation = parseConfigBool(configs, AMWebHookAllowDebugAnnotation, DefaultWebHookAllowDebugAnnotation)
+	acc.readinessCheckScheduler = parseConfigBool(configs, AMWebHookReadinessCheckScheduler, DefaultWebHookReadinessCheckScheduler)
+	acc.reconcileInterval = parseConfigDuration(configs, AMWebHookReconcileInterval, DefaultWebHookReconcileInterval)
+	acc.leaderElection = parseConfigBool(configs, AMWebHookLeaderElection, DefaultWebHookLeaderElection)
+	acc.auditSink = parseConfigValidated(configs, AMWebHookAuditSink, DefaultWebHookAuditSink, acc.auditSink, initial, validateAuditSink)
+	acc.auditSinkTarget = parseConfigString(configs, AMWebHookAuditSinkTarget, DefaultWebHookAuditSinkTarget)
+	acc.readTimeout = parseConfigDuration(configs, AMWebHookReadTimeout, DefaultWebHookReadTimeout)
+	acc.writeTimeout = parseConfigDuration(configs, AMWebHookWriteTimeout, DefaultWebHookWriteTimeout)
+	acc.maxConnections = parseConfigInt(configs, AMWebHookMaxConnections, DefaultWebHookMaxConnections)
+	acc.maxInFlightRequests = parseConfigInt(configs, AMWebHookMaxInFlightRequests, DefaultWebHookMaxInFlightRequests)
+	acc.rateLimitQPS = parseConfigInt(configs, AMWebHookRateLimitQPS, DefaultWebHookRateLimitQPS)
+	acc.rateLimitBurst = parseConfigInt(configs, AMWebHookRateLimitBurst, DefaultWebHookRateLimitBurst)
+	acc.clientRateLimitQPS = parseConfigInt(configs, AMWebHookClientRateLimitQPS, DefaultWebHookClientRateLimitQPS)
+	acc.clientRateLimitBurst = parseConfigInt(configs, AMWebHookClientRateLimitBurst, DefaultWebHookClientRateLimitBurst)
+	acc.shutdownDelay = parseConfigDuration(configs, AMWebHookShutdownDelay, DefaultWebHookShutdownDelay)
+	acc.shutdownTimeout = parseConfigDuration(configs, AMWebHookShutdownTimeout, DefaultWebHookShutdownTimeout)
+	acc.webhookFailurePolicy = parseConfigValidated(configs, AMWebHookFailurePolicy, DefaultWebHookFailurePolicy, acc.webhookFailurePolicy, initial, validateFailurePolicy)
+	timeoutSeconds := parseConfigValidated(configs, AMWebHookTimeoutSeconds, strconv.Itoa(DefaultWebHookTimeoutSeconds), strconv.Itoa(acc.webhookTimeoutSeconds), initial, validateWebhookTimeout)
+	acc.webhookTimeoutSeconds, _ = strconv.Atoi(timeoutSeconds)
+	acc.webhookNamespaceSelector = parseConfigBool(configs, AMWebHookNamespaceSelector, DefaultWebHookNamespaceSelector)
+	acc.debugAddress = parseConfigString(configs, AMWebHookDebugAddress, DefaultWebHookDebugAddress)
+	acc.schedulerServiceScheme = parseConfigValidated(configs, AMWebHookSchedulerServiceScheme, DefaultWebHookSchedulerServiceScheme, acc.schedulerServiceScheme, initial, validateSchedulerServiceScheme)
+	if acc.schedulerServiceScheme == "" {
+		acc.schedulerServiceScheme = SchemeHTTP
+		if acc.schedulerClientCertFile != "" || acc.schedulerCAFile != "" {
+			acc.schedulerServiceScheme = SchemeHTTPS
+		}
+	}
+	acc.schedulerValidateConfPath = parseConfigValidated(configs, AMWebHookSchedulerValidateConfPath, DefaultWebHookSchedulerValidateConfPath, acc.schedulerValidateConfPath, initial, validateURLPath)
 
 	// filtering
-	acc.processNamespaces = parseConfigRegexps(configs, AMFilteringProcessNamespaces, DefaultFilteringProcessNamespaces)
-	acc.bypassNamespaces = parseConfigRegexps(configs, AMFilteringBypassNamespaces, DefaultFilteringBypassNamespaces)
-	acc.labelNamespaces = parseConfigRegexps(configs, AMFilteringLabelNamespaces, DefaultFilteringLabelNamespaces)
-	acc.noLabelNamespaces = parseConfigRegexps(configs, AMFilteringNoLabelNamespaces, DefaultFilteringNoLabelNamespaces)
+	acc.defaultQueue = parseConfigValidated(configs, AMDefaultQueue, DefaultDefaultQueue, acc.defaultQueue, initial, validateQueueName)
+	acc.namespaceSource = parseConfigValidated(configs, AMFilteringNamespaceSource, DefaultFilteringNamespaceSource, acc.namespaceSource, initial, validateNamespaceSource)
+	filters := acc.namespaceFilters
+	acc.namespaceFilters = &NamespaceFilters{
+		ProcessNamespaces:        parseConfigRegexps(configs, AMFilteringProcessNamespaces, DefaultFilteringProcessNamespaces, filters.ProcessNamespaces, initial),
+		BypassNamespaces:         parseConfigRegexps(configs, AMFilteringBypassNamespaces, DefaultFilteringBypassNamespaces, filters.BypassNamespaces, initial),
+		LabelNamespaces:          parseConfigRegexps(configs, AMFilteringLabelNamespaces, DefaultFilteringLabelNamespaces, filters.LabelNamespaces, initial),
+		NoLabelNamespaces:        parseConfigRegexps(configs, AMFilteringNoLabelNamespaces, DefaultFilteringNoLabelNamespaces, filters.NoLabelNamespaces, initial),
+		ProcessPodSelector:       parseConfigSelector(configs, AMFilteringProcessPodSelector, DefaultFilteringProcessPodSelector, filters.ProcessPodSelector, initial),
+		BypassPodSelector:        parseConfigSelector(configs, AMFilteringBypassPodSelector, DefaultFilteringBypassPodSelector, filters.BypassPodSelector, initial),
+		ProcessNamespaceSelector: parseConfigSelector(configs, AMFilteringProcessNamespaceSelector, DefaultFilteringProcessNamespaceSelector, filters.ProcessNamespaceSelector, initial),
+		BypassNamespaceSelector:  parseConfigSelector(configs, AMFilteringBypassNamespaceSelector, DefaultFilteringBypassNamespaceSelector, filters.BypassNamespaceSelector, initial),
+		LabelNamespaceSelector:   parseConfigSelector(configs, AMFilteringLabelNamespaceSelector, DefaultFilteringLabelNamespaceSelector, filters.LabelNamespaceSelector, initial),
+		NoLabelNamespaceSelector: parseConfigSelector(configs, AMFilteringNoLabelNamespaceSelector, DefaultFilteringNoLabelNamespaceSelector, filters.NoLabelNamespaceSelector, initial),
+	}
 
 	// access control
-	acc.bypassAuth = parseConfigBool(configs, AMAccessControlBypassAuth, DefaultAccessControlBypassAuth)
-	acc.trustControllers = parseConfigBool(configs, AMAccessControlTrustControllers, DefaultAccessControlTrustControllers)
-	acc.systemUsers = parseConfigRegexps(configs, AMAccessControlSystemUsers, DefaultAccessControlSystemUsers)
-	acc.externalUsers = parseConfigRegexps(configs, AMAccessControlExternalUsers, DefaultAccessControlExternalUsers)
-	acc.externalGroups = parseConfigRegexps(configs, AMAccessControlExternalGroups, DefaultAccessControlExternalGroups)
+	access := acc.accessControl
+	trustedIdentities := parseConfigValidated(configs, AMAccessControlTrustedIdentities, DefaultAccessControlTrustedIdentities,
+		trustedIdentitiesString(access.TrustedIdentities), initial, validateTrustedIdentities)
+	identities, _ := parseTrustedIdentities(trustedIdentities)
+	acc.accessControl = &AccessControl{
+		BypassAuth:           parseConfigBool(configs, AMAccessControlBypassAuth, DefaultAccessControlBypassAuth),
+		BypassAuthNamespaces: parseConfigRegexps(configs, AMAccessControlBypassAuthNamespaces, DefaultAccessControlBypassAuthNamespaces, access.BypassAuthNamespaces, initial),
+		TrustControllers:     parseConfigBool(configs, AMAccessControlTrustControllers, DefaultAccessControlTrustControllers),
+		SystemUsers:          parseConfigRegexps(configs, AMAccessControlSystemUsers, DefaultAccessControlSystemUsers, access.SystemUsers, initial),
+		ExternalUsers:        parseConfigRegexps(configs, AMAccessControlExternalUsers, DefaultAccessControlExternalUsers, access.ExternalUsers, initial),
+		ExternalGroups:       parseConfigRegexps(configs, AMAccessControlExternalGroups, DefaultAccessControlExternalGroups, access.ExternalGroups, initial),
+		TrustedIdentities:    identities,
+	}
+	acc.identityServiceURL = parseConfigString(configs, AMAccessControlIdentityServiceURL, DefaultAccessControlIdentityServiceURL)
+	acc.identityServiceTimeout = parseConfigDuration(configs, AMAccessControlIdentityServiceTimeout, DefaultAccessControlIdentityServiceTimeout)
+	acc.identityServiceCacheTTL = parseConfigDuration(configs, AMAccessControlIdentityServiceCacheTTL, DefaultAccessControlIdentityServiceCacheTTL)
+	acc.identityFailurePolicy = parseConfigValidated(configs, AMAccessControlIdentityServiceFailurePolicy, DefaultAccessControlIdentityServiceFailurePolicy, acc.identityFailurePolicy, initial, validateFailurePolicy)
+	acc.identityServiceTokenFile = parseConfigString(configs, AMAccessControlIdentityServiceTokenFile, DefaultAccessControlIdentityServiceTokenFile)
+	acc.userInfoAnnotation = parseConfigValidated(configs, AMAccessControlUserInfoAnnotation, DefaultAccessControlUserInfoAnnotation, acc.userInfoAnnotation, initial, validateAnnotationKey)
+	acc.userInfoMaxGroups = parseConfigInt(configs, AMAccessControlUserInfoMaxGroups, DefaultAccessControlUserInfoMaxGroups)
+	acc.trackRejections = parseConfigBool(configs, AMAccessControlTrackRejections, DefaultAccessControlTrackRejections)
+
+	// mutation
+	acc.schedulingPolicyParams = parseConfigSchedulingPolicyParams(configs, AMMutationDefaultSchedulingPolicyParameters, DefaultMutationDefaultSchedulingPolicyParameters)
+	acc.overrideSchedulerName = parseConfigBool(configs, AMMutationOverrideExistingSchedulerName, DefaultMutationOverrideExistingSchedulerName)
+	acc.schedulerName = parseConfigValidated(configs, AMMutationSchedulerName, DefaultMutationSchedulerName, acc.schedulerName, initial, validateSchedulerName)
+	acc.gpuResourceNames = parseConfigStrings(configs, AMMutationGPUResourceNames, DefaultMutationGPUResourceNames)
+	acc.gpuQueue = parseConfigValidated(configs, AMMutationGPUQueue, DefaultMutationGPUQueue, acc.gpuQueue, initial, validateOptionalQueueName)
+	priorityClassQueues := parseConfigValidated(configs, AMMutationPriorityClassQueues, DefaultMutationPriorityClassQueues,
+		priorityClassMappingString(acc.priorityClassQueues), initial, validatePriorityClassQueues)
+	acc.priorityClassQueues, _ = parsePriorityClassQueues(priorityClassQueues)
+	priorityClassPreemption := parseConfigValidated(configs, AMMutationPriorityClassPreemption, DefaultMutationPriorityClassPreemption,
+		priorityClassMappingString(acc.priorityClassPreemption), initial, validatePriorityClassPreemption)
+	acc.priorityClassPreemption, _ = parsePriorityClassPreemption(priorityClassPreemption)
+	acc.serviceQueue = parseConfigValidated(configs, AMMutationServiceQueue, DefaultMutationServiceQueue, acc.serviceQueue, initial, validateOptionalQueueName)
+	priorityBuckets := parseConfigValidated(configs, AMMutationPriorityBuckets, DefaultMutationPriorityBuckets,
+		priorityBucketsString(acc.priorityBuckets), initial, validatePriorityBuckets)
+	acc.priorityBuckets, _ = parsePriorityBuckets(priorityBuckets)
+	acc.autogenTerminationGracePeriod = parseConfigInt(configs, AMMutationAutogenTerminationGracePeriod, DefaultMutationAutogenTerminationGracePeriod)
+	acc.ownerBasedAppID = parseConfigBool(configs, AMMutationOwnerBasedAppID, DefaultMutationOwnerBasedAppID)
+	acc.statefulSetAppID = parseConfigBool(configs, AMMutationStatefulSetAppID, DefaultMutationStatefulSetAppID)
+	acc.replicaSetAppID = parseConfigBool(configs, AMMutationReplicaSetAppID, DefaultMutationReplicaSetAppID)
+	acc.appIDTemplate = parseConfigValidated(configs, AMMutationAppIDTemplate, DefaultMutationAppIDTemplate, acc.appIDTemplate, initial, validateAppIDTemplate)
+	acc.costCenterConfigMap = parseConfigString(configs, AMMutationCostCenterConfigMap, DefaultMutationCostCenterConfigMap)
+	acc.costCenterLabel = parseConfigString(configs, AMMutationCostCenterLabel, DefaultMutationCostCenterLabel)
+	acc.defaultCostCenter = parseConfigString(configs, AMMutationDefaultCostCenter, DefaultMutationDefaultCostCenter)
+	namespaceFieldLabels := parseConfigValidated(configs, AMMutationNamespaceFieldLabels, DefaultMutationNamespaceFieldLabels,
+		namespaceFieldLabelsString(acc.namespaceFieldLabels), initial, validateNamespaceFieldLabels)
+	acc.namespaceFieldLabels, _ = parseNamespaceFieldLabels(namespaceFieldLabels)
+	acc.generationLabel = parseConfigString(configs, AMMutationGenerationLabel, DefaultMutationGenerationLabel)
+	acc.expandTaskGroupParams = parseConfigBool(configs, AMMutationExpandTaskGroupParameters, DefaultMutationExpandTaskGroupParameters)
+	acc.jobTaskGroups = parseConfigBool(configs, AMMutationJobTaskGroups, DefaultMutationJobTaskGroups)
+	acc.annotateWarnings = parseConfigBool(configs, AMMutationAnnotateWarnings, DefaultMutationAnnotateWarnings)
+	acc.annotateDecision = parseConfigBool(configs, AMMutationAnnotateDecision, DefaultMutationAnnotateDecision)
+	acc.mutatePodTemplates = parseConfigBool(configs, AMMutationPodTemplates, DefaultMutationPodTemplates)
+	acc.disabledMutators = parseConfigStrings(configs, AMMutationDisabledMutators, DefaultMutationDisabledMutators)
+	queueNodePools := parseConfigValidated(configs, AMMutationQueueNodePools, DefaultMutationQueueNodePools,
+		queueNodePoolsString(acc.queueNodePools), initial, validateQueueNodePools)
+	acc.queueNodePools, _ = parseQueueNodePools(queueNodePools)
+	queueMappingRules := parseConfigValidated(configs, AMMutationQueueMappingRules, DefaultMutationQueueMappingRules,
+		queueMappingRulesString(acc.queueMappingRules), initial, validateQueueMappingRules)
+	acc.queueMappingRules, _ = parseQueueMappingRules(queueMappingRules)
+	defaultRequests := parseConfigValidated(configs, AMMutationDefaultResourceRequests, DefaultMutationDefaultResourceRequests,
+		namespaceResourceCapsString(acc.defaultResourceRequests), initial, validateDefaultResourceRequests)
+	acc.defaultResourceRequests, _ = parseDefaultResourceRequests(defaultRequests)
+
+	// validation
+	acc.appQueueRules = parseConfigAppQueueRules(configs, AMValidationAppQueueRules, DefaultValidationAppQueueRules, acc.appQueueRules, initial)
+	acc.maxQueueDepth = parseConfigInt(configs, AMValidationMaxQueueDepth, DefaultValidationMaxQueueDepth)
+	acc.maxQueueCount = parseConfigInt(configs, AMValidationMaxQueueCount, DefaultValidationMaxQueueCount)
+	acc.requireRootQueue = parseConfigBool(configs, AMValidationRequireRootQueue, DefaultValidationRequireRootQueue)
+	acc.strictNamespace = parseConfigBool(configs, AMValidationStrictNamespace, DefaultValidationStrictNamespace)
+	acc.ownerAppIDConflict = parseConfigValidated(configs, AMValidationOwnerAppIDConflict, DefaultValidationOwnerAppIDConflict, acc.ownerAppIDConflict, initial, validateConflictAction)
+	acc.requireQueue = parseConfigBool(configs, AMValidationRequireQueue, DefaultValidationRequireQueue)
+	acc.requireLabelNamespaces = parseConfigRegexps(configs, AMValidationRequireLabelNamespaces, DefaultValidationRequireLabelNamespaces, acc.requireLabelNamespaces, initial)
+	acc.denyActiveQueueRemoval = parseConfigBool(configs, AMValidationDenyActiveQueueRemoval, DefaultValidationDenyActiveQueueRemoval)
+	acc.denyDrainingQueues = parseConfigBool(configs, AMValidationDenyDrainingQueues, DefaultValidationDenyDrainingQueues)
+	acc.drainingQueueCacheTTL = parseConfigDuration(configs, AMValidationDrainingQueueCacheTTL, DefaultValidationDrainingQueueCacheTTL)
+	acc.queueStateTimeout = parseConfigDuration(configs, AMValidationQueueStateTimeout, DefaultValidationQueueStateTimeout)
+	acc.denyExceedingQueueMax = parseConfigBool(configs, AMValidationDenyExceedingQueueMax, DefaultValidationDenyExceedingQueueMax)
+	acc.unknownQueueAction = parseConfigValidated(configs, AMValidationUnknownQueueAction, DefaultValidationUnknownQueueAction, acc.unknownQueueAction, initial, validateUnknownQueueAction)
+	acc.unknownQueueFallback = parseConfigValidated(configs, AMValidationUnknownQueueFallback, DefaultValidationUnknownQueueFallback, acc.unknownQueueFallback, initial, validateQueueName)
+	appIDPattern := parseConfigValidated(configs, AMValidationAppIDPattern, DefaultValidationAppIDPattern, regexpString(acc.appIDPattern), initial, validateRegexp)
+	acc.appIDPattern = nil
+	if appIDPattern != "" {
+		acc.appIDPattern = regexp.MustCompile(appIDPattern)
+	}
+	acc.appIDPatternGenerated = parseConfigBool(configs, AMValidationAppIDPatternGenerated, DefaultValidationAppIDPatternGenerated)
+	acc.uniqueAppID = parseConfigBool(configs, AMValidationUniqueAppID, DefaultValidationUniqueAppID)
+	acc.uniqueAppIDIndexSize = parseConfigInt(configs, AMValidationUniqueAppIDIndexSize, DefaultValidationUniqueAppIDIndexSize)
+	acc.uniqueAppIDTTL = parseConfigDuration(configs, AMValidationUniqueAppIDTTL, DefaultValidationUniqueAppIDTTL)
+	acc.warnMissingProbes = parseConfigBool(configs, AMValidationWarnMissingProbes, DefaultValidationWarnMissingProbes)
+	resourceCaps := parseConfigValidated(configs, AMValidationNamespaceResourceCaps, DefaultValidationNamespaceResourceCaps,
+		namespaceResourceCapsString(acc.namespaceResourceCaps), initial, validateNamespaceResourceCaps)
+	acc.namespaceResourceCaps, _ = parseNamespaceResourceCaps(resourceCaps)
+	acc.namespaceResourceCapAction = parseConfigValidated(configs, AMValidationNamespaceResourceCapAction, DefaultValidationNamespaceResourceCapAction, acc.namespaceResourceCapAction, initial, validateConflictAction)
+	acc.reservedQueueProperties = parseConfigStrings(configs, AMValidationReservedQueueProperties, DefaultValidationReservedQueueProperties)
+	acc.validateTaskGroups = parseConfigBool(configs, AMValidationTaskGroups, DefaultValidationTaskGroups)
+	acc.policyURL = parseConfigString(configs, AMValidationPolicyURL, DefaultValidationPolicyURL)
+	acc.policyTimeout = parseConfigDuration(configs, AMValidationPolicyTimeout, DefaultValidationPolicyTimeout)
+	acc.policyFailurePolicy = parseConfigValidated(configs, AMValidationPolicyFailurePolicy, DefaultValidationPolicyFailurePolicy, acc.policyFailurePolicy, initial, validateFailurePolicy)
 
 	acc.dumpConfigurationInternal()
 }
@@ -350,18 +1680,142 @@ func (acc *AdmissionControllerConf) dumpConfigurationInternal() {
 	log.Logger().Info("Loaded admission controller configuration",
 		zap.String("namespace", acc.namespace),
 		zap.String("kubeConfig", acc.kubeConfig),
+		zap.Uint64("generation", acc.generation),
 		zap.String("policyGroup", acc.policyGroup),
 		zap.String("amServiceName", acc.amServiceName),
 		zap.String("schedulerServiceAddress", acc.schedulerServiceAddress),
-		zap.Strings("processNamespaces", regexpsString(acc.processNamespaces)),
-		zap.Strings("bypassNamespaces", regexpsString(acc.bypassNamespaces)),
-		zap.Strings("labelNamespaces", regexpsString(acc.labelNamespaces)),
-		zap.Strings("noLabelNamespaces", regexpsString(acc.noLabelNamespaces)),
-		zap.Bool("bypassAuth", acc.bypassAuth),
-		zap.Bool("trustControllers", acc.trustControllers),
-		zap.Strings("systemUsers", regexpsString(acc.systemUsers)),
-		zap.Strings("externalUsers", regexpsString(acc.externalUsers)),
-		zap.Strings("externalGroups", regexpsString(acc.externalGroups)))
+		zap.Bool("drainMode", acc.drainMode),
+		zap.Bool("dryRun", acc.dryRun),
+		zap.Bool("failOnSchedulerUnreachable", acc.failOnSchedulerUnreach),
+		zap.Duration("schedulerValidateTimeout", acc.schedulerValidateTimeout),
+		zap.Bool("acceptCompressedRequests", acc.acceptCompressedRequests),
+		zap.Int("maxRequestSize", acc.maxRequestSize),
+		zap.String("schedulerServiceScheme", acc.schedulerServiceScheme),
+		zap.String("schedulerValidateConfPath", acc.schedulerValidateConfPath),
+		zap.String("schedulerClientCertFile", acc.schedulerClientCertFile),
+		zap.String("schedulerClientKeyFile", acc.schedulerClientKeyFile),
+		zap.String("schedulerCAFile", acc.schedulerCAFile),
+		zap.Int("schedulerMaxResponseSize", acc.schedulerMaxResponseSize),
+		zap.String("schedulerResponsePublicKeyFile", acc.schedulerResponseKeyFile),
+		zap.Bool("emitEvents", acc.emitEvents),
+		zap.Bool("allowDebugAnnotation", acc.allowDebugAnnotation),
+		zap.Bool("readinessCheckScheduler", acc.readinessCheckScheduler),
+		zap.Duration("reconcileInterval", acc.reconcileInterval),
+		zap.Bool("leaderElection", acc.leaderElection),
+		zap.String("auditSink", acc.auditSink),
+		zap.String("auditSinkTarget", acc.auditSinkTarget),
+		zap.Duration("readTimeout", acc.readTimeout),
+		zap.Duration("writeTimeout", acc.writeTimeout),
+		zap.Int("maxConnections", acc.maxConnections),
+		zap.Int("maxInFlightRequests", acc.maxInFlightRequests),
+		zap.Int("rateLimitQPS", acc.rateLimitQPS),
+		zap.Int("rateLimitBurst", acc.rateLimitBurst),
+		zap.Int("clientRateLimitQPS", acc.clientRateLimitQPS),
+		zap.Int("clientRateLimitBurst", acc.clientRateLimitBurst),
+		zap.Duration("shutdownDelay", acc.shutdownDelay),
+		zap.Duration("shutdownTimeout", acc.shutdownTimeout),
+		zap.String("webhookFailurePolicy", acc.webhookFailurePolicy),
+		zap.Int("webhookTimeoutSeconds", acc.webhookTimeoutSeconds),
+		zap.Bool("webhookNamespaceSelector", acc.webhookNamespaceSelector),
+		zap.String("debugAddress", acc.debugAddress),
+		zap.Strings("processNamespaces", regexpsString(acc.namespaceFilters.ProcessNamespaces)),
+		zap.Strings("bypassNamespaces", regexpsString(acc.namespaceFilters.BypassNamespaces)),
+		zap.Strings("labelNamespaces", regexpsString(acc.namespaceFilters.LabelNamespaces)),
+		zap.Strings("noLabelNamespaces", regexpsString(acc.namespaceFilters.NoLabelNamespaces)),
+		zap.String("processPodSelector", selectorString(acc.namespaceFilters.ProcessPodSelector)),
+		zap.String("bypassPodSelector", selectorString(acc.namespaceFilters.BypassPodSelector)),
+		zap.String("processNamespaceSelector", selectorString(acc.namespaceFilters.ProcessNamespaceSelector)),
+		zap.String("bypassNamespaceSelector", selectorString(acc.namespaceFilters.BypassNamespaceSelector)),
+		zap.String("labelNamespaceSelector", selectorString(acc.namespaceFilters.LabelNamespaceSelector)),
+		zap.String("noLabelNamespaceSelector", selectorString(acc.namespaceFilters.NoLabelNamespaceSelector)),
+		zap.String("defaultQueue", acc.defaultQueue),
+		zap.String("namespaceSource", acc.namespaceSource),
+		zap.Bool("bypassAuth", acc.accessControl.BypassAuth),
+		zap.Strings("bypassAuthNamespaces", regexpsString(acc.accessControl.BypassAuthNamespaces)),
+		zap.Bool("trustControllers", acc.accessControl.TrustControllers),
+		zap.Strings("systemUsers", regexpsString(acc.accessControl.SystemUsers)),
+		zap.Strings("externalUsers", regexpsString(acc.accessControl.ExternalUsers)),
+		zap.Strings("externalGroups", regexpsString(acc.accessControl.ExternalGroups)),
+		zap.String("trustedIdentities", trustedIdentitiesString(acc.accessControl.TrustedIdentities)),
+		zap.String("identityServiceURL", acc.identityServiceURL),
+		zap.Duration("identityServiceTimeout", acc.identityServiceTimeout),
+		zap.Duration("identityServiceCacheTTL", acc.identityServiceCacheTTL),
+		zap.String("identityServiceFailurePolicy", acc.identityFailurePolicy),
+		zap.String("identityServiceTokenFile", acc.identityServiceTokenFile),
+		zap.String("userInfoAnnotation", acc.userInfoAnnotation),
+		zap.Int("userInfoMaxGroups", acc.userInfoMaxGroups),
+		zap.Bool("trackRejections", acc.trackRejections),
+		zap.String("defaultSchedulingPolicyParameters", acc.schedulingPolicyParams),
+		zap.Bool("overrideExistingSchedulerName", acc.overrideSchedulerName),
+		zap.String("schedulerName", acc.schedulerName),
+		zap.Strings("gpuResourceNames", acc.gpuResourceNames),
+		zap.String("gpuQueue", acc.gpuQueue),
+		zap.String("priorityClassQueues", priorityClassMappingString(acc.priorityClassQueues)),
+		zap.String("priorityClassPreemption", priorityClassMappingString(acc.priorityClassPreemption)),
+		zap.String("serviceQueue", acc.serviceQueue),
+		zap.String("priorityBuckets", priorityBucketsString(acc.priorityBuckets)),
+		zap.Int("autogenTerminationGracePeriodSeconds", acc.autogenTerminationGracePeriod),
+		zap.Bool("ownerBasedAppId", acc.ownerBasedAppID),
+		zap.Bool("statefulSetAppId", acc.statefulSetAppID),
+		zap.Bool("replicaSetAppId", acc.replicaSetAppID),
+		zap.String("appIdTemplate", acc.appIDTemplate),
+		zap.String("costCenterConfigMap", acc.costCenterConfigMap),
+		zap.String("costCenterLabel", acc.costCenterLabel),
+		zap.String("namespaceFieldLabels", namespaceFieldLabelsString(acc.namespaceFieldLabels)),
+		zap.String("defaultCostCenter", acc.defaultCostCenter),
+		zap.String("generationLabel", acc.generationLabel),
+		zap.Bool("expandTaskGroupParameters", acc.expandTaskGroupParams),
+		zap.Bool("jobTaskGroups", acc.jobTaskGroups),
+		zap.Bool("annotateWarnings", acc.annotateWarnings),
+		zap.Bool("annotateDecision", acc.annotateDecision),
+		zap.Bool("mutatePodTemplates", acc.mutatePodTemplates),
+		zap.Strings("disabledMutators", acc.disabledMutators),
+		zap.String("queueNodePools", queueNodePoolsString(acc.queueNodePools)),
+		zap.String("queueMappingRules", queueMappingRulesString(acc.queueMappingRules)),
+		zap.String("defaultResourceRequests", namespaceResourceCapsString(acc.defaultResourceRequests)),
+		zap.Strings("appQueueRules", appQueueRulesString(acc.appQueueRules)),
+		zap.Int("maxQueueDepth", acc.maxQueueDepth),
+		zap.Int("maxQueueCount", acc.maxQueueCount),
+		zap.Bool("requireRootQueue", acc.requireRootQueue),
+		zap.Bool("strictNamespace", acc.strictNamespace),
+		zap.String("ownerAppIdConflict", acc.ownerAppIDConflict),
+		zap.Bool("requireQueue", acc.requireQueue),
+		zap.Strings("requireLabelNamespaces", regexpsString(acc.requireLabelNamespaces)),
+		zap.Bool("denyActiveQueueRemoval", acc.denyActiveQueueRemoval),
+		zap.Bool("denyDrainingQueues", acc.denyDrainingQueues),
+		zap.Duration("drainingQueueCacheTTL", acc.drainingQueueCacheTTL),
+		zap.Duration("queueStateTimeout", acc.queueStateTimeout),
+		zap.Bool("denyExceedingQueueMax", acc.denyExceedingQueueMax),
+		zap.String("unknownQueueAction", acc.unknownQueueAction),
+		zap.String("unknownQueueFallback", acc.unknownQueueFallback),
+		zap.String("appIdPattern", regexpString(acc.appIDPattern)),
+		zap.Bool("appIdPatternGenerated", acc.appIDPatternGenerated),
+		zap.Bool("uniqueAppId", acc.uniqueAppID),
+		zap.Int("uniqueAppIdIndexSize", acc.uniqueAppIDIndexSize),
+		zap.Duration("uniqueAppIdTTL", acc.uniqueAppIDTTL),
+		zap.Bool("warnMissingProbes", acc.warnMissingProbes),
+		zap.String("namespaceResourceCaps", namespaceResourceCapsString(acc.namespaceResourceCaps)),
+		zap.String("namespaceResourceCapAction", acc.namespaceResourceCapAction),
+		zap.Strings("reservedQueueProperties", acc.reservedQueueProperties),
+		zap.Bool("taskGroups", acc.validateTaskGroups),
+		zap.String("policyURL", acc.policyURL),
+		zap.Duration("policyTimeout", acc.policyTimeout),
+		zap.String("policyFailurePolicy", acc.policyFailurePolicy),
+		zap.Bool("maskAnnotations", acc.maskAnnotations))
+}
+
+func selectorString(selector labels.Selector) string {
+	if selector == nil {
+		return ""
+	}
+	return selector.String()
+}
+
+func regexpString(regex *regexp.Regexp) string {
+	if regex == nil {
+		return ""
+	}
+	return regex.String()
 }
 
 func regexpsString(regexes []*regexp.Regexp) []string {
@@ -372,9 +1826,24 @@ func regexpsString(regexes []*regexp.Regexp) []string {
 	return result
 }
 
-func parseConfigRegexps(config map[string]string, key string, defaultValue string) []*regexp.Regexp {
+func appQueueRulesString(rules []*AppQueueRule) []string {
+	result := make([]string, 0)
+	for _, rule := range rules {
+		result = append(result, rule.String())
+	}
+	return result
+}
+
+// parseConfigRegexps compiles the comma separated list of regular expressions. An invalid list falls back to the
+// default during the initial load, on a reload the previously compiled list is retained.
+func parseConfigRegexps(config map[string]string, key string, defaultValue string, previousValue []*regexp.Regexp, initial bool) []*regexp.Regexp {
 	value := parseConfigString(config, key, defaultValue)
 	result, err := parseRegexes(value)
+	if err != nil && !initial {
+		log.Logger().Error(fmt.Sprintf("Unable to parse regex values '%s' for configuration '%s', keeping previous value '%s'",
+			value, key, strings.Join(regexpsString(previousValue), ",")), zap.Error(err))
+		return previousValue
+	}
 	if err != nil {
 		log.Logger().Error(fmt.Sprintf("Unable to parse regex values '%s' for configuration '%s', using default value '%s'",
 			value, key, defaultValue), zap.Error(err))
@@ -386,6 +1855,591 @@ func parseConfigRegexps(config map[string]string, key string, defaultValue strin
 	return result
 }
 
+// parseConfigAppQueueRules parses the application queue rules. Falling back to the default would silently turn off
+// the enforcement: invalid rules are fatal on the initial load and keep the previous rules on a reload.
+func parseConfigAppQueueRules(config map[string]string, key string, defaultValue string, previousValue []*AppQueueRule, initial bool) []*AppQueueRule {
+	value := parseConfigString(config, key, defaultValue)
+	result, err := parseAppQueueRules(value)
+	if err != nil {
+		if initial {
+			log.Logger().Fatal(fmt.Sprintf("Unable to parse application queue rules '%s' for configuration '%s'", value, key), zap.Error(err))
+		}
+		log.Logger().Error(fmt.Sprintf("Unable to parse application queue rules '%s' for configuration '%s', keeping previous value '%s'",
+			value, key, strings.Join(appQueueRulesString(previousValue), ",")), zap.Error(err))
+		return previousValue
+	}
+	return result
+}
+
+// parseAppQueueRules parses a comma separated list of <appIdRegex>=<queuePrefix> entries. Queue names cannot contain
+// an equals sign, so the entry is split at the last one.
+func parseAppQueueRules(rules string) ([]*AppQueueRule, error) {
+	result := make([]*AppQueueRule, 0)
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+		idx := strings.LastIndex(rule, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("missing queue prefix in application queue rule '%s'", rule)
+		}
+		queuePrefix := strings.TrimSpace(rule[idx+1:])
+		if err := validateQueueName(queuePrefix); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(strings.TrimSpace(rule[:idx]))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &AppQueueRule{AppID: re, QueuePrefix: queuePrefix})
+	}
+	return result, nil
+}
+
+// parsePriorityClassQueues parses a comma separated list of <priorityClass>=<queue> entries.
+func parsePriorityClassQueues(mapping string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		priorityClass := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || priorityClass == "" {
+			return nil, fmt.Errorf("priority class queue mapping '%s' must be of the form priorityClass=queue", entry)
+		}
+		if _, ok := result[priorityClass]; ok {
+			return nil, fmt.Errorf("duplicate priority class '%s' in priority class queue mapping", priorityClass)
+		}
+		queue := strings.TrimSpace(kv[1])
+		if err := validateQueueName(queue); err != nil {
+			return nil, err
+		}
+		result[priorityClass] = queue
+	}
+	return result, nil
+}
+
+func validatePriorityClassQueues(mapping string) error {
+	_, err := parsePriorityClassQueues(mapping)
+	return err
+}
+
+// parsePriorityClassPreemption parses a comma separated list of <priorityClass>=<true|false> entries.
+func parsePriorityClassPreemption(mapping string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		priorityClass := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || priorityClass == "" {
+			return nil, fmt.Errorf("priority class preemption mapping '%s' must be of the form priorityClass=true|false", entry)
+		}
+		if _, ok := result[priorityClass]; ok {
+			return nil, fmt.Errorf("duplicate priority class '%s' in priority class preemption mapping", priorityClass)
+		}
+		allow, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid preemption value '%s' for priority class '%s'", strings.TrimSpace(kv[1]), priorityClass)
+		}
+		result[priorityClass] = strconv.FormatBool(allow)
+	}
+	return result, nil
+}
+
+func validatePriorityClassPreemption(mapping string) error {
+	_, err := parsePriorityClassPreemption(mapping)
+	return err
+}
+
+// priorityClassMappingString returns the mapping in its configuration format, sorted by priority class.
+func priorityClassMappingString(mapping map[string]string) string {
+	entries := make([]string, 0, len(mapping))
+	for priorityClass, queue := range mapping {
+		entries = append(entries, priorityClass+"="+queue)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// parseQueueNodePools parses a JSON object which maps a queue to its node pool, for example:
+// {"root.gpu": {"nodeSelector": {"pool": "gpu"}, "tolerations": [{"key": "dedicated", "value": "gpu", "effect": "NoSchedule"}]}}
+func parseQueueNodePools(value string) (map[string]*QueueNodePool, error) {
+	result := make(map[string]*QueueNodePool)
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid queue node pools: %v", err)
+	}
+	for queue, pool := range result {
+		if err := validateQueueName(queue); err != nil {
+			return nil, err
+		}
+		if pool == nil || (len(pool.NodeSelector) == 0 && len(pool.Tolerations) == 0) {
+			return nil, fmt.Errorf("node pool of queue '%s' must set a node selector or tolerations", queue)
+		}
+		for key, value := range pool.NodeSelector {
+			if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+				return nil, fmt.Errorf("invalid node selector key '%s' for queue '%s': %s", key, queue, strings.Join(errs, ", "))
+			}
+			if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+				return nil, fmt.Errorf("invalid node selector value '%s' for queue '%s': %s", value, queue, strings.Join(errs, ", "))
+			}
+		}
+		for _, toleration := range pool.Tolerations {
+			if err := validateToleration(toleration); err != nil {
+				return nil, fmt.Errorf("invalid toleration for queue '%s': %v", queue, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func validateToleration(toleration v1.Toleration) error {
+	switch toleration.Operator {
+	case v1.TolerationOpEqual, "":
+	case v1.TolerationOpExists:
+		if toleration.Value != "" {
+			return fmt.Errorf("value must be empty for operator '%s'", toleration.Operator)
+		}
+	default:
+		return fmt.Errorf("unsupported operator '%s'", toleration.Operator)
+	}
+	if toleration.Key == "" && toleration.Operator != v1.TolerationOpExists {
+		return fmt.Errorf("operator must be '%s' if the key is empty", v1.TolerationOpExists)
+	}
+	switch toleration.Effect {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute, "":
+	default:
+		return fmt.Errorf("unsupported effect '%s'", toleration.Effect)
+	}
+	return nil
+}
+
+func validateQueueNodePools(value string) error {
+	_, err := parseQueueNodePools(value)
+	return err
+}
+
+// queueNodePoolsString returns the node pools in their configuration format.
+func queueNodePoolsString(pools map[string]*QueueNodePool) string {
+	if len(pools) == 0 {
+		return ""
+	}
+	value, err := json.Marshal(pools)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+// parsePriorityBuckets parses a comma separated list of <bucket>=<minPriority> entries. The bucket name is used as a
+// label value.
+func parsePriorityBuckets(buckets string) ([]*PriorityBucket, error) {
+	result := make([]*PriorityBucket, 0)
+	names := make(map[string]bool)
+	priorities := make(map[int32]bool)
+	for _, entry := range strings.Split(buckets, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("priority bucket '%s' must be of the form bucket=minPriority", entry)
+		}
+		name := strings.TrimSpace(kv[0])
+		if errs := validation.IsValidLabelValue(name); name == "" || len(errs) != 0 {
+			return nil, fmt.Errorf("invalid priority bucket name '%s': %s", name, strings.Join(errs, ", "))
+		}
+		minPriority, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum priority for priority bucket '%s': %v", name, err)
+		}
+		if names[name] || priorities[int32(minPriority)] {
+			return nil, fmt.Errorf("duplicate priority bucket '%s'", entry)
+		}
+		names[name] = true
+		priorities[int32(minPriority)] = true
+		result = append(result, &PriorityBucket{Name: name, MinPriority: int32(minPriority)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MinPriority > result[j].MinPriority
+	})
+	return result, nil
+}
+
+// parseNamespaceResourceCaps parses a comma separated list of <namespace>:<resource>=<quantity> entries. A namespace
+// can be listed multiple times to cap more than one resource.
+func parseNamespaceResourceCaps(caps string) (map[string]v1.ResourceList, error) {
+	return parseNamespaceResourceLists(caps, "namespace resource cap")
+}
+
+func validateNamespaceResourceCaps(caps string) error {
+	_, err := parseNamespaceResourceCaps(caps)
+	return err
+}
+
+// parseDefaultResourceRequests parses the default requests in the same form as the namespace resource caps. The
+// namespace * sets the default for all namespaces, only cpu and memory requests can be defaulted.
+func parseDefaultResourceRequests(requests string) (map[string]v1.ResourceList, error) {
+	result, err := parseNamespaceResourceLists(requests, "default resource request")
+	if err != nil {
+		return nil, err
+	}
+	for namespace, resources := range result {
+		for name := range resources {
+			if name != v1.ResourceCPU && name != v1.ResourceMemory {
+				return nil, fmt.Errorf("default resource request '%s:%s' must be for cpu or memory", namespace, name)
+			}
+		}
+	}
+	return result, nil
+}
+
+func validateDefaultResourceRequests(requests string) error {
+	_, err := parseDefaultResourceRequests(requests)
+	return err
+}
+
+func parseNamespaceResourceLists(value string, kind string) (map[string]v1.ResourceList, error) {
+	result := make(map[string]v1.ResourceList)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		key := strings.SplitN(kv[0], ":", 2)
+		if len(kv) != 2 || len(key) != 2 {
+			return nil, fmt.Errorf("%s '%s' must be of the form namespace:resource=quantity", kind, entry)
+		}
+		namespace := strings.TrimSpace(key[0])
+		name := v1.ResourceName(strings.TrimSpace(key[1]))
+		if namespace == "" || name == "" {
+			return nil, fmt.Errorf("%s '%s' must be of the form namespace:resource=quantity", kind, entry)
+		}
+		quantity, err := resource.ParseQuantity(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity for %s '%s': %v", kind, entry, err)
+		}
+		if _, ok := result[namespace][name]; ok {
+			return nil, fmt.Errorf("duplicate %s '%s'", kind, entry)
+		}
+		if result[namespace] == nil {
+			result[namespace] = make(v1.ResourceList)
+		}
+		result[namespace][name] = quantity
+	}
+	return result, nil
+}
+
+func namespaceResourceCapsString(caps map[string]v1.ResourceList) string {
+	entries := make([]string, 0)
+	for namespace, resources := range caps {
+		for name, quantity := range resources {
+			entries = append(entries, fmt.Sprintf("%s:%s=%s", namespace, name, quantity.String()))
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// parseNamespaceFieldLabels parses a comma separated list of <label>=<fieldPath> entries. The field path is one of
+// metadata.name, metadata.uid, metadata.labels['<key>'] or metadata.annotations['<key>'].
+func parseNamespaceFieldLabels(mapping string) ([]*NamespaceFieldLabel, error) {
+	result := make([]*NamespaceFieldLabel, 0)
+	labels := make(map[string]bool)
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("namespace field label '%s' must be of the form label=fieldPath", entry)
+		}
+		label := strings.TrimSpace(kv[0])
+		if errs := validation.IsQualifiedName(label); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid label '%s' in namespace field label mapping: %s", label, strings.Join(errs, ", "))
+		}
+		if labels[label] {
+			return nil, fmt.Errorf("duplicate label '%s' in namespace field label mapping", label)
+		}
+		field, key, err := parseNamespaceFieldPath(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, err
+		}
+		labels[label] = true
+		result = append(result, &NamespaceFieldLabel{Label: label, Field: field, Key: key})
+	}
+	return result, nil
+}
+
+func parseNamespaceFieldPath(path string) (string, string, error) {
+	switch path {
+	case NamespaceFieldName, NamespaceFieldUID:
+		return path, "", nil
+	}
+	for _, field := range []string{NamespaceFieldLabels, NamespaceFieldAnnotations} {
+		if !strings.HasPrefix(path, field+"['") || !strings.HasSuffix(path, "']") {
+			continue
+		}
+		key := path[len(field)+2 : len(path)-2]
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return "", "", fmt.Errorf("invalid key in namespace field path '%s': %s", path, strings.Join(errs, ", "))
+		}
+		return field, key, nil
+	}
+	return "", "", fmt.Errorf("unsupported namespace field path '%s'", path)
+}
+
+func validateNamespaceFieldLabels(mapping string) error {
+	_, err := parseNamespaceFieldLabels(mapping)
+	return err
+}
+
+func namespaceFieldLabelsString(mapping []*NamespaceFieldLabel) string {
+	entries := make([]string, 0, len(mapping))
+	for _, entry := range mapping {
+		entries = append(entries, entry.String())
+	}
+	return strings.Join(entries, ",")
+}
+
+func validatePriorityBuckets(buckets string) error {
+	_, err := parsePriorityBuckets(buckets)
+	return err
+}
+
+func priorityBucketsString(buckets []*PriorityBucket) string {
+	entries := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		entries = append(entries, fmt.Sprintf("%s=%d", bucket.Name, bucket.MinPriority))
+	}
+	return strings.Join(entries, ",")
+}
+
+// parseConfigSchedulingPolicyParams accepts parameters separated by either commas or spaces and
+// normalizes them to the delimiter expected by the shim.
+func parseConfigSchedulingPolicyParams(config map[string]string, key string, defaultValue string) string {
+	value := parseConfigString(config, key, defaultValue)
+	params := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, param := range params {
+		if len(strings.Split(param, "=")) != 2 {
+			log.Logger().Error(fmt.Sprintf("Unable to parse scheduling policy parameters '%s' for configuration '%s', using default value '%s'",
+				value, key, defaultValue))
+			return defaultValue
+		}
+	}
+	return strings.Join(params, constants.SchedulingPolicyParamDelimiter)
+}
+
+// parseConfigSelector parses a label selector in the standard selector syntax, an empty value returns nil.
+func parseConfigSelector(config map[string]string, key string, defaultValue string, previousValue labels.Selector, initial bool) labels.Selector {
+	value := parseConfigValidated(config, key, defaultValue, selectorString(previousValue), initial, validateSelector)
+	selector, err := parseSelector(value)
+	if err != nil {
+		log.Logger().Fatal("BUG: can't parse validated label selector", zap.Error(err))
+	}
+	return selector
+}
+
+func parseSelector(value string) (labels.Selector, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	labelSelector, err := metav1.ParseToLabelSelector(value)
+	if err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(labelSelector)
+}
+
+func validateSelector(value string) error {
+	_, err := parseSelector(value)
+	return err
+}
+
+// parseConfigValidated returns the configured value if it passes validation. An invalid value is fatal during the
+// initial load so that typos are caught at startup, on a reload the previous value is retained.
+func parseConfigValidated(config map[string]string, key string, defaultValue string, previousValue string, initial bool, validate func(string) error) string {
+	value := parseConfigString(config, key, defaultValue)
+	if err := validate(value); err != nil {
+		if initial {
+			log.Logger().Fatal(fmt.Sprintf("Invalid value '%s' for configuration '%s'", value, key), zap.Error(err))
+		}
+		log.Logger().Error(fmt.Sprintf("Invalid value '%s' for configuration '%s', keeping previous value '%s'",
+			value, key, previousValue), zap.Error(err))
+		return previousValue
+	}
+	return value
+}
+
+// validateQueueName checks that the name is a dot delimited queue path with valid queue names at each level.
+func validateQueueName(name string) error {
+	if name == "" {
+		return fmt.Errorf("queue name must not be empty")
+	}
+	for _, part := range strings.Split(name, ".") {
+		if !queueNameRegExp.MatchString(part) {
+			return fmt.Errorf("invalid queue name '%s' in queue path '%s'", part, name)
+		}
+	}
+	return nil
+}
+
+// validateAnnotationKey checks that the key is a valid qualified name for an annotation.
+func validateAnnotationKey(key string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+		return fmt.Errorf("invalid annotation key '%s': %s", key, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validateSchedulerName checks that the name is accepted as the scheduler name of a pod.
+func validateSchedulerName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return fmt.Errorf("invalid scheduler name '%s': %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func validateNamespaceSource(source string) error {
+	if source != NamespaceSourceRequest && source != NamespaceSourceObject {
+		return fmt.Errorf("namespace source must be one of '%s' or '%s'", NamespaceSourceRequest, NamespaceSourceObject)
+	}
+	return nil
+}
+
+func validateRegexp(pattern string) error {
+	_, err := regexp.Compile(pattern)
+	return err
+}
+
+func validateSchedulerServiceScheme(scheme string) error {
+	if scheme != "" && scheme != SchemeHTTP && scheme != SchemeHTTPS {
+		return fmt.Errorf("scheduler service scheme must be one of '%s' or '%s'", SchemeHTTP, SchemeHTTPS)
+	}
+	return nil
+}
+
+// validateURLPath accepts an absolute URL path without query or fragment.
+func validateURLPath(path string) error {
+	parsed, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid URL path '%s': %v", path, err)
+	}
+	if !strings.HasPrefix(path, "/") || parsed.Path != path || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid URL path '%s': must be an absolute path without query or fragment", path)
+	}
+	return nil
+}
+
+func validateFailurePolicy(policy string) error {
+	if policy != FailurePolicyFail && policy != FailurePolicyIgnore {
+		return fmt.Errorf("failure policy must be one of '%s' or '%s'", FailurePolicyFail, FailurePolicyIgnore)
+	}
+	return nil
+}
+
+// validateWebhookTimeout checks the timeout against the range accepted by the API server for a webhook.
+func validateWebhookTimeout(value string) error {
+	timeout, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if timeout < 1 || timeout > 30 {
+		return fmt.Errorf("webhook timeout must be between 1 and 30 seconds")
+	}
+	return nil
+}
+
+func validateConflictAction(action string) error {
+	if action != ConflictActionAllow && action != ConflictActionWarn && action != ConflictActionDeny {
+		return fmt.Errorf("conflict action must be one of '%s', '%s' or '%s'", ConflictActionAllow, ConflictActionWarn, ConflictActionDeny)
+	}
+	return nil
+}
+
+func validateAuditSink(sink string) error {
+	switch sink {
+	case AuditSinkNone, AuditSinkLog, AuditSinkStdout, AuditSinkFile, AuditSinkHTTP:
+		return nil
+	}
+	return fmt.Errorf("audit sink must be one of '%s', '%s', '%s', '%s' or '%s'", AuditSinkNone, AuditSinkLog, AuditSinkStdout, AuditSinkFile, AuditSinkHTTP)
+}
+
+func validateUnknownQueueAction(action string) error {
+	switch action {
+	case UnknownQueueActionAllow, UnknownQueueActionDeny, UnknownQueueActionFallback:
+		return nil
+	}
+	return fmt.Errorf("unknown queue action must be one of '%s', '%s' or '%s'", UnknownQueueActionAllow, UnknownQueueActionDeny, UnknownQueueActionFallback)
+}
+
+// validateAppIDTemplate checks that the template only uses known placeholders and that the remaining text is valid
+// in a label value.
+func validateAppIDTemplate(template string) error {
+	if template == "" {
+		return fmt.Errorf("application ID template must not be empty")
+	}
+	for _, placeholder := range appIDTemplatePlaceholderRegExp.FindAllString(template, -1) {
+		if !isAppIDTemplatePlaceholder(placeholder) {
+			return fmt.Errorf("unknown placeholder '%s' in application ID template, supported placeholders are %s",
+				placeholder, strings.Join(appIDTemplatePlaceholders, ", "))
+		}
+	}
+	literal := appIDTemplatePlaceholderRegExp.ReplaceAllString(template, "")
+	if strings.ContainsAny(literal, "{}") {
+		return fmt.Errorf("unbalanced braces in application ID template '%s'", template)
+	}
+	if !appIDTemplateLiteralRegExp.MatchString(literal) {
+		return fmt.Errorf("application ID template '%s' contains characters that are not valid in a label value", template)
+	}
+	return nil
+}
+
+func isAppIDTemplatePlaceholder(placeholder string) bool {
+	for _, known := range appIDTemplatePlaceholders {
+		if placeholder == known {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOptionalQueueName allows an empty value to disable a feature, any other value must be a valid queue name.
+func validateOptionalQueueName(name string) error {
+	if name == "" {
+		return nil
+	}
+	return validateQueueName(name)
+}
+
+// parseConfigStrings splits a comma separated value into its trimmed, non-empty parts.
+func parseConfigStrings(config map[string]string, key string, defaultValue string) []string {
+	value := parseConfigString(config, key, defaultValue)
+	result := make([]string, 0)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) != 0 {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
 func parseConfigBool(config map[string]string, key string, defaultValue bool) bool {
 	value := parseConfigString(config, key, fmt.Sprintf("%t", defaultValue))
 	result, err := strconv.ParseBool(value)
@@ -408,6 +2462,17 @@ func parseConfigInt(config map[string]string, key string, defaultValue int) int
 	return int(result)
 }
 
+func parseConfigDuration(config map[string]string, key string, defaultValue time.Duration) time.Duration {
+	value := parseConfigString(config, key, defaultValue.String())
+	result, err := time.ParseDuration(value)
+	if err != nil || result < 0 {
+		log.Logger().Error(fmt.Sprintf("Unable to parse duration value '%s' for configuration '%s', using default value '%s'",
+			value, key, defaultValue), zap.Error(err))
+		return defaultValue
+	}
+	return result
+}
+
 func parseConfigString(config map[string]string, key string, defaultValue string) string {
 	if value, ok := config[key]; ok {
 		return value
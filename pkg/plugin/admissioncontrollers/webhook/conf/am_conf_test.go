@@ -20,10 +20,13 @@ package conf
 
 import (
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
 	schedulerconf "github.com/apache/yunikorn-k8shim/pkg/conf"
 )
 
@@ -37,6 +40,7 @@ func TestConfigMapVars(t *testing.T) {
 		AMFilteringBypassNamespaces:      "testBypassNamespaces",
 		AMFilteringLabelNamespaces:       "testLabelNamespaces",
 		AMFilteringNoLabelNamespaces:     "testNolabelNamespaces",
+		AMDefaultQueue:                   "root.sandbox",
 		AMAccessControlBypassAuth:        "true",
 		AMAccessControlSystemUsers:       "systemuser",
 		AMAccessControlExternalUsers:     "yunikorn",
@@ -46,15 +50,16 @@ func TestConfigMapVars(t *testing.T) {
 	assert.Equal(t, conf.GetPolicyGroup(), "testPolicyGroup")
 	assert.Equal(t, conf.GetAmServiceName(), "testYunikornService")
 	assert.Equal(t, conf.GetSchedulerServiceAddress(), "testAddress")
-	assert.Equal(t, conf.GetProcessNamespaces()[0].String(), "testProcessNamespaces")
-	assert.Equal(t, conf.GetBypassNamespaces()[0].String(), "testBypassNamespaces")
-	assert.Equal(t, conf.GetLabelNamespaces()[0].String(), "testLabelNamespaces")
-	assert.Equal(t, conf.GetNoLabelNamespaces()[0].String(), "testNolabelNamespaces")
-	assert.Equal(t, conf.GetBypassAuth(), true)
-	assert.Equal(t, conf.GetSystemUsers()[0].String(), "systemuser")
-	assert.Equal(t, conf.GetExternalUsers()[0].String(), "yunikorn")
-	assert.Equal(t, conf.GetExternalGroups()[0].String(), "devs")
-	assert.Equal(t, conf.GetTrustControllers(), false)
+	assert.Equal(t, conf.GetNamespaceFilters().ProcessNamespaces[0].String(), "testProcessNamespaces")
+	assert.Equal(t, conf.GetNamespaceFilters().BypassNamespaces[0].String(), "testBypassNamespaces")
+	assert.Equal(t, conf.GetNamespaceFilters().LabelNamespaces[0].String(), "testLabelNamespaces")
+	assert.Equal(t, conf.GetNamespaceFilters().NoLabelNamespaces[0].String(), "testNolabelNamespaces")
+	assert.Equal(t, conf.GetDefaultQueue(), "root.sandbox")
+	assert.Equal(t, conf.GetAccessControl().BypassAuth, true)
+	assert.Equal(t, conf.GetAccessControl().SystemUsers[0].String(), "systemuser")
+	assert.Equal(t, conf.GetAccessControl().ExternalUsers[0].String(), "yunikorn")
+	assert.Equal(t, conf.GetAccessControl().ExternalGroups[0].String(), "devs")
+	assert.Equal(t, conf.GetAccessControl().TrustControllers, false)
 
 	// test missing settings
 	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
@@ -62,23 +67,24 @@ func TestConfigMapVars(t *testing.T) {
 	assert.Equal(t, conf.GetNamespace(), schedulerconf.DefaultNamespace)
 	assert.Equal(t, conf.GetAmServiceName(), DefaultWebHookAmServiceName)
 	assert.Equal(t, conf.GetSchedulerServiceAddress(), DefaultWebHookSchedulerServiceAddress)
-	assert.Equal(t, 0, len(conf.GetProcessNamespaces()))
-	assert.Equal(t, conf.GetBypassNamespaces()[0].String(), DefaultFilteringBypassNamespaces)
-	assert.Equal(t, 0, len(conf.GetLabelNamespaces()))
-	assert.Equal(t, 0, len(conf.GetNoLabelNamespaces()))
-	assert.Equal(t, conf.GetBypassAuth(), DefaultAccessControlBypassAuth)
-	assert.Equal(t, conf.GetSystemUsers()[0].String(), DefaultAccessControlSystemUsers)
-	assert.Equal(t, 0, len(conf.GetExternalUsers()))
-	assert.Equal(t, 0, len(conf.GetExternalGroups()))
-	assert.Equal(t, conf.GetTrustControllers(), DefaultAccessControlTrustControllers)
+	assert.Equal(t, 0, len(conf.GetNamespaceFilters().ProcessNamespaces))
+	assert.Equal(t, conf.GetNamespaceFilters().BypassNamespaces[0].String(), DefaultFilteringBypassNamespaces)
+	assert.Equal(t, 0, len(conf.GetNamespaceFilters().LabelNamespaces))
+	assert.Equal(t, 0, len(conf.GetNamespaceFilters().NoLabelNamespaces))
+	assert.Equal(t, conf.GetDefaultQueue(), DefaultDefaultQueue)
+	assert.Equal(t, conf.GetAccessControl().BypassAuth, DefaultAccessControlBypassAuth)
+	assert.Equal(t, conf.GetAccessControl().SystemUsers[0].String(), DefaultAccessControlSystemUsers)
+	assert.Equal(t, 0, len(conf.GetAccessControl().ExternalUsers))
+	assert.Equal(t, 0, len(conf.GetAccessControl().ExternalGroups))
+	assert.Equal(t, conf.GetAccessControl().TrustControllers, DefaultAccessControlTrustControllers)
 
 	// test faulty settings for boolean values
 	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
 		AMAccessControlBypassAuth:       "xyz",
 		AMAccessControlTrustControllers: "xyz",
 	}}})
-	assert.Equal(t, conf.GetBypassAuth(), DefaultAccessControlBypassAuth)
-	assert.Equal(t, conf.GetTrustControllers(), DefaultAccessControlTrustControllers)
+	assert.Equal(t, conf.GetAccessControl().BypassAuth, DefaultAccessControlBypassAuth)
+	assert.Equal(t, conf.GetAccessControl().TrustControllers, DefaultAccessControlTrustControllers)
 
 	// test disable / enable of config hot refresh
 	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
@@ -118,3 +124,532 @@ func TestConfigMapVars(t *testing.T) {
 	}}, false)
 	assert.Equal(t, conf.GetPolicyGroup(), "testPolicyGroup2")
 }
+
+func TestParseConfigSchedulingPolicyParams(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationDefaultSchedulingPolicyParameters: "placeholderTimeoutInSeconds=60, gangSchedulingStyle=Hard",
+	}}})
+	assert.Equal(t, conf.GetDefaultSchedulingPolicyParameters(), "placeholderTimeoutInSeconds=60 gangSchedulingStyle=Hard")
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationDefaultSchedulingPolicyParameters: "placeholderTimeoutInSeconds",
+	}}})
+	assert.Equal(t, conf.GetDefaultSchedulingPolicyParameters(), DefaultMutationDefaultSchedulingPolicyParameters)
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetDefaultSchedulingPolicyParameters(), DefaultMutationDefaultSchedulingPolicyParameters)
+}
+
+func TestDefaultQueueValidation(t *testing.T) {
+	assert.NilError(t, validateQueueName("root.default"))
+	assert.NilError(t, validateQueueName("sandbox"))
+	assert.ErrorContains(t, validateQueueName(""), "must not be empty")
+	assert.ErrorContains(t, validateQueueName("root..default"), "invalid queue name")
+	assert.ErrorContains(t, validateQueueName("root.def ault"), "invalid queue name")
+
+	// an invalid value on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMDefaultQueue: "root.sandbox",
+	}}})
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMDefaultQueue: "root.",
+	}}}, false)
+	assert.Equal(t, conf.GetDefaultQueue(), "root.sandbox")
+}
+
+func TestParseAppQueueRules(t *testing.T) {
+	rules, err := parseAppQueueRules("^team-a-=root.team-a, ^a=b$=root.b")
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 2)
+	assert.Equal(t, rules[0].QueuePrefix, "root.team-a")
+	assert.Equal(t, rules[1].AppID.String(), "^a=b$")
+	assert.Equal(t, rules[1].QueuePrefix, "root.b")
+	assert.Check(t, rules[0].Matches("team-a-1", "root.team-a"))
+	assert.Check(t, rules[0].Matches("team-a-1", "root.team-a.child"))
+	assert.Check(t, !rules[0].Matches("team-a-1", "root.team-ab"))
+	assert.Check(t, rules[0].Matches("team-b-1", "root.other"))
+
+	_, err = parseAppQueueRules("^team-a-")
+	assert.ErrorContains(t, err, "missing queue prefix")
+	_, err = parseAppQueueRules("(=root.a")
+	assert.ErrorContains(t, err, "error parsing regexp")
+	_, err = parseAppQueueRules("^a=root..a")
+	assert.ErrorContains(t, err, "invalid queue name")
+
+	// an invalid value on reload keeps the previous rules
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationAppQueueRules: "^team-a-=root.team-a",
+	}}})
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationAppQueueRules: "(=root.a",
+	}}})
+	assert.DeepEqual(t, appQueueRulesString(conf.GetAppQueueRules()), []string{"^team-a-=root.team-a"})
+}
+
+func TestParseConfigRegexps(t *testing.T) {
+	// an invalid list falls back to the default on the initial load
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringBypassNamespaces: "^kube-(system",
+	}}})
+	assert.DeepEqual(t, regexpsString(conf.GetNamespaceFilters().BypassNamespaces), []string{DefaultFilteringBypassNamespaces})
+
+	// an invalid list on reload keeps the previous list
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringBypassNamespaces: "^kube-system$,^team-",
+	}}})
+	assert.DeepEqual(t, regexpsString(conf.GetNamespaceFilters().BypassNamespaces), []string{"^kube-system$", "^team-"})
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringBypassNamespaces: "^kube-(system",
+	}}})
+	assert.DeepEqual(t, regexpsString(conf.GetNamespaceFilters().BypassNamespaces), []string{"^kube-system$", "^team-"})
+}
+
+func TestConfigurationViews(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringProcessNamespaces:        "^team-",
+		AMFilteringBypassPodSelector:        "engine=default",
+		AMAccessControlTrustControllers:     "false",
+		AMAccessControlExternalUsers:        "^alice$",
+		AMAccessControlBypassAuthNamespaces: "^sandbox$",
+	}}})
+	filters := conf.GetNamespaceFilters()
+	access := conf.GetAccessControl()
+	assert.DeepEqual(t, regexpsString(filters.ProcessNamespaces), []string{"^team-"})
+	assert.DeepEqual(t, regexpsString(filters.BypassNamespaces), []string{DefaultFilteringBypassNamespaces})
+	assert.Check(t, filters.BypassPodSelector.Matches(labels.Set{"engine": "default"}))
+	assert.Check(t, !access.TrustControllers)
+	assert.DeepEqual(t, regexpsString(access.ExternalUsers), []string{"^alice$"})
+	assert.DeepEqual(t, regexpsString(access.BypassAuthNamespaces), []string{"^sandbox$"})
+
+	// a reload creates new views, views handed out earlier do not change
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringProcessNamespaces: "^prod-",
+		AMAccessControlExternalUsers: "^bob$",
+	}}})
+	assert.DeepEqual(t, regexpsString(filters.ProcessNamespaces), []string{"^team-"})
+	assert.DeepEqual(t, regexpsString(access.ExternalUsers), []string{"^alice$"})
+	assert.Check(t, !access.TrustControllers)
+	assert.DeepEqual(t, regexpsString(conf.GetNamespaceFilters().ProcessNamespaces), []string{"^prod-"})
+	assert.Check(t, conf.GetNamespaceFilters().BypassPodSelector.Matches(labels.Set{constants.LabelIgnore: "true"}))
+	assert.DeepEqual(t, regexpsString(conf.GetAccessControl().ExternalUsers), []string{"^bob$"})
+	assert.Check(t, conf.GetAccessControl().TrustControllers)
+}
+
+func TestParseConfigSelector(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Assert(t, conf.GetNamespaceFilters().ProcessPodSelector == nil, "process selector set without configuration")
+	assert.Check(t, conf.GetNamespaceFilters().BypassPodSelector.Matches(labels.Set{constants.LabelIgnore: "true"}))
+	assert.Check(t, !conf.GetNamespaceFilters().BypassPodSelector.Matches(labels.Set{constants.LabelIgnore: "false"}))
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringBypassPodSelector: "",
+	}}})
+	assert.Assert(t, conf.GetNamespaceFilters().BypassPodSelector == nil, "bypass selector set with empty configuration")
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringProcessPodSelector: "engine=yunikorn,tier in (batch, ml)",
+	}}})
+	assert.Check(t, conf.GetNamespaceFilters().ProcessPodSelector.Matches(labels.Set{"engine": "yunikorn", "tier": "ml"}))
+	assert.Check(t, !conf.GetNamespaceFilters().ProcessPodSelector.Matches(labels.Set{"engine": "yunikorn"}))
+
+	// an invalid selector on reload keeps the previous selector
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringProcessPodSelector: "engine in (yunikorn",
+	}}})
+	assert.Check(t, conf.GetNamespaceFilters().ProcessPodSelector.Matches(labels.Set{"engine": "yunikorn", "tier": "ml"}))
+	assert.Assert(t, validateSelector("engine in (yunikorn") != nil, "invalid selector accepted")
+}
+
+func TestParsePriorityClassPreemption(t *testing.T) {
+	mapping, err := parsePriorityClassPreemption("high=false, low = TRUE,")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, mapping, map[string]string{"high": "false", "low": "true"})
+	assert.Equal(t, priorityClassMappingString(mapping), "high=false,low=true")
+
+	_, err = parsePriorityClassPreemption("high")
+	assert.ErrorContains(t, err, "must be of the form priorityClass=true|false")
+	_, err = parsePriorityClassPreemption("high=maybe")
+	assert.ErrorContains(t, err, "invalid preemption value 'maybe' for priority class 'high'")
+	_, err = parsePriorityClassPreemption("high=true,high=false")
+	assert.ErrorContains(t, err, "duplicate priority class")
+}
+
+func TestParseQueueNodePools(t *testing.T) {
+	pools, err := parseQueueNodePools("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(pools), 0)
+	assert.Equal(t, queueNodePoolsString(pools), "")
+
+	pools, err = parseQueueNodePools(`{"root.gpu": {"nodeSelector": {"pool": "gpu"}, "tolerations": [{"key": "dedicated", "operator": "Exists"}]}}`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pools, map[string]*QueueNodePool{"root.gpu": {
+		NodeSelector: map[string]string{"pool": "gpu"},
+		Tolerations:  []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}},
+	}})
+	assert.Equal(t, queueNodePoolsString(pools), `{"root.gpu":{"nodeSelector":{"pool":"gpu"},"tolerations":[{"key":"dedicated","operator":"Exists"}]}}`)
+
+	invalid := map[string]string{
+		`["root.gpu"]`: "invalid queue node pools",
+		`{"root.gpu": {"selector": {"pool": "gpu"}}}`:      "unknown field",
+		`{"root..gpu": {"nodeSelector": {"pool": "gpu"}}}`: "invalid queue name",
+		`{"root.gpu": {}}`: "must set a node selector or tolerations",
+		`{"root.gpu": {"nodeSelector": {"pool/": "gpu"}}}`:                                  "invalid node selector key 'pool/'",
+		`{"root.gpu": {"nodeSelector": {"pool": "gpu!"}}}`:                                  "invalid node selector value 'gpu!'",
+		`{"root.gpu": {"tolerations": [{"operator": "Equal"}]}}`:                            "operator must be 'Exists' if the key is empty",
+		`{"root.gpu": {"tolerations": [{"key": "a", "operator": "Exists", "value": "b"}]}}`: "value must be empty",
+		`{"root.gpu": {"tolerations": [{"key": "a", "operator": "Lt"}]}}`:                   "unsupported operator 'Lt'",
+		`{"root.gpu": {"tolerations": [{"key": "a", "effect": "Sometimes"}]}}`:              "unsupported effect 'Sometimes'",
+	}
+	for value, expected := range invalid {
+		_, err = parseQueueNodePools(value)
+		assert.ErrorContains(t, err, expected, value)
+	}
+}
+
+func TestParsePriorityClassQueues(t *testing.T) {
+	mapping, err := parsePriorityClassQueues("high=root.prod, low = root.batch,")
+	assert.NilError(t, err)
+	assert.Equal(t, len(mapping), 2)
+	assert.Equal(t, mapping["high"], "root.prod")
+	assert.Equal(t, mapping["low"], "root.batch")
+	assert.Equal(t, priorityClassMappingString(mapping), "high=root.prod,low=root.batch")
+
+	_, err = parsePriorityClassQueues("high")
+	assert.ErrorContains(t, err, "must be of the form priorityClass=queue")
+	_, err = parsePriorityClassQueues("=root.prod")
+	assert.ErrorContains(t, err, "must be of the form priorityClass=queue")
+	_, err = parsePriorityClassQueues("high=root..prod")
+	assert.ErrorContains(t, err, "invalid queue name")
+	_, err = parsePriorityClassQueues("high=root.a,high=root.b")
+	assert.ErrorContains(t, err, "duplicate priority class")
+
+	// an invalid mapping on reload keeps the previous mapping
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationPriorityClassQueues: "high=root.prod",
+	}}})
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationPriorityClassQueues: "high=root.",
+	}}}, false)
+	assert.Equal(t, conf.GetPriorityClassQueues()["high"], "root.prod")
+}
+
+func TestSchedulerNameValidation(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetSchedulerName(), constants.SchedulerName)
+
+	assert.NilError(t, validateSchedulerName("yunikorn-canary"))
+	assert.NilError(t, validateSchedulerName("scheduler.example.com"))
+	assert.ErrorContains(t, validateSchedulerName(""), "invalid scheduler name ''")
+	assert.ErrorContains(t, validateSchedulerName("YuniKorn"), "invalid scheduler name 'YuniKorn'")
+	assert.ErrorContains(t, validateSchedulerName("yunikorn_canary"), "invalid scheduler name")
+
+	// an invalid value on reload keeps the previous value
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationSchedulerName: "yunikorn-canary",
+	}}})
+	assert.Equal(t, conf.GetSchedulerName(), "yunikorn-canary")
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationSchedulerName: "yunikorn canary",
+	}}})
+	assert.Equal(t, conf.GetSchedulerName(), "yunikorn-canary")
+}
+
+func TestParsePriorityBuckets(t *testing.T) {
+	buckets, err := parsePriorityBuckets("medium=1000, low=0,high=100000,")
+	assert.NilError(t, err)
+	assert.Equal(t, priorityBucketsString(buckets), "high=100000,medium=1000,low=0")
+
+	_, err = parsePriorityBuckets("low")
+	assert.ErrorContains(t, err, "must be of the form bucket=minPriority")
+	_, err = parsePriorityBuckets("=0")
+	assert.ErrorContains(t, err, "invalid priority bucket name ''")
+	_, err = parsePriorityBuckets("very high=0")
+	assert.ErrorContains(t, err, "invalid priority bucket name 'very high'")
+	_, err = parsePriorityBuckets("low=abc")
+	assert.ErrorContains(t, err, "invalid minimum priority for priority bucket 'low'")
+	_, err = parsePriorityBuckets("low=10000000000")
+	assert.ErrorContains(t, err, "invalid minimum priority for priority bucket 'low'")
+	_, err = parsePriorityBuckets("low=0,low=10")
+	assert.ErrorContains(t, err, "duplicate priority bucket 'low=10'")
+	_, err = parsePriorityBuckets("low=0,medium=0")
+	assert.ErrorContains(t, err, "duplicate priority bucket 'medium=0'")
+
+	// an invalid value on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationPriorityBuckets: "low=0,high=1000",
+	}}})
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationPriorityBuckets: "low=0,high",
+	}}})
+	assert.Equal(t, priorityBucketsString(conf.GetPriorityBuckets()), "high=1000,low=0")
+}
+
+func TestParseNamespaceFieldLabels(t *testing.T) {
+	mapping, err := parseNamespaceFieldLabels("ns=metadata.name, ns-uid=metadata.uid,example.com/team=metadata.labels['team'],owner=metadata.annotations['example.com/owner'],")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, mapping, []*NamespaceFieldLabel{
+		{Label: "ns", Field: NamespaceFieldName},
+		{Label: "ns-uid", Field: NamespaceFieldUID},
+		{Label: "example.com/team", Field: NamespaceFieldLabels, Key: "team"},
+		{Label: "owner", Field: NamespaceFieldAnnotations, Key: "example.com/owner"},
+	})
+	assert.Equal(t, namespaceFieldLabelsString(mapping),
+		"ns=metadata.name,ns-uid=metadata.uid,example.com/team=metadata.labels['team'],owner=metadata.annotations['example.com/owner']")
+
+	_, err = parseNamespaceFieldLabels("ns")
+	assert.ErrorContains(t, err, "must be of the form label=fieldPath")
+	_, err = parseNamespaceFieldLabels("=metadata.name")
+	assert.ErrorContains(t, err, "invalid label ''")
+	_, err = parseNamespaceFieldLabels("ns=metadata.name,ns=metadata.uid")
+	assert.ErrorContains(t, err, "duplicate label 'ns'")
+	_, err = parseNamespaceFieldLabels("ns=metadata.namespace")
+	assert.ErrorContains(t, err, "unsupported namespace field path 'metadata.namespace'")
+	_, err = parseNamespaceFieldLabels("team=metadata.labels[team]")
+	assert.ErrorContains(t, err, "unsupported namespace field path 'metadata.labels[team]'")
+	_, err = parseNamespaceFieldLabels("team=metadata.labels['']")
+	assert.ErrorContains(t, err, "invalid key in namespace field path")
+
+	// an invalid value on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationNamespaceFieldLabels: "ns=metadata.name",
+	}}})
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationNamespaceFieldLabels: "ns=metadata.spec",
+	}}})
+	assert.Equal(t, namespaceFieldLabelsString(conf.GetNamespaceFieldLabels()), "ns=metadata.name")
+}
+
+func TestParseNamespaceResourceCaps(t *testing.T) {
+	caps, err := parseNamespaceResourceCaps("team-a:memory=4Gi, team-a:cpu=2,team-b:nvidia.com/gpu=1,")
+	assert.NilError(t, err)
+	assert.Equal(t, namespaceResourceCapsString(caps), "team-a:cpu=2,team-a:memory=4Gi,team-b:nvidia.com/gpu=1")
+
+	_, err = parseNamespaceResourceCaps("team-a=2")
+	assert.ErrorContains(t, err, "must be of the form namespace:resource=quantity")
+	_, err = parseNamespaceResourceCaps(":cpu=2")
+	assert.ErrorContains(t, err, "must be of the form namespace:resource=quantity")
+	_, err = parseNamespaceResourceCaps("team-a:cpu=lots")
+	assert.ErrorContains(t, err, "invalid quantity for namespace resource cap 'team-a:cpu=lots'")
+	_, err = parseNamespaceResourceCaps("team-a:cpu=2,team-a:cpu=4")
+	assert.ErrorContains(t, err, "duplicate namespace resource cap 'team-a:cpu=4'")
+
+	// an invalid value on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationNamespaceResourceCaps: "team-a:cpu=2",
+	}}})
+	assert.Equal(t, conf.GetNamespaceResourceCapAction(), ConflictActionWarn)
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationNamespaceResourceCaps: "team-a:cpu",
+	}}})
+	assert.Equal(t, conf.GetNamespaceResourceCap("team-a").Cpu().String(), "2")
+	assert.Equal(t, len(conf.GetNamespaceResourceCap("team-b")), 0)
+	assert.Assert(t, conf.HasNamespaceResourceCaps())
+
+	// caps are not tracked if they are not configured or not enforced
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Assert(t, !conf.HasNamespaceResourceCaps())
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationNamespaceResourceCaps:      "team-a:cpu=2",
+		AMValidationNamespaceResourceCapAction: ConflictActionAllow,
+	}}})
+	assert.Assert(t, !conf.HasNamespaceResourceCaps())
+}
+
+func TestDefaultResourceRequests(t *testing.T) {
+	_, err := parseDefaultResourceRequests("*:nvidia.com/gpu=1")
+	assert.ErrorContains(t, err, "default resource request '*:nvidia.com/gpu' must be for cpu or memory")
+	_, err = parseDefaultResourceRequests("*:cpu=lots")
+	assert.ErrorContains(t, err, "invalid quantity for default resource request '*:cpu=lots'")
+
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationDefaultResourceRequests: "*:cpu=100m,*:memory=128Mi,team-a:cpu=500m,team-b:memory=1Gi",
+	}}})
+	requests := conf.GetDefaultResourceRequests("team-a")
+	assert.Equal(t, requests.Cpu().String(), "500m")
+	assert.Equal(t, requests.Memory().String(), "128Mi")
+	requests = conf.GetDefaultResourceRequests("team-b")
+	assert.Equal(t, requests.Cpu().String(), "100m")
+	assert.Equal(t, requests.Memory().String(), "1Gi")
+	assert.Equal(t, len(conf.GetDefaultResourceRequests("other")), 2)
+
+	// an invalid value on reload keeps the previous value
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationDefaultResourceRequests: "*:cpu",
+	}}})
+	assert.Equal(t, conf.GetDefaultResourceRequests("other").Cpu().String(), "100m")
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, len(conf.GetDefaultResourceRequests("team-a")), 0)
+}
+
+func TestUserInfoAnnotationValidation(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{}}})
+	assert.Equal(t, conf.GetUserInfoAnnotation(), "yunikorn.apache.org/user.info")
+
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMAccessControlUserInfoAnnotation: "example.com/submitter",
+	}}})
+	assert.Equal(t, conf.GetUserInfoAnnotation(), "example.com/submitter")
+
+	// an invalid key on reload keeps the previous value
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMAccessControlUserInfoAnnotation: "not a/valid/key",
+	}}})
+	assert.Equal(t, conf.GetUserInfoAnnotation(), "example.com/submitter")
+	assert.ErrorContains(t, validateAnnotationKey("not a/valid/key"), "invalid annotation key 'not a/valid/key'")
+}
+
+func TestSchedulerValidateConfPathValidation(t *testing.T) {
+	assert.NilError(t, validateURLPath("/ws/v1/validate-conf"))
+	assert.ErrorContains(t, validateURLPath("ws/v1/validate-conf"), "must be an absolute path")
+	assert.ErrorContains(t, validateURLPath("http://scheduler/ws/v1/validate-conf"), "must be an absolute path")
+	assert.ErrorContains(t, validateURLPath("/ws/v1/validate-conf?dryRun=true"), "without query or fragment")
+	assert.ErrorContains(t, validateURLPath("/ws/v1/validate-conf#top"), "without query or fragment")
+
+	// an invalid path on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{}}})
+	assert.Equal(t, conf.GetSchedulerValidateConfPath(), "/ws/v1/validate-conf")
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookSchedulerValidateConfPath: "/ws/v2/validate",
+	}}})
+	assert.Equal(t, conf.GetSchedulerValidateConfPath(), "/ws/v2/validate")
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookSchedulerValidateConfPath: "validate",
+	}}})
+	assert.Equal(t, conf.GetSchedulerValidateConfPath(), "/ws/v2/validate")
+}
+
+func TestNamespaceSourceValidation(t *testing.T) {
+	assert.NilError(t, validateNamespaceSource(NamespaceSourceRequest))
+	assert.NilError(t, validateNamespaceSource(NamespaceSourceObject))
+	assert.ErrorContains(t, validateNamespaceSource("pod"), "namespace source must be one of")
+
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetNamespaceSource(), DefaultFilteringNamespaceSource)
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMFilteringNamespaceSource: "pod",
+	}}}, false)
+	assert.Equal(t, conf.GetNamespaceSource(), DefaultFilteringNamespaceSource)
+}
+
+func TestIdentityServiceConfig(t *testing.T) {
+	assert.NilError(t, validateFailurePolicy(FailurePolicyFail))
+	assert.NilError(t, validateFailurePolicy(FailurePolicyIgnore))
+	assert.ErrorContains(t, validateFailurePolicy("Retry"), "failure policy must be one of")
+
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetIdentityServiceURL(), "")
+	assert.Equal(t, conf.GetIdentityServiceTimeout(), DefaultAccessControlIdentityServiceTimeout)
+	assert.Equal(t, conf.GetIdentityServiceCacheTTL(), DefaultAccessControlIdentityServiceCacheTTL)
+	assert.Equal(t, conf.GetIdentityServiceFailurePolicy(), FailurePolicyFail)
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMAccessControlIdentityServiceURL:           "http://identity:8080/users",
+		AMAccessControlIdentityServiceTimeout:       "2s",
+		AMAccessControlIdentityServiceCacheTTL:      "invalid",
+		AMAccessControlIdentityServiceFailurePolicy: FailurePolicyIgnore,
+	}}})
+	assert.Equal(t, conf.GetIdentityServiceURL(), "http://identity:8080/users")
+	assert.Equal(t, conf.GetIdentityServiceTimeout(), 2*time.Second)
+	assert.Equal(t, conf.GetIdentityServiceCacheTTL(), DefaultAccessControlIdentityServiceCacheTTL)
+	assert.Equal(t, conf.GetIdentityServiceFailurePolicy(), FailurePolicyIgnore)
+}
+
+func TestSchedulerValidateTimeout(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetSchedulerValidateTimeout(), DefaultWebHookSchedulerValidateTimeout)
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookSchedulerValidateTimeout: "3s",
+	}}})
+	assert.Equal(t, conf.GetSchedulerValidateTimeout(), 3*time.Second)
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookSchedulerValidateTimeout: "-1s",
+	}}})
+	assert.Equal(t, conf.GetSchedulerValidateTimeout(), DefaultWebHookSchedulerValidateTimeout)
+}
+
+func TestWebhookTimeoutValidation(t *testing.T) {
+	assert.NilError(t, validateWebhookTimeout("1"))
+	assert.NilError(t, validateWebhookTimeout("30"))
+	assert.ErrorContains(t, validateWebhookTimeout("0"), "between 1 and 30 seconds")
+	assert.ErrorContains(t, validateWebhookTimeout("31"), "between 1 and 30 seconds")
+	assert.ErrorContains(t, validateWebhookTimeout("10s"), "invalid syntax")
+
+	// an invalid timeout on reload keeps the previous value
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetWebhookTimeoutSeconds(), DefaultWebHookTimeoutSeconds)
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookTimeoutSeconds: "5",
+	}}}, false)
+	assert.Equal(t, conf.GetWebhookTimeoutSeconds(), 5)
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMWebHookTimeoutSeconds: "60",
+	}}}, false)
+	assert.Equal(t, conf.GetWebhookTimeoutSeconds(), 5)
+}
+
+func TestOwnerAppIDConflictValidation(t *testing.T) {
+	assert.NilError(t, validateConflictAction(ConflictActionAllow))
+	assert.NilError(t, validateConflictAction(ConflictActionWarn))
+	assert.NilError(t, validateConflictAction(ConflictActionDeny))
+	assert.ErrorContains(t, validateConflictAction("reject"), "conflict action must be one of")
+
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetOwnerAppIDConflict(), ConflictActionAllow)
+}
+
+func TestAppIDTemplateValidation(t *testing.T) {
+	assert.NilError(t, validateAppIDTemplate(DefaultMutationAppIDTemplate))
+	assert.NilError(t, validateAppIDTemplate("{namespace}.batch"))
+	assert.NilError(t, validateAppIDTemplate("{namespace}-{generateName}"))
+	assert.NilError(t, validateAppIDTemplate("{namespace}-{ownerKind}-{ownerName}-{date}"))
+	assert.NilError(t, validateAppIDTemplate("static-app"))
+	assert.ErrorContains(t, validateAppIDTemplate(""), "must not be empty")
+	assert.ErrorContains(t, validateAppIDTemplate("{ns}-app"), "unknown placeholder '{ns}'")
+	assert.ErrorContains(t, validateAppIDTemplate("{namespace-app"), "unbalanced braces")
+	assert.ErrorContains(t, validateAppIDTemplate("{namespace}}"), "unbalanced braces")
+	assert.ErrorContains(t, validateAppIDTemplate("{namespace}/app"), "not valid in a label value")
+
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetAppIDTemplate(), "yunikorn-{namespace}-autogen")
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationAppIDTemplate: "{namespace}.batch",
+	}}})
+	assert.Equal(t, conf.GetAppIDTemplate(), "{namespace}.batch")
+}
+
+func TestGeneration(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetGeneration(), uint64(1))
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{}}})
+	assert.Equal(t, conf.GetGeneration(), uint64(2))
+
+	// the update disabling hot refresh is applied, later updates are ignored and keep the generation
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		schedulerconf.CMSvcEnableConfigHotRefresh: "false",
+	}}})
+	assert.Equal(t, conf.GetGeneration(), uint64(3))
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, nil})
+	assert.Equal(t, conf.GetGeneration(), uint64(3))
+}
+
+func TestAppIDPatternValidation(t *testing.T) {
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, nil})
+	assert.Assert(t, conf.GetAppIDPattern() == nil, "pattern set without configuration")
+
+	conf = NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationAppIDPattern: "^team-.*$",
+	}}})
+	assert.Equal(t, conf.GetAppIDPattern().String(), "^team-.*$")
+
+	// an invalid pattern on reload keeps the previous pattern
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMValidationAppIDPattern: "^team-(.*$",
+	}}})
+	assert.Equal(t, conf.GetAppIDPattern().String(), "^team-.*$")
+
+	// clearing the pattern disables the check
+	conf.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{}}})
+	assert.Assert(t, conf.GetAppIDPattern() == nil, "pattern not cleared")
+}
@@ -0,0 +1,168 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// QueueRuleUser is replaced by the user of the pod in the queue of a queue mapping rule
+	QueueRuleUser = "{user}"
+	// QueueRuleNamespace is replaced by the namespace of the pod in the queue of a queue mapping rule
+	QueueRuleNamespace = "{namespace}"
+
+	// dots separate the levels of a queue path, the scheduler replaces them in user names in the same way
+	queueRuleDotReplacement = "_dot_"
+)
+
+// QueueMappingRule resolves the queue of a pod that does not set one. All matchers that are set must match the pod,
+// a list of expressions matches if any of its expressions matches. A rule without matchers matches every pod.
+type QueueMappingRule struct {
+	Users       []string `json:"users,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Namespaces  []string `json:"namespaces,omitempty"`
+	PodSelector string   `json:"podSelector,omitempty"`
+	Queue       string   `json:"queue"`
+
+	users       []*regexp.Regexp
+	groups      []*regexp.Regexp
+	namespaces  []*regexp.Regexp
+	podSelector labels.Selector
+}
+
+// Matches checks the matchers of the rule against the user and groups of the pod, its namespace and its labels. Users
+// and groups are not known for pods without user info, rules matching on them do not apply to those pods.
+func (r *QueueMappingRule) Matches(user string, groups []string, namespace string, podLabels map[string]string) bool {
+	if len(r.users) != 0 && (user == "" || !anyMatches(r.users, user)) {
+		return false
+	}
+	if len(r.groups) != 0 {
+		matched := false
+		for _, group := range groups {
+			if anyMatches(r.groups, group) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.namespaces) != 0 && !anyMatches(r.namespaces, namespace) {
+		return false
+	}
+	return r.podSelector == nil || r.podSelector.Matches(labels.Set(podLabels))
+}
+
+// QueueFor returns the queue of the rule with the placeholders replaced. False is returned if the queue refers to the
+// user of a pod without user info, or if the replaced queue is not a valid queue path.
+func (r *QueueMappingRule) QueueFor(user string, namespace string) (string, bool) {
+	if user == "" && strings.Contains(r.Queue, QueueRuleUser) {
+		return "", false
+	}
+	queue := strings.NewReplacer(
+		QueueRuleUser, strings.ReplaceAll(user, ".", queueRuleDotReplacement),
+		QueueRuleNamespace, namespace,
+	).Replace(r.Queue)
+	if validateQueueName(queue) != nil {
+		return "", false
+	}
+	return queue, true
+}
+
+func anyMatches(expressions []*regexp.Regexp, value string) bool {
+	for _, re := range expressions {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQueueMappingRules parses a JSON list of rules, which are checked in order, for example:
+// [{"groups": ["^data-"], "queue": "root.data.{user}"}, {"namespaces": ["^ci-"], "queue": "root.ci"}]
+func parseQueueMappingRules(value string) ([]*QueueMappingRule, error) {
+	result := make([]*QueueMappingRule, 0)
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid queue mapping rules: %v", err)
+	}
+	for i, rule := range result {
+		if rule == nil {
+			return nil, fmt.Errorf("queue mapping rule %d must not be empty", i)
+		}
+		placeholders := strings.NewReplacer(QueueRuleUser, "user", QueueRuleNamespace, "namespace")
+		if err := validateQueueName(placeholders.Replace(rule.Queue)); err != nil {
+			return nil, fmt.Errorf("invalid queue for queue mapping rule %d: %v", i, err)
+		}
+		var err error
+		if rule.users, err = compileRuleExpressions(rule.Users); err != nil {
+			return nil, fmt.Errorf("invalid user expression for queue mapping rule %d: %v", i, err)
+		}
+		if rule.groups, err = compileRuleExpressions(rule.Groups); err != nil {
+			return nil, fmt.Errorf("invalid group expression for queue mapping rule %d: %v", i, err)
+		}
+		if rule.namespaces, err = compileRuleExpressions(rule.Namespaces); err != nil {
+			return nil, fmt.Errorf("invalid namespace expression for queue mapping rule %d: %v", i, err)
+		}
+		if rule.podSelector, err = parseSelector(rule.PodSelector); err != nil {
+			return nil, fmt.Errorf("invalid pod selector for queue mapping rule %d: %v", i, err)
+		}
+	}
+	return result, nil
+}
+
+func compileRuleExpressions(expressions []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(expressions))
+	for _, expression := range expressions {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+func validateQueueMappingRules(value string) error {
+	_, err := parseQueueMappingRules(value)
+	return err
+}
+
+// queueMappingRulesString returns the rules in their configuration format.
+func queueMappingRulesString(rules []*QueueMappingRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	value, err := json.Marshal(rules)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
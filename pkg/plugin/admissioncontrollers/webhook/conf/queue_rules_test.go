@@ -0,0 +1,103 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package conf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestParseQueueMappingRules(t *testing.T) {
+	rules, err := parseQueueMappingRules("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 0)
+	assert.Equal(t, queueMappingRulesString(rules), "")
+
+	value := `[{"groups":["^data-"],"podSelector":"tier=batch","queue":"root.data.{user}"},{"queue":"root.{namespace}"}]`
+	rules, err = parseQueueMappingRules(value)
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 2)
+	assert.Equal(t, rules[0].Queue, "root.data.{user}")
+	assert.Equal(t, rules[0].podSelector.String(), "tier=batch")
+	assert.Equal(t, queueMappingRulesString(rules), value)
+
+	invalid := map[string]string{
+		`{"queue": "root.a"}`:                            "invalid queue mapping rules",
+		`[{"user": ["a"], "queue": "root.a"}]`:           "unknown field",
+		`[null]`:                                         "queue mapping rule 0 must not be empty",
+		`[{"users": ["a"]}]`:                             "invalid queue for queue mapping rule 0",
+		`[{"queue": "root..a"}]`:                         "invalid queue for queue mapping rule 0",
+		`[{"queue": "root.a"}, {"queue": "root.{app}"}]`: "invalid queue for queue mapping rule 1",
+		`[{"users": ["("], "queue": "root.a"}]`:          "invalid user expression for queue mapping rule 0",
+		`[{"groups": ["("], "queue": "root.a"}]`:         "invalid group expression for queue mapping rule 0",
+		`[{"namespaces": ["("], "queue": "root.a"}]`:     "invalid namespace expression for queue mapping rule 0",
+		`[{"podSelector": "a=(", "queue": "root.a"}]`:    "invalid pod selector for queue mapping rule 0",
+	}
+	for value, expected := range invalid {
+		_, err = parseQueueMappingRules(value)
+		assert.ErrorContains(t, err, expected, value)
+	}
+
+	// invalid rules on reload keep the previous rules
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationQueueMappingRules: `[{"queue": "root.a"}]`,
+	}}})
+	assert.Equal(t, len(conf.GetQueueMappingRules()), 1)
+	conf.updateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMMutationQueueMappingRules: `[{"queue": "root..a"}]`,
+	}}}, false)
+	assert.Equal(t, len(conf.GetQueueMappingRules()), 1)
+	assert.Equal(t, conf.GetQueueMappingRules()[0].Queue, "root.a")
+}
+
+func TestQueueMappingRuleMatches(t *testing.T) {
+	rules, err := parseQueueMappingRules(`[
+		{"users": ["^alice$", "^bob$"], "groups": ["^data-"], "namespaces": ["^team-"], "podSelector": "tier in (batch)", "queue": "root.a"},
+		{"queue": "root.b"}
+	]`)
+	assert.NilError(t, err)
+	rule := rules[0]
+	batch := map[string]string{"tier": "batch"}
+	assert.Check(t, rule.Matches("alice", []string{"dev", "data-eng"}, "team-a", batch), "all matchers match")
+	assert.Check(t, rule.Matches("bob", []string{"data-eng"}, "team-a", batch), "second user does not match")
+	assert.Check(t, !rule.Matches("carol", []string{"data-eng"}, "team-a", batch), "other user matches")
+	assert.Check(t, !rule.Matches("", []string{"data-eng"}, "team-a", batch), "unknown user matches")
+	assert.Check(t, !rule.Matches("alice", []string{"dev"}, "team-a", batch), "other group matches")
+	assert.Check(t, !rule.Matches("alice", nil, "team-a", batch), "unknown groups match")
+	assert.Check(t, !rule.Matches("alice", []string{"data-eng"}, "default", batch), "other namespace matches")
+	assert.Check(t, !rule.Matches("alice", []string{"data-eng"}, "team-a", nil), "other labels match")
+	assert.Check(t, rules[1].Matches("", nil, "default", nil), "rule without matchers does not match")
+}
+
+func TestQueueMappingRuleQueueFor(t *testing.T) {
+	rules, err := parseQueueMappingRules(`[{"queue": "root.{namespace}.{user}"}, {"queue": "root.{namespace}"}]`)
+	assert.NilError(t, err)
+	queue, ok := rules[0].QueueFor("john.doe", "team-a")
+	assert.Check(t, ok, "queue not resolved")
+	assert.Equal(t, queue, "root.team-a.john_dot_doe")
+	_, ok = rules[0].QueueFor("", "team-a")
+	assert.Check(t, !ok, "queue resolved without user")
+	_, ok = rules[0].QueueFor("john doe", "team-a")
+	assert.Check(t, !ok, "invalid queue resolved")
+	queue, ok = rules[1].QueueFor("", "team-a")
+	assert.Check(t, ok, "queue not resolved")
+	assert.Equal(t, queue, "root.team-a")
+}
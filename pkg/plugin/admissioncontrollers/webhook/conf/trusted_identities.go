@@ -0,0 +1,129 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regexPatternPrefix marks a pattern of a trusted identity as a regular expression instead of a glob pattern
+const regexPatternPrefix = "regex:"
+
+// TrustedIdentity is a set of users and groups that may set the user info annotation, optionally only in some
+// namespaces. Patterns are glob patterns matching the whole value, where * matches any sequence of characters and ?
+// matches a single character, unless they start with "regex:".
+type TrustedIdentity struct {
+	Users      []string `json:"users,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	users      []*regexp.Regexp
+	groups     []*regexp.Regexp
+	namespaces []*regexp.Regexp
+}
+
+// Matches returns true if the user or one of the groups matches the identity, and the identity is trusted in the
+// namespace. An identity without namespaces is trusted in all namespaces.
+func (i *TrustedIdentity) Matches(namespace string, userName string, groups []string) bool {
+	if len(i.namespaces) != 0 && !anyMatches(i.namespaces, namespace) {
+		return false
+	}
+	if anyMatches(i.users, userName) {
+		return true
+	}
+	for _, group := range groups {
+		if anyMatches(i.groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// compilePattern converts a glob pattern into an anchored regular expression, or compiles a pattern which starts with
+// "regex:" as is.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexPatternPrefix) {
+		return regexp.Compile(strings.TrimPrefix(pattern, regexPatternPrefix))
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	return regexp.Compile("^" + expr + "$")
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// parseTrustedIdentities parses a JSON list of trusted identities, for example:
+// [{"users": ["system:serviceaccount:spark-*:spark-operator"], "namespaces": ["spark-*"]}]
+func parseTrustedIdentities(value string) ([]*TrustedIdentity, error) {
+	result := make([]*TrustedIdentity, 0)
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid trusted identities: %v", err)
+	}
+	for i, identity := range result {
+		if identity == nil || (len(identity.Users) == 0 && len(identity.Groups) == 0) {
+			return nil, fmt.Errorf("trusted identity %d must list users or groups", i)
+		}
+		var err error
+		if identity.users, err = compilePatterns(identity.Users); err != nil {
+			return nil, fmt.Errorf("trusted identity %d: %v", i, err)
+		}
+		if identity.groups, err = compilePatterns(identity.Groups); err != nil {
+			return nil, fmt.Errorf("trusted identity %d: %v", i, err)
+		}
+		if identity.namespaces, err = compilePatterns(identity.Namespaces); err != nil {
+			return nil, fmt.Errorf("trusted identity %d: %v", i, err)
+		}
+	}
+	return result, nil
+}
+
+func validateTrustedIdentities(value string) error {
+	_, err := parseTrustedIdentities(value)
+	return err
+}
+
+// trustedIdentitiesString returns the trusted identities in their configuration format.
+func trustedIdentitiesString(identities []*TrustedIdentity) string {
+	if len(identities) == 0 {
+		return ""
+	}
+	value, err := json.Marshal(identities)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
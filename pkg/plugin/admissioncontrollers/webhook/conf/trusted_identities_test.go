@@ -0,0 +1,87 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package conf
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCompilePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		value   string
+		matches bool
+	}{
+		{"system:serviceaccount:spark-*:spark-operator", "system:serviceaccount:spark-a:spark-operator", true},
+		{"system:serviceaccount:spark-*:spark-operator", "system:serviceaccount:default:spark-operator", false},
+		{"system:serviceaccount:spark-*:spark-operator", "x-system:serviceaccount:spark-a:spark-operator", false},
+		{"team-?", "team-a", true},
+		{"team-?", "team-ab", false},
+		{"team.a", "team-a", false},
+		{"regex:^team-(a|b)$", "team-b", true},
+		{"regex:team", "my-team-a", true},
+	}
+	for _, c := range cases {
+		re, err := compilePattern(c.pattern)
+		assert.NilError(t, err)
+		assert.Equal(t, re.MatchString(c.value), c.matches, "%s matching %s", c.pattern, c.value)
+	}
+	_, err := compilePattern("regex:(")
+	assert.ErrorContains(t, err, "missing closing )")
+}
+
+func TestParseTrustedIdentities(t *testing.T) {
+	identities, err := parseTrustedIdentities("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(identities), 0)
+	assert.Equal(t, trustedIdentitiesString(identities), "")
+
+	value := `[{"users":["system:serviceaccount:spark-*:spark-operator"],"namespaces":["spark-*"]},{"groups":["regex:^ci-"]}]`
+	identities, err = parseTrustedIdentities(value)
+	assert.NilError(t, err)
+	assert.Equal(t, len(identities), 2)
+	assert.Equal(t, trustedIdentitiesString(identities), value)
+	assert.Check(t, identities[0].Matches("spark-a", "system:serviceaccount:spark-a:spark-operator", nil))
+	assert.Check(t, !identities[0].Matches("default", "system:serviceaccount:spark-a:spark-operator", nil))
+	assert.Check(t, identities[1].Matches("default", "user", []string{"devs", "ci-build"}))
+	assert.Check(t, !identities[1].Matches("default", "ci-build", nil), "group pattern matches user")
+
+	invalid := map[string]string{
+		`{"users": ["a"]}`:        "invalid trusted identities",
+		`[{"user": ["a"]}]`:       "unknown field",
+		`[null]`:                  "trusted identity 0 must list users or groups",
+		`[{"namespaces": ["a"]}]`: "trusted identity 0 must list users or groups",
+		`[{"users": ["a"]}, {"users": ["regex:("]}]`:    "trusted identity 1: invalid pattern 'regex:('",
+		`[{"groups": ["regex:("]}]`:                     "trusted identity 0: invalid pattern",
+		`[{"users": ["a"], "namespaces": ["regex:("]}]`: "trusted identity 0: invalid pattern",
+	}
+	for value, expected := range invalid {
+		_, err = parseTrustedIdentities(value)
+		assert.ErrorContains(t, err, expected, value)
+	}
+
+	// the identities are part of the access control view
+	conf := NewAdmissionControllerConf([]*v1.ConfigMap{nil, {Data: map[string]string{
+		AMAccessControlTrustedIdentities: value,
+	}}})
+	assert.Equal(t, len(conf.GetAccessControl().TrustedIdentities), 2)
+}
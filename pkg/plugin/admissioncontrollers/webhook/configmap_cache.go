@@ -0,0 +1,110 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	informersv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/utils"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+// ConfigMapCache keeps the configmaps of the scheduler namespace up to date via an informer, so that lookup tables
+// maintained by operators can be consulted during admission without calling the API server.
+type ConfigMapCache struct {
+	configMaps map[string]*v1.ConfigMap
+
+	sync.RWMutex
+}
+
+// NewConfigMapCache creates a new cache and registers it with the informer. A nil informer creates an empty cache.
+func NewConfigMapCache(configMaps informersv1.ConfigMapInformer) *ConfigMapCache {
+	cmc := &ConfigMapCache{
+		configMaps: make(map[string]*v1.ConfigMap),
+	}
+	if configMaps != nil {
+		configMaps.Informer().AddEventHandler(&configMapCacheUpdateHandler{cache: cmc})
+	}
+	return cmc
+}
+
+// getConfigMap returns the cached configmap, or nil if the configmap is not known.
+// The returned object is shared and must not be modified.
+func (cmc *ConfigMapCache) getConfigMap(name string) *v1.ConfigMap {
+	cmc.RLock()
+	defer cmc.RUnlock()
+	return cmc.configMaps[name]
+}
+
+// getValue returns the value of a key in the data of the configmap.
+func (cmc *ConfigMapCache) getValue(name string, key string) (string, bool) {
+	cm := cmc.getConfigMap(name)
+	if cm == nil {
+		return "", false
+	}
+	value, ok := cm.Data[key]
+	return value, ok
+}
+
+func (cmc *ConfigMapCache) addConfigMap(cm *v1.ConfigMap) {
+	cmc.Lock()
+	defer cmc.Unlock()
+	cmc.configMaps[cm.Name] = cm
+}
+
+func (cmc *ConfigMapCache) removeConfigMap(cm *v1.ConfigMap) {
+	cmc.Lock()
+	defer cmc.Unlock()
+	delete(cmc.configMaps, cm.Name)
+}
+
+type configMapCacheUpdateHandler struct {
+	cache *ConfigMapCache
+}
+
+func (h *configMapCacheUpdateHandler) OnAdd(obj interface{}) {
+	if cm := utils.Convert2ConfigMap(obj); cm != nil {
+		h.cache.addConfigMap(cm)
+	}
+}
+
+func (h *configMapCacheUpdateHandler) OnUpdate(_, newObj interface{}) {
+	if cm := utils.Convert2ConfigMap(newObj); cm != nil {
+		h.cache.addConfigMap(cm)
+	}
+}
+
+func (h *configMapCacheUpdateHandler) OnDelete(obj interface{}) {
+	var cm *v1.ConfigMap
+	switch t := obj.(type) {
+	case *v1.ConfigMap:
+		cm = t
+	case cache.DeletedFinalStateUnknown:
+		cm = utils.Convert2ConfigMap(t.Obj)
+	}
+	if cm == nil {
+		log.Logger().Warn("unable to convert to configmap")
+		return
+	}
+	h.cache.removeConfigMap(cm)
+}
@@ -0,0 +1,84 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	siCommon "github.com/apache/yunikorn-scheduler-interface/lib/go/common"
+)
+
+const (
+	debugAnnotation = siCommon.DomainYuniKorn + "admission-debug"
+	debugLoggerName = "admission-debug"
+)
+
+// newDebugLogger creates a logger which writes to the same outputs as the default logger but always logs at the
+// debug level. It is only used for requests that asked for verbose logging.
+func newDebugLogger() *zap.Logger {
+	config := *log.GetZapConfigs()
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	logger, err := config.Build()
+	if err != nil {
+		log.Logger().Warn("Unable to create debug logger, using the default logger", zap.Error(err))
+		return log.Logger().Named(debugLoggerName)
+	}
+	return logger.Named(debugLoggerName)
+}
+
+// debugRequested returns true if the object of the request carries the debug annotation and the annotation is
+// allowed by the configuration.
+func (c *admissionController) debugRequested(req *admissionv1.AdmissionRequest) bool {
+	if req == nil || c.debugLogger == nil || !c.conf.GetAllowDebugAnnotation() {
+		return false
+	}
+	object := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(req.Object.Raw, object); err != nil {
+		return false
+	}
+	return object.Annotations[debugAnnotation] == "true"
+}
+
+// logDebugRequest logs the full request object and the response at the debug level for requests that asked for
+// verbose logging, independent of the configured log level.
+func (c *admissionController) logDebugRequest(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if resp == nil || !c.debugRequested(req) {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("UID", string(req.UID)),
+		zap.String("namespace", req.Namespace),
+		zap.String("kind", req.Kind.Kind),
+		zap.String("operation", string(req.Operation)),
+		zap.ByteString("object", req.Object.Raw),
+		zap.Bool("allowed", resp.Allowed),
+		zap.ByteString("patch", resp.Patch),
+		zap.Strings("warnings", resp.Warnings),
+	}
+	if resp.Result != nil {
+		fields = append(fields, zap.String("reason", resp.Result.Message))
+	}
+	c.debugLogger.Debug("admission request debug", fields...)
+}
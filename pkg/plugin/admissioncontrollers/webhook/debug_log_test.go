@@ -0,0 +1,73 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestLogDebugRequest(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookAllowDebugAnnotation: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	core, logs := observer.New(zapcore.DebugLevel)
+	ac.debugLogger = zap.New(core)
+	annotated := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{debugAnnotation: "true"},
+	}}
+	plain := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+
+	// only annotated requests are logged
+	req := createPodRequest(t, plain)
+	ac.logDebugRequest(req, ac.mutate(req))
+	assert.Equal(t, logs.Len(), 0)
+	req = createPodRequest(t, annotated)
+	ac.logDebugRequest(req, ac.mutate(req))
+	entries := logs.TakeAll()
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Level, zapcore.DebugLevel)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, fields["UID"], "test-uid")
+	assert.Equal(t, fields["allowed"], true)
+	assert.Assert(t, len(fields["patch"].(string)) > 0, "patch not logged")
+
+	// annotation set to another value
+	annotated.Annotations[debugAnnotation] = "false"
+	req = createPodRequest(t, annotated)
+	ac.logDebugRequest(req, ac.mutate(req))
+	assert.Equal(t, logs.Len(), 0)
+
+	// annotation not allowed
+	annotated.Annotations[debugAnnotation] = "true"
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	ac.debugLogger = zap.New(core)
+	req = createPodRequest(t, annotated)
+	ac.logDebugRequest(req, ac.mutate(req))
+	assert.Equal(t, logs.Len(), 0)
+}
@@ -0,0 +1,114 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	siCommon "github.com/apache/yunikorn-scheduler-interface/lib/go/common"
+)
+
+const (
+	admissionDecisionAnnotation = siCommon.DomainYuniKorn + "admission-decision"
+	maxDecisionAnnotationLength = 2048
+	schedulerNamePath           = "/spec/schedulerName"
+
+	// sources of the application ID and queue of a pod
+	sourceNone               = "none"
+	sourcePod                = "pod"
+	appIDSourceGenerated     = "generated"
+	appIDSourceOwner         = "owner"
+	appIDSourceStatefulSet   = "statefulSet"
+	appIDSourceReplicaSet    = "replicaSet"
+	queueSourceRule          = "rule"
+	queueSourceGPU           = "gpu"
+	queueSourcePriorityClass = "priorityClass"
+	queueSourceService       = "service"
+	queueSourceNamespace     = "namespace"
+	queueSourceDefault       = "default"
+)
+
+// admissionDecision summarises what the admission controller decided for a pod.
+type admissionDecision struct {
+	SchedulerName       string   `json:"schedulerName"`
+	SchedulerNameSet    bool     `json:"schedulerNameSet"`
+	ApplicationIDSource string   `json:"applicationIdSource"`
+	QueueSource         string   `json:"queueSource"`
+	Warnings            []string `json:"warnings,omitempty"`
+	WarningsTruncated   bool     `json:"warningsTruncated,omitempty"`
+}
+
+// decisionAnnotationValue returns the JSON summary of the decision for the pod, derived from the pod and the patch.
+// Warnings are dropped from the end until the value fits the maximum annotation length.
+func (c *admissionController) decisionAnnotationValue(namespace string, pod *v1.Pod, patch []patchOperation, warnings []string) string {
+	decision := &admissionDecision{
+		SchedulerName:       pod.Spec.SchedulerName,
+		ApplicationIDSource: sourceNone,
+		QueueSource:         sourceNone,
+		Warnings:            warnings,
+	}
+	for _, op := range patch {
+		if op.Path == schedulerNamePath {
+			if name, ok := op.Value.(string); ok {
+				decision.SchedulerName = name
+				decision.SchedulerNameSet = true
+			}
+		}
+	}
+
+	_, hasAppID := pod.Labels[constants.LabelApplicationID]
+	_, hasSparkAppID := pod.Labels[constants.SparkLabelAppID]
+	switch {
+	case hasAppID || hasSparkAppID:
+		decision.ApplicationIDSource = sourcePod
+	case hasPatchPath(patch, labelsPath+"/"+jsonPointerEscaper.Replace(constants.LabelApplicationID)):
+		decision.ApplicationIDSource = appIDSourceGenerated
+		if c.conf.GetStatefulSetAppID() && statefulSetOwner(pod) != nil {
+			decision.ApplicationIDSource = appIDSourceStatefulSet
+		} else if c.conf.GetReplicaSetAppID() && replicaSetOwner(pod) != nil {
+			decision.ApplicationIDSource = appIDSourceReplicaSet
+		} else if c.conf.GetOwnerBasedAppID() && getPodOwner(pod) != nil {
+			decision.ApplicationIDSource = appIDSourceOwner
+		}
+	}
+
+	if _, ok := pod.Labels[constants.LabelQueueName]; ok {
+		decision.QueueSource = sourcePod
+	} else if hasPatchPath(patch, labelsPath+"/"+jsonPointerEscaper.Replace(constants.LabelQueueName)) {
+		_, decision.QueueSource = c.getDefaultQueue(namespace, pod)
+	}
+
+	for {
+		value, err := json.Marshal(decision)
+		if err != nil {
+			log.Logger().Error("failed to marshal admission decision", zap.Error(err))
+			return ""
+		}
+		if len(value) <= maxDecisionAnnotationLength || len(decision.Warnings) == 0 {
+			return string(value)
+		}
+		decision.Warnings = decision.Warnings[:len(decision.Warnings)-1]
+		decision.WarningsTruncated = true
+	}
+}
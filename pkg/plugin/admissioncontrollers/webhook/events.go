@@ -0,0 +1,117 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+
+	"github.com/apache/yunikorn-k8shim/pkg/client"
+)
+
+const (
+	eventReportingController  = "yunikorn-admission-controller"
+	eventReasonDenied         = "AdmissionDenied"
+	eventReasonAppIDGenerated = "ApplicationIDGenerated"
+	eventActionDeny           = "Deny"
+	eventActionMutate         = "Mutate"
+)
+
+// newEventRecorder creates a recorder which sends events to the API server until the stop channel is closed.
+func newEventRecorder(kubeClient client.KubeClient, stopChan <-chan struct{}) events.EventRecorder {
+	broadcaster := events.NewBroadcaster(&events.EventSinkImpl{
+		Interface: kubeClient.GetClientSet().EventsV1()})
+	broadcaster.StartRecordingToSink(stopChan)
+	return broadcaster.NewRecorder(scheme.Scheme, eventReportingController)
+}
+
+// recordEvent emits an event for the object if events are enabled. Without a recorder nothing is emitted.
+func (c *admissionController) recordEvent(regarding runtime.Object, eventType string, reason string, action string, note string) {
+	if c.recorder == nil || !c.conf.GetEmitEvents() {
+		return
+	}
+	c.recorder.Eventf(regarding, nil, eventType, reason, action, "%s", note)
+}
+
+// recordDenial emits a warning event for a denied request. A denied object is never created, the event is emitted on
+// its controller if it has one, so that it is shown when describing the controller.
+func (c *admissionController) recordDenial(req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse) {
+	if resp.Allowed || resp.Result == nil {
+		return
+	}
+	target := requestObjectMetadata(req)
+	owner := metav1.GetControllerOf(target)
+	if owner == nil {
+		c.recordEvent(target, v1.EventTypeWarning, eventReasonDenied, eventActionDeny, resp.Result.Message)
+		return
+	}
+	note := fmt.Sprintf("%s %s denied: %s", strings.ToLower(target.Kind), target.Name, resp.Result.Message)
+	c.recordEvent(ownerEventTarget(target.Namespace, owner), v1.EventTypeWarning, eventReasonDenied, eventActionDeny, note)
+}
+
+// ownerEventTarget describes the owner of an object in the namespace for use as the subject of an event.
+func ownerEventTarget(namespace string, owner *metav1.OwnerReference) runtime.Object {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      owner.Name,
+			Namespace: namespace,
+			UID:       owner.UID,
+		},
+	}
+}
+
+// requestEventTarget describes the object of the request for use as the subject of an event.
+func requestEventTarget(req *admissionv1.AdmissionRequest) runtime.Object {
+	return requestObjectMetadata(req)
+}
+
+// requestObjectMetadata decodes the metadata of the object of the request. Objects that are being created with a
+// generated name do not have a name yet, the prefix for the name is used instead.
+func requestObjectMetadata(req *admissionv1.AdmissionRequest) *metav1.PartialObjectMetadata {
+	target := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(req.Object.Raw, target); err != nil {
+		target = &metav1.PartialObjectMetadata{}
+	}
+	target.TypeMeta = metav1.TypeMeta{
+		APIVersion: metav1.GroupVersion{Group: req.Kind.Group, Version: req.Kind.Version}.String(),
+		Kind:       req.Kind.Kind,
+	}
+	if target.Name == "" {
+		target.Name = req.Name
+	}
+	if target.Name == "" {
+		target.Name = target.GenerateName
+	}
+	if req.Namespace != "" {
+		target.Namespace = req.Namespace
+	}
+	return target
+}
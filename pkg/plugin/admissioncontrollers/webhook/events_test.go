@@ -0,0 +1,202 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func recordedEvents(recorder *events.FakeRecorder) []string {
+	var result []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			result = append(result, event)
+		default:
+			return result
+		}
+	}
+}
+
+func TestRecordEvents(t *testing.T) {
+	srv := serverMock(Failure)
+	defer srv.Close()
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:   strings.Replace(srv.URL, "http://", "", 1),
+		conf.AMWebHookEmitEvents:                "true",
+		conf.AMValidationRequireLabelNamespaces: "^strict-",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	recorder := events.NewFakeRecorder(10)
+	ac.recorder = recorder
+
+	// denied user info annotation
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{userInfoAnnotation: validUserInfoAnnotation},
+	}}
+	req := createPodRequest(t, pod)
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	resp := ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Warning AdmissionDenied user test with groups [dev] is not allowed to set user annotation",
+	})
+
+	// generated application ID
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Normal ApplicationIDGenerated no applicationId label found, generated yunikorn-test-ns-autogen",
+	})
+
+	// explicit application ID is not reported
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{"applicationId": "my-app"},
+	}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(recordedEvents(recorder)), 0)
+
+	// pod validation
+	resp = ac.validatePod(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "strict-team"}}))
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.DeepEqual(t, recordedEvents(recorder), []string{
+		"Warning AdmissionDenied pods in namespace strict-team must set the 'queue' and 'applicationId' label(s) explicitly",
+	})
+
+	// configmap validation
+	configMap := prepareConfigMap(ConfigData)
+	configMap.Namespace = "default"
+	configMapJSON, err := json.Marshal(configMap)
+	assert.NilError(t, err, "failed to marshal configmap")
+	resp = ac.validateConf(&admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: "default",
+		Kind:      metav1.GroupVersionKind{Kind: "ConfigMap"},
+		Object:    runtime.RawExtension{Raw: configMapJSON},
+	})
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	recorded := recordedEvents(recorder)
+	assert.Equal(t, len(recorded), 1)
+	assert.Check(t, strings.HasPrefix(recorded[0], "Warning AdmissionDenied "), "unexpected event %s", recorded[0])
+
+	// events disabled
+	overrides[conf.AMWebHookEmitEvents] = "false"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Equal(t, len(recordedEvents(recorder)), 0)
+
+	// no recorder
+	overrides[conf.AMWebHookEmitEvents] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	ac.recorder = nil
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+}
+
+// targetRecorder keeps the subjects of the recorded events.
+type targetRecorder struct {
+	targets []*metav1.PartialObjectMetadata
+	notes   []string
+}
+
+func (r *targetRecorder) Eventf(regarding runtime.Object, _ runtime.Object, _, _, _, note string, args ...interface{}) {
+	target, _ := regarding.(*metav1.PartialObjectMetadata)
+	r.targets = append(r.targets, target)
+	r.notes = append(r.notes, fmt.Sprintf(note, args...))
+}
+
+func TestRecordDenialOnController(t *testing.T) {
+	config := createConfigWithOverrides(map[string]string{conf.AMWebHookEmitEvents: "true"})
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	recorder := &targetRecorder{}
+	ac.recorder = recorder
+
+	isController := true
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "web-abc",
+		Namespace:   "test-ns",
+		Annotations: map[string]string{userInfoAnnotation: validUserInfoAnnotation},
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web", UID: "rs-uid", Controller: &isController},
+		},
+	}}
+	req := createPodRequest(t, pod)
+	req.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	resp := ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Equal(t, len(recorder.targets), 1)
+	assert.Equal(t, recorder.targets[0].APIVersion, "apps/v1")
+	assert.Equal(t, recorder.targets[0].Kind, "ReplicaSet")
+	assert.Equal(t, recorder.targets[0].Name, "web")
+	assert.Equal(t, recorder.targets[0].Namespace, "test-ns")
+	assert.Equal(t, string(recorder.targets[0].UID), "rs-uid")
+	assert.Equal(t, recorder.notes[0], "pod web-abc denied: user test with groups [dev] is not allowed to set user annotation")
+
+	// an owner which is not the controller is not the subject
+	pod.OwnerReferences[0].Controller = nil
+	req = createPodRequest(t, pod)
+	req.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	req.UserInfo = authv1.UserInfo{Username: "test", Groups: []string{"dev"}}
+	resp = ac.mutate(req)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Equal(t, len(recorder.targets), 2)
+	assert.Equal(t, recorder.targets[1].Kind, "Pod")
+	assert.Equal(t, recorder.targets[1].Name, "web-abc")
+	assert.Equal(t, recorder.notes[1], "user test with groups [dev] is not allowed to set user annotation")
+}
+
+func TestRequestEventTarget(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "web-", Namespace: "pod-ns"}}
+	req := createPodRequest(t, pod)
+	req.Kind = metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	req.Namespace = "test-ns"
+	target, ok := requestEventTarget(req).(*metav1.PartialObjectMetadata)
+	assert.Assert(t, ok, "unexpected target type")
+	assert.Equal(t, target.APIVersion, "v1")
+	assert.Equal(t, target.Kind, "Pod")
+	assert.Equal(t, target.Name, "web-")
+	assert.Equal(t, target.Namespace, "test-ns")
+
+	pod.Name = "web-1"
+	req = createPodRequest(t, pod)
+	req.Kind = metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	target, ok = requestEventTarget(req).(*metav1.PartialObjectMetadata)
+	assert.Assert(t, ok, "unexpected target type")
+	assert.Equal(t, target.APIVersion, "apps/v1")
+	assert.Equal(t, target.Name, "web-1")
+	assert.Equal(t, target.Namespace, "pod-ns")
+}
@@ -0,0 +1,93 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// admissionHandler processes a decoded admission request received on a webhook path.
+type admissionHandler func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// admissionHandlers maps webhook paths to the handler processing the admission requests received on the path.
+type admissionHandlers struct {
+	handlers map[string]admissionHandler
+
+	sync.RWMutex
+}
+
+// newAdmissionHandlers creates the handler map with the default paths registered.
+func newAdmissionHandlers(defaults map[string]admissionHandler) *admissionHandlers {
+	handlers := make(map[string]admissionHandler, len(defaults))
+	for path, handler := range defaults {
+		handlers[path] = handler
+	}
+	return &admissionHandlers{
+		handlers: handlers,
+	}
+}
+
+// register adds the handler for the path. The path must be absolute and must not be registered already.
+func (h *admissionHandlers) register(path string, handler admissionHandler) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("webhook path %q must start with a '/'", path)
+	}
+	if handler == nil {
+		return fmt.Errorf("webhook path %s has no handler", path)
+	}
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.handlers[path]; ok {
+		return fmt.Errorf("webhook path %s is already registered", path)
+	}
+	h.handlers[path] = handler
+	return nil
+}
+
+// get returns the handler for the path, false is returned if the path is not registered.
+func (h *admissionHandlers) get(path string) (admissionHandler, bool) {
+	h.RLock()
+	defer h.RUnlock()
+	handler, ok := h.handlers[path]
+	return handler, ok
+}
+
+// paths returns the registered paths in sorted order.
+func (h *admissionHandlers) paths() []string {
+	h.RLock()
+	defer h.RUnlock()
+	paths := make([]string, 0, len(h.handlers))
+	for path := range h.handlers {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// registerHandler adds a webhook path served by the admission controller. Requests on the path are decoded, audited
+// and encoded in the same way as the requests on the default paths. Paths must be registered before the webhook
+// server is started.
+func (c *admissionController) registerHandler(path string, handler admissionHandler) error {
+	return c.handlers.register(path, handler)
+}
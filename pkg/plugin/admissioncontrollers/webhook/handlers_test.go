@@ -0,0 +1,80 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.DeepEqual(t, ac.handlers.paths(), []string{mutateURL, validateURL, validateConfURL})
+
+	var received *admissionv1.AdmissionRequest
+	custom := func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		received = req
+		return admissionResponseBuilder(string(req.UID), false, "denied by custom handler", nil)
+	}
+	assert.NilError(t, ac.registerHandler("/custom", custom))
+	assert.DeepEqual(t, ac.handlers.paths(), []string{"/custom", mutateURL, validateURL, validateConfURL})
+
+	// invalid registrations
+	assert.ErrorContains(t, ac.registerHandler("custom", custom), "must start with a '/'")
+	assert.ErrorContains(t, ac.registerHandler("/other", nil), "has no handler")
+	assert.ErrorContains(t, ac.registerHandler("/custom", custom), "already registered")
+	assert.ErrorContains(t, ac.registerHandler(mutateURL, custom), "already registered")
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(admissionReviewBody(t, createPodRequest(t, pod))))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		return w
+	}
+
+	// custom path
+	w := serve("/custom")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Assert(t, received != nil, "custom handler not called")
+	resp := admissionReviewResponse(t, w)
+	assert.Check(t, !resp.Allowed, "response was allowed")
+	assert.Equal(t, resp.Result.Message, "denied by custom handler")
+
+	// default path still routed to the mutation
+	received = nil
+	w = serve(mutateURL)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Check(t, received == nil, "custom handler called for the default path")
+	resp = admissionReviewResponse(t, w)
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Check(t, len(resp.Patch) != 0, "mutation did not return a patch")
+
+	// unknown path
+	w = serve("/unknown")
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
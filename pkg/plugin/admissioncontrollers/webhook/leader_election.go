@@ -0,0 +1,82 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+const (
+	leaderElectionLeaseName     = "yunikorn-admission-controller"
+	leaderElectionLeaseDuration = 15 * time.Second
+	leaderElectionRenewDeadline = 10 * time.Second
+	leaderElectionRetryPeriod   = 2 * time.Second
+)
+
+// runLeaderElection campaigns for the admission controller lease in the namespace until the stop channel is closed.
+// The lead function is called when the leadership is acquired, with a channel that is closed when it is lost. A
+// replica that loses the leadership campaigns again.
+func runLeaderElection(clientset kubernetes.Interface, namespace string, identity string, lead func(<-chan struct{}), stopChan <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: leaderElectionLeaseName, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			Name:            leaderElectionLeaseName,
+			LeaseDuration:   leaderElectionLeaseDuration,
+			RenewDeadline:   leaderElectionRenewDeadline,
+			RetryPeriod:     leaderElectionRetryPeriod,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(leaderCtx context.Context) {
+					log.Logger().Info("Acquired the admission controller leadership", zap.String("identity", identity))
+					lead(leaderCtx.Done())
+				},
+				OnStoppedLeading: func() {
+					log.Logger().Info("Released the admission controller leadership", zap.String("identity", identity))
+				},
+				OnNewLeader: func(leader string) {
+					log.Logger().Info("Admission controller leader elected", zap.String("leader", leader))
+				},
+			},
+		})
+	}
+}
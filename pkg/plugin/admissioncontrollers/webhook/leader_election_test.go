@@ -0,0 +1,67 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLeaderElection(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	leading := make(chan struct{})
+	lost := make(chan struct{})
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runLeaderElection(clientset, "default", "replica-1", func(leaderStop <-chan struct{}) {
+			close(leading)
+			<-leaderStop
+			close(lost)
+		}, stopChan)
+		close(done)
+	}()
+
+	select {
+	case <-leading:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership not acquired")
+	}
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), leaderElectionLeaseName, metav1.GetOptions{})
+	assert.NilError(t, err, "lease not created")
+	assert.Equal(t, *lease.Spec.HolderIdentity, "replica-1")
+
+	// stopping releases the leadership
+	close(stopChan)
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership not released")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader election did not stop")
+	}
+}
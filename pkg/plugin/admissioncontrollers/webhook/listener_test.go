@@ -0,0 +1,74 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	listener := newLimitListener(inner, 1)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, err := net.Dial("tcp", inner.Addr().String())
+	assert.NilError(t, err)
+	defer first.Close()
+	second, err := net.Dial("tcp", inner.Addr().String())
+	assert.NilError(t, err)
+	defer second.Close()
+
+	// only one connection is accepted until it is closed
+	conn := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("second connection accepted while the limit was reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NilError(t, conn.Close())
+	// closing twice releases the slot once
+	conn.Close()
+	select {
+	case conn = <-accepted:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second connection not accepted after the first was closed")
+	}
+
+	// a closed listener stops accepting
+	assert.NilError(t, listener.Close())
+	_, err = listener.Accept()
+	assert.Assert(t, err != nil, "accept on closed listener succeeded")
+}
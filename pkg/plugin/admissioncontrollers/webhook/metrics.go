@@ -0,0 +1,193 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	metricsURL       = "/metrics"
+	metricsNamespace = "yunikorn"
+	metricsSubsystem = "admission_controller"
+	// maximum number of users used as a label of the user info rejections, further users are counted as other
+	maxRejectionUsers = 100
+	otherUserLabel    = "other"
+)
+
+// reasons used to label the rejected requests
+const (
+	rejectionInvalidRequest       = "invalid_request"
+	rejectionNamespace            = "namespace"
+	rejectionUserInfo             = "user_info"
+	rejectionQueueRequired        = "queue_required"
+	rejectionLabelsRequired       = "labels_required"
+	rejectionAppIDPattern         = "app_id_pattern"
+	rejectionAppIDConflict        = "app_id_conflict"
+	rejectionAppIDUnique          = "app_id_unique"
+	rejectionTaskGroups           = "task_groups"
+	rejectionTaskGroupParameters  = "task_group_parameters"
+	rejectionPodTemplate          = "pod_template"
+	rejectionNamespaceResourceCap = "namespace_resource_cap"
+	rejectionAppQueueRules        = "app_queue_rules"
+	rejectionUnknownQueue         = "unknown_queue"
+	rejectionQueueDraining        = "queue_draining"
+	rejectionQueueCapacity        = "queue_capacity"
+	rejectionPolicy               = "policy"
+	rejectionInvalidConfig        = "invalid_config"
+	rejectionInternalError        = "internal_error"
+)
+
+// admissionMetrics are the metrics of the admission controller. They are kept in a registry of their own, which is
+// exposed on the metrics endpoint of the webhook server.
+type admissionMetrics struct {
+	registry          *prometheus.Registry
+	requestDuration   *prometheus.HistogramVec
+	patches           *prometheus.CounterVec
+	rejections        *prometheus.CounterVec
+	bypassedPods      *prometheus.CounterVec
+	mirrorPods        *prometheus.CounterVec
+	configValidations *prometheus.CounterVec
+	userRejections    *prometheus.CounterVec
+	groupRejections   *prometheus.CounterVec
+	throttledRequests *prometheus.CounterVec
+
+	// users that have a label of their own in the user rejections
+	rejectionUsers map[string]bool
+	usersLock      sync.Mutex
+}
+
+var metrics = newAdmissionMetrics()
+
+func newAdmissionMetrics() *admissionMetrics {
+	m := &admissionMetrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to process an admission request, by path.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"path"}),
+		patches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "patches_total",
+			Help:      "Number of admission responses that patched the object, by kind.",
+		}, []string{"kind"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rejections_total",
+			Help:      "Number of denied admission requests, by reason. Includes the requests allowed in dry run mode.",
+		}, []string{"reason"}),
+		bypassedPods: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "bypassed_pods_total",
+			Help:      "Number of pods admitted without processing, by namespace.",
+		}, []string{"namespace"}),
+		mirrorPods: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "mirror_pods_total",
+			Help:      "Number of mirror and static pods admitted without mutation, by namespace.",
+		}, []string{"namespace"}),
+		configValidations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "config_validations_total",
+			Help:      "Number of validated scheduler configurations, by result.",
+		}, []string{"result"}),
+		userRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "user_info_rejections_by_user_total",
+			Help:      "Number of requests denied because of the user info annotation, by requesting user. Users beyond the first 100 are counted as other. Only counted if rejection tracking is enabled.",
+		}, []string{"user"}),
+		groupRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "user_info_rejections_by_group_total",
+			Help:      "Number of requests denied because of the user info annotation, by group of the requesting user. Only groups matching the external groups are counted. Only counted if rejection tracking is enabled.",
+		}, []string{"group"}),
+		throttledRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "throttled_requests_total",
+			Help:      "Number of admission requests refused because the webhook was overloaded, by limit.",
+		}, []string{"limit"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.patches,
+		m.rejections,
+		m.bypassedPods,
+		m.mirrorPods,
+		m.configValidations,
+		m.userRejections,
+		m.groupRejections,
+		m.throttledRequests,
+	)
+	m.rejectionUsers = make(map[string]bool)
+	return m
+}
+
+// userLabel returns the label of the user in the user rejections. The first users get a label of their own, all
+// further users share the other label: users are chosen by whoever submits pods, the number of series is bounded.
+func (m *admissionMetrics) userLabel(user string) string {
+	m.usersLock.Lock()
+	defer m.usersLock.Unlock()
+	if m.rejectionUsers[user] {
+		return user
+	}
+	if len(m.rejectionUsers) >= maxRejectionUsers {
+		return otherUserLabel
+	}
+	m.rejectionUsers[user] = true
+	return user
+}
+
+// handler returns the HTTP handler for the metrics endpoint.
+func (m *admissionMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeRequest records the processing time of a request and whether the object was patched.
+func (m *admissionMetrics) observeRequest(path string, req *admissionv1.AdmissionRequest, resp *admissionv1.AdmissionResponse, start time.Time) {
+	m.requestDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+	if req != nil && resp != nil && len(resp.Patch) != 0 {
+		m.patches.WithLabelValues(req.Kind.Kind).Inc()
+	}
+}
+
+// denyResponse builds the response for a denied request and counts the rejection for the reason.
+func denyResponse(uid string, reason string, message string) *admissionv1.AdmissionResponse {
+	metrics.rejections.WithLabelValues(reason).Inc()
+	return admissionResponseBuilder(uid, false, message, nil)
+}
@@ -0,0 +1,82 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+)
+
+func TestMetrics(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	serve := func(pod *v1.Pod) {
+		r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(admissionReviewBody(t, createPodRequest(t, pod))))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		assert.Equal(t, w.Code, http.StatusOK)
+	}
+	patches := testutil.ToFloat64(metrics.patches.WithLabelValues("Pod"))
+	rejections := testutil.ToFloat64(metrics.rejections.WithLabelValues(rejectionTaskGroups))
+	bypassed := testutil.ToFloat64(metrics.bypassedPods.WithLabelValues("kube-system"))
+
+	// patched pod
+	serve(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}})
+	assert.Equal(t, testutil.ToFloat64(metrics.patches.WithLabelValues("Pod")), patches+1)
+
+	// rejected pod
+	serve(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{constants.AnnotationTaskGroups: "invalid"},
+	}})
+	assert.Equal(t, testutil.ToFloat64(metrics.rejections.WithLabelValues(rejectionTaskGroups)), rejections+1)
+	assert.Equal(t, testutil.ToFloat64(metrics.patches.WithLabelValues("Pod")), patches+1)
+
+	// bypassed pod
+	serve(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system"}})
+	assert.Equal(t, testutil.ToFloat64(metrics.bypassedPods.WithLabelValues("kube-system")), bypassed+1)
+
+	// all requests are timed
+	assert.Assert(t, testutil.CollectAndCount(metrics.requestDuration) > 0, "no request durations recorded")
+
+	// metrics endpoint
+	w := httptest.NewRecorder()
+	metrics.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsURL, nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+	body := w.Body.String()
+	for _, name := range []string{
+		"yunikorn_admission_controller_request_duration_seconds",
+		"yunikorn_admission_controller_patches_total",
+		"yunikorn_admission_controller_rejections_total",
+		"yunikorn_admission_controller_bypassed_pods_total",
+		"go_goroutines",
+	} {
+		assert.Check(t, strings.Contains(body, name), "metric %s not exposed", name)
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+// names of the default pod mutators, in the order they are applied
+const (
+	mutatorSchedulerName              = "schedulerName"
+	mutatorLabels                     = "labels"
+	mutatorSchedulingPolicyParameters = "schedulingPolicyParameters"
+	mutatorPreemption                 = "preemption"
+	mutatorNodePool                   = "nodePool"
+	mutatorResourceRequests           = "resourceRequests"
+)
+
+const (
+	nodeSelectorPath = "/spec/nodeSelector"
+	tolerationsPath  = "/spec/tolerations"
+)
+
+// podMutator adds the patch operations for one aspect of a pod to the patch. The pod is not modified, operations added
+// by earlier mutators are visible in the patch.
+type podMutator func(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation
+
+type namedMutator struct {
+	name    string
+	mutator podMutator
+}
+
+// podMutators is the ordered chain of mutators applied to the pods processed by the admission controller.
+type podMutators struct {
+	mutators []namedMutator
+
+	sync.RWMutex
+}
+
+func newPodMutators(defaults []namedMutator) *podMutators {
+	return &podMutators{
+		mutators: append([]namedMutator(nil), defaults...),
+	}
+}
+
+// register adds the mutator at the end of the chain. The name must be unique, it is used to disable the mutator.
+func (m *podMutators) register(name string, mutator podMutator) error {
+	if name == "" {
+		return fmt.Errorf("mutator must have a name")
+	}
+	if mutator == nil {
+		return fmt.Errorf("mutator %s is not defined", name)
+	}
+	m.Lock()
+	defer m.Unlock()
+	for _, existing := range m.mutators {
+		if existing.name == name {
+			return fmt.Errorf("mutator %s is already registered", name)
+		}
+	}
+	m.mutators = append(m.mutators, namedMutator{name: name, mutator: mutator})
+	return nil
+}
+
+// list returns a copy of the chain.
+func (m *podMutators) list() []namedMutator {
+	m.RLock()
+	defer m.RUnlock()
+	return append([]namedMutator(nil), m.mutators...)
+}
+
+// names returns the names of the mutators in the chain.
+func (m *podMutators) names() []string {
+	mutators := m.list()
+	names := make([]string, 0, len(mutators))
+	for _, mutator := range mutators {
+		names = append(names, mutator.name)
+	}
+	return names
+}
+
+// registerMutator adds a mutator to the end of the chain applied to pods. Mutators must be registered before the
+// webhook server is started.
+func (c *admissionController) registerMutator(name string, mutator podMutator) error {
+	return c.mutators.register(name, mutator)
+}
+
+// defaultMutators returns the mutators that make up the chain of a new admission controller.
+func (c *admissionController) defaultMutators() []namedMutator {
+	return []namedMutator{
+		{name: mutatorSchedulerName, mutator: c.mutateSchedulerName},
+		{name: mutatorLabels, mutator: c.mutateLabels},
+		{name: mutatorSchedulingPolicyParameters, mutator: c.mutateSchedulingPolicyParameters},
+		{name: mutatorPreemption, mutator: c.mutatePreemption},
+		{name: mutatorNodePool, mutator: c.mutateNodePool},
+		{name: mutatorResourceRequests, mutator: c.mutateResourceRequests},
+	}
+}
+
+// mutatePod applies the chain of mutators to the pod, skipping the mutators disabled in the configuration.
+func (c *admissionController) mutatePod(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	disabled := make(map[string]bool)
+	for _, name := range c.conf.GetDisabledMutators() {
+		disabled[name] = true
+	}
+	for _, mutator := range c.mutators.list() {
+		if disabled[mutator.name] {
+			log.Logger().Debug("skipping disabled mutator",
+				zap.String("mutator", mutator.name),
+				zap.String("podName", pod.Name),
+				zap.String("generateName", pod.GenerateName))
+			continue
+		}
+		patch = mutator.mutator(namespace, pod, patch)
+	}
+	return patch
+}
+
+func (c *admissionController) mutateSchedulerName(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	if !c.shouldUpdateSchedulerName(pod) {
+		log.Logger().Info("skipping update of scheduler name since pod requests a different scheduler",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("namespace", namespace),
+			zap.String("schedulerName", pod.Spec.SchedulerName))
+		return patch
+	}
+	return updateSchedulerName(patch, c.conf.GetSchedulerName())
+}
+
+func (c *admissionController) mutateLabels(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	if !c.shouldLabelNamespace(namespace) {
+		log.Logger().Info("skipping update of pod labels since namespace is set to no-label",
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("namespace", namespace))
+		return patch
+	}
+	return c.updateLabels(namespace, pod, patch)
+}
+
+func (c *admissionController) mutateSchedulingPolicyParameters(_ string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	return c.updateSchedulingPolicyParameters(pod, patch)
+}
+
+// mutatePreemption sets the allow preemption annotation from the priority class of the pod. An annotation set on the
+// pod is kept.
+func (c *admissionController) mutatePreemption(_ string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	if pod.Spec.PriorityClassName == "" {
+		return patch
+	}
+	allow, ok := c.conf.GetPriorityClassPreemption()[pod.Spec.PriorityClassName]
+	if !ok {
+		return patch
+	}
+	if _, exists := pod.Annotations[constants.AnnotationAllowPreemption]; exists {
+		return patch
+	}
+	log.Logger().Info("setting allow preemption from priority class",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("priorityClass", pod.Spec.PriorityClassName),
+		zap.String("allowPreemption", allow))
+	return updateAnnotation(pod, patch, constants.AnnotationAllowPreemption, allow)
+}
+
+// mutateNodePool pins the pod to the node pool of its queue. The node selector of the pool replaces entries with the
+// same key on the pod, tolerations of the pool are added unless the pod already has them.
+func (c *admissionController) mutateNodePool(_ string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	queue := effectiveLabels(pod, patch)[constants.LabelQueueName]
+	if queue == "" {
+		return patch
+	}
+	pool := queueNodePool(c.conf.GetQueueNodePools(), queue)
+	if pool == nil {
+		return patch
+	}
+	log.Logger().Info("pinning pod to the node pool of its queue",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("queue", queue))
+	keys := make([]string, 0, len(pool.NodeSelector))
+	for key := range pool.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		patch = addMapEntry(patch, nodeSelectorPath, len(pod.Spec.NodeSelector) != 0, key, pool.NodeSelector[key])
+	}
+	var tolerations []v1.Toleration
+	for i := range pool.Tolerations {
+		if !hasToleration(pod.Spec.Tolerations, &pool.Tolerations[i]) {
+			tolerations = append(tolerations, pool.Tolerations[i])
+		}
+	}
+	if len(tolerations) == 0 {
+		return patch
+	}
+	if len(pod.Spec.Tolerations) == 0 {
+		return append(patch, patchOperation{Op: "add", Path: tolerationsPath, Value: tolerations})
+	}
+	for _, toleration := range tolerations {
+		patch = append(patch, patchOperation{Op: "add", Path: tolerationsPath + "/-", Value: toleration})
+	}
+	return patch
+}
+
+// mutateResourceRequests sets the default requests of the namespace on containers and init containers that do not
+// request the resource. A container with a limit for the resource is left alone, the request defaults to the limit.
+func (c *admissionController) mutateResourceRequests(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+	defaults := c.conf.GetDefaultResourceRequests(namespace)
+	if len(defaults) == 0 {
+		return patch
+	}
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	containerPatch := func(path string, containers []v1.Container) {
+		for i := range containers {
+			resources := containers[i].Resources
+			requestsPath := fmt.Sprintf("%s/%d/resources/requests", path, i)
+			for _, name := range names {
+				resourceName := v1.ResourceName(name)
+				if _, ok := resources.Requests[resourceName]; ok {
+					continue
+				}
+				if _, ok := resources.Limits[resourceName]; ok {
+					continue
+				}
+				quantity := defaults[resourceName]
+				log.Logger().Debug("setting default resource request on container",
+					zap.String("podName", pod.Name),
+					zap.String("generateName", pod.GenerateName),
+					zap.String("container", containers[i].Name),
+					zap.String("resource", name),
+					zap.String("quantity", quantity.String()))
+				patch = addMapEntry(patch, requestsPath, len(resources.Requests) != 0, name, quantity.String())
+			}
+		}
+	}
+	containerPatch("/spec/initContainers", pod.Spec.InitContainers)
+	containerPatch("/spec/containers", pod.Spec.Containers)
+	return patch
+}
+
+// queueNodePool returns the node pool of the queue, or of its closest parent with a node pool.
+func queueNodePool(pools map[string]*conf.QueueNodePool, queue string) *conf.QueueNodePool {
+	queue = qualifiedQueuePath(queue)
+	var result *conf.QueueNodePool
+	longest := 0
+	for poolQueue, pool := range pools {
+		path := qualifiedQueuePath(poolQueue)
+		if (queue == path || strings.HasPrefix(queue, path+".")) && len(path) > longest {
+			result = pool
+			longest = len(path)
+		}
+	}
+	return result
+}
+
+func hasToleration(tolerations []v1.Toleration, toleration *v1.Toleration) bool {
+	for i := range tolerations {
+		if tolerations[i].MatchToleration(toleration) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestRegisterMutator(t *testing.T) {
+	ac := initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.DeepEqual(t, ac.mutators.names(), []string{mutatorSchedulerName, mutatorLabels, mutatorSchedulingPolicyParameters, mutatorPreemption, mutatorNodePool,
+		mutatorResourceRequests})
+
+	var seenQueue string
+	custom := func(namespace string, pod *v1.Pod, patch []patchOperation) []patchOperation {
+		// runs after the default mutators and sees their changes
+		seenQueue = effectiveLabels(pod, patch)[constants.LabelQueueName]
+		return updateLabel(pod, patch, "team", namespace+"-team")
+	}
+	assert.NilError(t, ac.registerMutator("team", custom))
+	assert.DeepEqual(t, ac.mutators.names(), []string{mutatorSchedulerName, mutatorLabels, mutatorSchedulingPolicyParameters, mutatorPreemption, mutatorNodePool,
+		mutatorResourceRequests, "team"})
+
+	// invalid registrations
+	assert.ErrorContains(t, ac.registerMutator("", custom), "mutator must have a name")
+	assert.ErrorContains(t, ac.registerMutator("other", nil), "mutator other is not defined")
+	assert.ErrorContains(t, ac.registerMutator("team", custom), "mutator team is already registered")
+	assert.ErrorContains(t, ac.registerMutator(mutatorLabels, custom), "mutator labels is already registered")
+
+	resp := ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	result := labels(t, resp.Patch)
+	assert.Equal(t, result["team"], "test-ns-team")
+	assert.Equal(t, result[constants.LabelQueueName], "root.default")
+	assert.Equal(t, seenQueue, "root.default")
+	assert.Equal(t, schedulerName(t, resp.Patch), constants.SchedulerName)
+}
+
+func TestDisabledMutators(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationDisabledMutators: "labels, unknown",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(labels(t, resp.Patch)), 0)
+	assert.Equal(t, schedulerName(t, resp.Patch), constants.SchedulerName)
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationDisabledMutators: mutatorSchedulerName,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, schedulerName(t, resp.Patch), "")
+	assert.Equal(t, labels(t, resp.Patch)[constants.LabelQueueName], "root.default")
+}
+
+func TestMutatePreemption(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationPriorityClassQueues:     "high=root.prod",
+		conf.AMMutationPriorityClassPreemption: "high=false,low=TRUE",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(pod *v1.Pod) *admissionv1.AdmissionResponse {
+		pod.Namespace = "test-ns"
+		resp := ac.mutate(createPodRequest(t, pod))
+		assert.Check(t, resp.Allowed, "response not allowed")
+		return resp
+	}
+
+	// queue and preemption mapped from the same priority class
+	resp := mutate(&v1.Pod{Spec: v1.PodSpec{PriorityClassName: "high"}})
+	assert.Equal(t, annotations(t, resp.Patch)[constants.AnnotationAllowPreemption], "false")
+	assert.Equal(t, labels(t, resp.Patch)[constants.LabelQueueName], "root.prod")
+	resp = mutate(&v1.Pod{Spec: v1.PodSpec{PriorityClassName: "low"}})
+	assert.Equal(t, annotations(t, resp.Patch)[constants.AnnotationAllowPreemption], "true")
+
+	// unmapped priority class or no priority class
+	resp = mutate(&v1.Pod{Spec: v1.PodSpec{PriorityClassName: "other"}})
+	_, ok := annotations(t, resp.Patch)[constants.AnnotationAllowPreemption]
+	assert.Check(t, !ok, "preemption annotation set for unmapped priority class")
+	resp = mutate(&v1.Pod{})
+	_, ok = annotations(t, resp.Patch)[constants.AnnotationAllowPreemption]
+	assert.Check(t, !ok, "preemption annotation set without priority class")
+
+	// the annotation on the pod is kept
+	resp = mutate(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.AnnotationAllowPreemption: "true"}},
+		Spec:       v1.PodSpec{PriorityClassName: "high"},
+	})
+	_, ok = annotations(t, resp.Patch)[constants.AnnotationAllowPreemption]
+	assert.Check(t, !ok, "preemption annotation replaced")
+}
+
+func TestMutateNodePool(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationQueueNodePools: `{
+			"root.gpu": {"nodeSelector": {"pool": "gpu"}, "tolerations": [{"key": "dedicated", "value": "gpu", "effect": "NoSchedule"}]},
+			"gpu.large": {"nodeSelector": {"pool": "gpu-large"}}}`,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(queue string, spec v1.PodSpec) map[string]interface{} {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Labels: map[string]string{constants.LabelQueueName: queue}},
+			Spec:       spec,
+		}
+		resp := ac.mutate(createPodRequest(t, pod))
+		assert.Check(t, resp.Allowed, "response not allowed")
+		result := make(map[string]interface{})
+		for _, op := range parsePatch(t, resp.Patch) {
+			if strings.HasPrefix(op.Path, nodeSelectorPath) || strings.HasPrefix(op.Path, tolerationsPath) {
+				result[op.Path] = op.Value
+			}
+		}
+		return result
+	}
+	dedicated := map[string]interface{}{"key": "dedicated", "value": "gpu", "effect": "NoSchedule"}
+
+	// queue and child queue of the pool
+	ops := mutate("root.gpu", v1.PodSpec{})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		nodeSelectorPath:           map[string]interface{}{},
+		nodeSelectorPath + "/pool": "gpu",
+		tolerationsPath:            []interface{}{dedicated},
+	})
+	ops = mutate("root.gpu.team-a", v1.PodSpec{NodeSelector: map[string]string{"zone": "a"}})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		nodeSelectorPath + "/pool": "gpu",
+		tolerationsPath:            []interface{}{dedicated},
+	})
+
+	// the closest pool wins
+	ops = mutate("root.gpu.large", v1.PodSpec{})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		nodeSelectorPath:           map[string]interface{}{},
+		nodeSelectorPath + "/pool": "gpu-large",
+	})
+
+	// existing tolerations are kept and not duplicated
+	ops = mutate("root.gpu", v1.PodSpec{Tolerations: []v1.Toleration{{Key: "other", Operator: v1.TolerationOpExists}}})
+	assert.DeepEqual(t, ops[tolerationsPath+"/-"], dedicated)
+	ops = mutate("root.gpu", v1.PodSpec{Tolerations: []v1.Toleration{{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}}})
+	_, ok := ops[tolerationsPath+"/-"]
+	assert.Check(t, !ok, "toleration duplicated")
+
+	// queues without a pool
+	assert.Equal(t, len(mutate("root.gpus", v1.PodSpec{})), 0)
+	assert.Equal(t, len(mutate("root.default", v1.PodSpec{})), 0)
+}
+
+func TestMutateResourceRequests(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationDefaultResourceRequests: "*:cpu=100m,*:memory=128Mi,team-a:cpu=500m",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(namespace string, spec v1.PodSpec) map[string]interface{} {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace}, Spec: spec}
+		resp := ac.mutate(createPodRequest(t, pod))
+		assert.Check(t, resp.Allowed, "response not allowed")
+		result := make(map[string]interface{})
+		for _, op := range parsePatch(t, resp.Patch) {
+			if strings.Contains(op.Path, "/resources/requests") {
+				result[op.Path] = op.Value
+			}
+		}
+		return result
+	}
+	requests := func(cpu, memory string) v1.ResourceRequirements {
+		list := make(v1.ResourceList)
+		if cpu != "" {
+			list[v1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			list[v1.ResourceMemory] = resource.MustParse(memory)
+		}
+		return v1.ResourceRequirements{Requests: list}
+	}
+
+	// containers without requests get the global defaults
+	ops := mutate("test-ns", v1.PodSpec{Containers: []v1.Container{{Name: "main"}}})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		"/spec/containers/0/resources/requests":        map[string]interface{}{},
+		"/spec/containers/0/resources/requests/cpu":    "100m",
+		"/spec/containers/0/resources/requests/memory": "128Mi",
+	})
+
+	// namespace defaults replace the global default, existing requests are kept
+	ops = mutate("team-a", v1.PodSpec{
+		InitContainers: []v1.Container{{Name: "init", Resources: requests("", "1Gi")}},
+		Containers:     []v1.Container{{Name: "main", Resources: requests("2", "")}},
+	})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		"/spec/initContainers/0/resources/requests/cpu": "500m",
+		"/spec/containers/0/resources/requests/memory":  "128Mi",
+	})
+
+	// a limit sets the request
+	ops = mutate("test-ns", v1.PodSpec{Containers: []v1.Container{{
+		Name:      "main",
+		Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+	}}})
+	assert.DeepEqual(t, ops, map[string]interface{}{
+		"/spec/containers/0/resources/requests":        map[string]interface{}{},
+		"/spec/containers/0/resources/requests/memory": "128Mi",
+	})
+
+	// fully specified containers are not changed
+	assert.Equal(t, len(mutate("test-ns", v1.PodSpec{Containers: []v1.Container{{Name: "main", Resources: requests("1", "1Gi")}}})), 0)
+}
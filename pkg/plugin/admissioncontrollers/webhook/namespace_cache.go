@@ -0,0 +1,116 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	informersv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+// NamespaceCache keeps the namespaces of the cluster up to date via an informer, so that namespace metadata can be
+// consulted during admission without calling the API server.
+type NamespaceCache struct {
+	namespaces map[string]*v1.Namespace
+
+	sync.RWMutex
+}
+
+// NewNamespaceCache creates a new cache and registers it with the informer. A nil informer creates an empty cache.
+func NewNamespaceCache(namespaces informersv1.NamespaceInformer) *NamespaceCache {
+	nsc := &NamespaceCache{
+		namespaces: make(map[string]*v1.Namespace),
+	}
+	if namespaces != nil {
+		namespaces.Informer().AddEventHandler(&namespaceUpdateHandler{cache: nsc})
+	}
+	return nsc
+}
+
+// getNamespace returns the cached namespace object, or nil if the namespace is not known.
+// The returned object is shared and must not be modified.
+func (nsc *NamespaceCache) getNamespace(name string) *v1.Namespace {
+	nsc.RLock()
+	defer nsc.RUnlock()
+	return nsc.namespaces[name]
+}
+
+// getAnnotation returns the value of an annotation on the namespace.
+func (nsc *NamespaceCache) getAnnotation(name string, key string) (string, bool) {
+	ns := nsc.getNamespace(name)
+	if ns == nil {
+		return "", false
+	}
+	value, ok := ns.Annotations[key]
+	return value, ok
+}
+
+func (nsc *NamespaceCache) addNamespace(ns *v1.Namespace) {
+	nsc.Lock()
+	defer nsc.Unlock()
+	nsc.namespaces[ns.Name] = ns
+}
+
+func (nsc *NamespaceCache) removeNamespace(ns *v1.Namespace) {
+	nsc.Lock()
+	defer nsc.Unlock()
+	delete(nsc.namespaces, ns.Name)
+}
+
+type namespaceUpdateHandler struct {
+	cache *NamespaceCache
+}
+
+func (h *namespaceUpdateHandler) OnAdd(obj interface{}) {
+	if ns := convert2Namespace(obj); ns != nil {
+		h.cache.addNamespace(ns)
+	}
+}
+
+func (h *namespaceUpdateHandler) OnUpdate(_, newObj interface{}) {
+	if ns := convert2Namespace(newObj); ns != nil {
+		h.cache.addNamespace(ns)
+	}
+}
+
+func (h *namespaceUpdateHandler) OnDelete(obj interface{}) {
+	var ns *v1.Namespace
+	switch t := obj.(type) {
+	case *v1.Namespace:
+		ns = t
+	case cache.DeletedFinalStateUnknown:
+		ns = convert2Namespace(t.Obj)
+	}
+	if ns == nil {
+		log.Logger().Warn("unable to convert to namespace")
+		return
+	}
+	h.cache.removeNamespace(ns)
+}
+
+func convert2Namespace(obj interface{}) *v1.Namespace {
+	if ns, ok := obj.(*v1.Namespace); ok {
+		return ns
+	}
+	return nil
+}
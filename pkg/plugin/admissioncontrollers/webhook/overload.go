@@ -0,0 +1,143 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+// limits used to label the throttled requests
+const (
+	throttleInFlight = "in_flight"
+	throttleGlobal   = "global"
+	throttleClient   = "client"
+)
+
+const (
+	// maxTrackedClients bounds the number of client rate limiters, all client limiters are reset when it is reached
+	maxTrackedClients = 10000
+	retryAfterSeconds = "1"
+)
+
+// rateLimiter is a token bucket for the configured rate and burst.
+type rateLimiter struct {
+	flowcontrol.RateLimiter
+	qps   int
+	burst int
+}
+
+// updateLimiter returns the limiter for the rate and burst: the current limiter if the configuration did not change, a
+// new limiter if it did, or nil if the rate is not limited.
+func updateLimiter(current *rateLimiter, qps int, burst int) *rateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	if current != nil && current.qps == qps && current.burst == burst {
+		return current
+	}
+	bucket := burst
+	if bucket <= 0 {
+		bucket = qps
+	}
+	return &rateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(float32(qps), bucket),
+		qps:         qps,
+		burst:       burst,
+	}
+}
+
+// overloadProtection limits the admission requests processed by the webhook. A storm of requests is refused early,
+// instead of slowing down the admission of every object.
+type overloadProtection struct {
+	conf     *conf.AdmissionControllerConf
+	inFlight int64
+	global   *rateLimiter
+	clients  map[string]*rateLimiter
+
+	sync.Mutex
+}
+
+func newOverloadProtection(conf *conf.AdmissionControllerConf) *overloadProtection {
+	return &overloadProtection{
+		conf: conf,
+	}
+}
+
+// acquire admits a request against the in flight and the global rate limits. It returns the limit that refused the
+// request, or an empty limit and the function that must be called once the request is processed.
+func (o *overloadProtection) acquire() (string, func()) {
+	// requests are always counted, so that the limit can be enabled while requests are processed
+	inFlight := atomic.AddInt64(&o.inFlight, 1)
+	release := func() {
+		atomic.AddInt64(&o.inFlight, -1)
+	}
+	if maxInFlight := o.conf.GetMaxInFlightRequests(); maxInFlight > 0 && inFlight > int64(maxInFlight) {
+		release()
+		return throttleInFlight, nil
+	}
+	qps, burst := o.conf.GetRateLimit()
+	o.Lock()
+	o.global = updateLimiter(o.global, qps, burst)
+	limiter := o.global
+	o.Unlock()
+	if limiter != nil && !limiter.TryAccept() {
+		release()
+		return throttleGlobal, nil
+	}
+	return "", release
+}
+
+// allowClient checks the rate limit of the requesting user. The API server sends all requests, the user that created
+// the object identifies the client.
+func (o *overloadProtection) allowClient(client string) bool {
+	qps, burst := o.conf.GetClientRateLimit()
+	o.Lock()
+	if qps <= 0 {
+		o.clients = nil
+		o.Unlock()
+		return true
+	}
+	current := o.clients[client]
+	limiter := updateLimiter(current, qps, burst)
+	if limiter != current {
+		if o.clients == nil || (current == nil && len(o.clients) >= maxTrackedClients) {
+			o.clients = make(map[string]*rateLimiter)
+		}
+		o.clients[client] = limiter
+	}
+	o.Unlock()
+	return limiter.TryAccept()
+}
+
+// throttle refuses a request with a 429 response. The API server handles the response as a failed webhook call: the
+// failure policy of the webhook decides whether the object is admitted.
+func throttle(w http.ResponseWriter, limit string) {
+	log.Logger().Debug("admission request throttled", zap.String("limit", limit))
+	metrics.throttledRequests.WithLabelValues(limit).Inc()
+	w.Header().Set("Retry-After", retryAfterSeconds)
+	http.Error(w, "too many admission requests", http.StatusTooManyRequests)
+}
@@ -0,0 +1,119 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestOverloadInFlight(t *testing.T) {
+	o := newOverloadProtection(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookMaxInFlightRequests: "1",
+	}))
+	limit, release := o.acquire()
+	assert.Equal(t, limit, "")
+	limit, _ = o.acquire()
+	assert.Equal(t, limit, throttleInFlight)
+	release()
+	limit, release = o.acquire()
+	assert.Equal(t, limit, "")
+	release()
+	assert.Equal(t, o.inFlight, int64(0))
+}
+
+func TestOverloadRateLimit(t *testing.T) {
+	o := newOverloadProtection(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookRateLimitQPS:   "1",
+		conf.AMWebHookRateLimitBurst: "2",
+	}))
+	for i := 0; i < 2; i++ {
+		limit, release := o.acquire()
+		assert.Equal(t, limit, "", "request %d throttled", i)
+		release()
+	}
+	limit, _ := o.acquire()
+	assert.Equal(t, limit, throttleGlobal)
+	assert.Equal(t, o.inFlight, int64(0))
+
+	// no limits by default
+	o = newOverloadProtection(createConfig())
+	for i := 0; i < 100; i++ {
+		limit, release := o.acquire()
+		assert.Equal(t, limit, "", "request %d throttled", i)
+		release()
+		assert.Check(t, o.allowClient("user"), "request %d throttled for client", i)
+	}
+}
+
+func TestOverloadClientRateLimit(t *testing.T) {
+	config := createConfigWithOverrides(map[string]string{
+		conf.AMWebHookClientRateLimitQPS: "1",
+	})
+	o := newOverloadProtection(config)
+	assert.Check(t, o.allowClient("user-a"), "first request throttled")
+	assert.Check(t, !o.allowClient("user-a"), "second request not throttled")
+	assert.Check(t, o.allowClient("user-b"), "request of other client throttled")
+	assert.Equal(t, len(o.clients), 2)
+
+	// a changed rate replaces the limiter
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{
+		conf.AMWebHookClientRateLimitQPS:   "1",
+		conf.AMWebHookClientRateLimitBurst: "2",
+	}}})
+	assert.Check(t, o.allowClient("user-a"), "request throttled after rate change")
+	assert.Check(t, o.allowClient("user-a"), "burst request throttled after rate change")
+	assert.Check(t, !o.allowClient("user-a"), "request above burst not throttled")
+
+	// disabling the limit drops the limiters
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{}}})
+	assert.Check(t, o.allowClient("user-a"), "request throttled without limit")
+	assert.Equal(t, len(o.clients), 0)
+}
+
+func TestServeThrottled(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookClientRateLimitQPS: "1",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}})
+	req.UserInfo = authv1.UserInfo{Username: "system:serviceaccount:apps:operator"}
+	serve := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, mutateURL, bytes.NewReader(admissionReviewBody(t, req)))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ac.serve(w, r)
+		return w
+	}
+	w := serve()
+	assert.Equal(t, w.Code, http.StatusOK)
+	w = serve()
+	assert.Equal(t, w.Code, http.StatusTooManyRequests)
+	assert.Equal(t, w.Header().Get("Retry-After"), retryAfterSeconds)
+	assert.Equal(t, ac.overload.inFlight, int64(0))
+}
@@ -0,0 +1,119 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	informersappsv1 "k8s.io/client-go/informers/apps/v1"
+	informersbatchv1 "k8s.io/client-go/informers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+// maxOwnerDepth limits the owner chain that is followed, owner references are set by users and may contain a cycle.
+const maxOwnerDepth = 5
+
+// OwnerCache tracks the controller of the intermediate workload objects, ReplicaSets and Jobs, via informers. This
+// allows the pods to be grouped by the top-level controller, e.g. the Deployment or CronJob, without calling the API
+// server during admission.
+type OwnerCache struct {
+	owners map[types.UID]metav1.OwnerReference
+
+	sync.RWMutex
+}
+
+// NewOwnerCache creates a new cache and registers it with the informers. Nil informers create an empty cache.
+func NewOwnerCache(replicaSets informersappsv1.ReplicaSetInformer, jobs informersbatchv1.JobInformer) *OwnerCache {
+	oc := &OwnerCache{
+		owners: make(map[types.UID]metav1.OwnerReference),
+	}
+	if replicaSets != nil {
+		replicaSets.Informer().AddEventHandler(&ownerUpdateHandler{cache: oc})
+	}
+	if jobs != nil {
+		jobs.Informer().AddEventHandler(&ownerUpdateHandler{cache: oc})
+	}
+	return oc
+}
+
+// getTopLevelOwner follows the controllers of the owner until an object is reached whose controller is not known.
+func (oc *OwnerCache) getTopLevelOwner(owner *metav1.OwnerReference) *metav1.OwnerReference {
+	oc.RLock()
+	defer oc.RUnlock()
+	for i := 0; i < maxOwnerDepth; i++ {
+		parent, ok := oc.owners[owner.UID]
+		if !ok {
+			break
+		}
+		owner = &parent
+	}
+	return owner
+}
+
+// addObject records the controller of the object. Objects without a controller are not tracked.
+func (oc *OwnerCache) addObject(obj metav1.Object) {
+	controller := metav1.GetControllerOf(obj)
+	if controller == nil {
+		oc.removeObject(obj)
+		return
+	}
+	oc.Lock()
+	defer oc.Unlock()
+	oc.owners[obj.GetUID()] = *controller
+}
+
+func (oc *OwnerCache) removeObject(obj metav1.Object) {
+	oc.Lock()
+	defer oc.Unlock()
+	delete(oc.owners, obj.GetUID())
+}
+
+type ownerUpdateHandler struct {
+	cache *OwnerCache
+}
+
+func (h *ownerUpdateHandler) OnAdd(obj interface{}) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		h.cache.addObject(accessor)
+	}
+}
+
+func (h *ownerUpdateHandler) OnUpdate(_, newObj interface{}) {
+	if accessor, err := meta.Accessor(newObj); err == nil {
+		h.cache.addObject(accessor)
+	}
+}
+
+func (h *ownerUpdateHandler) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		log.Logger().Warn("unable to convert to owned object", zap.Error(err))
+		return
+	}
+	h.cache.removeObject(accessor)
+}
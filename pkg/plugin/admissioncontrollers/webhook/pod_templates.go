@@ -0,0 +1,139 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+const (
+	podTemplatePath        = "/spec/template"
+	cronJobPodTemplatePath = "/spec/jobTemplate/spec/template"
+)
+
+// podOnlyPaths are only patched on the pods, never on a pod template. The application ID depends on the pod: its
+// owner, which does not exist yet when the controller is created, and the time it is created. The settings derived
+// from a generated application ID follow it.
+var podOnlyPaths = map[string]bool{
+	labelsPath + "/" + constants.LabelApplicationID:                        true,
+	labelsPath + "/" + constants.LabelDisableStateAware:                    true,
+	labelsPath + "/" + jsonPointerEscaper.Replace(statefulSetOrdinalLabel): true,
+	terminationGracePeriodPath:                                             true,
+}
+
+// podTemplateWorkload is a controller with the pod template that is mutated in place of its pods.
+type podTemplateWorkload struct {
+	meta     metav1.ObjectMeta
+	template *v1.PodTemplateSpec
+	path     string
+}
+
+// getPodTemplateWorkload decodes the controllers whose pod template is mutated. Other kinds return nil.
+func getPodTemplateWorkload(req *admissionv1.AdmissionRequest) (*podTemplateWorkload, error) {
+	switch req.Kind.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := json.Unmarshal(req.Object.Raw, &deployment); err != nil {
+			return nil, err
+		}
+		return &podTemplateWorkload{meta: deployment.ObjectMeta, template: &deployment.Spec.Template, path: podTemplatePath}, nil
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := json.Unmarshal(req.Object.Raw, &statefulSet); err != nil {
+			return nil, err
+		}
+		return &podTemplateWorkload{meta: statefulSet.ObjectMeta, template: &statefulSet.Spec.Template, path: podTemplatePath}, nil
+	case "Job":
+		var job batchv1.Job
+		if err := json.Unmarshal(req.Object.Raw, &job); err != nil {
+			return nil, err
+		}
+		return &podTemplateWorkload{meta: job.ObjectMeta, template: &job.Spec.Template, path: podTemplatePath}, nil
+	case "CronJob":
+		var cronJob batchv1.CronJob
+		if err := json.Unmarshal(req.Object.Raw, &cronJob); err != nil {
+			return nil, err
+		}
+		return &podTemplateWorkload{meta: cronJob.ObjectMeta, template: &cronJob.Spec.JobTemplate.Spec.Template, path: cronJobPodTemplatePath}, nil
+	}
+	return nil, nil
+}
+
+// templatePod returns a pod with the metadata and spec of the template.
+func (w *podTemplateWorkload) templatePod(namespace string) *v1.Pod {
+	name := w.meta.Name
+	if name == "" {
+		name = w.meta.GenerateName
+	}
+	pod := &v1.Pod{
+		ObjectMeta: *w.template.ObjectMeta.DeepCopy(),
+		Spec:       w.template.Spec,
+	}
+	pod.Namespace = namespace
+	pod.GenerateName = name + "-"
+	return pod
+}
+
+// updatePodTemplate applies the pod mutators to the pod template of a controller, setting the scheduler name, the
+// queue label and the other settings that do not depend on the pod in the same way as they are set on a pod. Pods
+// created from the template then carry them from the start, the application ID is still generated for each pod. Only
+// new controllers are mutated: changing the template of an existing controller would replace its running pods.
+func (c *admissionController) updatePodTemplate(req *admissionv1.AdmissionRequest, patch []patchOperation) ([]patchOperation, error) {
+	if !c.conf.GetMutatePodTemplates() || req.Operation != admissionv1.Create {
+		return patch, nil
+	}
+	workload, err := getPodTemplateWorkload(req)
+	if err != nil || workload == nil {
+		return patch, err
+	}
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = workload.meta.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	pod := workload.templatePod(namespace)
+	if !c.shouldProcessPod(namespace, pod) {
+		log.Logger().Info("bypassing pod template",
+			zap.String("kind", req.Kind.Kind),
+			zap.String("name", workload.meta.Name),
+			zap.String("namespace", namespace))
+		return patch, nil
+	}
+
+	for _, op := range c.mutatePod(namespace, pod, nil) {
+		if podOnlyPaths[op.Path] {
+			continue
+		}
+		op.Path = workload.path + op.Path
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
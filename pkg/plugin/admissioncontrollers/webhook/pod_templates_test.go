@@ -0,0 +1,153 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func createWorkloadRequest(t *testing.T, kind string, namespace string, workload interface{}) *admissionv1.AdmissionRequest {
+	raw, err := json.Marshal(workload)
+	assert.NilError(t, err, "failed to marshal workload")
+	return &admissionv1.AdmissionRequest{
+		UID:       "test-uid",
+		Namespace: namespace,
+		Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+// templatePatch returns the values of the patch operations below the template path, keyed by the remaining path.
+func templatePatch(t *testing.T, patch []byte, path string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, op := range parsePatch(t, patch) {
+		assert.Check(t, len(op.Path) > len(path) && op.Path[:len(path)] == path, "unexpected patch path %s", op.Path)
+		result[op.Path[len(path):]] = op.Value
+	}
+	return result
+}
+
+func TestMutatePodTemplates(t *testing.T) {
+	template := v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}}}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test-ns"},
+		Spec:       appsv1.DeploymentSpec{Template: template},
+	}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "test-ns"},
+		Spec:       appsv1.StatefulSetSpec{Template: template},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "pi", Namespace: "test-ns"},
+		Spec:       batchv1.JobSpec{Template: template},
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "test-ns"},
+		Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{
+			Spec: batchv1.JobSpec{Template: template},
+		}},
+	}
+	overrides := map[string]string{
+		conf.AMMutationPodTemplates:    "true",
+		conf.AMMutationOwnerBasedAppID: "true",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// the application ID is generated for each pod, it depends on the owner of the pod
+	tests := []struct {
+		kind     string
+		workload interface{}
+		path     string
+	}{
+		{"Deployment", deployment, podTemplatePath},
+		{"StatefulSet", statefulSet, podTemplatePath},
+		{"Job", job, podTemplatePath},
+		{"CronJob", cronJob, cronJobPodTemplatePath},
+	}
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			resp := ac.mutate(createWorkloadRequest(t, test.kind, "test-ns", test.workload))
+			assert.Check(t, resp.Allowed, "response not allowed")
+			assert.DeepEqual(t, templatePatch(t, resp.Patch, test.path), map[string]interface{}{
+				schedulerNamePath: constants.SchedulerName,
+				labelsPath + "/" + constants.LabelQueueName: "root.default",
+			})
+		})
+	}
+
+	// pod specific settings are not set on the template
+	overrides[conf.AMMutationAutogenTerminationGracePeriod] = "10"
+	overrides[conf.AMMutationStatefulSetAppID] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp := ac.mutate(createWorkloadRequest(t, "StatefulSet", "test-ns", statefulSet))
+	assert.DeepEqual(t, templatePatch(t, resp.Patch, podTemplatePath), map[string]interface{}{
+		schedulerNamePath: constants.SchedulerName,
+		labelsPath + "/" + constants.LabelQueueName: "root.default",
+	})
+
+	// labels set on the template are kept
+	deployment.Spec.Template.Labels = map[string]string{constants.LabelApplicationID: "my-app", constants.LabelQueueName: "root.web"}
+	resp = ac.mutate(createWorkloadRequest(t, "Deployment", "test-ns", deployment))
+	assert.DeepEqual(t, templatePatch(t, resp.Patch, podTemplatePath), map[string]interface{}{
+		schedulerNamePath: constants.SchedulerName,
+	})
+
+	// another scheduler requested
+	deployment.Spec.Template.Spec.SchedulerName = "other-scheduler"
+	resp = ac.mutate(createWorkloadRequest(t, "Deployment", "test-ns", deployment))
+	assert.Equal(t, len(resp.Patch), 0)
+
+	// updates are not mutated
+	req := createWorkloadRequest(t, "Job", "test-ns", job)
+	req.Operation = admissionv1.Update
+	resp = ac.mutate(req)
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+
+	// bypassed namespace
+	resp = ac.mutate(createWorkloadRequest(t, "Job", "kube-system", job))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+
+	// kinds without a mutated template
+	resp = ac.mutate(createWorkloadRequest(t, "DaemonSet", "test-ns", &appsv1.DaemonSet{Spec: appsv1.DaemonSetSpec{Template: template}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+
+	// disabled
+	overrides[conf.AMMutationPodTemplates] = "false"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = ac.mutate(createWorkloadRequest(t, "Job", "test-ns", job))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, len(resp.Patch), 0)
+}
@@ -0,0 +1,121 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	informersv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	resourcehelper "k8s.io/kubernetes/pkg/api/v1/resource"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/utils"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+// PodUsageCache tracks the resources requested by the pods of each namespace via an informer. The usage is an
+// estimate: pods that are admitted but not yet seen by the informer are not included.
+type PodUsageCache struct {
+	requests map[string]map[types.UID]v1.ResourceList
+
+	sync.RWMutex
+}
+
+// NewPodUsageCache creates a new cache and registers it with the informer. A nil informer creates an empty cache.
+func NewPodUsageCache(pods informersv1.PodInformer) *PodUsageCache {
+	puc := &PodUsageCache{
+		requests: make(map[string]map[types.UID]v1.ResourceList),
+	}
+	if pods != nil {
+		pods.Informer().AddEventHandler(&podUsageUpdateHandler{cache: puc})
+	}
+	return puc
+}
+
+// getUsage returns the sum of the resources requested by the pods in the namespace.
+func (puc *PodUsageCache) getUsage(namespace string) v1.ResourceList {
+	puc.RLock()
+	defer puc.RUnlock()
+	usage := make(v1.ResourceList)
+	for _, requests := range puc.requests[namespace] {
+		for name, quantity := range requests {
+			total := usage[name]
+			total.Add(quantity)
+			usage[name] = total
+		}
+	}
+	return usage
+}
+
+// addPod records the requests of the pod. Pods which have terminated no longer use resources and are removed.
+func (puc *PodUsageCache) addPod(pod *v1.Pod) {
+	if isTerminalPod(pod) {
+		puc.removePod(pod)
+		return
+	}
+	requests, _ := resourcehelper.PodRequestsAndLimits(pod)
+	puc.Lock()
+	defer puc.Unlock()
+	if puc.requests[pod.Namespace] == nil {
+		puc.requests[pod.Namespace] = make(map[types.UID]v1.ResourceList)
+	}
+	puc.requests[pod.Namespace][pod.UID] = requests
+}
+
+func (puc *PodUsageCache) removePod(pod *v1.Pod) {
+	puc.Lock()
+	defer puc.Unlock()
+	delete(puc.requests[pod.Namespace], pod.UID)
+	if len(puc.requests[pod.Namespace]) == 0 {
+		delete(puc.requests, pod.Namespace)
+	}
+}
+
+type podUsageUpdateHandler struct {
+	cache *PodUsageCache
+}
+
+func (h *podUsageUpdateHandler) OnAdd(obj interface{}) {
+	if pod, err := utils.Convert2Pod(obj); err == nil {
+		h.cache.addPod(pod)
+	}
+}
+
+func (h *podUsageUpdateHandler) OnUpdate(_, newObj interface{}) {
+	if pod, err := utils.Convert2Pod(newObj); err == nil {
+		h.cache.addPod(pod)
+	}
+}
+
+func (h *podUsageUpdateHandler) OnDelete(obj interface{}) {
+	var pod *v1.Pod
+	switch t := obj.(type) {
+	case *v1.Pod:
+		pod = t
+	case cache.DeletedFinalStateUnknown:
+		pod, _ = utils.Convert2Pod(t.Obj)
+	}
+	if pod == nil {
+		log.Logger().Warn("unable to convert to pod")
+		return
+	}
+	h.cache.removePod(pod)
+}
@@ -0,0 +1,155 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+// maximum size of a policy decision read from the policy engine
+const maxPolicyResponseSize = 1024 * 1024
+
+// policyInput is the input document evaluated by the policy engine: the admission request and the patch computed by
+// the admission controller.
+type policyInput struct {
+	Request *admissionv1.AdmissionRequest `json:"request"`
+	Patch   []patchOperation              `json:"patch"`
+}
+
+// PolicyDecision is the policy decision document. The policy engine is queried through the OPA data API:
+// POST <policyURL> with {"input": <policyInput>} returns {"result": <PolicyDecision>}. A pod is denied if allowed is
+// false, the reason is returned to the submitter. The patch operations are added to the patch of the pod. An undefined
+// decision admits the pod unchanged.
+type PolicyDecision struct {
+	Allowed *bool            `json:"allowed,omitempty"`
+	Reason  string           `json:"reason,omitempty"`
+	Patch   []patchOperation `json:"patch,omitempty"`
+}
+
+type policyResponse struct {
+	Result *PolicyDecision `json:"result"`
+}
+
+// checkPolicy evaluates the pod and its patch against the policies, if a policy engine is configured, and returns the
+// patch with the operations added by the policies. It runs before the queue and application checks, which therefore
+// validate the labels set by the policies. If the policy engine cannot be queried the configured failure policy
+// applies.
+func (c *admissionController) checkPolicy(req *admissionv1.AdmissionRequest, patch []patchOperation) ([]patchOperation, error) {
+	policyURL := c.conf.GetPolicyURL()
+	if policyURL == "" {
+		return patch, nil
+	}
+	decision, err := c.queryPolicy(policyURL, req, patch)
+	if err != nil {
+		if c.conf.GetPolicyFailurePolicy() == conf.FailurePolicyIgnore {
+			log.Logger().Warn("Unable to evaluate admission policies, admitting pod", zap.Error(err))
+			return patch, nil
+		}
+		return patch, fmt.Errorf("unable to evaluate admission policies: %v", err)
+	}
+	if decision == nil {
+		return patch, nil
+	}
+	if decision.Allowed != nil && !*decision.Allowed {
+		if decision.Reason == "" {
+			return patch, fmt.Errorf("denied by admission policy")
+		}
+		return patch, fmt.Errorf("denied by admission policy: %s", decision.Reason)
+	}
+	for _, op := range decision.Patch {
+		if err = validatePolicyPatch(op); err != nil {
+			return patch, fmt.Errorf("invalid patch returned by admission policy: %v", err)
+		}
+	}
+	return append(patch, decision.Patch...), nil
+}
+
+func (c *admissionController) queryPolicy(policyURL string, req *admissionv1.AdmissionRequest, patch []patchOperation) (*PolicyDecision, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"input": policyInput{Request: req, Patch: patch},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.conf.GetPolicyTimeout())
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, policyURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.policyClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("policy engine responded with unexpected status %d", response.StatusCode)
+	}
+	content, err := io.ReadAll(io.LimitReader(response.Body, maxPolicyResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxPolicyResponseSize {
+		return nil, fmt.Errorf("policy decision exceeds %d bytes", maxPolicyResponseSize)
+	}
+	var result policyResponse
+	if err = json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+	return result.Result, nil
+}
+
+// validatePolicyPatch checks that a patch operation returned by a policy can be applied to a pod. Labels can only be
+// set one at a time with an add operation: replacing or removing the metadata or the labels as a whole would not be
+// seen by the label checks.
+func validatePolicyPatch(op patchOperation) error {
+	switch op.Op {
+	case "add", "replace", "remove":
+	default:
+		return fmt.Errorf("unsupported operation '%s'", op.Op)
+	}
+	if !strings.HasPrefix(op.Path, "/") {
+		return fmt.Errorf("path '%s' must start with '/'", op.Path)
+	}
+	if op.Path == metadataPath || op.Path == labelsPath {
+		return fmt.Errorf("path '%s' cannot be patched, set individual labels instead", op.Path)
+	}
+	if strings.HasPrefix(op.Path, labelsPath+"/") {
+		if op.Op != "add" {
+			return fmt.Errorf("unsupported operation '%s' for label path '%s'", op.Op, op.Path)
+		}
+		if _, ok := op.Value.(string); !ok {
+			return fmt.Errorf("label path '%s' must have a string value", op.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	var decision string
+	var input map[string]map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NilError(t, err)
+		assert.NilError(t, json.Unmarshal(body, &input))
+		if decision == "error" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = w.Write([]byte(decision))
+		assert.NilError(t, err)
+	}))
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMValidationPolicyURL: srv.URL + "/v1/data/yunikorn/admission",
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func() (bool, string, []byte) {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Labels:    map[string]string{"team": "a"},
+		}}
+		resp := ac.mutate(createPodRequest(t, pod))
+		if resp.Result != nil {
+			return resp.Allowed, resp.Result.Message, resp.Patch
+		}
+		return resp.Allowed, "", resp.Patch
+	}
+
+	// undefined decision admits the pod, the request and patch are sent as input
+	decision = `{}`
+	allowed, _, _ := mutate()
+	assert.Check(t, allowed, "response not allowed for undefined decision")
+	assert.Equal(t, input["input"]["request"].(map[string]interface{})["namespace"], "team-a")
+	assert.Assert(t, len(input["input"]["patch"].([]interface{})) > 0, "patch not sent to policy engine")
+
+	// deny with and without a reason
+	decision = `{"result": {"allowed": false, "reason": "team a must use queue root.team-a"}}`
+	allowed, message, _ := mutate()
+	assert.Check(t, !allowed, "response allowed for denied pod")
+	assert.Equal(t, message, "denied by admission policy: team a must use queue root.team-a")
+	decision = `{"result": {"allowed": false}}`
+	_, message, _ = mutate()
+	assert.Equal(t, message, "denied by admission policy")
+
+	// patch augmentation
+	decision = `{"result": {"allowed": true, "patch": [{"op": "add", "path": "/metadata/labels/queue", "value": "root.team-a"}]}}`
+	allowed, _, patch := mutate()
+	assert.Check(t, allowed, "response not allowed for augmented patch")
+	assert.Equal(t, labels(t, patch)[constants.LabelQueueName], "root.team-a")
+	decision = `{"result": {"patch": [{"op": "move", "path": "/metadata/labels/queue"}]}}`
+	allowed, message, _ = mutate()
+	assert.Check(t, !allowed, "response allowed for invalid patch")
+	assert.Equal(t, message, "invalid patch returned by admission policy: unsupported operation 'move'")
+
+	// labels set by a policy are validated like all other labels
+	overrides[conf.AMValidationAppQueueRules] = "^yunikorn-.*-autogen$=root.team-a"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	decision = `{"result": {"patch": [{"op": "add", "path": "/metadata/labels/queue", "value": "root.team-a"}]}}`
+	allowed, _, _ = mutate()
+	assert.Check(t, allowed, "response not allowed for policy queue matching the rules")
+	decision = `{"result": {"patch": [{"op": "add", "path": "/metadata/labels/queue", "value": "root.other"}]}}`
+	allowed, message, _ = mutate()
+	assert.Check(t, !allowed, "response allowed for policy queue violating the rules")
+	assert.Equal(t, message, "application yunikorn-team-a-autogen must be submitted to a queue under root.team-a, requested queue 'root.other'")
+	delete(overrides, conf.AMValidationAppQueueRules)
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+
+	// labels cannot be replaced or removed behind the label checks
+	decision = `{"result": {"patch": [{"op": "replace", "path": "/metadata/labels", "value": {"queue": "root.other"}}]}}`
+	_, message, _ = mutate()
+	assert.Equal(t, message, "invalid patch returned by admission policy: path '/metadata/labels' cannot be patched, set individual labels instead")
+	decision = `{"result": {"patch": [{"op": "remove", "path": "/metadata"}]}}`
+	_, message, _ = mutate()
+	assert.Equal(t, message, "invalid patch returned by admission policy: path '/metadata' cannot be patched, set individual labels instead")
+	decision = `{"result": {"patch": [{"op": "remove", "path": "/metadata/labels/applicationId"}]}}`
+	_, message, _ = mutate()
+	assert.Equal(t, message, "invalid patch returned by admission policy: unsupported operation 'remove' for label path '/metadata/labels/applicationId'")
+	decision = `{"result": {"patch": [{"op": "add", "path": "/metadata/labels/queue", "value": 1}]}}`
+	_, message, _ = mutate()
+	assert.Equal(t, message, "invalid patch returned by admission policy: label path '/metadata/labels/queue' must have a string value")
+
+	// policy engine failure
+	decision = "error"
+	allowed, message, _ = mutate()
+	assert.Check(t, !allowed, "response allowed with failing policy engine")
+	assert.Equal(t, message, "unable to evaluate admission policies: policy engine responded with unexpected status 500")
+	overrides[conf.AMValidationPolicyFailurePolicy] = conf.FailurePolicyIgnore
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	allowed, _, _ = mutate()
+	assert.Check(t, allowed, "response not allowed with ignored policy engine failure")
+}
@@ -0,0 +1,259 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	rootQueueName = "root"
+	// maxConfigChanges limits the number of changes listed in a rejection message.
+	maxConfigChanges = 10
+)
+
+// The types below are the subset of the scheduler configuration that the admission controller inspects locally.
+// Full validation of the configuration is left to the scheduler.
+
+type schedulerConfig struct {
+	Partitions []partitionConfig `yaml:"partitions"`
+}
+
+type partitionConfig struct {
+	Name           string        `yaml:"name"`
+	Queues         []queueConfig `yaml:"queues"`
+	PlacementRules []interface{} `yaml:"placementrules,omitempty"`
+}
+
+type queueConfig struct {
+	Name       string            `yaml:"name"`
+	Properties map[string]string `yaml:"properties,omitempty"`
+	Resources  queueResources    `yaml:"resources,omitempty"`
+	Queues     []queueConfig     `yaml:"queues,omitempty"`
+}
+
+type queueResources struct {
+	Guaranteed map[string]string `yaml:"guaranteed,omitempty"`
+	Max        map[string]string `yaml:"max,omitempty"`
+}
+
+func parseSchedulerConfig(content string) (*schedulerConfig, error) {
+	config := &schedulerConfig{}
+	if err := yaml.Unmarshal([]byte(content), config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// walkQueues calls the visitor for each queue in the partition with the fully qualified queue path and the depth of
+// the queue in the hierarchy, starting at 1 for the top level queues. Walking stops at the first error returned.
+func (p *partitionConfig) walkQueues(visitor func(path string, depth int, queue *queueConfig) error) error {
+	return walkQueues("", 1, p.Queues, visitor)
+}
+
+func walkQueues(parent string, depth int, queues []queueConfig, visitor func(path string, depth int, queue *queueConfig) error) error {
+	for i := range queues {
+		queue := &queues[i]
+		path := queue.Name
+		if parent != "" {
+			path = parent + "." + queue.Name
+		}
+		if err := visitor(path, depth, queue); err != nil {
+			return err
+		}
+		if err := walkQueues(path, depth+1, queue.Queues, visitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQueueLimits verifies the depth of the queue hierarchy and the total number of queues against the given maximums.
+// A maximum of zero or less disables the check.
+func checkQueueLimits(config *schedulerConfig, maxDepth int, maxCount int) error {
+	count := 0
+	for i := range config.Partitions {
+		partition := &config.Partitions[i]
+		err := partition.walkQueues(func(path string, depth int, _ *queueConfig) error {
+			if maxDepth > 0 && depth > maxDepth {
+				return fmt.Errorf("queue %s in partition %s exceeds the maximum queue depth of %d", path, partition.Name, maxDepth)
+			}
+			count++
+			if maxCount > 0 && count > maxCount {
+				return fmt.Errorf("queue %s in partition %s exceeds the maximum number of queues of %d", path, partition.Name, maxCount)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRootQueue verifies that each partition has a single top level queue named root. The name is compared
+// case-insensitively, in line with the scheduler.
+func checkRootQueue(config *schedulerConfig) error {
+	for i := range config.Partitions {
+		partition := &config.Partitions[i]
+		if len(partition.Queues) == 0 {
+			return fmt.Errorf("partition %s does not define the root queue", partition.Name)
+		}
+		for _, queue := range partition.Queues {
+			if !strings.EqualFold(queue.Name, rootQueueName) {
+				return fmt.Errorf("queue %s in partition %s is defined at the top level, the only top level queue must be root", queue.Name, partition.Name)
+			}
+		}
+		if len(partition.Queues) > 1 {
+			return fmt.Errorf("partition %s defines the root queue more than once", partition.Name)
+		}
+	}
+	return nil
+}
+
+// checkReservedProperties verifies that no queue sets one of the reserved property keys. Keys are compared
+// case-sensitively, in line with the scheduler.
+func checkReservedProperties(config *schedulerConfig, reserved []string) error {
+	if len(reserved) == 0 {
+		return nil
+	}
+	for i := range config.Partitions {
+		partition := &config.Partitions[i]
+		err := partition.walkQueues(func(path string, _ int, queue *queueConfig) error {
+			for _, key := range reserved {
+				if _, ok := queue.Properties[key]; ok {
+					return fmt.Errorf("queue %s in partition %s sets the reserved property %s", path, partition.Name, key)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueRef identifies a queue by its partition and fully qualified path.
+type queueRef struct {
+	partition string
+	path      string
+}
+
+// removedQueues returns the queues that exist in the current configuration but not in the proposed configuration, in
+// the order they appear in the current configuration. Queue and partition names are compared case-insensitively, in
+// line with the scheduler.
+func removedQueues(current *schedulerConfig, proposed *schedulerConfig) []queueRef {
+	remaining := make(map[queueRef]bool)
+	for i := range proposed.Partitions {
+		partition := &proposed.Partitions[i]
+		_ = partition.walkQueues(func(path string, _ int, _ *queueConfig) error {
+			remaining[queueRef{partition: strings.ToLower(partition.Name), path: strings.ToLower(path)}] = true
+			return nil
+		})
+	}
+	removed := make([]queueRef, 0)
+	for i := range current.Partitions {
+		partition := &current.Partitions[i]
+		_ = partition.walkQueues(func(path string, _ int, _ *queueConfig) error {
+			if !remaining[queueRef{partition: strings.ToLower(partition.Name), path: strings.ToLower(path)}] {
+				removed = append(removed, queueRef{partition: partition.Name, path: path})
+			}
+			return nil
+		})
+	}
+	return removed
+}
+
+// configChanges describes the differences between the current and the proposed configuration: added and removed
+// partitions, changed placement rules, and added, removed and changed queues. Partition and queue names are compared
+// case-insensitively, in line with the scheduler.
+func configChanges(current *schedulerConfig, proposed *schedulerConfig) []string {
+	changes := make([]string, 0)
+	currentPartitions := make(map[string]*partitionConfig)
+	for i := range current.Partitions {
+		currentPartitions[strings.ToLower(current.Partitions[i].Name)] = &current.Partitions[i]
+	}
+	proposedPartitions := make(map[string]bool)
+	for i := range proposed.Partitions {
+		partition := &proposed.Partitions[i]
+		proposedPartitions[strings.ToLower(partition.Name)] = true
+		existing, ok := currentPartitions[strings.ToLower(partition.Name)]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("partition %s added", partition.Name))
+			continue
+		}
+		if !reflect.DeepEqual(existing.PlacementRules, partition.PlacementRules) {
+			changes = append(changes, fmt.Sprintf("placement rules of partition %s changed", partition.Name))
+		}
+		changes = append(changes, queueChanges(existing, partition)...)
+	}
+	for i := range current.Partitions {
+		if !proposedPartitions[strings.ToLower(current.Partitions[i].Name)] {
+			changes = append(changes, fmt.Sprintf("partition %s removed", current.Partitions[i].Name))
+		}
+	}
+	return changes
+}
+
+// queueChanges describes the queues added, removed or changed between two versions of a partition. A queue is changed
+// if its own properties or resources differ, changes to child queues are reported for the children.
+func queueChanges(current *partitionConfig, proposed *partitionConfig) []string {
+	existing := make(map[string]*queueConfig)
+	_ = current.walkQueues(func(path string, _ int, queue *queueConfig) error {
+		existing[strings.ToLower(path)] = queue
+		return nil
+	})
+	changes := make([]string, 0)
+	_ = proposed.walkQueues(func(path string, _ int, queue *queueConfig) error {
+		old, ok := existing[strings.ToLower(path)]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("queue %s added to partition %s", path, proposed.Name))
+			return nil
+		}
+		var fields []string
+		if !reflect.DeepEqual(old.Properties, queue.Properties) {
+			fields = append(fields, "properties")
+		}
+		if !reflect.DeepEqual(old.Resources, queue.Resources) {
+			fields = append(fields, "resources")
+		}
+		if len(fields) != 0 {
+			changes = append(changes, fmt.Sprintf("queue %s in partition %s changed %s", path, proposed.Name, strings.Join(fields, " and ")))
+		}
+		return nil
+	})
+	for _, queue := range removedQueues(&schedulerConfig{Partitions: []partitionConfig{*current}},
+		&schedulerConfig{Partitions: []partitionConfig{*proposed}}) {
+		changes = append(changes, fmt.Sprintf("queue %s removed from partition %s", queue.path, current.Name))
+	}
+	return changes
+}
+
+// summarizeChanges joins the changes for a message, listing at most maxConfigChanges of them.
+func summarizeChanges(changes []string) string {
+	if len(changes) <= maxConfigChanges {
+		return strings.Join(changes, "; ")
+	}
+	return fmt.Sprintf("%s; and %d more", strings.Join(changes[:maxConfigChanges], "; "), len(changes)-maxConfigChanges)
+}
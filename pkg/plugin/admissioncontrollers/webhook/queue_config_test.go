@@ -0,0 +1,368 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+const NestedConfigData = `
+partitions:
+  - name: default
+    queues:
+      - name: root
+        queues:
+          - name: a
+            queues:
+              - name: b
+                queues:
+                  - name: c
+          - name: d
+`
+
+func TestParseSchedulerConfig(t *testing.T) {
+	config, err := parseSchedulerConfig(NestedConfigData)
+	assert.NilError(t, err)
+	assert.Equal(t, len(config.Partitions), 1)
+	paths := make([]string, 0)
+	depths := make([]int, 0)
+	err = config.Partitions[0].walkQueues(func(path string, depth int, _ *queueConfig) error {
+		paths = append(paths, path)
+		depths = append(depths, depth)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, paths, []string{"root", "root.a", "root.a.b", "root.a.b.c", "root.d"})
+	assert.DeepEqual(t, depths, []int{1, 2, 3, 4, 2})
+
+	_, err = parseSchedulerConfig("partitions: [")
+	assert.Assert(t, err != nil, "invalid yaml parsed")
+}
+
+func TestCheckQueueLimits(t *testing.T) {
+	config, err := parseSchedulerConfig(NestedConfigData)
+	assert.NilError(t, err)
+
+	// within limits or unlimited
+	assert.NilError(t, checkQueueLimits(config, 0, 0))
+	assert.NilError(t, checkQueueLimits(config, 4, 5))
+
+	// exceeding limits
+	assert.ErrorContains(t, checkQueueLimits(config, 3, 0), "queue root.a.b.c in partition default exceeds the maximum queue depth of 3")
+	assert.ErrorContains(t, checkQueueLimits(config, 0, 4), "queue root.d in partition default exceeds the maximum number of queues of 4")
+}
+
+func TestValidateConfigMapQueueLimits(t *testing.T) {
+	srv := serverMock(Success)
+	defer srv.Close()
+	configmap := prepareConfigMap(NestedConfigData)
+
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationMaxQueueDepth:        "4",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", configmap))
+
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationMaxQueueDepth:        "2",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.ErrorContains(t, ac.validateConfigMap("default", configmap), "exceeds the maximum queue depth of 2")
+}
+
+func TestRemovedQueues(t *testing.T) {
+	current, err := parseSchedulerConfig(NestedConfigData)
+	assert.NilError(t, err)
+
+	// identical and case-insensitive configurations remove nothing
+	assert.Equal(t, len(removedQueues(current, current)), 0)
+	proposed, err := parseSchedulerConfig(strings.Replace(NestedConfigData, "name: a", "name: A", 1))
+	assert.NilError(t, err)
+	assert.Equal(t, len(removedQueues(current, proposed)), 0)
+
+	// removing a parent removes all its children
+	proposed, err = parseSchedulerConfig(`
+partitions:
+  - name: default
+    queues:
+      - name: root
+        queues:
+          - name: d
+          - name: e
+`)
+	assert.NilError(t, err)
+	removed := removedQueues(current, proposed)
+	paths := make([]string, 0)
+	for _, queue := range removed {
+		assert.Equal(t, queue.partition, "default")
+		paths = append(paths, queue.path)
+	}
+	assert.DeepEqual(t, paths, []string{"root.a", "root.a.b", "root.a.b.c"})
+}
+
+func TestValidateConfigMapActiveQueueRemoval(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/validate-conf", successResponseMock)
+	handler.HandleFunc("/ws/v1/partition/default/queue/root.a.b.c/applications", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"applicationID": "app-1", "applicationState": "Running"}]`)) //nolint:errcheck
+	})
+	handler.HandleFunc("/ws/v1/partition/default/queue/root.d/applications", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"applicationID": "app-2", "applicationState": "Completed"}]`)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:   srv.Listener.Addr().String(),
+		conf.AMValidationDenyActiveQueueRemoval: "true",
+		"queues.yaml":                           NestedConfigData,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// removed queue with only completed applications
+	configmap := prepareConfigMap(strings.Replace(NestedConfigData, "          - name: d\n", "", 1))
+	assert.NilError(t, ac.validateConfigMap("default", configmap))
+
+	// removed queue with a running application
+	configmap = prepareConfigMap(strings.Replace(NestedConfigData, "                queues:\n                  - name: c\n", "", 1))
+	assert.ErrorContains(t, ac.validateConfigMap("default", configmap),
+		"queue root.a.b.c in partition default cannot be removed, it has active applications: app-1")
+
+	// removed queue unknown to the scheduler
+	configmap = prepareConfigMap(NestedConfigData + "          - name: e\n")
+	assert.NilError(t, ac.validateConfigMap("default", configmap))
+	ac.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:   srv.Listener.Addr().String(),
+		conf.AMValidationDenyActiveQueueRemoval: "true",
+		"queues.yaml":                           NestedConfigData + "          - name: e\n",
+	})
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(NestedConfigData)))
+
+	// check disabled
+	ac.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		"queues.yaml":                         NestedConfigData,
+	})
+	configmap = prepareConfigMap(strings.Replace(NestedConfigData, "                queues:\n                  - name: c\n", "", 1))
+	assert.NilError(t, ac.validateConfigMap("default", configmap))
+}
+
+func TestConfigChanges(t *testing.T) {
+	current, err := parseSchedulerConfig(NestedConfigData)
+	assert.NilError(t, err)
+	assert.Equal(t, len(configChanges(current, current)), 0)
+
+	proposed, err := parseSchedulerConfig(`
+partitions:
+  - name: default
+    placementrules:
+      - name: tag
+        value: namespace
+    queues:
+      - name: root
+        queues:
+          - name: A
+            properties:
+              application.sort.policy: fifo
+          - name: d
+            resources:
+              max:
+                memory: 1G
+          - name: e
+  - name: gpu
+    queues:
+      - name: root
+`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, configChanges(current, proposed), []string{
+		"placement rules of partition default changed",
+		"queue root.A in partition default changed properties",
+		"queue root.d in partition default changed resources",
+		"queue root.e added to partition default",
+		"queue root.a.b removed from partition default",
+		"queue root.a.b.c removed from partition default",
+		"partition gpu added",
+	})
+	assert.DeepEqual(t, configChanges(proposed, current), []string{
+		"placement rules of partition default changed",
+		"queue root.a in partition default changed properties",
+		"queue root.a.b added to partition default",
+		"queue root.a.b.c added to partition default",
+		"queue root.d in partition default changed resources",
+		"queue root.e removed from partition default",
+		"partition gpu removed",
+	})
+
+	changes := make([]string, maxConfigChanges+2)
+	for i := range changes {
+		changes[i] = "change"
+	}
+	assert.Equal(t, summarizeChanges(changes[:2]), "change; change")
+	assert.Assert(t, strings.HasSuffix(summarizeChanges(changes), "change; and 2 more"))
+}
+
+func TestValidateConfigMapRejectionChanges(t *testing.T) {
+	srv := serverMock(Failure)
+	defer srv.Close()
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		"queues.yaml":                         NestedConfigData,
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// rejected by the scheduler
+	err := ac.validateConfigMap("default", prepareConfigMap(NestedConfigData+"          - name: e\n"))
+	assert.Error(t, err, "policy group queues: configuration rejected by the scheduler: Invalid config (changes: queue root.e added to partition default)")
+	err = ac.validateConfigMap("default", prepareConfigMap(NestedConfigData))
+	assert.Error(t, err, "policy group queues: configuration rejected by the scheduler: Invalid config")
+
+	// rejected locally before the scheduler is called
+	ac.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationMaxQueueDepth:        "2",
+		"queues.yaml":                         NestedConfigData,
+	})
+	err = ac.validateConfigMap("default", prepareConfigMap(NestedConfigData+"          - name: e\n"))
+	assert.Error(t, err, "policy group queues: configuration rejected by the admission controller: queue root.a.b in partition "+
+		"default exceeds the maximum queue depth of 2 (changes: queue root.e added to partition default)")
+}
+
+func TestValidateConfigMapPolicyGroups(t *testing.T) {
+	srv := serverMock(Success)
+	defer srv.Close()
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationMaxQueueDepth:        "3",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	shallow := strings.Replace(NestedConfigData, "                queues:\n                  - name: c\n", "", 1)
+
+	// every policy group is validated, not only the pending one
+	configmap := prepareConfigMap(shallow)
+	configmap.Data["batch.yaml"] = shallow
+	assert.NilError(t, ac.validateConfigMap("default", configmap))
+	configmap.Data["batch.yaml"] = NestedConfigData
+	assert.ErrorContains(t, ac.validateConfigMap("default", configmap),
+		"policy group batch: configuration rejected by the admission controller: queue root.a.b.c in partition default exceeds the maximum queue depth of 3")
+
+	// failures of all policy groups are reported
+	configmap.Data["queues.yaml"] = NestedConfigData
+	err := ac.validateConfigMap("default", configmap)
+	assert.ErrorContains(t, err, "policy group batch: ")
+	assert.ErrorContains(t, err, "; policy group queues: ")
+
+	assert.DeepEqual(t, policyGroups(map[string]string{"batch.yaml": "", "log.level": "INFO"}, "queues"), []string{"batch", "queues"})
+}
+
+func TestCheckRootQueue(t *testing.T) {
+	config, err := parseSchedulerConfig(NestedConfigData)
+	assert.NilError(t, err)
+	assert.NilError(t, checkRootQueue(config))
+	config, err = parseSchedulerConfig(strings.Replace(NestedConfigData, "name: root", "name: ROOT", 1))
+	assert.NilError(t, err)
+	assert.NilError(t, checkRootQueue(config))
+
+	config, err = parseSchedulerConfig(`
+partitions:
+  - name: default
+`)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, checkRootQueue(config), "partition default does not define the root queue")
+
+	config, err = parseSchedulerConfig(`
+partitions:
+  - name: default
+    queues:
+      - name: a
+`)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, checkRootQueue(config), "queue a in partition default is defined at the top level")
+
+	config, err = parseSchedulerConfig(`
+partitions:
+  - name: default
+    queues:
+      - name: root
+      - name: root
+`)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, checkRootQueue(config), "partition default defines the root queue more than once")
+}
+
+const reservedPropertyConfig = `
+partitions:
+  - name: default
+    queues:
+      - name: root
+        queues:
+          - name: a
+            properties:
+              application.sort.policy: fifo
+              internal.owner: team-a
+`
+
+func TestCheckReservedProperties(t *testing.T) {
+	config, err := parseSchedulerConfig(reservedPropertyConfig)
+	assert.NilError(t, err)
+	assert.NilError(t, checkReservedProperties(config, nil))
+	assert.NilError(t, checkReservedProperties(config, []string{"internal.priority", "Internal.Owner"}))
+	assert.ErrorContains(t, checkReservedProperties(config, []string{"internal.priority", "internal.owner"}),
+		"queue root.a in partition default sets the reserved property internal.owner")
+}
+
+func TestValidateConfigMapReservedProperties(t *testing.T) {
+	srv := serverMock(Success)
+	defer srv.Close()
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    srv.Listener.Addr().String(),
+		conf.AMValidationReservedQueueProperties: "internal.owner, internal.priority",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(NestedConfigData)))
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(reservedPropertyConfig)),
+		"sets the reserved property internal.owner")
+}
+
+func TestValidateConfigMapRootQueue(t *testing.T) {
+	srv := serverMock(Success)
+	defer srv.Close()
+	noRoot := prepareConfigMap(`
+partitions:
+  - name: default
+    queues:
+      - name: a
+`)
+
+	// not required
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", noRoot))
+
+	// required
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationRequireRootQueue:     "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(NestedConfigData)))
+	assert.ErrorContains(t, ac.validateConfigMap("default", noRoot), "the only top level queue must be root")
+}
@@ -0,0 +1,78 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-scheduler-interface/lib/go/si"
+)
+
+// mappedQueue returns the queue of the first queue mapping rule that matches the pod. The user and groups are read
+// from the user info annotation of the pod, which the scheduler uses to place the application as well. A matching rule
+// that cannot resolve a valid queue is skipped.
+func (c *admissionController) mappedQueue(namespace string, pod *v1.Pod) (string, bool) {
+	rules := c.conf.GetQueueMappingRules()
+	if len(rules) == 0 {
+		return "", false
+	}
+	user, groups := c.podUserInfo(pod)
+	for i, rule := range rules {
+		if !rule.Matches(user, groups, namespace, pod.Labels) {
+			continue
+		}
+		queue, ok := rule.QueueFor(user, namespace)
+		if !ok {
+			log.Logger().Debug("skipping queue mapping rule without a valid queue for pod",
+				zap.Int("rule", i),
+				zap.String("podName", pod.Name),
+				zap.String("generateName", pod.GenerateName),
+				zap.String("user", user))
+			continue
+		}
+		log.Logger().Debug("using queue from queue mapping rule",
+			zap.Int("rule", i),
+			zap.String("podName", pod.Name),
+			zap.String("generateName", pod.GenerateName),
+			zap.String("queue", queue))
+		return queue, true
+	}
+	return "", false
+}
+
+// podUserInfo returns the user and groups of the user info annotation of the pod. Nothing is returned if the pod has
+// no user info annotation, or if it cannot be decoded.
+func (c *admissionController) podUserInfo(pod *v1.Pod) (string, []string) {
+	for _, key := range c.annotationHandler.UserInfoAnnotationKeys() {
+		value, ok := pod.Annotations[key]
+		if !ok {
+			continue
+		}
+		var userGroups si.UserGroupInformation
+		if err := json.Unmarshal([]byte(value), &userGroups); err != nil {
+			return "", nil
+		}
+		return userGroups.User, userGroups.Groups
+	}
+	return "", nil
+}
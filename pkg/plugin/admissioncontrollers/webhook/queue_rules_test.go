@@ -0,0 +1,112 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestMappedQueue(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMMutationGPUQueue: "root.gpu",
+		conf.AMMutationQueueMappingRules: `[
+			{"users": ["^admin$"], "queue": "root.admin"},
+			{"groups": ["^data-"], "podSelector": "tier=batch", "queue": "root.data.{user}"},
+			{"namespaces": ["^ci-"], "queue": "root.ci.{namespace}"},
+			{"users": [".*"], "queue": "root.users.{user}"}
+		]`,
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	userInfo := func(user string, groups ...string) map[string]string {
+		value, err := json.Marshal(map[string]interface{}{"user": user, "groups": groups})
+		assert.NilError(t, err)
+		return map[string]string{userInfoAnnotation: string(value)}
+	}
+
+	cases := []struct {
+		name      string
+		namespace string
+		pod       *v1.Pod
+		queue     string
+		source    string
+	}{
+		{"user", "test-ns", &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: userInfo("admin", "data-eng")}}, "root.admin", queueSourceRule},
+		{"group and labels", "test-ns", &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: userInfo("john.doe", "data-eng"), Labels: map[string]string{"tier": "batch"}}}, "root.data.john_dot_doe", queueSourceRule},
+		{"group without labels", "test-ns", &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: userInfo("jane", "data-eng")}}, "root.users.jane", queueSourceRule},
+		{"namespace", "ci-build", &v1.Pod{}, "root.ci.ci-build", queueSourceRule},
+		// the user placeholder cannot be resolved without user info
+		{"no user info", "test-ns", &v1.Pod{}, "root.default", queueSourceDefault},
+		// the rules are checked before the GPU queue
+		{"gpu", "test-ns", &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{Resources: v1.ResourceRequirements{
+			Limits: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}}}}}}, "root.gpu", queueSourceGPU},
+		{"gpu with user", "test-ns", &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: userInfo("jane")}, Spec: v1.PodSpec{
+			Containers: []v1.Container{{Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}}}}}}, "root.users.jane", queueSourceRule},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			queue, source := ac.getDefaultQueue(c.namespace, c.pod)
+			assert.Equal(t, queue, c.queue)
+			assert.Equal(t, source, c.source)
+		})
+	}
+}
+
+func TestMutateQueueMappingRules(t *testing.T) {
+	overrides := map[string]string{
+		conf.AMMutationAnnotateDecision:  "true",
+		conf.AMMutationQueueMappingRules: `[{"namespaces": ["^ci-"], "podSelector": "!skip", "queue": "root.ci"}]`,
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	resp := ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ci-build"}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, labels(t, resp.Patch)[constants.LabelQueueName], "root.ci")
+	decision := &admissionDecision{}
+	assert.NilError(t, json.Unmarshal([]byte(annotations(t, resp.Patch)[admissionDecisionAnnotation].(string)), decision))
+	assert.Equal(t, decision.QueueSource, queueSourceRule)
+
+	// the queue of the pod is kept
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ci-build",
+		Labels:    map[string]string{constants.LabelQueueName: "root.team"},
+	}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	_, ok := labels(t, resp.Patch)[constants.LabelQueueName]
+	assert.Check(t, !ok, "queue label of pod replaced")
+
+	resp = ac.mutate(createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "ci-build",
+		Labels:    map[string]string{"skip": "true"},
+	}}))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Equal(t, labels(t, resp.Patch)[constants.LabelQueueName], "root.default")
+}
@@ -0,0 +1,282 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common"
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+// queue state reported by the scheduler for a queue that does not accept new applications
+const queueStateDraining = "Draining"
+
+// maximum time a failure to retrieve the queue state is cached: requests do not all wait for an unresponsive scheduler
+const queueStateFailureTTL = 5 * time.Second
+
+// PartitionQueue is the subset of the queue information returned by the scheduler for a partition that is needed to
+// find the draining queues and the maximum resources of the queues.
+type PartitionQueue struct {
+	QueueName   string           `json:"queuename"`
+	Status      string           `json:"status"`
+	MaxResource map[string]int64 `json:"maxResource"`
+	Children    []PartitionQueue `json:"children"`
+}
+
+// queueState is the state of the queues of the default partition, keyed by the fully qualified, lower case queue
+// path. The maximum resource of a queue is limited by the maximum resources of its parents.
+type queueState struct {
+	draining     map[string]bool
+	maxResources map[string]map[string]int64
+}
+
+// queueStateCache holds the queue state, or the error, as last retrieved from the scheduler.
+type queueStateCache struct {
+	state   *queueState
+	err     error
+	expires time.Time
+
+	sync.Mutex
+}
+
+// queueStateLookup retrieves the queue state at most once for an admission request. All queue checks of the request
+// use the same state.
+type queueStateLookup struct {
+	c     *admissionController
+	state *queueState
+	err   error
+	done  bool
+}
+
+func (c *admissionController) newQueueStateLookup() *queueStateLookup {
+	return &queueStateLookup{c: c}
+}
+
+func (l *queueStateLookup) get() (*queueState, error) {
+	if !l.done {
+		l.state, l.err = l.c.getQueueState()
+		l.done = true
+	}
+	return l.state, l.err
+}
+
+// checkQueueDraining denies pods which are submitted to a queue that the scheduler is draining. If the queue state
+// cannot be retrieved the pod is allowed, the scheduler rejects the application if needed.
+func (c *admissionController) checkQueueDraining(queues *queueStateLookup, labels map[string]string) error {
+	if !c.conf.GetDenyDrainingQueues() {
+		return nil
+	}
+	queue := labels[constants.LabelQueueName]
+	if queue == "" {
+		return nil
+	}
+	state, err := queues.get()
+	if err != nil {
+		log.Logger().Warn("Unable to retrieve queue state from YuniKorn scheduler, skipping draining queue check",
+			zap.String("queue", queue),
+			zap.Error(err))
+		return nil
+	}
+	if state.draining[qualifiedQueuePath(queue)] {
+		return fmt.Errorf("queue %s is draining and does not accept new pods", queue)
+	}
+	return nil
+}
+
+// checkQueueExists handles pods which are submitted to a queue that does not exist in the scheduler. Depending on the
+// configured action the pod is allowed, denied, or moved to the fallback queue. Queues that are created dynamically
+// by the placement rules do not exist until the first application is placed in them, the check should not be used
+// with such queues. If the queue state cannot be retrieved, or the scheduler reports no queues, the pod is allowed.
+func (c *admissionController) checkQueueExists(queues *queueStateLookup, pod *v1.Pod, patch []patchOperation) ([]patchOperation, error) {
+	action := c.conf.GetUnknownQueueAction()
+	if action == conf.UnknownQueueActionAllow {
+		return patch, nil
+	}
+	queue := effectiveLabels(pod, patch)[constants.LabelQueueName]
+	if queue == "" {
+		return patch, nil
+	}
+	state, err := queues.get()
+	if err != nil {
+		log.Logger().Warn("Unable to retrieve queue state from YuniKorn scheduler, skipping queue existence check",
+			zap.String("queue", queue),
+			zap.Error(err))
+		return patch, nil
+	}
+	if len(state.maxResources) == 0 || state.exists(qualifiedQueuePath(queue)) {
+		return patch, nil
+	}
+	if action == conf.UnknownQueueActionDeny {
+		return patch, fmt.Errorf("queue %s does not exist", queue)
+	}
+	fallback := c.conf.GetUnknownQueueFallback()
+	log.Logger().Warn("queue does not exist, using the fallback queue",
+		zap.String("podName", pod.Name),
+		zap.String("generateName", pod.GenerateName),
+		zap.String("queue", queue),
+		zap.String("fallback", fallback))
+	return updateLabel(pod, patch, constants.LabelQueueName, fallback), nil
+}
+
+// checkQueueCapacity denies pods which request more of a resource than the maximum resource of their queue: such a
+// pod can never be scheduled. A queue that does not exist yet is limited by its closest existing parent. If the queue
+// state cannot be retrieved the pod is allowed.
+func (c *admissionController) checkQueueCapacity(queues *queueStateLookup, pod *v1.Pod, labels map[string]string) error {
+	if !c.conf.GetDenyExceedingQueueMax() {
+		return nil
+	}
+	queue := labels[constants.LabelQueueName]
+	if queue == "" {
+		return nil
+	}
+	state, err := queues.get()
+	if err != nil {
+		log.Logger().Warn("Unable to retrieve queue state from YuniKorn scheduler, skipping queue capacity check",
+			zap.String("queue", queue),
+			zap.Error(err))
+		return nil
+	}
+	maxResource := state.maxResource(qualifiedQueuePath(queue))
+	request := common.GetPodResource(pod)
+	if len(maxResource) == 0 || request == nil {
+		return nil
+	}
+	var exceeded []string
+	for name, limit := range maxResource {
+		if quantity, ok := request.Resources[name]; ok && quantity != nil && quantity.Value > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s (requested %d, maximum %d)", name, quantity.Value, limit))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	sort.Strings(exceeded)
+	return fmt.Errorf("pod can never fit in queue %s: %s", queue, strings.Join(exceeded, ", "))
+}
+
+// getQueueState returns the queue state, retrieving it from the scheduler if the cached state has expired. A failure
+// is cached as well, for the cache TTL but at most queueStateFailureTTL.
+func (c *admissionController) getQueueState() (*queueState, error) {
+	now := time.Now()
+	c.queueState.Lock()
+	if (c.queueState.state != nil || c.queueState.err != nil) && now.Before(c.queueState.expires) {
+		state, err := c.queueState.state, c.queueState.err
+		c.queueState.Unlock()
+		return state, err
+	}
+	c.queueState.Unlock()
+
+	ctx := context.Background()
+	if timeout := c.conf.GetQueueStateTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var root PartitionQueue
+	endpoint := c.scheduler.url(schedulerPartitionQueuesURLPattern, url.PathEscape(constants.DefaultPartition))
+	found, err := c.getSchedulerResource(ctx, endpoint, &root)
+	if err != nil {
+		ttl := c.conf.GetDrainingQueueCacheTTL()
+		if ttl > queueStateFailureTTL {
+			ttl = queueStateFailureTTL
+		}
+		c.queueState.Lock()
+		defer c.queueState.Unlock()
+		c.queueState.state = nil
+		c.queueState.err = err
+		c.queueState.expires = now.Add(ttl)
+		return nil, err
+	}
+	state := &queueState{
+		draining:     make(map[string]bool),
+		maxResources: make(map[string]map[string]int64),
+	}
+	if found {
+		collectQueueState(&root, nil, state)
+	}
+
+	c.queueState.Lock()
+	defer c.queueState.Unlock()
+	c.queueState.state = state
+	c.queueState.err = nil
+	c.queueState.expires = now.Add(c.conf.GetDrainingQueueCacheTTL())
+	return state, nil
+}
+
+func collectQueueState(queue *PartitionQueue, parentMax map[string]int64, state *queueState) {
+	path := strings.ToLower(queue.QueueName)
+	if strings.EqualFold(queue.Status, queueStateDraining) {
+		state.draining[path] = true
+	}
+	maxResource := make(map[string]int64, len(parentMax))
+	for name, limit := range parentMax {
+		maxResource[name] = limit
+	}
+	for name, limit := range queue.MaxResource {
+		if parentLimit, ok := maxResource[name]; !ok || limit < parentLimit {
+			maxResource[name] = limit
+		}
+	}
+	state.maxResources[path] = maxResource
+	for i := range queue.Children {
+		collectQueueState(&queue.Children[i], maxResource, state)
+	}
+}
+
+// exists returns true if the queue is known to the scheduler.
+func (s *queueState) exists(path string) bool {
+	_, ok := s.maxResources[path]
+	return ok
+}
+
+// maxResource returns the maximum resource of the queue, or of its closest parent if the queue does not exist.
+func (s *queueState) maxResource(path string) map[string]int64 {
+	for {
+		if maxResource, ok := s.maxResources[path]; ok {
+			return maxResource
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return nil
+		}
+		path = path[:i]
+	}
+}
+
+// qualifiedQueuePath returns the lower case queue path starting at the root queue. Pods may name a queue without the
+// root prefix, the scheduler places such queues under the root queue.
+func qualifiedQueuePath(queue string) string {
+	queue = strings.ToLower(queue)
+	if queue == rootQueueName || strings.HasPrefix(queue, rootQueueName+".") {
+		return queue
+	}
+	return rootQueueName + "." + queue
+}
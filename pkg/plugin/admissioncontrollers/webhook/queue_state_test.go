@@ -0,0 +1,365 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+const partitionQueues = `{
+	"queuename": "root",
+	"status": "Active",
+	"maxResource": {"memory": 64000000000, "vcore": 32000},
+	"children": [
+		{"queuename": "root.default", "status": "Active"},
+		{"queuename": "root.retired", "status": "Draining", "children": [
+			{"queuename": "root.retired.child", "status": "Active"}
+		]},
+		{"queuename": "root.small", "status": "Active", "maxResource": {"memory": 2000000000, "vcore": 64000}, "children": [
+			{"queuename": "root.small.child", "status": "Active", "maxResource": {"vcore": 1000}}
+		]}
+	]
+}`
+
+func TestCheckQueueDraining(t *testing.T) {
+	var requests int32
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(partitionQueues)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(queue string) (bool, string) {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Labels:    map[string]string{constants.LabelQueueName: queue},
+		}}
+		resp := ac.mutate(createPodRequest(t, pod))
+		if resp.Result != nil {
+			return resp.Allowed, resp.Result.Message
+		}
+		return resp.Allowed, ""
+	}
+
+	// not enabled
+	allowed, _ := mutate("root.retired")
+	assert.Check(t, allowed, "response not allowed with check disabled")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(0))
+
+	// draining queue, with and without the root prefix
+	overrides[conf.AMValidationDenyDrainingQueues] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	allowed, message := mutate("root.retired")
+	assert.Check(t, !allowed, "response allowed for draining queue")
+	assert.Equal(t, message, "queue root.retired is draining and does not accept new pods")
+	allowed, _ = mutate("Retired")
+	assert.Check(t, !allowed, "response allowed for draining queue without root prefix")
+
+	// active queues, including the child of a draining queue
+	allowed, _ = mutate("root.default")
+	assert.Check(t, allowed, "response not allowed for active queue")
+	allowed, _ = mutate("root.retired.child")
+	assert.Check(t, allowed, "response not allowed for active child queue")
+	allowed, _ = mutate("root.unknown")
+	assert.Check(t, allowed, "response not allowed for unknown queue")
+
+	// the queue state is cached
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+	overrides[conf.AMValidationDrainingQueueCacheTTL] = "0s"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	ac.queueState = &queueStateCache{}
+	mutate("root.default")
+	mutate("root.default")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(3))
+
+	// scheduler unreachable
+	srv.Close()
+	allowed, _ = mutate("root.retired")
+	assert.Check(t, allowed, "response not allowed with unreachable scheduler")
+}
+
+func TestQueueStateOncePerRequest(t *testing.T) {
+	var requests int32
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(partitionQueues)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:  srv.Listener.Addr().String(),
+		conf.AMValidationDenyDrainingQueues:    "true",
+		conf.AMValidationDenyExceedingQueueMax: "true",
+		conf.AMValidationUnknownQueueAction:    conf.UnknownQueueActionDeny,
+		conf.AMValidationDrainingQueueCacheTTL: "0s",
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{constants.LabelQueueName: "root.default"},
+	}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for active queue")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+}
+
+func TestQueueStateFailure(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer close(release)
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+		conf.AMValidationDenyDrainingQueues:   "true",
+		conf.AMValidationQueueStateTimeout:    "50ms",
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Labels:    map[string]string{constants.LabelQueueName: "root.retired"},
+	}}
+
+	// a hung scheduler is bounded by the queue state timeout
+	start := time.Now()
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed with hung scheduler")
+	assert.Check(t, time.Since(start) < 5*time.Second, "queue state timeout not applied")
+
+	// the failure is cached
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed with cached failure")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+
+	// the failure expires
+	ac.queueState.Lock()
+	ac.queueState.expires = time.Now()
+	ac.queueState.Unlock()
+	ac.mutate(createPodRequest(t, pod))
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(2))
+}
+
+func TestQualifiedQueuePath(t *testing.T) {
+	assert.Equal(t, qualifiedQueuePath("root"), "root")
+	assert.Equal(t, qualifiedQueuePath("ROOT.A"), "root.a")
+	assert.Equal(t, qualifiedQueuePath("a.b"), "root.a.b")
+	assert.Equal(t, qualifiedQueuePath("rootless"), "root.rootless")
+}
+
+func TestCheckQueueCapacity(t *testing.T) {
+	var requests int32
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(partitionQueues)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(queue string, cpu string, memory string) (bool, string) {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test-ns",
+				Labels:    map[string]string{constants.LabelQueueName: queue},
+			},
+			Spec: v1.PodSpec{Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(cpu),
+					v1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}}},
+		}
+		resp := ac.mutate(createPodRequest(t, pod))
+		if resp.Result != nil {
+			return resp.Allowed, resp.Result.Message
+		}
+		return resp.Allowed, ""
+	}
+
+	// not enabled
+	allowed, _ := mutate("root.small", "100", "1G")
+	assert.Check(t, allowed, "response not allowed with check disabled")
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(0))
+
+	overrides[conf.AMValidationDenyExceedingQueueMax] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	allowed, _ = mutate("root.small", "1", "1G")
+	assert.Check(t, allowed, "response not allowed for pod that fits")
+	allowed, message := mutate("root.small", "2", "3G")
+	assert.Check(t, !allowed, "response allowed for pod that never fits")
+	assert.Equal(t, message, "pod can never fit in queue root.small: memory (requested 3000000000, maximum 2000000000)")
+
+	// the parent limits the child, the root limits the parent
+	allowed, message = mutate("small.child", "2", "3G")
+	assert.Check(t, !allowed, "response allowed for pod that never fits in child")
+	assert.Equal(t, message, "pod can never fit in queue small.child: memory (requested 3000000000, maximum 2000000000), vcore (requested 2000, maximum 1000)")
+	allowed, message = mutate("root.small", "40", "1G")
+	assert.Check(t, !allowed, "response allowed for pod that never fits in parent")
+	assert.Equal(t, message, "pod can never fit in queue root.small: vcore (requested 40000, maximum 32000)")
+
+	// unknown queues are limited by their closest parent
+	allowed, _ = mutate("root.small.dynamic", "1", "3G")
+	assert.Check(t, !allowed, "response allowed for pod that never fits in dynamic queue")
+	allowed, _ = mutate("root.default", "1", "3G")
+	assert.Check(t, allowed, "response not allowed for pod that fits")
+
+	// scheduler unreachable
+	assert.Equal(t, atomic.LoadInt32(&requests), int32(1))
+	srv.Close()
+	ac.queueState = &queueStateCache{}
+	allowed, _ = mutate("root.small", "2", "3G")
+	assert.Check(t, allowed, "response not allowed with unreachable scheduler")
+}
+
+func TestValidatePodQueueCapacity(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(partitionQueues)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:  srv.Listener.Addr().String(),
+		conf.AMValidationDenyExceedingQueueMax: "true",
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Labels:    map[string]string{constants.LabelQueueName: "root.small"},
+		},
+		Spec: v1.PodSpec{
+			SchedulerName: ac.conf.GetSchedulerName(),
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1G")}},
+			}},
+		},
+	}
+	resp := ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod that fits")
+
+	// a container added after the pod was mutated
+	pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{
+		Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("2G")}},
+	})
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "response allowed for pod that never fits")
+	assert.Equal(t, resp.Result.Message, "pod can never fit in queue root.small: memory (requested 3000000000, maximum 2000000000)")
+
+	// pods of other schedulers are not checked
+	pod.Spec.SchedulerName = v1.DefaultSchedulerName
+	resp = ac.validatePod(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed for pod of other scheduler")
+}
+
+func TestCheckQueueExists(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/partition/default/queues", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(partitionQueues)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: srv.Listener.Addr().String(),
+	}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	mutate := func(queue string) *admissionv1.AdmissionResponse {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Labels:    map[string]string{constants.LabelQueueName: queue},
+		}}
+		return ac.mutate(createPodRequest(t, pod))
+	}
+
+	// allowed by default
+	resp := mutate("root.typo")
+	assert.Check(t, resp.Allowed, "response not allowed with check disabled")
+
+	// deny unknown queues, with and without the root prefix
+	overrides[conf.AMValidationUnknownQueueAction] = conf.UnknownQueueActionDeny
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = mutate("root.typo")
+	assert.Check(t, !resp.Allowed, "response allowed for unknown queue")
+	assert.Equal(t, resp.Result.Message, "queue root.typo does not exist")
+	resp = mutate("Small.Child")
+	assert.Check(t, resp.Allowed, "response not allowed for existing queue")
+
+	// move unknown queues to the fallback queue
+	overrides[conf.AMValidationUnknownQueueAction] = conf.UnknownQueueActionFallback
+	overrides[conf.AMValidationUnknownQueueFallback] = "root.small"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = mutate("root.typo")
+	assert.Check(t, resp.Allowed, "response not allowed for fallback")
+	var patch []patchOperation
+	assert.NilError(t, json.Unmarshal(resp.Patch, &patch))
+	assert.Equal(t, effectiveLabels(&v1.Pod{}, patch)[constants.LabelQueueName], "root.small")
+	resp = mutate("root.default")
+	patch = nil
+	assert.NilError(t, json.Unmarshal(resp.Patch, &patch))
+	_, ok := effectiveLabels(&v1.Pod{}, patch)[constants.LabelQueueName]
+	assert.Check(t, !ok, "queue label changed for existing queue")
+
+	// scheduler unreachable
+	srv.Close()
+	ac.queueState = &queueStateCache{}
+	overrides[conf.AMValidationUnknownQueueAction] = conf.UnknownQueueActionDeny
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	resp = mutate("root.typo")
+	assert.Check(t, resp.Allowed, "response not allowed with unreachable scheduler")
+}
@@ -0,0 +1,255 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+// schedulerSignatureHeader carries the base64 encoded signature of the response body.
+const schedulerSignatureHeader = "X-YuniKorn-Signature"
+
+var (
+	errResponseTooLarge = errors.New("response exceeds the maximum size")
+	errMissingSignature = errors.New("response is not signed")
+	errInvalidSignature = errors.New("response signature is invalid")
+)
+
+// schedulerTLSFiles is the scheme and TLS material used to connect to the scheduler REST API.
+type schedulerTLSFiles struct {
+	scheme   string
+	certFile string
+	keyFile  string
+	caFile   string
+}
+
+// schedulerClient provides the HTTP client for calls to the scheduler REST API. The client is rebuilt when the
+// configured scheme or TLS material changes, so that a configuration reload takes effect without a restart.
+type schedulerClient struct {
+	conf      *conf.AdmissionControllerConf
+	files     schedulerTLSFiles
+	client    *http.Client
+	keyFile   string
+	publicKey crypto.PublicKey
+
+	sync.Mutex
+}
+
+func newSchedulerClient(conf *conf.AdmissionControllerConf) *schedulerClient {
+	return &schedulerClient{
+		conf: conf,
+	}
+}
+
+// url returns the URL of the scheduler REST API for the given pattern, which must start with the scheme and
+// address placeholders followed by the remaining arguments.
+func (sc *schedulerClient) url(pattern string, args ...interface{}) string {
+	return fmt.Sprintf(pattern, append([]interface{}{sc.conf.GetSchedulerServiceScheme(), sc.conf.GetSchedulerServiceAddress()}, args...)...)
+}
+
+// validateConfURL returns the URL of the configuration validation endpoint, using the configured path.
+func (sc *schedulerClient) validateConfURL() string {
+	return sc.url(schedulerValidateConfURLPattern, sc.conf.GetSchedulerValidateConfPath())
+}
+
+// httpClient returns the client for the current configuration. Plaintext HTTP uses a client without TLS settings.
+func (sc *schedulerClient) httpClient() (*http.Client, error) {
+	files := schedulerTLSFiles{
+		scheme:   sc.conf.GetSchedulerServiceScheme(),
+		certFile: sc.conf.GetSchedulerClientCertFile(),
+		keyFile:  sc.conf.GetSchedulerClientKeyFile(),
+		caFile:   sc.conf.GetSchedulerCAFile(),
+	}
+	sc.Lock()
+	defer sc.Unlock()
+	if sc.client != nil && sc.files == files {
+		return sc.client, nil
+	}
+	client := &http.Client{}
+	if files.scheme == conf.SchemeHTTPS {
+		tlsConfig, err := newSchedulerTLSConfig(files)
+		if err != nil {
+			log.Logger().Error("Unable to load TLS configuration for the scheduler", zap.Error(err))
+			return nil, err
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	sc.files = files
+	sc.client = client
+	return client, nil
+}
+
+func newSchedulerTLSConfig(files schedulerTLSFiles) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if files.certFile != "" || files.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.certFile, files.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if files.caFile != "" {
+		caPem, err := os.ReadFile(files.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", files.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// decodeResponse decodes the JSON response of the scheduler while it is read, without buffering the whole body.
+// Responses larger than the configured maximum are rejected, a maximum of zero or less disables the limit.
+func (sc *schedulerClient) decodeResponse(body io.Reader, v interface{}) error {
+	return json.NewDecoder(sc.limitReader(body)).Decode(v)
+}
+
+// decodeSignedResponse decodes the JSON response of the scheduler after verifying its signature with the configured
+// public key. The body must be read completely before it can be verified. Without a public key the signature is not
+// checked and the response is decoded as it is read.
+func (sc *schedulerClient) decodeSignedResponse(response *http.Response, v interface{}) error {
+	publicKey, err := sc.responsePublicKey()
+	if err != nil {
+		return err
+	}
+	if publicKey == nil {
+		return sc.decodeResponse(response.Body, v)
+	}
+	body, err := io.ReadAll(sc.limitReader(response.Body))
+	if err != nil {
+		return err
+	}
+	header := response.Header.Get(schedulerSignatureHeader)
+	if header == "" {
+		return errMissingSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	if err = verifySignature(publicKey, body, signature); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (sc *schedulerClient) limitReader(body io.Reader) io.Reader {
+	if maxSize := sc.conf.GetSchedulerMaxResponseSize(); maxSize > 0 {
+		return &limitedReader{reader: body, remaining: int64(maxSize), tooLarge: errResponseTooLarge}
+	}
+	return body
+}
+
+// responsePublicKey returns the key for the configured public key file, or nil if none is configured. The key is
+// reloaded when the configured file changes.
+func (sc *schedulerClient) responsePublicKey() (crypto.PublicKey, error) {
+	keyFile := sc.conf.GetSchedulerResponsePublicKeyFile()
+	if keyFile == "" {
+		return nil, nil
+	}
+	sc.Lock()
+	defer sc.Unlock()
+	if sc.publicKey != nil && sc.keyFile == keyFile {
+		return sc.publicKey, nil
+	}
+	keyPem, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response public key: %v", err)
+	}
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in response public key %s", keyFile)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse response public key: %v", err)
+	}
+	sc.keyFile = keyFile
+	sc.publicKey = publicKey
+	return publicKey, nil
+}
+
+// verifySignature checks the signature of the data. RSA keys use PKCS #1 v1.5 and ECDSA keys an ASN.1 encoded
+// signature, both over the SHA-256 digest of the data. Ed25519 keys sign the data itself.
+func verifySignature(publicKey crypto.PublicKey, data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return errInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unsupported response public key type %T", publicKey)
+	}
+	return nil
+}
+
+// limitedReader returns the too large error once more than the remaining number of bytes is read, unlike
+// io.LimitReader which silently truncates the data.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	tooLarge  error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, l.tooLarge
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,321 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/apache/yunikorn-k8shim/pkg/pki"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestSchedulerClientURL(t *testing.T) {
+	sc := newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: "scheduler:9080",
+	}))
+	assert.Equal(t, sc.validateConfURL(), "http://scheduler:9080/ws/v1/validate-conf")
+	assert.Equal(t, sc.url(schedulerQueueAppsURLPattern, "default", "root.a"),
+		"http://scheduler:9080/ws/v1/partition/default/queue/root.a/applications")
+
+	// TLS material switches to https unless the scheme is set explicitly
+	sc = newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: "scheduler:9443",
+		conf.AMWebHookSchedulerCAFile:         "/etc/ca.pem",
+	}))
+	assert.Equal(t, sc.validateConfURL(), "https://scheduler:9443/ws/v1/validate-conf")
+	sc = newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress: "scheduler:9443",
+		conf.AMWebHookSchedulerServiceScheme:  "https",
+	}))
+	assert.Equal(t, sc.validateConfURL(), "https://scheduler:9443/ws/v1/validate-conf")
+
+	// custom validation path keeps the scheme and address
+	sc = newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:   "scheduler:9080",
+		conf.AMWebHookSchedulerValidateConfPath: "/ws/v2/config/validate",
+	}))
+	assert.Equal(t, sc.validateConfURL(), "http://scheduler:9080/ws/v2/config/validate")
+}
+
+func TestValidateConfigMapCustomPath(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v2/config/validate", successResponseMock)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    srv.Listener.Addr().String(),
+		conf.AMWebHookSchedulerValidateConfPath:  "/ws/v2/config/validate",
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)))
+}
+
+func TestSchedulerClientMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey, err := pki.GenerateCACertificate(time.Now().AddDate(0, 0, 1))
+	assert.NilError(t, err)
+	caFile := writePem(t, dir, "ca.pem", caCert, nil)
+	serverCert, serverKey, err := pki.GenerateServerCertificate("localhost", []string{"localhost"}, caCert, caKey)
+	assert.NilError(t, err)
+	serverPair := loadPair(t, writePem(t, dir, "server.pem", serverCert, nil), writePem(t, dir, "server-key.pem", nil, serverKey))
+	clientCert, clientKey, err := pki.GenerateServerCertificate("admission-controller", nil, caCert, caKey)
+	assert.NilError(t, err)
+	certFile := writePem(t, dir, "client.pem", clientCert, nil)
+	keyFile := writePem(t, dir, "client-key.pem", nil, clientKey)
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/validate-conf", successResponseMock)
+	srv := httptest.NewUnstartedServer(handler)
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	srv.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	assert.NilError(t, err)
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    "localhost:" + port,
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+		conf.AMWebHookSchedulerCAFile:            caFile,
+		conf.AMWebHookSchedulerClientCertFile:    certFile,
+		conf.AMWebHookSchedulerClientKeyFile:     keyFile,
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)))
+
+	// the scheduler rejects connections without a client certificate
+	delete(overrides, conf.AMWebHookSchedulerClientCertFile)
+	delete(overrides, conf.AMWebHookSchedulerClientKeyFile)
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "scheduler was unreachable")
+
+	// unusable TLS material
+	overrides[conf.AMWebHookSchedulerCAFile] = filepath.Join(dir, "missing.pem")
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "unable to read CA bundle")
+}
+
+func writePem(t *testing.T, dir string, name string, cert *x509.Certificate, key *rsa.PrivateKey) string {
+	var data *[]byte
+	var err error
+	if cert != nil {
+		data, err = pki.EncodeCertificatePem(cert)
+	} else {
+		data, err = pki.EncodePrivateKeyPem(key)
+	}
+	assert.NilError(t, err)
+	path := filepath.Join(dir, name)
+	assert.NilError(t, os.WriteFile(path, *data, 0600))
+	return path
+}
+
+func loadPair(t *testing.T, certFile string, keyFile string) tls.Certificate {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NilError(t, err)
+	return pair
+}
+
+func TestDecodeResponseLimit(t *testing.T) {
+	sc := newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerMaxResponseSize: "32",
+	}))
+	var response ValidateConfResponse
+	// exactly at the limit
+	body := `{"allowed":true,"reason":"abcd"}`
+	assert.Equal(t, len(body), 32)
+	assert.NilError(t, sc.decodeResponse(strings.NewReader(body), &response))
+	assert.Assert(t, response.Allowed)
+	assert.Equal(t, response.Reason, "abcd")
+
+	// over the limit
+	body = `{"allowed":true,"reason":"abcde"}`
+	err := sc.decodeResponse(strings.NewReader(body), &response)
+	assert.Assert(t, errors.Is(err, errResponseTooLarge), "expected size error, got %v", err)
+
+	// no limit
+	sc = newSchedulerClient(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerMaxResponseSize: "0",
+	}))
+	body = fmt.Sprintf(`{"allowed":false,"reason":"%s"}`, strings.Repeat("x", 1024*1024))
+	assert.NilError(t, sc.decodeResponse(strings.NewReader(body), &response))
+	assert.Equal(t, len(response.Reason), 1024*1024)
+}
+
+func TestValidateConfigMapLargeConfig(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("partitions:\n  - name: default\n    queues:\n      - name: root\n        queues:\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, "          - name: queue-%d\n", i)
+	}
+	content := sb.String()
+
+	var received int64
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/validate-conf", func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.StoreInt64(&received, n)
+		fmt.Fprintf(w, `{"allowed":false,"reason":"%s"}`, strings.Repeat("x", 4096))
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// the full configuration is sent and the response is decoded within the limit
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    srv.Listener.Addr().String(),
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	err := ac.validateConfigMap("default", prepareConfigMap(content))
+	assert.Equal(t, atomic.LoadInt64(&received), int64(len(content)))
+	assert.Equal(t, err.Error(), strings.Repeat("x", 4096))
+
+	// a response over the limit is not read completely
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:    srv.Listener.Addr().String(),
+		conf.AMWebHookFailOnSchedulerUnreachable: "true",
+		conf.AMWebHookSchedulerMaxResponseSize:   "1024",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(content)), "response exceeds the maximum size")
+}
+
+func TestValidateConfigMapResponseSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	keyFile := writePublicKey(t, t.TempDir(), "response.pem", publicKey)
+
+	var signature atomic.Value
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ws/v1/validate-conf", func(w http.ResponseWriter, r *http.Request) {
+		body := []byte(`{"allowed":true,"reason":""}`)
+		switch signature.Load().(string) {
+		case "valid":
+			w.Header().Set(schedulerSignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, body)))
+		case "invalid":
+			w.Header().Set(schedulerSignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte("other"))))
+		case "malformed":
+			w.Header().Set(schedulerSignatureHeader, "not base64!")
+		}
+		_, _ = w.Write(body)
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	overrides := map[string]string{
+		conf.AMWebHookSchedulerServiceAddress:        srv.Listener.Addr().String(),
+		conf.AMWebHookFailOnSchedulerUnreachable:     "true",
+		conf.AMWebHookSchedulerResponsePublicKeyFile: keyFile,
+	}
+	ac := initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// valid signature
+	signature.Store("valid")
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)))
+
+	// invalid or unsigned responses are treated as an unreachable scheduler
+	signature.Store("invalid")
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "response signature is invalid")
+	signature.Store("malformed")
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "response signature is invalid")
+	signature.Store("")
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "response is not signed")
+
+	// failing open accepts the configmap
+	overrides[conf.AMWebHookFailOnSchedulerUnreachable] = "false"
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)))
+
+	// no public key, signature is not checked
+	delete(overrides, conf.AMWebHookSchedulerResponsePublicKeyFile)
+	overrides[conf.AMWebHookFailOnSchedulerUnreachable] = "true"
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.NilError(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)))
+
+	// unusable public key
+	overrides[conf.AMWebHookSchedulerResponsePublicKeyFile] = filepath.Join(t.TempDir(), "missing.pem")
+	ac = initAdmissionController(createConfigWithOverrides(overrides), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	assert.ErrorContains(t, ac.validateConfigMap("default", prepareConfigMap(ConfigData)), "unable to read response public key")
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte(`{"allowed":true,"reason":""}`)
+	digest := sha256.Sum256(data)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	assert.NilError(t, err)
+	assert.NilError(t, verifySignature(&rsaKey.PublicKey, data, signature))
+	assert.Equal(t, verifySignature(&rsaKey.PublicKey, []byte("other"), signature), errInvalidSignature)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	signature, err = ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	assert.NilError(t, err)
+	assert.NilError(t, verifySignature(&ecdsaKey.PublicKey, data, signature))
+	assert.Equal(t, verifySignature(&ecdsaKey.PublicKey, []byte("other"), signature), errInvalidSignature)
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	signature = ed25519.Sign(edPrivate, data)
+	assert.NilError(t, verifySignature(edPublic, data, signature))
+	assert.Equal(t, verifySignature(edPublic, []byte("other"), signature), errInvalidSignature)
+
+	assert.ErrorContains(t, verifySignature("key", data, signature), "unsupported response public key type")
+}
+
+func writePublicKey(t *testing.T, dir string, name string, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NilError(t, err)
+	path := filepath.Join(dir, name)
+	assert.NilError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return path
+}
@@ -0,0 +1,270 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/apache/yunikorn-k8shim/pkg/apis/yunikorn.apache.org/v1alpha1"
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+const (
+	templateAnnotationsPath = "/spec/template/metadata/annotations"
+	taskGroupParamName      = "name"
+	taskGroupParamMinMember = "minMember"
+)
+
+// taskGroupSpec is the part of a task group that is validated when a pod is admitted. The minimum resource is kept
+// raw to report the quantities that cannot be parsed.
+type taskGroupSpec struct {
+	Name        string                     `json:"name"`
+	MinMember   int32                      `json:"minMember"`
+	MinResource map[string]json.RawMessage `json:"minResource"`
+}
+
+// taskGroupWorkload is the part of a controller needed to build the task group for its pods. Task groups can be
+// generated without parameters for a parallel workload.
+type taskGroupWorkload struct {
+	name        string
+	annotations map[string]string
+	replicas    *int32
+	template    *v1.PodTemplateSpec
+	parallel    bool
+}
+
+// getTaskGroupWorkload decodes the controllers that support task group parameters. Other kinds return nil.
+func getTaskGroupWorkload(req *admissionv1.AdmissionRequest) (*taskGroupWorkload, error) {
+	switch req.Kind.Kind {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		if err := json.Unmarshal(req.Object.Raw, &deployment); err != nil {
+			return nil, err
+		}
+		return &taskGroupWorkload{
+			name:        deployment.Name,
+			annotations: deployment.Annotations,
+			replicas:    deployment.Spec.Replicas,
+			template:    &deployment.Spec.Template,
+		}, nil
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		if err := json.Unmarshal(req.Object.Raw, &statefulSet); err != nil {
+			return nil, err
+		}
+		return &taskGroupWorkload{
+			name:        statefulSet.Name,
+			annotations: statefulSet.Annotations,
+			replicas:    statefulSet.Spec.Replicas,
+			template:    &statefulSet.Spec.Template,
+		}, nil
+	case "Job":
+		var job batchv1.Job
+		if err := json.Unmarshal(req.Object.Raw, &job); err != nil {
+			return nil, err
+		}
+		name := job.Name
+		if name == "" {
+			name = strings.TrimSuffix(job.GenerateName, "-")
+		}
+		parallelism := jobParallelism(&job)
+		return &taskGroupWorkload{
+			name:        name,
+			annotations: job.Annotations,
+			replicas:    &parallelism,
+			template:    &job.Spec.Template,
+			parallel:    parallelism > 1 && name != "",
+		}, nil
+	}
+	return nil, nil
+}
+
+// jobParallelism returns the number of pods of the job that run at the same time: the parallelism, limited by the
+// number of completions if that is set.
+func jobParallelism(job *batchv1.Job) int32 {
+	parallelism := int32(1)
+	if job.Spec.Parallelism != nil {
+		parallelism = *job.Spec.Parallelism
+	}
+	if job.Spec.Completions != nil && *job.Spec.Completions < parallelism {
+		parallelism = *job.Spec.Completions
+	}
+	return parallelism
+}
+
+// updateTaskGroups expands the task group parameters annotation of a controller into the task group annotations of
+// its pod template, so that all pods created by the controller form one gang. Jobs that run more than one pod in
+// parallel get a task group without parameters if configured, with the parallelism as the minimum member count. Task
+// groups already defined on the template are never overwritten. Controllers in namespaces not processed by YuniKorn
+// are ignored.
+func (c *admissionController) updateTaskGroups(req *admissionv1.AdmissionRequest, patch []patchOperation) ([]patchOperation, error) {
+	expand := c.conf.GetExpandTaskGroupParameters()
+	generate := c.conf.GetJobTaskGroups()
+	if (!expand && !generate) || !c.shouldProcessNamespace(req.Namespace) {
+		return patch, nil
+	}
+	workload, err := getTaskGroupWorkload(req)
+	if err != nil || workload == nil {
+		return patch, err
+	}
+	params, ok := workload.annotations[taskGroupParametersAnnotation]
+	if !ok || !expand {
+		if !generate || !workload.parallel {
+			return patch, nil
+		}
+		params = ""
+	}
+	if _, ok = workload.template.Annotations[constants.AnnotationTaskGroups]; ok {
+		log.Logger().Info("task groups already defined, ignoring task group parameters",
+			zap.String("kind", req.Kind.Kind),
+			zap.String("name", workload.name))
+		return patch, nil
+	}
+	taskGroup, err := buildTaskGroup(workload, params)
+	if err != nil {
+		return patch, fmt.Errorf("invalid %s annotation: %v", taskGroupParametersAnnotation, err)
+	}
+	taskGroups, err := json.Marshal([]v1alpha1.TaskGroup{*taskGroup})
+	if err != nil {
+		return patch, err
+	}
+	log.Logger().Info("injecting task groups into pod template",
+		zap.String("kind", req.Kind.Kind),
+		zap.String("name", workload.name),
+		zap.ByteString("taskGroups", taskGroups))
+	exists := len(workload.template.Annotations) != 0
+	patch = addMapEntry(patch, templateAnnotationsPath, exists, constants.AnnotationTaskGroups, string(taskGroups))
+	if _, ok = workload.template.Annotations[constants.AnnotationTaskGroupName]; !ok {
+		patch = addMapEntry(patch, templateAnnotationsPath, exists, constants.AnnotationTaskGroupName, taskGroup.Name)
+	}
+	return patch, nil
+}
+
+// buildTaskGroup creates the task group from the parameters, a list of key=value pairs. The name defaults to the name
+// of the controller and the minimum member count to its replica count. The minimum resource is the sum of the
+// container requests of the pod template.
+func buildTaskGroup(workload *taskGroupWorkload, params string) (*v1alpha1.TaskGroup, error) {
+	taskGroup := &v1alpha1.TaskGroup{
+		Name:        workload.name,
+		MinMember:   1,
+		MinResource: make(map[string]resource.Quantity),
+	}
+	if workload.replicas != nil {
+		taskGroup.MinMember = *workload.replicas
+	}
+	fields := strings.FieldsFunc(params, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("parameter %s must be of the form key=value", field)
+		}
+		switch kv[0] {
+		case taskGroupParamName:
+			taskGroup.Name = kv[1]
+		case taskGroupParamMinMember:
+			minMember, err := strconv.ParseInt(kv[1], 10, 32)
+			if err != nil || minMember < 1 {
+				return nil, fmt.Errorf("minMember must be a positive number, got %s", kv[1])
+			}
+			taskGroup.MinMember = int32(minMember)
+		default:
+			return nil, fmt.Errorf("unknown parameter %s", kv[0])
+		}
+	}
+	if taskGroup.Name == "" {
+		return nil, fmt.Errorf("task group name must be set if the controller has no name")
+	}
+	if taskGroup.MinMember < 1 {
+		return nil, fmt.Errorf("minMember must be a positive number, controller has %d replicas", taskGroup.MinMember)
+	}
+	for _, container := range workload.template.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := taskGroup.MinResource[name.String()]
+			total.Add(quantity)
+			taskGroup.MinResource[name.String()] = total
+		}
+	}
+	return taskGroup, nil
+}
+
+// checkTaskGroups verifies the task groups annotation of the pod. The annotation must be a JSON list of task groups
+// with unique names, a minimum member count that is not negative and a minimum resource of valid quantities.
+func (c *admissionController) checkTaskGroups(pod *v1.Pod) error {
+	if !c.conf.GetValidateTaskGroups() {
+		return nil
+	}
+	value, ok := pod.Annotations[constants.AnnotationTaskGroups]
+	if !ok {
+		return nil
+	}
+	if err := validateTaskGroups(value); err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", constants.AnnotationTaskGroups, err)
+	}
+	return nil
+}
+
+func validateTaskGroups(value string) error {
+	var taskGroups []taskGroupSpec
+	if err := json.Unmarshal([]byte(value), &taskGroups); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for i, taskGroup := range taskGroups {
+		if taskGroup.Name == "" {
+			return fmt.Errorf("task group %d has no name", i)
+		}
+		if names[taskGroup.Name] {
+			return fmt.Errorf("duplicate task group name %s", taskGroup.Name)
+		}
+		names[taskGroup.Name] = true
+		if taskGroup.MinMember < 0 {
+			return fmt.Errorf("task group %s has a negative minMember %d", taskGroup.Name, taskGroup.MinMember)
+		}
+		resourceNames := make([]string, 0, len(taskGroup.MinResource))
+		for name := range taskGroup.MinResource {
+			resourceNames = append(resourceNames, name)
+		}
+		sort.Strings(resourceNames)
+		for _, name := range resourceNames {
+			raw := taskGroup.MinResource[name]
+			var quantity string
+			if err := json.Unmarshal(raw, &quantity); err != nil {
+				quantity = string(raw)
+			}
+			if _, err := resource.ParseQuantity(quantity); err != nil {
+				return fmt.Errorf("task group %s has an invalid quantity %s for resource %s", taskGroup.Name, quantity, name)
+			}
+		}
+	}
+	return nil
+}
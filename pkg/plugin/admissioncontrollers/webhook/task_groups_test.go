@@ -0,0 +1,280 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/apache/yunikorn-k8shim/pkg/apis/yunikorn.apache.org/v1alpha1"
+	"github.com/apache/yunikorn-k8shim/pkg/common/constants"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func taskGroupDeployment(params string, replicas int32, templateAnnotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Annotations: map[string]string{taskGroupParametersAnnotation: params},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: templateAnnotations},
+				Spec: v1.PodSpec{Containers: []v1.Container{
+					{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("500m"),
+						v1.ResourceMemory: resource.MustParse("1Gi"),
+					}}},
+					{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("250m"),
+					}}},
+				}},
+			},
+		},
+	}
+}
+
+func workloadRequest(t *testing.T, kind string, obj interface{}) *admissionv1.AdmissionRequest {
+	raw, err := json.Marshal(obj)
+	assert.NilError(t, err, "failed to marshal workload")
+	return &admissionv1.AdmissionRequest{
+		UID:    "test-uid",
+		Kind:   metav1.GroupVersionKind{Kind: kind},
+		Object: runtime.RawExtension{Raw: raw},
+	}
+}
+
+func patchedTaskGroups(t *testing.T, patch []patchOperation) []v1alpha1.TaskGroup {
+	for _, op := range patch {
+		if op.Path == templateAnnotationsPath+"/"+jsonPointerEscaper.Replace(constants.AnnotationTaskGroups) {
+			var taskGroups []v1alpha1.TaskGroup
+			assert.NilError(t, json.Unmarshal([]byte(op.Value.(string)), &taskGroups))
+			return taskGroups
+		}
+	}
+	return nil
+}
+
+func TestUpdateTaskGroups(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationExpandTaskGroupParameters: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	// defaults from the deployment
+	patch, err := ac.updateTaskGroups(workloadRequest(t, "Deployment", taskGroupDeployment("", 3, nil)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 3)
+	assert.Equal(t, patch[0].Path, templateAnnotationsPath)
+	assert.Equal(t, patch[2].Path, templateAnnotationsPath+"/yunikorn.apache.org~1task-group-name")
+	assert.Equal(t, patch[2].Value, "web")
+	taskGroups := patchedTaskGroups(t, patch)
+	assert.Equal(t, len(taskGroups), 1)
+	assert.Equal(t, taskGroups[0].Name, "web")
+	assert.Equal(t, taskGroups[0].MinMember, int32(3))
+	cpu := taskGroups[0].MinResource["cpu"]
+	memory := taskGroups[0].MinResource["memory"]
+	assert.Equal(t, cpu.MilliValue(), int64(750))
+	assert.Equal(t, memory.Value(), int64(1024*1024*1024))
+
+	// explicit parameters, existing template annotations are kept
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Deployment",
+		taskGroupDeployment("name=gang, minMember=2", 3, map[string]string{"existing": "value"})), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 2)
+	taskGroups = patchedTaskGroups(t, patch)
+	assert.Equal(t, taskGroups[0].Name, "gang")
+	assert.Equal(t, taskGroups[0].MinMember, int32(2))
+	assert.Equal(t, patch[1].Value, "gang")
+
+	// statefulset
+	replicas := int32(4)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "db",
+			Annotations: map[string]string{taskGroupParametersAnnotation: ""},
+		},
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "StatefulSet", statefulSet), nil)
+	assert.NilError(t, err)
+	taskGroups = patchedTaskGroups(t, patch)
+	assert.Equal(t, taskGroups[0].Name, "db")
+	assert.Equal(t, taskGroups[0].MinMember, int32(4))
+
+	// existing task groups are not overwritten
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Deployment",
+		taskGroupDeployment("", 3, map[string]string{constants.AnnotationTaskGroups: "[]"})), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+
+	// malformed parameters
+	for _, params := range []string{"minMember", "minMember=0", "minMember=abc", "size=3"} {
+		_, err = ac.updateTaskGroups(workloadRequest(t, "Deployment", taskGroupDeployment(params, 3, nil)), nil)
+		assert.ErrorContains(t, err, "invalid yunikorn.apache.org/task-group-parameters annotation")
+	}
+	_, err = ac.updateTaskGroups(workloadRequest(t, "Deployment", taskGroupDeployment("", 0, nil)), nil)
+	assert.ErrorContains(t, err, "minMember must be a positive number")
+
+	// unsupported kind
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "ReplicaSet", &appsv1.ReplicaSet{}), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+
+	// disabled
+	ac = initAdmissionController(createConfig(), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Deployment", taskGroupDeployment("", 3, nil)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+}
+
+func TestUpdateJobTaskGroups(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationJobTaskGroups: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	job := func(parallelism int32, completions *int32) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "train-"},
+			Spec: batchv1.JobSpec{
+				Parallelism: &parallelism,
+				Completions: completions,
+				Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{
+					{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}}},
+				}}},
+			},
+		}
+	}
+
+	// generated from the parallelism without parameters
+	patch, err := ac.updateTaskGroups(workloadRequest(t, "Job", job(4, nil)), nil)
+	assert.NilError(t, err)
+	taskGroups := patchedTaskGroups(t, patch)
+	assert.Equal(t, len(taskGroups), 1)
+	assert.Equal(t, taskGroups[0].Name, "train")
+	assert.Equal(t, taskGroups[0].MinMember, int32(4))
+	cpu := taskGroups[0].MinResource["cpu"]
+	assert.Equal(t, cpu.Value(), int64(2))
+
+	// limited by the completions
+	completions := int32(2)
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Job", job(4, &completions)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, patchedTaskGroups(t, patch)[0].MinMember, int32(2))
+
+	// jobs that run one pod at a time are not a gang
+	completions = 1
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Job", job(4, &completions)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Job", job(1, nil)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+
+	// other kinds still need parameters
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Deployment", taskGroupDeployment("", 3, nil)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+
+	// parameters are expanded on jobs if configured
+	ac = initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationExpandTaskGroupParameters: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Job", job(4, nil)), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(patch), 0)
+	withParams := job(4, nil)
+	withParams.Annotations = map[string]string{taskGroupParametersAnnotation: "minMember=3"}
+	patch, err = ac.updateTaskGroups(workloadRequest(t, "Job", withParams), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, patchedTaskGroups(t, patch)[0].MinMember, int32(3))
+}
+
+func TestMutateTaskGroups(t *testing.T) {
+	ac := initAdmissionController(createConfigWithOverrides(map[string]string{
+		conf.AMMutationExpandTaskGroupParameters: "true",
+	}), NewNamespaceCache(nil), NewConfigMapCache(nil))
+
+	resp := ac.mutate(workloadRequest(t, "Deployment", taskGroupDeployment("minMember=2", 3, nil)))
+	assert.Check(t, resp.Allowed, "response not allowed")
+	assert.Assert(t, len(resp.Patch) > 0, "no patch returned")
+
+	resp = ac.mutate(workloadRequest(t, "Deployment", taskGroupDeployment("minMember=-1", 3, nil)))
+	assert.Check(t, !resp.Allowed, "malformed parameters allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "minMember must be a positive number, got -1"))
+}
+
+func TestValidateTaskGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"valid", `[{"name":"tg-1","minMember":2,"minResource":{"cpu":"500m","memory":"1Gi","nvidia.com/gpu":1}},{"name":"tg-2","minMember":0}]`, ""},
+		{"empty list", `[]`, ""},
+		{"malformed json", `[{"name":"tg-1"`, "unexpected end of JSON input"},
+		{"not a list", `{"name":"tg-1"}`, "cannot unmarshal object"},
+		{"no name", `[{"minMember":1}]`, "task group 0 has no name"},
+		{"duplicate name", `[{"name":"tg-1","minMember":1},{"name":"tg-1","minMember":2}]`, "duplicate task group name tg-1"},
+		{"negative minMember", `[{"name":"tg-1","minMember":-1}]`, "task group tg-1 has a negative minMember -1"},
+		{"invalid quantity", `[{"name":"tg-1","minMember":1,"minResource":{"cpu":"500m","memory":"lots"}}]`, "task group tg-1 has an invalid quantity lots for resource memory"},
+		{"invalid quantity type", `[{"name":"tg-1","minMember":1,"minResource":{"cpu":true}}]`, "task group tg-1 has an invalid quantity true for resource cpu"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTaskGroups(test.value)
+			if test.err == "" {
+				assert.NilError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestMutateInvalidTaskGroups(t *testing.T) {
+	config := createConfig()
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "test-ns",
+		Annotations: map[string]string{constants.AnnotationTaskGroups: `[{"name":"tg-1","minMember":-1}]`},
+	}}
+	resp := ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, !resp.Allowed, "invalid task groups allowed")
+	assert.Check(t, strings.Contains(resp.Result.Message, "invalid "+constants.AnnotationTaskGroups+" annotation"))
+
+	pod.Annotations[constants.AnnotationTaskGroups] = `[{"name":"tg-1","minMember":1,"minResource":{"cpu":"1"}}]`
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed")
+
+	// validation disabled
+	pod.Annotations[constants.AnnotationTaskGroups] = `[{"name":"tg-1","minMember":-1}]`
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: map[string]string{conf.AMValidationTaskGroups: "false"}}})
+	resp = ac.mutate(createPodRequest(t, pod))
+	assert.Check(t, resp.Allowed, "response not allowed")
+}
@@ -0,0 +1,109 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apache/yunikorn-k8shim/pkg/log"
+)
+
+const (
+	rejectionsURL       = "/debug/rejections"
+	maxRejectionSamples = 100
+)
+
+// userInfoRejection is a request that was denied because of its user info annotation.
+type userInfoRejection struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Groups    []string  `json:"groups"`
+	Namespace string    `json:"namespace"`
+	Message   string    `json:"message"`
+}
+
+// rejectionSamples keeps the most recent user info rejections, older rejections are overwritten.
+type rejectionSamples struct {
+	samples []userInfoRejection
+	next    int
+
+	sync.Mutex
+}
+
+func (s *rejectionSamples) add(rejection userInfoRejection) {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.samples) < maxRejectionSamples {
+		s.samples = append(s.samples, rejection)
+		return
+	}
+	s.samples[s.next] = rejection
+	s.next = (s.next + 1) % maxRejectionSamples
+}
+
+// list returns the samples, oldest first.
+func (s *rejectionSamples) list() []userInfoRejection {
+	s.Lock()
+	defer s.Unlock()
+	result := make([]userInfoRejection, 0, len(s.samples))
+	result = append(result, s.samples[s.next:]...)
+	return append(result, s.samples[:s.next]...)
+}
+
+// recordUserInfoRejection counts the rejection by user and by group, and keeps it as a sample, if rejection tracking
+// is enabled. The requesting user is normally the controller that creates the pods, which makes misconfigured
+// controllers visible. Users and groups are chosen by whoever submits pods: only a bounded number of users is used
+// as a label, and only the groups matching the configured external groups are counted.
+func (c *admissionController) recordUserInfoRejection(namespace string, userName string, groups []string, message string) {
+	if !c.conf.GetTrackRejections() {
+		return
+	}
+	metrics.userRejections.WithLabelValues(metrics.userLabel(userName)).Inc()
+	externalGroups := c.conf.GetAccessControl().ExternalGroups
+	for _, group := range groups {
+		if regexpsMatch(externalGroups, group) {
+			metrics.groupRejections.WithLabelValues(group).Inc()
+		}
+	}
+	c.rejections.add(userInfoRejection{
+		Time:      time.Now(),
+		User:      userName,
+		Groups:    groups,
+		Namespace: namespace,
+		Message:   message,
+	})
+}
+
+// serveRejections writes the recent user info rejections as JSON. The endpoint is only available if rejection
+// tracking is enabled, and only on the debug server as the samples contain user identities.
+func (c *admissionController) serveRejections(w http.ResponseWriter, r *http.Request) {
+	if !c.conf.GetTrackRejections() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c.rejections.list()); err != nil {
+		log.Logger().Error("Unable to write rejection samples", zap.Error(err))
+	}
+}
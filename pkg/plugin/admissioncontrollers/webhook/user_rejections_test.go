@@ -0,0 +1,113 @@
+/*
+ Licensed to the Apache Software Foundation (ASF) under one
+ or more contributor license agreements.  See the NOTICE file
+ distributed with this work for additional information
+ regarding copyright ownership.  The ASF licenses this file
+ to you under the Apache License, Version 2.0 (the
+ "License"); you may not use this file except in compliance
+ with the License.  You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
+)
+
+func TestRejectionSamples(t *testing.T) {
+	samples := &rejectionSamples{}
+	assert.Equal(t, len(samples.list()), 0)
+	for i := 0; i < maxRejectionSamples+5; i++ {
+		samples.add(userInfoRejection{User: fmt.Sprintf("user-%d", i)})
+	}
+	list := samples.list()
+	assert.Equal(t, len(list), maxRejectionSamples)
+	assert.Equal(t, list[0].User, "user-5")
+	assert.Equal(t, list[maxRejectionSamples-1].User, fmt.Sprintf("user-%d", maxRejectionSamples+4))
+}
+
+func TestRecordUserInfoRejection(t *testing.T) {
+	overrides := map[string]string{}
+	config := createConfigWithOverrides(overrides)
+	ac := initAdmissionController(config, NewNamespaceCache(nil), NewConfigMapCache(nil))
+	reject := func(annotation string) {
+		req := createPodRequest(t, &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "test-ns",
+			Annotations: map[string]string{userInfoAnnotation: annotation},
+		}})
+		req.UserInfo = authv1.UserInfo{Username: "system:serviceaccount:apps:operator", Groups: []string{"operators"}}
+		resp := ac.mutate(req)
+		assert.Check(t, !resp.Allowed, "response was allowed")
+	}
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ac.serveRejections(w, httptest.NewRequest(http.MethodGet, rejectionsURL, nil))
+		return w
+	}
+	rejections := testutil.ToFloat64(metrics.rejections.WithLabelValues(rejectionUserInfo))
+	users := testutil.ToFloat64(metrics.userRejections.WithLabelValues("system:serviceaccount:apps:operator"))
+	groups := testutil.ToFloat64(metrics.groupRejections.WithLabelValues("operators"))
+
+	// tracking disabled
+	reject(validUserInfoAnnotation)
+	assert.Equal(t, testutil.ToFloat64(metrics.rejections.WithLabelValues(rejectionUserInfo)), rejections+1)
+	assert.Equal(t, testutil.ToFloat64(metrics.userRejections.WithLabelValues("system:serviceaccount:apps:operator")), users)
+	assert.Equal(t, len(ac.rejections.list()), 0)
+	assert.Equal(t, serve().Code, http.StatusNotFound)
+
+	// tracking enabled
+	overrides[conf.AMAccessControlTrackRejections] = "true"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	reject(validUserInfoAnnotation)
+	assert.Equal(t, testutil.ToFloat64(metrics.rejections.WithLabelValues(rejectionUserInfo)), rejections+2)
+	assert.Equal(t, testutil.ToFloat64(metrics.userRejections.WithLabelValues("system:serviceaccount:apps:operator")), users+1)
+	// groups not matching the external groups are not counted
+	assert.Equal(t, testutil.ToFloat64(metrics.groupRejections.WithLabelValues("operators")), groups)
+	w := serve()
+	assert.Equal(t, w.Code, http.StatusOK)
+	var samples []userInfoRejection
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &samples))
+	assert.Equal(t, len(samples), 1)
+	assert.Equal(t, samples[0].User, "system:serviceaccount:apps:operator")
+	assert.DeepEqual(t, samples[0].Groups, []string{"operators"})
+	assert.Equal(t, samples[0].Namespace, "test-ns")
+	assert.Equal(t, samples[0].Message, "user system:serviceaccount:apps:operator with groups [operators] is not allowed to set user annotation")
+
+	// external group with an invalid annotation
+	overrides[conf.AMAccessControlExternalGroups] = "^operators$"
+	config.UpdateConfigMaps([]*v1.ConfigMap{nil, {Data: overrides}})
+	reject("{")
+	assert.Equal(t, testutil.ToFloat64(metrics.userRejections.WithLabelValues("system:serviceaccount:apps:operator")), users+2)
+	assert.Equal(t, testutil.ToFloat64(metrics.groupRejections.WithLabelValues("operators")), groups+1)
+}
+
+func TestRejectionUserLabel(t *testing.T) {
+	m := newAdmissionMetrics()
+	for i := 0; i < maxRejectionUsers; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		assert.Equal(t, m.userLabel(user), user)
+	}
+	assert.Equal(t, m.userLabel("user-0"), "user-0")
+	assert.Equal(t, m.userLabel("late-user"), otherUserLabel)
+	assert.Equal(t, len(m.rejectionUsers), maxRejectionUsers)
+}
@@ -22,11 +22,15 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
+
+	"k8s.io/client-go/informers"
 
 	"github.com/apache/yunikorn-k8shim/pkg/client"
 	schedulerconf "github.com/apache/yunikorn-k8shim/pkg/conf"
@@ -39,12 +43,15 @@ import (
 const (
 	HTTPPort  = 9089
 	healthURL = "/health"
+	livezURL  = "/livez"
+	readyzURL = "/readyz"
 )
 
 type WebHook struct {
-	ac     *admissionController
-	port   int
-	server *http.Server
+	ac          *admissionController
+	port        int
+	server      *http.Server
+	debugServer *http.Server
 	sync.Mutex
 }
 
@@ -60,32 +67,73 @@ func main() {
 	kubeClient := client.NewKubeClient(amConf.GetKubeConfig())
 	amConf.StartInformers(kubeClient)
 
+	informerFactory := informers.NewSharedInformerFactory(kubeClient.GetClientSet(), 0)
+	nsCache := NewNamespaceCache(informerFactory.Core().V1().Namespaces())
+	namespacedInformerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient.GetClientSet(), 0, informers.WithNamespace(amConf.GetNamespace()))
+	cmCache := NewConfigMapCache(namespacedInformerFactory.Core().V1().ConfigMaps())
+	// the pods are only tracked if namespace resource caps are configured at startup
+	podUsage := NewPodUsageCache(nil)
+	if amConf.HasNamespaceResourceCaps() {
+		podUsage = NewPodUsageCache(informerFactory.Core().V1().Pods())
+	}
+	// the owner chain is only tracked if owner based application IDs are enabled at startup
+	owners := NewOwnerCache(nil, nil)
+	if amConf.GetOwnerBasedAppID() {
+		owners = NewOwnerCache(informerFactory.Apps().V1().ReplicaSets(), informerFactory.Batch().V1().Jobs())
+	}
+	informerStopChan := make(chan struct{})
+	informerFactory.Start(informerStopChan)
+	namespacedInformerFactory.Start(informerStopChan)
+	informerFactory.WaitForCacheSync(informerStopChan)
+	namespacedInformerFactory.WaitForCacheSync(informerStopChan)
+
 	wm, err := NewWebhookManager(amConf)
 	if err != nil {
 		log.Logger().Fatal("Failed to initialize webhook manager", zap.Error(err))
 	}
 
-	ac := initAdmissionController(amConf)
+	ac := initAdmissionController(amConf, nsCache, cmCache)
+	ac.recorder = newEventRecorder(kubeClient, informerStopChan)
+	ac.podUsage = podUsage
+	ac.owners = owners
+	ac.markReady()
 
+	// with leader election only the leader installs the webhooks, all replicas serve requests
+	leaderElection := amConf.GetLeaderElection()
 	webhook := CreateWebhook(ac, HTTPPort)
-	certs := UpdateWebhookConfiguration(wm)
+	certs := UpdateWebhookConfiguration(wm, !leaderElection)
 	webhook.Startup(certs)
 
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
 
 	WaitForCertExpiration(wm, signalChan)
+	if leaderElection {
+		identity, err := os.Hostname()
+		if err != nil {
+			log.Logger().Fatal("Unable to determine leader election identity", zap.Error(err))
+		}
+		go runLeaderElection(kubeClient.GetClientSet(), amConf.GetNamespace(), identity, func(stopChan <-chan struct{}) {
+			if err := installWebhooks(wm); err != nil {
+				log.Logger().Warn("Unable to install webhooks", zap.Error(err))
+			}
+			reconcileWebhooks(wm, amConf, stopChan)
+		}, informerStopChan)
+	} else {
+		go reconcileWebhooks(wm, amConf, informerStopChan)
+	}
 
 	for {
 		switch <-signalChan {
 		case syscall.SIGUSR1: // reload certificates
-			certs := UpdateWebhookConfiguration(wm)
+			certs := UpdateWebhookConfiguration(wm, !leaderElection)
 			webhook.Shutdown()
 			webhook.Startup(certs)
 			WaitForCertExpiration(wm, signalChan)
 		default: // terminate
+			webhook.Drain()
 			amConf.StopInformers()
-			webhook.Shutdown()
+			close(informerStopChan)
 			os.Exit(0)
 		}
 	}
@@ -98,7 +146,9 @@ func WaitForCertExpiration(wm WebhookManager, ch chan os.Signal) {
 	}()
 }
 
-func UpdateWebhookConfiguration(wm WebhookManager) *tls.Certificate {
+// UpdateWebhookConfiguration loads the CA certificates and generates the server certificate. The webhooks are only
+// installed if requested.
+func UpdateWebhookConfiguration(wm WebhookManager, install bool) *tls.Certificate {
 	err := wm.LoadCACertificates()
 	if err != nil {
 		log.Logger().Fatal("Failed to initialize CA certificates", zap.Error(err))
@@ -109,9 +159,11 @@ func UpdateWebhookConfiguration(wm WebhookManager) *tls.Certificate {
 		log.Logger().Fatal("Unable to generate server certificate", zap.Error(err))
 	}
 
-	err = wm.InstallWebhooks()
-	if err != nil {
-		log.Logger().Fatal("Unable to install webhooks for admission controller", zap.Error(err))
+	if install {
+		err = wm.InstallWebhooks()
+		if err != nil {
+			log.Logger().Fatal("Unable to install webhooks for admission controller", zap.Error(err))
+		}
 	}
 
 	return certs
@@ -129,20 +181,36 @@ func (wh *WebHook) Startup(certs *tls.Certificate) {
 	defer wh.Unlock()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc(healthURL, wh.ac.health)
-	mux.HandleFunc(mutateURL, wh.ac.serve)
-	mux.HandleFunc(validateConfURL, wh.ac.serve)
+	mux.HandleFunc(healthURL, wh.ac.livez)
+	mux.HandleFunc(livezURL, wh.ac.livez)
+	mux.HandleFunc(readyzURL, wh.ac.readyz)
+	mux.Handle(metricsURL, metrics.handler())
+	admissionPaths := wh.ac.handlers.paths()
+	for _, path := range admissionPaths {
+		mux.HandleFunc(path, wh.ac.serve)
+	}
 
+	amConf := wh.ac.conf
 	wh.server = &http.Server{
 		Addr: fmt.Sprintf(":%v", wh.port),
 		TLSConfig: &tls.Config{
 			MinVersion:   tls.VersionTLS12,
 			Certificates: []tls.Certificate{*certs}},
-		Handler: mux,
+		Handler:      mux,
+		ReadTimeout:  amConf.GetReadTimeout(),
+		WriteTimeout: amConf.GetWriteTimeout(),
+	}
+	listener, err := net.Listen("tcp", wh.server.Addr)
+	if err != nil {
+		log.Logger().Fatal("failed to start admission controller", zap.Error(err))
+	}
+	if maxConnections := amConf.GetMaxConnections(); maxConnections > 0 {
+		listener = newLimitListener(listener, maxConnections)
 	}
 
+	server := wh.server
 	go func() {
-		if err := wh.server.ListenAndServeTLS("", ""); err != nil {
+		if err := server.ServeTLS(listener, "", ""); err != nil {
 			if err == http.ErrServerClosed {
 				log.Logger().Info("existing server closed")
 			} else {
@@ -153,19 +221,77 @@ func (wh *WebHook) Startup(certs *tls.Certificate) {
 
 	log.Logger().Info("the admission controller started",
 		zap.Int("port", HTTPPort),
-		zap.Strings("listeningOn", []string{healthURL, mutateURL, validateConfURL}))
+		zap.Strings("listeningOn", append([]string{healthURL, livezURL, readyzURL, metricsURL}, admissionPaths...)))
+
+	wh.startDebugServer()
 }
 
+// startDebugServer starts the plain HTTP server for the debug endpoints on the configured address. The debug endpoints
+// expose user identities, by default they are only served on the loopback interface and can be reached with a port
+// forward. A failure to start it is not fatal, the debug endpoints are not needed to admit pods.
+func (wh *WebHook) startDebugServer() {
+	debugAddress := wh.ac.conf.GetDebugAddress()
+	if debugAddress == "" {
+		return
+	}
+	debugMux := http.NewServeMux()
+	debugMux.HandleFunc(rejectionsURL, wh.ac.serveRejections)
+	wh.debugServer = &http.Server{
+		Addr:         debugAddress,
+		Handler:      debugMux,
+		ReadTimeout:  wh.ac.conf.GetReadTimeout(),
+		WriteTimeout: wh.ac.conf.GetWriteTimeout(),
+	}
+	listener, err := net.Listen("tcp", debugAddress)
+	if err != nil {
+		log.Logger().Warn("failed to start the debug server", zap.Error(err))
+		wh.debugServer = nil
+		return
+	}
+	server := wh.debugServer
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Logger().Warn("debug server stopped", zap.Error(err))
+		}
+	}()
+	log.Logger().Info("the debug server started",
+		zap.String("address", debugAddress),
+		zap.Strings("listeningOn", []string{rejectionsURL}))
+}
+
+// Shutdown stops the server. In-flight requests are allowed to complete within the shutdown timeout, connections
+// that are still open after the timeout are closed.
 func (wh *WebHook) Shutdown() {
 	wh.Lock()
 	defer wh.Unlock()
 
 	if wh.server != nil {
 		log.Logger().Info("shutting down the admission controller...")
-		err := wh.server.Shutdown(context.Background())
-		if err != nil {
-			log.Logger().Fatal("failed to stop the admission controller", zap.Error(err))
+		ctx, cancel := context.WithTimeout(context.Background(), wh.ac.conf.GetShutdownTimeout())
+		defer cancel()
+		if err := wh.server.Shutdown(ctx); err != nil {
+			log.Logger().Warn("in-flight requests did not complete in time, closing connections", zap.Error(err))
+			if err = wh.server.Close(); err != nil {
+				log.Logger().Error("failed to stop the admission controller", zap.Error(err))
+			}
 		}
 		wh.server = nil
 	}
+	if wh.debugServer != nil {
+		if err := wh.debugServer.Close(); err != nil {
+			log.Logger().Error("failed to stop the debug server", zap.Error(err))
+		}
+		wh.debugServer = nil
+	}
+}
+
+// Drain reports the admission controller as not ready and keeps serving requests for the shutdown delay, so that the
+// API server stops sending requests to this replica before the server is shut down.
+func (wh *WebHook) Drain() {
+	wh.ac.markNotReady()
+	if delay := wh.ac.conf.GetShutdownDelay(); delay > 0 {
+		log.Logger().Info("draining the admission controller", zap.Duration("delay", delay))
+		time.Sleep(delay)
+	}
+	wh.Shutdown()
 }
@@ -25,10 +25,13 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
 	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/apache/yunikorn-k8shim/pkg/client"
@@ -46,12 +49,16 @@ const (
 	secretName        = "admission-controller-secrets"
 	validatingWebhook = "yunikorn-admission-controller-validations"
 	validateConfHook  = "admission-webhook.yunikorn.validate-conf"
+	validatePodsHook  = "admission-webhook.yunikorn.validate-pods"
 	mutatingWebhook   = "yunikorn-admission-controller-mutations"
 	mutatePodsWebhook = "admission-webhook.yunikorn.mutate-pods"
 	caCert1Path       = "cacert1.pem"
 	caCert2Path       = "cacert2.pem"
 	caPrivateKey1Path = "cakey1.pem"
 	caPrivateKey2Path = "cakey2.pem"
+
+	// how often a disabled reconciliation checks whether it has been enabled
+	reconcileDisabledInterval = time.Minute
 )
 
 // WebhookManager is used to handle all registration requirements for the webhook, including certificates
@@ -224,9 +231,42 @@ func (wm *webhookManagerImpl) InstallWebhooks() error {
 
 func (wm *webhookManagerImpl) WaitForCertificateExpiration() {
 	renewTime := wm.getExpiration().AddDate(0, 0, -30)
+	log.Logger().Info("Certificates will be renewed", zap.Time("renewTime", renewTime))
 	time.Sleep(time.Until(renewTime))
 }
 
+// reconcileWebhooks periodically installs the webhooks until the stop channel is closed. The webhook configurations
+// are only updated if they no longer match the CA certificates, for instance after they have been replaced by a
+// redeployment. Without the reconciliation such a change would silently break all admissions until the next
+// certificate renewal. The CA certificates are reloaded first to pick up certificates renewed by other replicas.
+func reconcileWebhooks(wm WebhookManager, conf *conf.AdmissionControllerConf, stopChan <-chan struct{}) {
+	for {
+		interval := conf.GetReconcileInterval()
+		enabled := interval > 0
+		if !enabled {
+			interval = reconcileDisabledInterval
+		}
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(interval):
+		}
+		if enabled {
+			if err := installWebhooks(wm); err != nil {
+				log.Logger().Warn("Unable to reconcile webhooks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// installWebhooks reloads the CA certificates and installs the webhooks.
+func installWebhooks(wm WebhookManager) error {
+	if err := wm.LoadCACertificates(); err != nil {
+		return err
+	}
+	return wm.InstallWebhooks()
+}
+
 func (wm *webhookManagerImpl) getExpiration() time.Time {
 	wm.RLock()
 	defer wm.RUnlock()
@@ -380,21 +420,121 @@ func (wm *webhookManagerImpl) installMutatingWebhook() (bool, error) {
 }
 
 func (wm *webhookManagerImpl) checkValidatingWebhook(webhook *v1.ValidatingWebhookConfiguration) error {
-	ignore := v1.Ignore
-	none := v1.SideEffectClassNone
-	path := "/validate-conf"
-
 	value, ok := webhook.ObjectMeta.GetLabels()["app"]
 	if !ok || value != "yunikorn" {
 		return errors.New("webhook: missing label app=yunikorn")
 	}
 
-	if len(webhook.Webhooks) != 1 {
+	if len(webhook.Webhooks) != 2 {
 		return errors.New("webhook: wrong webhook count")
 	}
 
-	hook := webhook.Webhooks[0]
-	if hook.Name != validateConfHook {
+	err := wm.checkValidatingHook(webhook.Webhooks[0], validateConfHook, validateConfURL,
+		[]v1.OperationType{v1.Create, v1.Update}, "configmaps", wm.validateConfFailurePolicy())
+	if err != nil {
+		return err
+	}
+	return wm.checkValidatingHook(webhook.Webhooks[1], validatePodsHook, validateURL,
+		[]v1.OperationType{v1.Create}, "pods", wm.podFailurePolicy())
+}
+
+// validateConfFailurePolicy returns the failure policy of the configmap validation webhook. When configmaps are
+// rejected while the scheduler is unreachable, they are also rejected while the admission controller is unreachable.
+func (wm *webhookManagerImpl) validateConfFailurePolicy() v1.FailurePolicyType {
+	if wm.conf.GetFailOnSchedulerUnreachable() {
+		return v1.Fail
+	}
+	return v1.Ignore
+}
+
+// podFailurePolicy returns the configured failure policy of the pod webhooks.
+func (wm *webhookManagerImpl) podFailurePolicy() v1.FailurePolicyType {
+	return v1.FailurePolicyType(wm.conf.GetWebhookFailurePolicy())
+}
+
+// timeoutSeconds returns the configured timeout of all webhooks.
+func (wm *webhookManagerImpl) timeoutSeconds() int32 {
+	return int32(wm.conf.GetWebhookTimeoutSeconds())
+}
+
+// podNamespaceSelector translates the process and bypass namespaces into a selector on the namespace name label, set
+// by the API server on every namespace. Only expressions matching a single name, like ^kube-system$, can be translated:
+// a process list with other expressions is not registered, other bypass expressions are applied by the admission
+// controller only. No selector is registered if a process selector is configured, it takes precedence over the lists.
+func (wm *webhookManagerImpl) podNamespaceSelector() *metav1.LabelSelector {
+	if !wm.conf.GetWebhookNamespaceSelector() {
+		return nil
+	}
+	filters := wm.conf.GetNamespaceFilters()
+	if filters.ProcessPodSelector != nil || filters.ProcessNamespaceSelector != nil {
+		return nil
+	}
+	selector := &metav1.LabelSelector{}
+	if len(filters.ProcessNamespaces) != 0 {
+		names, complete := namespaceNames(filters.ProcessNamespaces)
+		if !complete {
+			return nil
+		}
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   names,
+		})
+	}
+	if names, _ := namespaceNames(filters.BypassNamespaces); len(names) != 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   names,
+		})
+	}
+	if len(selector.MatchExpressions) == 0 {
+		return nil
+	}
+	return selector
+}
+
+// namespaceNames returns the names matched by the expressions that match a single name, and whether all expressions
+// could be translated.
+func namespaceNames(expressions []*regexp.Regexp) ([]string, bool) {
+	names := make([]string, 0, len(expressions))
+	complete := true
+	for _, re := range expressions {
+		expr := re.String()
+		if len(expr) < 2 || expr[0] != '^' || expr[len(expr)-1] != '$' {
+			complete = false
+			continue
+		}
+		inner, err := regexp.Compile(expr[1 : len(expr)-1])
+		if err != nil {
+			complete = false
+			continue
+		}
+		name, literal := inner.LiteralPrefix()
+		if !literal || name == "" {
+			complete = false
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, complete
+}
+
+// selectorsEqual compares two namespace selectors. The API server registers an empty selector if none is set.
+func selectorsEqual(selector *metav1.LabelSelector, expected *metav1.LabelSelector) bool {
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+	if expected == nil {
+		expected = &metav1.LabelSelector{}
+	}
+	return apiequality.Semantic.DeepEqual(selector, expected)
+}
+
+func (wm *webhookManagerImpl) checkValidatingHook(hook v1.ValidatingWebhook, name string, path string, operations []v1.OperationType, resource string, failurePolicy v1.FailurePolicyType) error {
+	none := v1.SideEffectClassNone
+
+	if hook.Name != name {
 		return errors.New("webhook: wrong webhook name")
 	}
 
@@ -427,9 +567,14 @@ func (wm *webhookManagerImpl) checkValidatingWebhook(webhook *v1.ValidatingWebho
 	}
 
 	rule := rules[0]
-	if len(rule.Operations) != 2 || rule.Operations[0] != v1.Create || rule.Operations[1] != v1.Update {
+	if len(rule.Operations) != len(operations) {
 		return errors.New("webhook: wrong operations")
 	}
+	for i, op := range operations {
+		if rule.Operations[i] != op {
+			return errors.New("webhook: wrong operations")
+		}
+	}
 
 	if len(rule.APIGroups) != 1 || rule.APIGroups[0] != "" {
 		return errors.New("webhook: wrong api groups")
@@ -439,14 +584,18 @@ func (wm *webhookManagerImpl) checkValidatingWebhook(webhook *v1.ValidatingWebho
 		return errors.New("webhook: wrong api versions")
 	}
 
-	if len(rule.Resources) != 1 || rule.Resources[0] != "configmaps" {
+	if len(rule.Resources) != 1 || rule.Resources[0] != resource {
 		return errors.New("webhook: wrong resources")
 	}
 
-	if hook.FailurePolicy == nil || *hook.FailurePolicy != ignore {
+	if hook.FailurePolicy == nil || *hook.FailurePolicy != failurePolicy {
 		return errors.New("webhook: wrong failure policy")
 	}
 
+	if hook.TimeoutSeconds == nil || *hook.TimeoutSeconds != wm.timeoutSeconds() {
+		return errors.New("webhook: wrong timeout")
+	}
+
 	if hook.SideEffects == nil || *hook.SideEffects != none {
 		return errors.New("webhook: wrong side effects")
 	}
@@ -455,7 +604,6 @@ func (wm *webhookManagerImpl) checkValidatingWebhook(webhook *v1.ValidatingWebho
 }
 
 func (wm *webhookManagerImpl) checkMutatingWebhook(webhook *v1.MutatingWebhookConfiguration) error {
-	ignore := v1.Ignore
 	none := v1.SideEffectClassNone
 	path := "/mutate"
 
@@ -497,29 +645,45 @@ func (wm *webhookManagerImpl) checkMutatingWebhook(webhook *v1.MutatingWebhookCo
 	}
 
 	rules := hook.Rules
-	if len(rules) != 1 {
+	expectedRules := wm.mutatingRules()
+	if len(rules) != len(expectedRules) {
 		return errors.New("webhook: wrong rule count")
 	}
 
-	rule := rules[0]
-	if len(rule.Operations) != 1 || rule.Operations[0] != v1.Create {
-		return errors.New("webhook: wrong operations")
-	}
+	for i, rule := range rules {
+		expected := expectedRules[i]
+		if len(rule.Operations) != len(expected.Operations) {
+			return errors.New("webhook: wrong operations")
+		}
+		for j, op := range expected.Operations {
+			if rule.Operations[j] != op {
+				return errors.New("webhook: wrong operations")
+			}
+		}
 
-	if len(rule.APIGroups) != 1 || rule.APIGroups[0] != "" {
-		return errors.New("webhook: wrong api groups")
+		if !stringsEqual(rule.APIGroups, expected.APIGroups) {
+			return errors.New("webhook: wrong api groups")
+		}
+
+		if !stringsEqual(rule.APIVersions, expected.APIVersions) {
+			return errors.New("webhook: wrong api versions")
+		}
+
+		if !stringsEqual(rule.Resources, expected.Resources) {
+			return errors.New("webhook: wrong resources")
+		}
 	}
 
-	if len(rule.APIVersions) != 1 || rule.APIVersions[0] != "v1" {
-		return errors.New("webhook: wrong api versions")
+	if hook.FailurePolicy == nil || *hook.FailurePolicy != wm.podFailurePolicy() {
+		return errors.New("webhook: wrong failure policy")
 	}
 
-	if len(rule.Resources) != 1 || rule.Resources[0] != "pods" {
-		return errors.New("webhook: wrong resources")
+	if hook.TimeoutSeconds == nil || *hook.TimeoutSeconds != wm.timeoutSeconds() {
+		return errors.New("webhook: wrong timeout")
 	}
 
-	if hook.FailurePolicy == nil || *hook.FailurePolicy != ignore {
-		return errors.New("webhook: wrong failure policy")
+	if !selectorsEqual(hook.NamespaceSelector, wm.podNamespaceSelector()) {
+		return errors.New("webhook: wrong namespace selector")
 	}
 
 	if hook.SideEffects == nil || *hook.SideEffects != none {
@@ -575,9 +739,12 @@ func (wm *webhookManagerImpl) createEmptyValidatingWebhook() *v1.ValidatingWebho
 }
 
 func (wm *webhookManagerImpl) populateValidatingWebhook(webhook *v1.ValidatingWebhookConfiguration, caBundle []byte) {
-	ignore := v1.Ignore
+	confFailurePolicy := wm.validateConfFailurePolicy()
+	podFailurePolicy := wm.podFailurePolicy()
+	timeout := wm.timeoutSeconds()
 	none := v1.SideEffectClassNone
-	path := "/validate-conf"
+	path := validateConfURL
+	podsPath := validateURL
 
 	namespace := wm.conf.GetNamespace()
 	serviceName := wm.conf.GetAmServiceName()
@@ -595,7 +762,23 @@ func (wm *webhookManagerImpl) populateValidatingWebhook(webhook *v1.ValidatingWe
 				Operations: []v1.OperationType{v1.Create, v1.Update},
 				Rule:       v1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"configmaps"}},
 			}},
-			FailurePolicy:           &ignore,
+			FailurePolicy:           &confFailurePolicy,
+			TimeoutSeconds:          &timeout,
+			AdmissionReviewVersions: []string{"v1"},
+			SideEffects:             &none,
+		},
+		{
+			Name: validatePodsHook,
+			ClientConfig: v1.WebhookClientConfig{
+				Service:  &v1.ServiceReference{Name: serviceName, Namespace: namespace, Path: &podsPath},
+				CABundle: caBundle,
+			},
+			Rules: []v1.RuleWithOperations{{
+				Operations: []v1.OperationType{v1.Create},
+				Rule:       v1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+			}},
+			FailurePolicy:           &podFailurePolicy,
+			TimeoutSeconds:          &timeout,
 			AdmissionReviewVersions: []string{"v1"},
 			SideEffects:             &none,
 		},
@@ -610,7 +793,8 @@ func (wm *webhookManagerImpl) createEmptyMutatingWebhook() *v1.MutatingWebhookCo
 }
 
 func (wm *webhookManagerImpl) populateMutatingWebhook(webhook *v1.MutatingWebhookConfiguration, caBundle []byte) {
-	ignore := v1.Ignore
+	failurePolicy := wm.podFailurePolicy()
+	timeout := wm.timeoutSeconds()
 	none := v1.SideEffectClassNone
 	path := "/mutate"
 
@@ -626,17 +810,47 @@ func (wm *webhookManagerImpl) populateMutatingWebhook(webhook *v1.MutatingWebhoo
 				Service:  &v1.ServiceReference{Name: serviceName, Namespace: namespace, Path: &path},
 				CABundle: caBundle,
 			},
-			Rules: []v1.RuleWithOperations{{
-				Operations: []v1.OperationType{v1.Create},
-				Rule:       v1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
-			}},
-			FailurePolicy:           &ignore,
+			Rules:                   wm.mutatingRules(),
+			NamespaceSelector:       wm.podNamespaceSelector(),
+			FailurePolicy:           &failurePolicy,
+			TimeoutSeconds:          &timeout,
 			AdmissionReviewVersions: []string{"v1"},
 			SideEffects:             &none,
 		},
 	}
 }
 
+// mutatingRules returns the resources sent to the mutating webhook. Pods are always mutated, the controllers are only
+// sent if their pod templates are mutated.
+func (wm *webhookManagerImpl) mutatingRules() []v1.RuleWithOperations {
+	rules := []v1.RuleWithOperations{{
+		Operations: []v1.OperationType{v1.Create},
+		Rule:       v1.Rule{APIGroups: []string{""}, APIVersions: []string{"v1"}, Resources: []string{"pods"}},
+	}}
+	if wm.conf.GetMutatePodTemplates() {
+		rules = append(rules, v1.RuleWithOperations{
+			Operations: []v1.OperationType{v1.Create},
+			Rule:       v1.Rule{APIGroups: []string{"apps"}, APIVersions: []string{"v1"}, Resources: []string{"deployments", "statefulsets"}},
+		}, v1.RuleWithOperations{
+			Operations: []v1.OperationType{v1.Create},
+			Rule:       v1.Rule{APIGroups: []string{"batch"}, APIVersions: []string{"v1"}, Resources: []string{"jobs", "cronjobs"}},
+		})
+	}
+	return rules
+}
+
+func stringsEqual(values []string, expected []string) bool {
+	if len(values) != len(expected) {
+		return false
+	}
+	for i, value := range expected {
+		if values[i] != value {
+			return false
+		}
+	}
+	return true
+}
+
 // gets the best certificate / private key pair to use (one with latest expiration)
 func (wm *webhookManagerImpl) getBestCACertificate() (*x509.Certificate, *rsa.PrivateKey, error) {
 	wm.RLock()
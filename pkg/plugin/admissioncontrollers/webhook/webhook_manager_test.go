@@ -24,6 +24,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sync"
 	"testing"
 	"time"
@@ -41,6 +42,7 @@ import (
 	fakecorev1 "k8s.io/client-go/kubernetes/typed/core/v1/fake"
 
 	"github.com/apache/yunikorn-k8shim/pkg/pki"
+	"github.com/apache/yunikorn-k8shim/pkg/plugin/admissioncontrollers/webhook/conf"
 )
 
 var (
@@ -350,6 +352,13 @@ func TestCheckValidatingWebhook(t *testing.T) {
 			fail := arv1.Fail
 			h.Webhooks[0].FailurePolicy = &fail
 		}},
+		{name: "MissingTimeout", expected: "timeout", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			h.Webhooks[0].TimeoutSeconds = nil
+		}},
+		{name: "WrongTimeout", expected: "timeout", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			timeout := int32(30)
+			h.Webhooks[1].TimeoutSeconds = &timeout
+		}},
 		{name: "MissingSideEffects", expected: "side effects", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
 			h.Webhooks[0].SideEffects = nil
 		}},
@@ -357,6 +366,19 @@ func TestCheckValidatingWebhook(t *testing.T) {
 			some := arv1.SideEffectClassSome
 			h.Webhooks[0].SideEffects = &some
 		}},
+		{name: "WrongPodsWebhookName", expected: "webhook name", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			h.Webhooks[1].Name = "invalid-hook-name"
+		}},
+		{name: "WrongPodsServicePath", expected: "service path", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			var path = "/validate-conf"
+			h.Webhooks[1].ClientConfig.Service.Path = &path
+		}},
+		{name: "WrongPodsOperations", expected: "operations", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			h.Webhooks[1].Rules[0].Operations[0] = arv1.Connect
+		}},
+		{name: "WrongPodsResources", expected: "resources", mutator: func(h *arv1.ValidatingWebhookConfiguration) {
+			h.Webhooks[1].Rules[0].Resources[0] = "configmaps"
+		}},
 	}
 
 	testSetupOnce(t)
@@ -378,6 +400,81 @@ func TestCheckValidatingWebhook(t *testing.T) {
 	}
 }
 
+func TestValidatingWebhookFailClosed(t *testing.T) {
+	testSetupOnce(t)
+	wm := createPopulatedWm(fakeClientSet())
+	wm.conf = createConfigWithOverrides(map[string]string{conf.AMWebHookFailOnSchedulerUnreachable: "true"})
+
+	vh := wm.createEmptyValidatingWebhook()
+	wm.populateValidatingWebhook(vh, caBundle)
+	assert.Equal(t, *vh.Webhooks[0].FailurePolicy, arv1.Fail, "configmap webhook does not fail closed")
+	assert.Equal(t, *vh.Webhooks[1].FailurePolicy, arv1.Ignore, "pod webhook does not fail open")
+	assert.NilError(t, wm.checkValidatingWebhook(vh), "check failed")
+
+	// a webhook installed for fail-open must be updated
+	ignore := arv1.Ignore
+	vh.Webhooks[0].FailurePolicy = &ignore
+	assert.ErrorContains(t, wm.checkValidatingWebhook(vh), "failure policy")
+}
+
+func TestWebhookRuntimeConfig(t *testing.T) {
+	testSetupOnce(t)
+	wm := createPopulatedWm(fakeClientSet())
+	wm.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookFailurePolicy:       conf.FailurePolicyFail,
+		conf.AMWebHookTimeoutSeconds:      "5",
+		conf.AMWebHookNamespaceSelector:   "true",
+		conf.AMFilteringProcessNamespaces: "^team-a$,^team-b$",
+		conf.AMFilteringBypassNamespaces:  "^kube-system$,^kube-",
+	})
+
+	vh := wm.createEmptyValidatingWebhook()
+	wm.populateValidatingWebhook(vh, caBundle)
+	assert.Equal(t, *vh.Webhooks[0].FailurePolicy, arv1.Ignore, "configmap webhook does not fail open")
+	assert.Equal(t, *vh.Webhooks[1].FailurePolicy, arv1.Fail, "pod webhook does not fail closed")
+	assert.Equal(t, *vh.Webhooks[0].TimeoutSeconds, int32(5))
+	assert.Equal(t, *vh.Webhooks[1].TimeoutSeconds, int32(5))
+	assert.NilError(t, wm.checkValidatingWebhook(vh), "check failed")
+
+	mh := wm.createEmptyMutatingWebhook()
+	wm.populateMutatingWebhook(mh, caBundle)
+	assert.Equal(t, *mh.Webhooks[0].FailurePolicy, arv1.Fail, "mutating webhook does not fail closed")
+	assert.Equal(t, *mh.Webhooks[0].TimeoutSeconds, int32(5))
+	assert.DeepEqual(t, mh.Webhooks[0].NamespaceSelector, &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: v1.LabelMetadataName, Operator: metav1.LabelSelectorOpIn, Values: []string{"team-a", "team-b"}},
+		{Key: v1.LabelMetadataName, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+	}})
+	assert.NilError(t, wm.checkMutatingWebhook(mh), "check failed")
+
+	// a process list that cannot be translated is not registered
+	wm.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookNamespaceSelector:   "true",
+		conf.AMFilteringProcessNamespaces: "^team-",
+	})
+	assert.DeepEqual(t, wm.podNamespaceSelector(), &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: v1.LabelMetadataName, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+	}})
+	assert.ErrorContains(t, wm.checkMutatingWebhook(mh), "namespace selector")
+
+	// the selector is not registered unless enabled
+	wm.conf = createConfig()
+	assert.Check(t, wm.podNamespaceSelector() == nil, "namespace selector registered")
+}
+
+func TestNamespaceNames(t *testing.T) {
+	names, complete := namespaceNames(nil)
+	assert.Equal(t, len(names), 0)
+	assert.Check(t, complete, "empty list not complete")
+	names, complete = namespaceNames([]*regexp.Regexp{regexp.MustCompile(`^kube-system$`), regexp.MustCompile(`^team\.a$`)})
+	assert.DeepEqual(t, names, []string{"kube-system", "team.a"})
+	assert.Check(t, complete, "literal names not complete")
+	for _, expr := range []string{"kube-system", "^kube-", "^team-[ab]$", "^a$|^b$", "^$"} {
+		names, complete = namespaceNames([]*regexp.Regexp{regexp.MustCompile(expr)})
+		assert.Equal(t, len(names), 0, "name found for %s", expr)
+		assert.Check(t, !complete, "expression %s translated", expr)
+	}
+}
+
 func TestCheckMutatingWebhook(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -450,6 +547,19 @@ func TestCheckMutatingWebhook(t *testing.T) {
 			fail := arv1.Fail
 			h.Webhooks[0].FailurePolicy = &fail
 		}},
+		{name: "MissingTimeout", expected: "timeout", mutator: func(h *arv1.MutatingWebhookConfiguration) {
+			h.Webhooks[0].TimeoutSeconds = nil
+		}},
+		{name: "WrongTimeout", expected: "timeout", mutator: func(h *arv1.MutatingWebhookConfiguration) {
+			timeout := int32(30)
+			h.Webhooks[0].TimeoutSeconds = &timeout
+		}},
+		{name: "DefaultedNamespaceSelector", expected: "", mutator: func(h *arv1.MutatingWebhookConfiguration) {
+			h.Webhooks[0].NamespaceSelector = &metav1.LabelSelector{}
+		}},
+		{name: "WrongNamespaceSelector", expected: "namespace selector", mutator: func(h *arv1.MutatingWebhookConfiguration) {
+			h.Webhooks[0].NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+		}},
 		{name: "MissingSideEffects", expected: "side effects", mutator: func(h *arv1.MutatingWebhookConfiguration) {
 			h.Webhooks[0].SideEffects = nil
 		}},
@@ -478,6 +588,69 @@ func TestCheckMutatingWebhook(t *testing.T) {
 	}
 }
 
+func TestMutatingWebhookPodTemplates(t *testing.T) {
+	testSetupOnce(t)
+	wm := createPopulatedWm(fakeClientSet())
+
+	// only pods are sent by default
+	mh := wm.createEmptyMutatingWebhook()
+	wm.populateMutatingWebhook(mh, caBundle)
+	assert.Equal(t, len(mh.Webhooks[0].Rules), 1)
+	assert.DeepEqual(t, mh.Webhooks[0].Rules[0].Resources, []string{"pods"})
+
+	// a webhook without the controllers must be reinstalled once pod templates are mutated
+	wm.conf = createConfigWithOverrides(map[string]string{conf.AMMutationPodTemplates: "true"})
+	assert.ErrorContains(t, wm.checkMutatingWebhook(mh), "rule count")
+	mh = wm.createEmptyMutatingWebhook()
+	wm.populateMutatingWebhook(mh, caBundle)
+	rules := mh.Webhooks[0].Rules
+	assert.Equal(t, len(rules), 3)
+	assert.DeepEqual(t, rules[1].APIGroups, []string{"apps"})
+	assert.DeepEqual(t, rules[1].Resources, []string{"deployments", "statefulsets"})
+	assert.DeepEqual(t, rules[2].APIGroups, []string{"batch"})
+	assert.DeepEqual(t, rules[2].Resources, []string{"jobs", "cronjobs"})
+	assert.NilError(t, wm.checkMutatingWebhook(mh), "check failed")
+
+	// a rule removed by hand is detected
+	mh.Webhooks[0].Rules[2].Resources = []string{"jobs"}
+	assert.ErrorContains(t, wm.checkMutatingWebhook(mh), "resources")
+}
+
+func TestReconcileWebhooks(t *testing.T) {
+	testSetupOnce(t)
+	clientset := fakeClientSet()
+	secret := createSecret()
+	addCert(t, secret, cacert1, cakey1, 1)
+	addCert(t, secret, cacert2, cakey2, 2)
+	clientset.secrets["default/admission-controller-secrets"] = secret
+	wm := createPopulatedWm(clientset)
+	wm.conf = createConfigWithOverrides(map[string]string{
+		conf.AMWebHookReconcileInterval: "10ms",
+	})
+
+	// a stale CA bundle is replaced and a removed webhook is recreated
+	vh := wm.createEmptyValidatingWebhook()
+	wm.populateValidatingWebhook(vh, []byte("stale"))
+	clientset.validatingWebhooks["yunikorn-admission-controller-validations"] = vh
+
+	stopChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		reconcileWebhooks(wm, wm.conf, stopChan)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(stopChan)
+	<-done
+
+	vh, ok := clientset.validatingWebhooks["yunikorn-admission-controller-validations"]
+	assert.Assert(t, ok, "validating webhook not found")
+	assert.NilError(t, wm.checkValidatingWebhook(vh), "validating webhook was not repaired")
+	mh, ok := clientset.mutatingWebhooks["yunikorn-admission-controller-mutations"]
+	assert.Assert(t, ok, "mutating webhook not found")
+	assert.NilError(t, wm.checkMutatingWebhook(mh), "mutating webhook is malformed")
+}
+
 func createPopulatedWm(clientset kubernetes.Interface) *webhookManagerImpl {
 	wm := newWebhookManagerImpl(createConfig(), clientset)
 	wm.caCert1 = cacert1